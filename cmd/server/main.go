@@ -2,65 +2,83 @@ package main
 
 import (
 	"context"
+	"flag"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
-	database "github.com/4Noyis/my-library/internal/database"
-	"github.com/4Noyis/my-library/internal/handlers"
+	"github.com/4Noyis/my-library/internal/app"
+	"github.com/4Noyis/my-library/internal/config"
 	"github.com/4Noyis/my-library/internal/logger"
-	"github.com/4Noyis/my-library/internal/middleware"
-	"github.com/gorilla/mux"
+	"github.com/4Noyis/my-library/internal/tracing"
 	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
 )
 
 func main() {
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	printConfig := flags.Bool("print-config", false, "print the effective configuration, secrets redacted, and exit")
 
-	logger.LogInfo("Starting library management server", nil)
-
-	err := database.ConnectMongoDB()
+	cfg, err := config.Load(flags, os.Args[1:])
 	if err != nil {
 		logger.Logger.WithFields(logrus.Fields{
 			"error": err.Error(),
 			"type":  "startup",
-		}).Fatal("Failed to connect to MongoDB")
+		}).Fatal("Failed to load configuration")
 	}
-	defer database.DisconnectMongoDB()
 
-	r := mux.NewRouter()
+	if *printConfig {
+		out, err := yaml.Marshal(cfg.Redacted())
+		if err != nil {
+			logger.Logger.WithFields(logrus.Fields{
+				"error": err.Error(),
+				"type":  "startup",
+			}).Fatal("Failed to print configuration")
+		}
+		os.Stdout.Write(out)
+		return
+	}
 
-	// Add logging middleware
-	r.Use(middleware.LoggingMiddleware)
+	logger.Configure(cfg.Log)
+	logger.LogInfo(context.Background(), "Starting library management server", logrus.Fields{
+		"profile": cfg.Profile,
+	})
 
-	// Public routes (no authentication required)
-	r.HandleFunc("/api/v1/auth/register", handlers.RegisterHandler).Methods("POST")
-	r.HandleFunc("/api/v1/auth/login", handlers.LoginHandler).Methods("POST")
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		logger.Logger.WithFields(logrus.Fields{
+			"error": err.Error(),
+			"type":  "startup",
+		}).Fatal("Failed to set up tracing")
+	}
 
-	// Protected routes (authentication required)
-	protected := r.PathPrefix("/api/v1").Subrouter()
-	protected.Use(middleware.AuthMiddleware)
+	application, err := app.New(cfg)
+	if err != nil {
+		logger.Logger.WithFields(logrus.Fields{
+			"error": err.Error(),
+			"type":  "startup",
+		}).Fatal("Failed to connect to MongoDB")
+	}
 
-	// Book routes - all require authentication
-	protected.HandleFunc("/books", handlers.GetAllBooksHandler).Methods("GET")
-	protected.HandleFunc("/books", handlers.CreateBookHandler).Methods("POST")
-	protected.HandleFunc("/books/{id}", handlers.GetOneBookHandler).Methods("GET")
-	protected.HandleFunc("/books/{id}", handlers.UpdateBookHandler).Methods("PATCH")
-	protected.HandleFunc("/books/{id}", handlers.DeleteBookHandler).Methods("DELETE")
+	// Background workers stop when the shutdown signal is received
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	application.Start(workerCtx)
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
+	port := strconv.Itoa(cfg.Server.Port)
 
 	server := &http.Server{
 		Addr:         ":" + port,
-		Handler:      r,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		Handler:      application.Handler(),
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
 	}
+	server.RegisterOnShutdown(application.CloseEventStreams)
 
 	if err != nil {
 		logger.Logger.WithFields(logrus.Fields{
@@ -72,7 +90,7 @@ func main() {
 
 	// server starts in goroutine
 	go func() {
-		logger.LogInfo("Server starting", logrus.Fields{"port": port})
+		logger.LogInfo(context.Background(), "Server starting", logrus.Fields{"port": port})
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			logger.Logger.WithFields(logrus.Fields{
 				"error": err.Error(),
@@ -82,21 +100,72 @@ func main() {
 		}
 	}()
 
+	// The gRPC API listens on its own port
+	grpcServer := application.GRPCServer()
+	if cfg.Server.GRPCPort != 0 {
+		grpcPort := strconv.Itoa(cfg.Server.GRPCPort)
+		listener, err := net.Listen("tcp", ":"+grpcPort)
+		if err != nil {
+			logger.Logger.WithFields(logrus.Fields{
+				"error":     err.Error(),
+				"grpc_port": grpcPort,
+				"type":      "startup",
+			}).Fatal("gRPC server failed to start")
+		}
+
+		go func() {
+			logger.LogInfo(context.Background(), "gRPC server starting", logrus.Fields{"grpc_port": grpcPort})
+			if err := grpcServer.Serve(listener); err != nil {
+				logger.Logger.WithFields(logrus.Fields{
+					"error":     err.Error(),
+					"grpc_port": grpcPort,
+					"type":      "startup",
+				}).Fatal("gRPC server failed to start")
+			}
+		}()
+	}
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	logger.LogInfo("Server ready - waiting for requests", logrus.Fields{"port": port})
+	logger.LogInfo(context.Background(), "Server ready - waiting for requests", logrus.Fields{"port": port})
 
 	// block until recieve a signal
 	<-quit
-	logger.LogInfo("Shutdown signal received, initiating graceful shutdown", logrus.Fields{"port": port})
+	logger.LogInfo(context.Background(), "Shutdown signal received, initiating graceful shutdown", logrus.Fields{"port": port})
+	application.BeginShutdown()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	// Give the orchestrator time to see the failing readiness probe and stop
+	// sending traffic before the listener closes
+	time.Sleep(cfg.Server.ShutdownDrain)
+	stopWorkers()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
-		logger.LogInfo("Server forced to shutdown", logrus.Fields{"port": port})
+		logger.LogInfo(context.Background(), "Server forced to shutdown", logrus.Fields{"port": port})
+	}
+
+	// Let in-flight gRPC calls finish within the same grace period
+	grpcStopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(grpcStopped)
+	}()
+	select {
+	case <-grpcStopped:
+	case <-ctx.Done():
+		grpcServer.Stop()
+		logger.LogInfo(context.Background(), "gRPC server forced to shutdown", nil)
+	}
+
+	// Let asynchronous event subscribers drain before the database goes away
+	application.Close()
+
+	if err := shutdownTracing(ctx); err != nil {
+		logger.LogError(context.Background(), "ShutdownTracing", err, nil)
 	}
 
-	logger.LogInfo("Server exited gracefully", logrus.Fields{"port": port})
+	logger.LogInfo(context.Background(), "Server exited gracefully", logrus.Fields{"port": port})
 
 }
@@ -0,0 +1,619 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/config"
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/problem"
+	"github.com/4Noyis/my-library/internal/repositories/memory"
+	"github.com/4Noyis/my-library/internal/services"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/api")
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	logger.Logger.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// apiCase is one request of the end-to-end suite. Cases run in order against
+// one server, so each sees the changes made by the ones before it.
+type apiCase struct {
+	name   string // also names the golden file
+	method string
+	path   string // {var} is replaced with a captured value
+	as     string // variable holding the bearer token; anonymous if empty
+	header map[string]string
+	body   string
+	status int
+
+	// capture stores values from the JSON response as variables, keyed by
+	// variable name, e.g. {"book_id": "book.id"}
+	capture map[string]string
+	// awaitOutbox waits for the outbox relay to deliver pending events first
+	awaitOutbox bool
+	// stream reads only the first server-sent event of the response
+	stream bool
+	// noGolden skips the body check for responses that change every run
+	noGolden bool
+	// promote makes the user whose ID is in this variable an admin directly
+	// in storage after the request, the way an operator makes the first admin
+	promote string
+}
+
+var apiCases = []apiCase{
+	// Probes and metrics
+	{name: "healthz", method: "GET", path: "/healthz", status: 200},
+	{name: "readyz", method: "GET", path: "/readyz", status: 200},
+	{name: "metrics", method: "GET", path: "/metrics", status: 200, noGolden: true},
+
+	// API reference
+	{name: "openapi", method: "GET", path: "/openapi.json", status: 200},
+	{name: "docs", method: "GET", path: "/docs", status: 200},
+
+	// Registration and login
+	{name: "register_admin", method: "POST", path: "/api/v1/auth/register", status: 201,
+		body:    `{"username":"alice","email":"alice@example.com","password":"correct-horse-battery-staple"}`,
+		capture: map[string]string{"alice_id": "data.id"}, promote: "alice_id"},
+	{name: "register_reader", method: "POST", path: "/api/v1/auth/register", status: 201,
+		body:    `{"username":"rita","email":"rita@example.com","password":"correct-horse-battery-staple"}`,
+		capture: map[string]string{"rita_id": "data.id"}},
+	{name: "register_target", method: "POST", path: "/api/v1/auth/register", status: 201,
+		body:    `{"username":"bob","email":"bob@example.com","password":"correct-horse-battery-staple"}`,
+		capture: map[string]string{"bob_id": "data.id"}},
+	{name: "register_duplicate_username", method: "POST", path: "/api/v1/auth/register", status: 409,
+		body: `{"username":"rita","email":"other@example.com","password":"correct-horse-battery-staple"}`},
+	{name: "register_duplicate_email", method: "POST", path: "/api/v1/auth/register", status: 409,
+		body: `{"username":"other","email":"rita@example.com","password":"correct-horse-battery-staple"}`},
+	{name: "register_weak_password", method: "POST", path: "/api/v1/auth/register", status: 400,
+		body: `{"username":"weak","email":"weak@example.com","password":"short"}`},
+	{name: "register_with_role", method: "POST", path: "/api/v1/auth/register", status: 400,
+		body: `{"username":"mallory","email":"mallory@example.com","password":"correct-horse-battery-staple","role":"admin"}`},
+	{name: "register_malformed_json", method: "POST", path: "/api/v1/auth/register", status: 400, body: `{"username":`},
+
+	{name: "login_admin", method: "POST", path: "/api/v1/auth/login", status: 200,
+		body:    `{"username":"alice","password":"correct-horse-battery-staple"}`,
+		capture: map[string]string{"admin": "data.token"}},
+	{name: "login_reader", method: "POST", path: "/api/v1/auth/login", status: 200,
+		body:    `{"username":"rita","password":"correct-horse-battery-staple"}`,
+		capture: map[string]string{"reader": "data.token"}},
+	{name: "login_target", method: "POST", path: "/api/v1/auth/login", status: 200,
+		body:    `{"username":"bob","password":"correct-horse-battery-staple"}`,
+		capture: map[string]string{"bob": "data.token"}},
+	{name: "login_wrong_password", method: "POST", path: "/api/v1/auth/login", status: 401,
+		body: `{"username":"rita","password":"not-the-password"}`},
+	{name: "login_unknown_user", method: "POST", path: "/api/v1/auth/login", status: 401,
+		body: `{"username":"nobody","password":"correct-horse-battery-staple"}`},
+	{name: "login_malformed_json", method: "POST", path: "/api/v1/auth/login", status: 400, body: `[]`},
+
+	{name: "unknown_route", method: "GET", path: "/api/v1/nope", as: "reader", status: 404},
+	{name: "method_not_allowed", method: "PUT", path: "/healthz", status: 405},
+
+	{name: "oidc_login_disabled", method: "GET", path: "/api/v1/auth/oidc/login", status: 404},
+	{name: "oidc_callback_without_state", method: "GET", path: "/api/v1/auth/oidc/callback?code=x&state=y", status: 400},
+
+	// Authentication failures
+	{name: "auth_missing_header", method: "GET", path: "/api/v1/books", status: 401},
+	{name: "auth_wrong_scheme", method: "GET", path: "/api/v1/books", status: 401,
+		header: map[string]string{"Authorization": "Basic YWxpY2U6c2VjcmV0"}},
+	{name: "auth_invalid_token", method: "GET", path: "/api/v1/books", status: 401,
+		header: map[string]string{"Authorization": "Bearer not.a.jwt"}},
+
+	// Books
+	{name: "books_list_empty", method: "GET", path: "/api/v1/books", as: "reader", status: 200},
+	{name: "book_create", method: "POST", path: "/api/v1/books", as: "reader", status: 200,
+		body:    `{"isbn":"9780261103573","title":"The Fellowship of the Ring","author":"J. R. R. Tolkien","publisher":"Allen & Unwin","published_at":"1954-07-29T00:00:00Z","genre":"Fantasy","language":"en","pages":423,"location":"A-12"}`,
+		capture: map[string]string{"book_id": "book.id"}},
+	{name: "book_create_second", method: "POST", path: "/api/v1/books", as: "reader", status: 200,
+		body: `{"isbn":"9780547928203","title":"The Two Towers","author":"J. R. R. Tolkien","pages":352}`},
+	{name: "book_create_malformed_json", method: "POST", path: "/api/v1/books", as: "reader", status: 400, body: `{"title":`},
+	{name: "book_create_unknown_field", method: "POST", path: "/api/v1/books", as: "reader", status: 400, body: `{"title":"Dune","shelf":"C-1"}`},
+	{name: "book_create_wrong_types", method: "POST", path: "/api/v1/books", as: "reader", status: 400, body: `{"title":42,"pages":"many","published_at":"1965"}`},
+	{name: "book_create_read_only", method: "POST", path: "/api/v1/books", as: "reader", status: 400, body: `{"id":7,"title":"Dune"}`},
+	{name: "book_get", method: "GET", path: "/api/v1/books/{book_id}", as: "reader", status: 200},
+	{name: "book_get_missing", method: "GET", path: "/api/v1/books/999", as: "reader", status: 404},
+	{name: "book_get_invalid_id", method: "GET", path: "/api/v1/books/abc", as: "reader", status: 400},
+	{name: "book_update", method: "PATCH", path: "/api/v1/books/{book_id}", as: "reader", status: 200,
+		body: `{"location":"B-3","description":"First volume"}`},
+	{name: "book_update_missing", method: "PATCH", path: "/api/v1/books/999", as: "reader", status: 404, body: `{"title":"Nothing"}`},
+	{name: "book_update_invalid_id", method: "PATCH", path: "/api/v1/books/abc", as: "reader", status: 400, body: `{"title":"Nothing"}`},
+	{name: "book_update_malformed_json", method: "PATCH", path: "/api/v1/books/{book_id}", as: "reader", status: 400, body: `nope`},
+	{name: "book_history", method: "GET", path: "/api/v1/books/{book_id}/history", as: "reader", status: 200},
+	{name: "book_history_missing", method: "GET", path: "/api/v1/books/999/history", as: "reader", status: 404},
+	{name: "book_revert", method: "POST", path: "/api/v1/books/{book_id}/revert/1", as: "reader", status: 200},
+	{name: "book_revert_missing_revision", method: "POST", path: "/api/v1/books/{book_id}/revert/42", as: "reader", status: 404},
+	{name: "book_revert_invalid_revision", method: "POST", path: "/api/v1/books/{book_id}/revert/x", as: "reader", status: 400},
+	{name: "books_list", method: "GET", path: "/api/v1/books", as: "reader", status: 200},
+	{name: "book_delete", method: "DELETE", path: "/api/v1/books/{book_id}", as: "reader", status: 200},
+	{name: "book_delete_missing", method: "DELETE", path: "/api/v1/books/{book_id}", as: "reader", status: 404},
+	{name: "book_delete_invalid_id", method: "DELETE", path: "/api/v1/books/abc", as: "reader", status: 400},
+
+	// Change stream
+	{name: "events_stream", method: "GET", path: "/api/v1/events/stream", as: "reader", status: 200, stream: true},
+	{name: "events_stream_unknown_type", method: "GET", path: "/api/v1/events/stream?types=book.burned", as: "reader", status: 400},
+
+	// Current user
+	{name: "me_get", method: "GET", path: "/api/v1/me", as: "reader", status: 200},
+	{name: "me_update", method: "PATCH", path: "/api/v1/me", as: "reader", status: 200, body: `{"email":"rita@library.example"}`},
+	{name: "me_update_taken_username", method: "PATCH", path: "/api/v1/me", as: "reader", status: 409, body: `{"username":"alice"}`},
+	{name: "me_update_malformed_json", method: "PATCH", path: "/api/v1/me", as: "reader", status: 400, body: `{`},
+	{name: "me_sessions", method: "GET", path: "/api/v1/me/sessions", as: "reader", status: 200,
+		capture: map[string]string{"reader_session": "data.0.id"}},
+	{name: "me_session_delete_missing", method: "DELETE", path: "/api/v1/me/sessions/{alice_id}", as: "reader", status: 404},
+	{name: "me_session_delete_invalid_id", method: "DELETE", path: "/api/v1/me/sessions/xyz", as: "reader", status: 400},
+	{name: "me_password_wrong_current", method: "POST", path: "/api/v1/me/password", as: "bob", status: 401,
+		body: `{"current_password":"wrong","new_password":"another-long-passphrase"}`},
+	{name: "me_password_malformed_json", method: "POST", path: "/api/v1/me/password", as: "bob", status: 400, body: `{`},
+	{name: "me_password", method: "POST", path: "/api/v1/me/password", as: "bob", status: 200,
+		body: `{"current_password":"correct-horse-battery-staple","new_password":"another-long-passphrase"}`},
+
+	// Admin: users and audit
+	{name: "admin_forbidden", method: "GET", path: "/api/v1/admin/users", as: "reader", status: 403},
+	{name: "admin_users_list", method: "GET", path: "/api/v1/admin/users?role=user", as: "admin", status: 200},
+	{name: "admin_users_search", method: "GET", path: "/api/v1/admin/users?q=ALI", as: "admin", status: 200},
+	{name: "admin_users_invalid_filter", method: "GET", path: "/api/v1/admin/users?active=maybe", as: "admin", status: 400},
+	{name: "admin_user_get", method: "GET", path: "/api/v1/admin/users/{bob_id}", as: "admin", status: 200},
+	{name: "admin_user_get_missing", method: "GET", path: "/api/v1/admin/users/{reader_session}", as: "admin", status: 404},
+	{name: "admin_user_get_invalid_id", method: "GET", path: "/api/v1/admin/users/123", as: "admin", status: 400},
+	{name: "admin_user_role", method: "PATCH", path: "/api/v1/admin/users/{bob_id}/role", as: "admin", status: 200, body: `{"role":"admin"}`},
+	{name: "admin_user_role_invalid", method: "PATCH", path: "/api/v1/admin/users/{bob_id}/role", as: "admin", status: 400, body: `{"role":"owner"}`},
+	{name: "admin_user_status", method: "PATCH", path: "/api/v1/admin/users/{bob_id}/status", as: "admin", status: 200, body: `{"is_active":false}`},
+	{name: "admin_user_status_malformed_json", method: "PATCH", path: "/api/v1/admin/users/{bob_id}/status", as: "admin", status: 400, body: `{"is_active":"no"}`},
+	{name: "admin_user_reset_password", method: "POST", path: "/api/v1/admin/users/{bob_id}/reset-password", as: "admin", status: 200},
+	{name: "admin_user_delete", method: "DELETE", path: "/api/v1/admin/users/{bob_id}", as: "admin", status: 200},
+	{name: "admin_user_delete_missing", method: "DELETE", path: "/api/v1/admin/users/{bob_id}", as: "admin", status: 404},
+	{name: "admin_audit", method: "GET", path: "/api/v1/admin/audit?target_type=book", as: "admin", status: 200, awaitOutbox: true},
+	{name: "admin_audit_invalid_filter", method: "GET", path: "/api/v1/admin/audit?from=yesterday", as: "admin", status: 400},
+
+	// Admin: webhooks
+	{name: "webhooks_list_empty", method: "GET", path: "/api/v1/admin/webhooks", as: "admin", status: 200},
+	{name: "webhook_create", method: "POST", path: "/api/v1/admin/webhooks", as: "admin", status: 201,
+		body:    `{"url":"{receiver}/hook","event_types":["book.created"],"secret":"` + webhookSecret + `"}`,
+		capture: map[string]string{"webhook_id": "data.id"}},
+	{name: "webhook_create_invalid", method: "POST", path: "/api/v1/admin/webhooks", as: "admin", status: 400,
+		body: `{"url":"ftp://example.com","event_types":["book.created"]}`},
+	{name: "webhook_create_malformed_json", method: "POST", path: "/api/v1/admin/webhooks", as: "admin", status: 400, body: `{`},
+	{name: "webhook_get", method: "GET", path: "/api/v1/admin/webhooks/{webhook_id}", as: "admin", status: 200},
+	{name: "webhook_get_missing", method: "GET", path: "/api/v1/admin/webhooks/{alice_id}", as: "admin", status: 404},
+	{name: "webhook_update", method: "PATCH", path: "/api/v1/admin/webhooks/{webhook_id}", as: "admin", status: 200,
+		body: `{"event_types":["book.created","book.deleted"]}`},
+	{name: "webhook_ping", method: "POST", path: "/api/v1/admin/webhooks/{webhook_id}/ping", as: "admin", status: 200,
+		capture: map[string]string{"delivery_id": "data.id"}},
+	{name: "webhook_deliveries", method: "GET", path: "/api/v1/admin/webhooks/{webhook_id}/deliveries", as: "admin", status: 200},
+	{name: "webhook_retry_not_dead", method: "POST", path: "/api/v1/admin/webhooks/{webhook_id}/deliveries/{delivery_id}/retry", as: "admin", status: 404},
+	{name: "webhook_retry_missing", method: "POST", path: "/api/v1/admin/webhooks/{webhook_id}/deliveries/{alice_id}/retry", as: "admin", status: 404},
+	{name: "webhook_delete", method: "DELETE", path: "/api/v1/admin/webhooks/{webhook_id}", as: "admin", status: 200},
+	{name: "webhook_delete_missing", method: "DELETE", path: "/api/v1/admin/webhooks/{webhook_id}", as: "admin", status: 404},
+
+	// GraphQL
+	{name: "graphql_unauthenticated", method: "POST", path: "/graphql", status: 401, body: `{"query":"{ me { username } }"}`},
+	{name: "graphql_unknown_field", method: "POST", path: "/graphql", as: "reader", status: 400, body: `{"query":"{ me { username } }","vars":{}}`},
+	{name: "graphql_invalid_query", method: "POST", path: "/graphql", as: "reader", status: 200, body: `{"query":"{ shelves { id } }"}`},
+	{name: "graphql_me", method: "POST", path: "/graphql", as: "reader", status: 200,
+		body: `{"query":"{ me { id username email role isActive authProvider } }"}`},
+	{name: "graphql_create_book", method: "POST", path: "/graphql", as: "reader", status: 200,
+		body: `{"query":"mutation Add($input: BookInput!) { createBook(input: $input) { id title author publishedAt pages } }","operationName":"Add",` +
+			`"variables":{"input":{"isbn":"9780547928197","title":"The Return of the King","author":"J. R. R. Tolkien","publishedAt":"1955-10-20T00:00:00Z","pages":416}}}`},
+	{name: "graphql_update_book", method: "POST", path: "/graphql", as: "admin", status: 200,
+		body: `{"query":"mutation { updateBook(id: 3, input: {location: \"A-13\"}) { id location } }"}`},
+	{name: "graphql_books", method: "POST", path: "/graphql", as: "reader", status: 200,
+		body: `{"query":"{ books(filter: {author: \"J. R. R. Tolkien\"}, limit: 10) { total page limit books { id title history { rev changedByUsername changedBy { username email } changes { field before after } } } } }"}`},
+	{name: "graphql_books_search", method: "POST", path: "/graphql", as: "reader", status: 200,
+		body: `{"query":"{ books(filter: {search: \"king\"}) { total books { title } } }"}`},
+	{name: "graphql_book", method: "POST", path: "/graphql", as: "admin", status: 200,
+		body: `{"query":"query Book($id: Int!) { book(id: $id) { title history { rev changedBy { username email } } } }","variables":{"id":3}}`},
+	{name: "graphql_book_missing", method: "POST", path: "/graphql", as: "reader", status: 200, body: `{"query":"{ book(id: 999) { title } }"}`},
+	{name: "graphql_delete_missing", method: "POST", path: "/graphql", as: "reader", status: 200, body: `{"query":"mutation { deleteBook(id: 999) { id } }"}`},
+	{name: "graphql_users_forbidden", method: "POST", path: "/graphql", as: "reader", status: 200, body: `{"query":"{ users { total } }"}`},
+	{name: "graphql_users", method: "POST", path: "/graphql", as: "admin", status: 200,
+		body: `{"query":"{ users(filter: {role: USER}) { total users { username email role isActive } } }"}`},
+	{name: "graphql_user_invalid_id", method: "POST", path: "/graphql", as: "admin", status: 200, body: `{"query":"{ user(id: \"123\") { username } }"}`},
+	{name: "graphql_update_own_role", method: "POST", path: "/graphql", as: "admin", status: 200,
+		body: `{"query":"mutation { updateUserRole(id: \"{alice_id}\", role: USER) { role } }"}`},
+
+	// Version 2: enveloped responses and string IDs
+	{name: "v2_register", method: "POST", path: "/api/v2/auth/register", status: 201,
+		body:    `{"username":"carol","email":"carol@example.com","password":"correct-horse-battery-staple"}`,
+		capture: map[string]string{"carol_id": "data.id"}},
+	{name: "v2_register_duplicate", method: "POST", path: "/api/v2/auth/register", status: 409,
+		body: `{"username":"carol","email":"carol@example.com","password":"correct-horse-battery-staple"}`},
+	{name: "v2_login", method: "POST", path: "/api/v2/auth/login", status: 200,
+		body:    `{"username":"carol","password":"correct-horse-battery-staple"}`,
+		capture: map[string]string{"carol": "data.token"}},
+	{name: "v2_login_wrong_password", method: "POST", path: "/api/v2/auth/login", status: 401,
+		body: `{"username":"carol","password":"not-the-password"}`},
+	{name: "v2_auth_missing_header", method: "GET", path: "/api/v2/books", status: 401},
+	{name: "v2_book_create", method: "POST", path: "/api/v2/books", as: "carol", status: 201,
+		body:    `{"isbn":"9780441172719","title":"Dune","author":"Frank Herbert","published_at":"1965-08-01T00:00:00Z","genre":"Science Fiction","language":"en","pages":412}`,
+		capture: map[string]string{"v2_book_id": "data.id"}},
+	{name: "v2_book_create_read_only", method: "POST", path: "/api/v2/books", as: "carol", status: 400, body: `{"id":"7","title":"Dune"}`},
+	{name: "v2_book_get", method: "GET", path: "/api/v2/books/{v2_book_id}", as: "carol", status: 200},
+	{name: "v2_book_get_missing", method: "GET", path: "/api/v2/books/999", as: "carol", status: 404},
+	{name: "v2_book_get_invalid_id", method: "GET", path: "/api/v2/books/abc", as: "carol", status: 400},
+	{name: "v2_book_update", method: "PATCH", path: "/api/v2/books/{v2_book_id}", as: "carol", status: 200, body: `{"location":"C-7"}`},
+	{name: "v2_book_history", method: "GET", path: "/api/v2/books/{v2_book_id}/history", as: "carol", status: 200},
+	{name: "v2_book_revert", method: "POST", path: "/api/v2/books/{v2_book_id}/revert/1", as: "carol", status: 200},
+	{name: "v2_books_list", method: "GET", path: "/api/v2/books?author=Frank%20Herbert&limit=5", as: "carol", status: 200},
+	{name: "v2_books_list_invalid_page", method: "GET", path: "/api/v2/books?page=first", as: "carol", status: 400},
+	{name: "v2_book_delete", method: "DELETE", path: "/api/v2/books/{v2_book_id}", as: "carol", status: 200},
+	{name: "v2_book_delete_missing", method: "DELETE", path: "/api/v2/books/{v2_book_id}", as: "carol", status: 404},
+	{name: "v2_me_get", method: "GET", path: "/api/v2/me", as: "carol", status: 200},
+	{name: "v2_me_update", method: "PATCH", path: "/api/v2/me", as: "carol", status: 200, body: `{"email":"carol@library.example"}`},
+	{name: "v2_me_password", method: "POST", path: "/api/v2/me/password", as: "carol", status: 204,
+		body: `{"current_password":"correct-horse-battery-staple","new_password":"another-long-passphrase"}`},
+	{name: "v2_me_sessions", method: "GET", path: "/api/v2/me/sessions", as: "carol", status: 200,
+		capture: map[string]string{"carol_session": "data.0.id"}},
+	{name: "v2_me_session_delete_missing", method: "DELETE", path: "/api/v2/me/sessions/{alice_id}", as: "carol", status: 404},
+	{name: "v2_me_session_delete", method: "DELETE", path: "/api/v2/me/sessions/{carol_session}", as: "carol", status: 204},
+	{name: "v2_me_after_logout", method: "GET", path: "/api/v2/me", as: "carol", status: 401},
+}
+
+// webhookSecret signs the deliveries to the suite's webhook receiver
+const webhookSecret = "s3cret"
+
+func memoryStorage() Storage {
+	return Storage{
+		Database:  memory.NewDB(),
+		Books:     memory.NewBookRepository(),
+		Revisions: memory.NewBookRevisionRepository(),
+		Users:     memory.NewUserRepository(),
+		Sessions:  memory.NewSessionRepository(),
+		Audit:     memory.NewAuditRepository(),
+		Webhooks:  memory.NewWebhookRepository(),
+		Outbox:    memory.NewOutboxRepository(),
+	}
+}
+
+// apiSuite runs apiCases against an App on in-memory storage
+type apiSuite struct {
+	t      *testing.T
+	server *httptest.Server
+	outbox *memory.OutboxRepository
+	users  *memory.UserRepository
+	vars   map[string]string
+}
+
+func newAPISuite(t *testing.T) *apiSuite {
+	storage := memoryStorage()
+	a := NewWithStorage(config.Default(config.ProfileTest), storage)
+	ctx, cancel := context.WithCancel(context.Background())
+	a.Start(ctx)
+
+	// Webhook deliveries are sent here. Like a real receiver it rejects
+	// payloads not signed with the secret of the webhook_create case.
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		timestamp := r.Header.Get("X-Webhook-Timestamp")
+		want := "sha256=" + services.SignWebhookPayload(webhookSecret, timestamp, body)
+		if timestamp == "" || !hmac.Equal([]byte(r.Header.Get("X-Webhook-Signature")), []byte(want)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	server := httptest.NewServer(a.Handler())
+	t.Cleanup(func() {
+		server.Close()
+		receiver.Close()
+		cancel()
+		a.Close()
+	})
+
+	return &apiSuite{
+		t:      t,
+		server: server,
+		outbox: storage.Outbox.(*memory.OutboxRepository),
+		users:  storage.Users.(*memory.UserRepository),
+		vars:   map[string]string{"receiver": receiver.URL},
+	}
+}
+
+func TestAPI(t *testing.T) {
+	s := newAPISuite(t)
+	for _, tc := range apiCases {
+		// Cases depend on each other, so stop at the first failure
+		if !t.Run(tc.name, s.run(tc)) {
+			t.FailNow()
+		}
+	}
+}
+
+// TestAPICoversEveryRoute fails when a route has no case in apiCases
+func TestAPICoversEveryRoute(t *testing.T) {
+	a := NewWithStorage(config.Default(config.ProfileTest), memoryStorage())
+	defer a.Close()
+
+	covered := map[*mux.Route]bool{}
+	for _, tc := range apiCases {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		var match mux.RouteMatch
+		if a.router.Match(req, &match) && match.Route != nil {
+			covered[match.Route] = true
+		}
+	}
+
+	a.router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil // a path prefix holding a subrouter
+		}
+		if !covered[route] {
+			template, _ := route.GetPathTemplate()
+			t.Errorf("no test case for %s %s", strings.Join(methods, ","), template)
+		}
+		return nil
+	})
+}
+
+func (s *apiSuite) run(tc apiCase) func(t *testing.T) {
+	return func(t *testing.T) {
+		if tc.awaitOutbox {
+			s.awaitOutbox(t)
+		}
+
+		req, err := http.NewRequest(tc.method, s.server.URL+s.expand(tc.path), strings.NewReader(s.expand(tc.body)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if tc.as != "" {
+			token, ok := s.vars[tc.as]
+			if !ok {
+				t.Fatalf("no token captured for %q", tc.as)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		for name, value := range tc.header {
+			req.Header.Set(name, value)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		var body []byte
+		if tc.stream {
+			body = readFirstEvent(t, resp.Body)
+		} else if body, err = io.ReadAll(resp.Body); err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != tc.status {
+			t.Fatalf("status %d, want %d; body: %s", resp.StatusCode, tc.status, body)
+		}
+		if resp.Header.Get("X-Request-ID") == "" {
+			t.Error("response has no X-Request-ID header")
+		}
+		if contentType := resp.Header.Get("Content-Type"); resp.StatusCode >= 400 && contentType != problem.ContentType {
+			t.Errorf("error response has Content-Type %q, want %q", contentType, problem.ContentType)
+		}
+
+		for name, path := range tc.capture {
+			s.vars[name] = capture(t, body, path)
+		}
+		if tc.promote != "" {
+			s.promote(t, s.vars[tc.promote])
+		}
+		if !tc.noGolden {
+			s.checkGolden(t, tc.name, body)
+		}
+	}
+}
+
+// expand replaces {var} in text with captured variables
+func (s *apiSuite) expand(text string) string {
+	for name, value := range s.vars {
+		text = strings.ReplaceAll(text, "{"+name+"}", value)
+	}
+	return text
+}
+
+func (s *apiSuite) promote(t *testing.T, userID string) {
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.users.UpdateUser(context.Background(), id, bson.D{{Key: "role", Value: "admin"}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (s *apiSuite) awaitOutbox(t *testing.T) {
+	deadline := time.Now().Add(5 * time.Second)
+	for s.outbox.Pending() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("outbox relay did not deliver pending events")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func readFirstEvent(t *testing.T, r io.Reader) []byte {
+	var event bytes.Buffer
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if scanner.Text() == "" {
+			return event.Bytes()
+		}
+		event.WriteString(scanner.Text() + "\n")
+	}
+	t.Fatalf("stream ended before the first event: %v", scanner.Err())
+	return nil
+}
+
+// capture returns the value at a dotted path such as "data.0.id" in a JSON
+// document
+func capture(t *testing.T, body []byte, path string) string {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		t.Fatalf("capture %s: %v", path, err)
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			value = v[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i >= len(v) {
+				t.Fatalf("capture %s: no element %s", path, key)
+			}
+			value = v[i]
+		default:
+			t.Fatalf("capture %s: %s not found", path, key)
+		}
+	}
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	t.Fatalf("capture %s: unexpected value %v", path, value)
+	return ""
+}
+
+// checkGolden compares body with testdata/api/<name>.golden, after replacing
+// the values that differ between runs
+func (s *apiSuite) checkGolden(t *testing.T, name string, body []byte) {
+	got := s.normalize(t, body)
+	path := filepath.Join("testdata", "api", name+".golden")
+
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("response differs from %s (run with -update if the change is intended)\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// Fields whose values are random or depend on timing
+var volatileFields = map[string]bool{
+	"token":              true,
+	"temporary_password": true,
+	"request_id":         true,
+	"event_id":           true,
+	"latency_ms":         true,
+	"duration_ms":        true,
+}
+
+var (
+	objectIDPattern  = regexp.MustCompile(`\b[0-9a-f]{24}\b`)
+	timestampPattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}`)
+)
+
+// normalize pretty-prints a JSON body with sorted keys, replacing captured
+// IDs with their variable names and other run-dependent values with
+// placeholders. Other bodies are returned as they are.
+func (s *apiSuite) normalize(t *testing.T, body []byte) []byte {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return body
+	}
+
+	names := map[string]string{}
+	for name, v := range s.vars {
+		names[v] = name
+	}
+
+	var walk func(key string, v interface{}) interface{}
+	walk = func(key string, v interface{}) interface{} {
+		switch value := v.(type) {
+		case map[string]interface{}:
+			for k, child := range value {
+				value[k] = walk(k, child)
+			}
+			return value
+		case []interface{}:
+			for i, child := range value {
+				value[i] = walk(key, child)
+			}
+			return value
+		case string:
+			// Webhook payloads are JSON documents of their own
+			if key == "payload" {
+				payload := json.NewDecoder(strings.NewReader(value))
+				payload.UseNumber()
+				var document map[string]interface{}
+				if payload.Decode(&document) == nil {
+					return walk("", document)
+				}
+			}
+			if name, ok := names[value]; ok {
+				return "{" + name + "}"
+			}
+			if strings.HasPrefix(value, s.vars["receiver"]) {
+				return "{receiver}" + strings.TrimPrefix(value, s.vars["receiver"])
+			}
+			// IDs also appear inside other strings, e.g. problem instance paths
+			v = objectIDPattern.ReplaceAllStringFunc(value, func(id string) string {
+				if name, ok := names[id]; ok {
+					return "{" + name + "}"
+				}
+				return "<object-id>"
+			})
+		}
+		switch {
+		case volatileFields[key]:
+			return "<" + key + ">"
+		case isString(v, timestampPattern) && !strings.HasPrefix(v.(string), "0001-01-01"):
+			return "<timestamp>"
+		}
+		return v
+	}
+
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(walk("", value)); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func isString(v interface{}, pattern *regexp.Regexp) bool {
+	s, ok := v.(string)
+	return ok && pattern.MatchString(s)
+}
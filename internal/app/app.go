@@ -0,0 +1,323 @@
+// Package app builds the library server from its configuration. Each App owns
+// its database connection, event bus, services and router, so several can run
+// side by side in one process (e.g. behind httptest.Server in tests).
+// Logging, metrics and tracing stay process-wide.
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/4Noyis/my-library/internal/config"
+	"github.com/4Noyis/my-library/internal/database"
+	"github.com/4Noyis/my-library/internal/events"
+	"github.com/4Noyis/my-library/internal/graphql"
+	"github.com/4Noyis/my-library/internal/handlers"
+	"github.com/4Noyis/my-library/internal/metrics"
+	"github.com/4Noyis/my-library/internal/middleware"
+	"github.com/4Noyis/my-library/internal/openapi"
+	"github.com/4Noyis/my-library/internal/problem"
+	"github.com/4Noyis/my-library/internal/repositories"
+	"github.com/4Noyis/my-library/internal/rpc"
+	"github.com/4Noyis/my-library/internal/services"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
+	"google.golang.org/grpc"
+)
+
+// Storage is the persistence an App is built on
+type Storage struct {
+	Database  services.Database
+	Books     services.BookStore
+	Revisions services.BookRevisionStore
+	Users     services.UserStore
+	Sessions  services.SessionStore
+	Audit     services.AuditStore
+	Webhooks  services.WebhookStore
+	Outbox    services.OutboxStore
+}
+
+// MongoStorage is Storage backed by the repositories on db
+func MongoStorage(db *database.DB) Storage {
+	return Storage{
+		Database:  db,
+		Books:     repositories.NewBookCollection(db),
+		Revisions: repositories.NewBookRevisionRepository(db),
+		Users:     repositories.NewUserRepository(db),
+		Sessions:  repositories.NewSessionRepository(db),
+		Audit:     repositories.NewAuditRepository(db),
+		Webhooks:  repositories.NewWebhookRepository(db),
+		Outbox:    repositories.NewOutboxRepository(db),
+	}
+}
+
+type App struct {
+	Config  *config.Config
+	Storage Storage
+	Bus     *events.Bus
+	// DB is the MongoDB connection opened by New; nil for NewWithStorage
+	DB *database.DB
+
+	router      *mux.Router
+	spec        *openapi.Document
+	handler     http.Handler
+	grpcServer  *grpc.Server
+	health      *services.HealthService
+	webhooks    *services.WebhookService
+	relay       *services.OutboxRelay
+	eventStream *services.EventStream
+}
+
+// New connects to MongoDB and builds the services and router described by
+// cfg. Call Start to run the background workers and Close when done.
+func New(cfg *config.Config) (*App, error) {
+	db, err := database.ConnectMongoDB(cfg.Mongo)
+	if err != nil {
+		return nil, err
+	}
+	if err := repositories.EnsureIndexes(context.Background(), db); err != nil {
+		db.Disconnect()
+		return nil, err
+	}
+
+	a := NewWithStorage(cfg, MongoStorage(db))
+	a.DB = db
+	return a, nil
+}
+
+// NewWithStorage builds the app on storage instead of connecting to the
+// database in cfg, e.g. on in-memory repositories in tests
+func NewWithStorage(cfg *config.Config, storage Storage) *App {
+	bus := events.NewBus()
+	outbox := services.NewOutbox(storage.Outbox)
+
+	userService := services.NewUserService(cfg, storage.Database, storage.Users, storage.Sessions, outbox)
+	auditService := services.NewAuditService(storage.Audit)
+	webhookService := services.NewWebhookService(storage.Webhooks, cfg.Webhooks)
+	services.RegisterEventSubscribers(bus, auditService, webhookService)
+
+	a := &App{
+		Config:      cfg,
+		Storage:     storage,
+		Bus:         bus,
+		health:      services.NewHealthService(storage.Database),
+		webhooks:    webhookService,
+		relay:       services.NewOutboxRelay(outbox, bus, cfg.Outbox),
+		eventStream: services.NewEventStream(bus, cfg.Stream),
+	}
+
+	bookService := services.NewBookService(storage.Database, storage.Books, storage.Revisions, outbox)
+	h := handlers.New(handlers.Services{
+		Users:       userService,
+		OIDC:        services.NewOIDCService(userService, cfg.OIDC),
+		Books:       bookService,
+		Audit:       auditService,
+		Webhooks:    webhookService,
+		Health:      a.health,
+		EventStream: a.eventStream,
+	})
+	limiter := services.NewRateLimiter(services.NewMemoryRateLimitStore(), services.NewRateLimitConfig(cfg.RateLimit))
+
+	a.spec = newSpec()
+	gql := graphql.Handler(graphql.Services{Books: bookService, Users: userService})
+	a.router = newRouter(cfg, h, gql, userService, limiter, a.spec)
+	// Request IDs wrap the router so unmatched routes get one too
+	a.handler = middleware.RequestIDMiddleware(a.router)
+	a.grpcServer = rpc.NewServer(rpc.Services{Books: bookService, Users: userService, Limiter: limiter})
+	return a
+}
+
+func newRouter(cfg *config.Config, h *handlers.Handlers, gql http.Handler, userService *services.UserService, limiter *services.RateLimiter, spec *openapi.Document) *mux.Router {
+	r := mux.NewRouter()
+	r.NotFoundHandler = problem.NotFoundHandler()
+	r.MethodNotAllowedHandler = problem.MethodNotAllowedHandler()
+
+	// Tracing runs first so request logs carry the trace ID
+	r.Use(otelmux.Middleware(cfg.Tracing.ServiceName))
+
+	// Add logging middleware
+	r.Use(middleware.LoggingMiddleware)
+
+	// Responses are checked against the API reference in tests
+	if cfg.Validation.Responses {
+		r.Use(middleware.ResponseValidationMiddleware(spec))
+	}
+
+	// Orchestrator probes
+	r.HandleFunc("/healthz", h.LivenessHandler).Methods("GET")
+	r.HandleFunc("/readyz", h.ReadinessHandler).Methods("GET")
+
+	// Prometheus metrics
+	r.Handle("/metrics", metrics.Handler()).Methods("GET")
+
+	// API reference
+	r.Handle("/openapi.json", openapi.Handler(spec)).Methods("GET")
+	r.Handle("/docs", openapi.DocsHandler(spec.Info.Title, "/openapi.json")).Methods("GET")
+
+	rateLimit := middleware.RateLimitMiddleware(limiter)
+	validate := func(next http.Handler) http.Handler { return next }
+	if cfg.Validation.Requests {
+		validate = middleware.RequestValidationMiddleware(spec)
+	}
+
+	// Each version of the REST API has its own subrouter. Once a deprecation
+	// date is configured, v1 routes with a v2 successor announce it.
+	v1 := r.PathPrefix("/api/v1").Subrouter()
+	v2 := r.PathPrefix("/api/v2").Subrouter()
+	deprecated, _ := config.ParseDate(cfg.Versioning.V1Deprecated)
+	sunset, _ := config.ParseDate(cfg.Versioning.V1Sunset)
+	v1.Use(middleware.VersionMiddleware(middleware.APIVersion{
+		Name:       "v1",
+		Deprecated: deprecated,
+		Sunset:     sunset,
+		Successor:  successorIn(v2, "/api/v1", "/api/v2"),
+	}))
+	v2.Use(middleware.VersionMiddleware(middleware.APIVersion{Name: "v2"}))
+
+	// Public routes (no authentication required)
+	public := v1.PathPrefix("/auth").Subrouter()
+	public.Use(rateLimit)
+	public.Use(validate)
+	public.HandleFunc("/register", h.RegisterHandler).Methods("POST")
+	public.HandleFunc("/login", h.LoginHandler).Methods("POST")
+	public.HandleFunc("/oidc/login", h.OIDCLoginHandler).Methods("GET")
+	public.HandleFunc("/oidc/callback", h.OIDCCallbackHandler).Methods("GET")
+
+	// Protected routes (authentication required), limited per user
+	protected := v1.NewRoute().Subrouter()
+	protected.Use(middleware.AuthMiddleware(userService))
+	protected.Use(rateLimit)
+	protected.Use(validate)
+
+	// Book routes - all require authentication
+	protected.HandleFunc("/books", h.GetAllBooksHandler).Methods("GET")
+	protected.HandleFunc("/books", h.CreateBookHandler).Methods("POST")
+	protected.HandleFunc("/books/{id}", h.GetOneBookHandler).Methods("GET")
+	protected.HandleFunc("/books/{id}", h.UpdateBookHandler).Methods("PATCH")
+	protected.HandleFunc("/books/{id}", h.DeleteBookHandler).Methods("DELETE")
+	protected.HandleFunc("/books/{id}/history", h.BookHistoryHandler).Methods("GET")
+	protected.HandleFunc("/books/{id}/revert/{rev}", h.RevertBookHandler).Methods("POST")
+
+	// Live stream of catalog changes
+	protected.HandleFunc("/events/stream", h.EventStreamHandler).Methods("GET")
+
+	// Current user account routes
+	protected.HandleFunc("/me", h.GetMeHandler).Methods("GET")
+	protected.HandleFunc("/me", h.UpdateMeHandler).Methods("PATCH")
+	protected.HandleFunc("/me/password", h.ChangePasswordHandler).Methods("POST")
+	protected.HandleFunc("/me/sessions", h.ListSessionsHandler).Methods("GET")
+	protected.HandleFunc("/me/sessions/{id}", h.DeleteSessionHandler).Methods("DELETE")
+
+	// Admin routes - require the admin role
+	admin := protected.PathPrefix("/admin").Subrouter()
+	admin.Use(middleware.AdminMiddleware)
+
+	admin.HandleFunc("/users", h.ListUsersHandler).Methods("GET")
+	admin.HandleFunc("/users/{id}", h.GetUserHandler).Methods("GET")
+	admin.HandleFunc("/users/{id}", h.DeleteUserHandler).Methods("DELETE")
+	admin.HandleFunc("/users/{id}/role", h.UpdateUserRoleHandler).Methods("PATCH")
+	admin.HandleFunc("/users/{id}/status", h.UpdateUserStatusHandler).Methods("PATCH")
+	admin.HandleFunc("/users/{id}/reset-password", h.ResetUserPasswordHandler).Methods("POST")
+	admin.HandleFunc("/audit", h.ListAuditEventsHandler).Methods("GET")
+
+	admin.HandleFunc("/webhooks", h.ListWebhooksHandler).Methods("GET")
+	admin.HandleFunc("/webhooks", h.CreateWebhookHandler).Methods("POST")
+	admin.HandleFunc("/webhooks/{id}", h.GetWebhookHandler).Methods("GET")
+	admin.HandleFunc("/webhooks/{id}", h.UpdateWebhookHandler).Methods("PATCH")
+	admin.HandleFunc("/webhooks/{id}", h.DeleteWebhookHandler).Methods("DELETE")
+	admin.HandleFunc("/webhooks/{id}/deliveries", h.ListWebhookDeliveriesHandler).Methods("GET")
+	admin.HandleFunc("/webhooks/{id}/deliveries/{deliveryId}/retry", h.RetryWebhookDeliveryHandler).Methods("POST")
+	admin.HandleFunc("/webhooks/{id}/ping", h.PingWebhookHandler).Methods("POST")
+
+	// Version 2: the same services, with every body in an envelope and
+	// string IDs. Administration, webhooks, single sign-on and the event
+	// stream are only in v1 so far.
+	publicV2 := v2.PathPrefix("/auth").Subrouter()
+	publicV2.Use(rateLimit)
+	publicV2.Use(validate)
+	publicV2.HandleFunc("/register", h.RegisterV2Handler).Methods("POST")
+	publicV2.HandleFunc("/login", h.LoginV2Handler).Methods("POST")
+
+	protectedV2 := v2.NewRoute().Subrouter()
+	protectedV2.Use(middleware.AuthMiddleware(userService))
+	protectedV2.Use(rateLimit)
+	protectedV2.Use(validate)
+
+	protectedV2.HandleFunc("/books", h.ListBooksV2Handler).Methods("GET")
+	protectedV2.HandleFunc("/books", h.CreateBookV2Handler).Methods("POST")
+	protectedV2.HandleFunc("/books/{id}", h.GetBookV2Handler).Methods("GET")
+	protectedV2.HandleFunc("/books/{id}", h.UpdateBookV2Handler).Methods("PATCH")
+	protectedV2.HandleFunc("/books/{id}", h.DeleteBookV2Handler).Methods("DELETE")
+	protectedV2.HandleFunc("/books/{id}/history", h.BookHistoryV2Handler).Methods("GET")
+	protectedV2.HandleFunc("/books/{id}/revert/{rev}", h.RevertBookV2Handler).Methods("POST")
+
+	protectedV2.HandleFunc("/me", h.GetMeV2Handler).Methods("GET")
+	protectedV2.HandleFunc("/me", h.UpdateMeV2Handler).Methods("PATCH")
+	protectedV2.HandleFunc("/me/password", h.ChangePasswordV2Handler).Methods("POST")
+	protectedV2.HandleFunc("/me/sessions", h.ListSessionsV2Handler).Methods("GET")
+	protectedV2.HandleFunc("/me/sessions/{id}", h.DeleteSessionV2Handler).Methods("DELETE")
+
+	// GraphQL, authenticated and limited like the REST API
+	graphQL := r.PathPrefix("/graphql").Subrouter()
+	graphQL.Use(middleware.AuthMiddleware(userService))
+	graphQL.Use(rateLimit)
+	graphQL.Use(validate)
+	graphQL.Handle("", gql).Methods("POST")
+
+	return r
+}
+
+// successorIn returns a function finding the route that replaces a request's
+// in the router of the next version, at the same path under nextPrefix
+func successorIn(next *mux.Router, prefix, nextPrefix string) func(*http.Request) (string, bool) {
+	return func(r *http.Request) (string, bool) {
+		path := nextPrefix + strings.TrimPrefix(r.URL.Path, prefix)
+		probe := &http.Request{Method: r.Method, URL: &url.URL{Path: path}, Header: http.Header{}}
+
+		var match mux.RouteMatch
+		if next.Match(probe, &match) && match.MatchErr == nil {
+			return path, true
+		}
+		return "", false
+	}
+}
+
+// Handler is the root HTTP handler of the app
+func (a *App) Handler() http.Handler {
+	return a.handler
+}
+
+// GRPCServer serves the gRPC API of the app; hand it a listener with Serve
+func (a *App) GRPCServer() *grpc.Server {
+	return a.grpcServer
+}
+
+// Start runs the webhook dispatcher and outbox relay until ctx is cancelled
+func (a *App) Start(ctx context.Context) {
+	go a.webhooks.RunDispatcher(ctx)
+	go a.relay.Run(ctx)
+}
+
+// BeginShutdown fails the readiness probe from now on; call it as soon as
+// shutdown starts so traffic drains before the listener closes
+func (a *App) BeginShutdown() {
+	a.health.BeginShutdown()
+}
+
+// CloseEventStreams ends every open event stream. Streams never finish on
+// their own, so register it with http.Server.RegisterOnShutdown.
+func (a *App) CloseEventStreams() {
+	a.eventStream.Close()
+}
+
+// Close lets asynchronous event subscribers drain, then disconnects from
+// MongoDB. Stop the background workers and the HTTP server first.
+func (a *App) Close() {
+	a.eventStream.Close()
+	a.Bus.Close()
+	if a.DB != nil {
+		a.DB.Disconnect()
+	}
+}
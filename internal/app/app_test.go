@@ -0,0 +1,106 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/config"
+)
+
+// newTestServer starts an App on its own database behind an httptest.Server.
+// It needs a MongoDB server at TEST_MONGO_URI; the database is dropped when
+// the test ends.
+func newTestServer(t *testing.T, database string) *httptest.Server {
+	t.Helper()
+	uri := os.Getenv("TEST_MONGO_URI")
+	if uri == "" {
+		t.Skip("TEST_MONGO_URI not set")
+	}
+
+	cfg := config.Default(config.ProfileTest)
+	cfg.Mongo.URI = uri
+	cfg.Mongo.Database = database
+
+	a, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	a.Start(ctx)
+
+	server := httptest.NewServer(a.Handler())
+	t.Cleanup(func() {
+		server.Close()
+		cancel()
+		a.DB.Client().Database(database).Drop(context.Background())
+		a.Close()
+	})
+	return server
+}
+
+func register(t *testing.T, server *httptest.Server, username string) int {
+	t.Helper()
+	body := `{"username":"` + username + `","email":"` + username + `@example.com","password":"correct-horse-battery-staple"}`
+	resp, err := http.Post(server.URL+"/api/v1/auth/register", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestAppsAreIsolated(t *testing.T) {
+	suffix := strconv.FormatInt(time.Now().UnixNano(), 36)
+	first := newTestServer(t, "library_test_"+suffix+"_a")
+	second := newTestServer(t, "library_test_"+suffix+"_b")
+
+	if status := register(t, first, "alice"); status != http.StatusCreated {
+		t.Fatalf("register on first app: status %d", status)
+	}
+	if status := register(t, first, "alice"); status != http.StatusConflict {
+		t.Fatalf("duplicate register on first app: status %d, want %d", status, http.StatusConflict)
+	}
+	// The second app has its own database, so the username is still free
+	if status := register(t, second, "alice"); status != http.StatusCreated {
+		t.Fatalf("register on second app: status %d", status)
+	}
+}
+
+func TestBeginShutdownFailsReadiness(t *testing.T) {
+	a := NewWithStorage(config.Default(config.ProfileTest), memoryStorage())
+	server := httptest.NewServer(a.Handler())
+	t.Cleanup(server.Close)
+
+	status := func(path string) int {
+		t.Helper()
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if got := status("/readyz"); got != http.StatusOK {
+		t.Fatalf("readyz before shutdown: status %d", got)
+	}
+
+	a.BeginShutdown()
+
+	if got := status("/readyz"); got != http.StatusServiceUnavailable {
+		t.Errorf("readyz during shutdown: status %d, want %d", got, http.StatusServiceUnavailable)
+	}
+	// Requests are still served while the load balancer drains
+	if got := status("/healthz"); got != http.StatusOK {
+		t.Errorf("healthz during shutdown: status %d, want %d", got, http.StatusOK)
+	}
+	if got := status("/api/v1/books"); got != http.StatusUnauthorized {
+		t.Errorf("API during shutdown: status %d, want %d", got, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,425 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/config"
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/4Noyis/my-library/internal/rpc/libraryv1"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// paritySuite serves one app over both REST and gRPC
+type paritySuite struct {
+	rest    *httptest.Server
+	catalog libraryv1.CatalogServiceClient
+	auth    libraryv1.AuthServiceClient
+}
+
+func newParitySuite(t *testing.T) *paritySuite {
+	return newParitySuiteWith(t, config.Default(config.ProfileTest))
+}
+
+func newParitySuiteWith(t *testing.T, cfg *config.Config) *paritySuite {
+	a := NewWithStorage(cfg, memoryStorage())
+
+	rest := httptest.NewServer(a.Handler())
+	t.Cleanup(rest.Close)
+
+	listener := bufconn.Listen(1 << 20)
+	go a.GRPCServer().Serve(listener)
+	t.Cleanup(a.GRPCServer().Stop)
+
+	conn, err := grpc.NewClient("passthrough:///library",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return &paritySuite{
+		rest:    rest,
+		catalog: libraryv1.NewCatalogServiceClient(conn),
+		auth:    libraryv1.NewAuthServiceClient(conn),
+	}
+}
+
+// call sends a REST request and decodes its JSON response into out
+func (s *paritySuite) call(t *testing.T, method, path, token, body string, wantStatus int, out interface{}) {
+	t.Helper()
+	req, err := http.NewRequest(method, s.rest.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != wantStatus {
+		t.Fatalf("%s %s: status %d, want %d", method, path, resp.StatusCode, wantStatus)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+}
+
+func withToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func TestGRPCMatchesREST(t *testing.T) {
+	s := newParitySuite(t)
+
+	// Sign up over REST, sign in over gRPC: both APIs share the accounts
+	var registered struct{ Data models.User }
+	s.call(t, "POST", "/api/v1/auth/register", "",
+		`{"username":"rita","email":"rita@example.com","password":"correct-Horse-1"}`, http.StatusCreated, &registered)
+
+	login, err := s.auth.Login(context.Background(), &libraryv1.LoginRequest{Username: "rita", Password: "correct-Horse-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := login.GetToken()
+	ctx := withToken(token)
+
+	var me struct{ Data models.User }
+	s.call(t, "GET", "/api/v1/me", token, "", http.StatusOK, &me)
+	grpcMe, err := s.auth.GetMe(ctx, &libraryv1.GetMeRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertSame(t, "me", utcUser(me.Data), userFromGRPC(grpcMe))
+	assertSame(t, "login user", utcUser(registered.Data), userFromGRPC(login.GetUser()))
+
+	// Change the catalog through both APIs
+	published := time.Date(1954, 7, 29, 0, 0, 0, 0, time.UTC)
+	created, err := s.catalog.CreateBook(ctx, &libraryv1.CreateBookRequest{Book: &libraryv1.Book{
+		Isbn:        "9780261103573",
+		Title:       "The Fellowship of the Ring",
+		Author:      "J. R. R. Tolkien",
+		PublishedAt: timestamppb.New(published),
+		Genre:       "Fantasy",
+		Pages:       423,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var second struct{ Book models.Book }
+	s.call(t, "POST", "/api/v1/books", token,
+		`{"isbn":"9780261102361","title":"The Two Towers","author":"J. R. R. Tolkien","genre":"Fantasy","pages":352}`,
+		http.StatusOK, &second)
+
+	var updated struct{ Book models.Book }
+	s.call(t, "PATCH", fmt.Sprintf("/api/v1/books/%d", created.GetId()), token, `{"location":"A-12"}`, http.StatusOK, &updated)
+	if _, err := s.catalog.UpdateBook(ctx, &libraryv1.UpdateBookRequest{Id: int64(second.Book.ID), Book: &libraryv1.Book{Location: "B-3"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.catalog.RevertBook(ctx, &libraryv1.RevertBookRequest{Id: created.GetId(), Rev: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Then read it back through both
+	var books []models.Book
+	s.call(t, "GET", "/api/v1/books", token, "", http.StatusOK, &books)
+	page, err := s.catalog.ListBooks(ctx, &libraryv1.ListBooksRequest{Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.GetTotal() != int64(len(books)) {
+		t.Errorf("gRPC total %d, REST listed %d books", page.GetTotal(), len(books))
+	}
+	restBooks := make([]models.Book, len(books))
+	grpcBooks := make([]models.Book, len(page.GetBooks()))
+	for i := range books {
+		restBooks[i] = utcBook(books[i])
+	}
+	for i, book := range page.GetBooks() {
+		grpcBooks[i] = bookFromGRPC(book)
+	}
+	assertSame(t, "books", restBooks, grpcBooks)
+
+	for _, book := range books {
+		var restBook models.Book
+		s.call(t, "GET", fmt.Sprintf("/api/v1/books/%d", book.ID), token, "", http.StatusOK, &restBook)
+		grpcBook, err := s.catalog.GetBook(ctx, &libraryv1.GetBookRequest{Id: int64(book.ID)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertSame(t, fmt.Sprintf("book %d", book.ID), utcBook(restBook), bookFromGRPC(grpcBook))
+
+		var history struct{ Revisions []models.BookRevision }
+		s.call(t, "GET", fmt.Sprintf("/api/v1/books/%d/history", book.ID), token, "", http.StatusOK, &history)
+		grpcHistory, err := s.catalog.GetBookHistory(ctx, &libraryv1.GetBookHistoryRequest{Id: int64(book.ID)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		restRevisions := make([]models.BookRevision, len(history.Revisions))
+		grpcRevisions := make([]models.BookRevision, len(grpcHistory.GetRevisions()))
+		for i := range history.Revisions {
+			restRevisions[i] = utcRevision(history.Revisions[i])
+		}
+		for i, revision := range grpcHistory.GetRevisions() {
+			grpcRevisions[i] = revisionFromGRPC(revision)
+		}
+		assertSame(t, fmt.Sprintf("history of book %d", book.ID), restRevisions, grpcRevisions)
+	}
+}
+
+func TestGRPCErrorsMatchREST(t *testing.T) {
+	s := newParitySuite(t)
+
+	var registered struct{ Data models.User }
+	s.call(t, "POST", "/api/v1/auth/register", "",
+		`{"username":"rita","email":"rita@example.com","password":"correct-Horse-1"}`, http.StatusCreated, &registered)
+	login, err := s.auth.Login(context.Background(), &libraryv1.LoginRequest{Username: "rita", Password: "correct-Horse-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := withToken(login.GetToken())
+
+	tests := []struct {
+		name       string
+		rest       func(t *testing.T) models.Problem
+		grpc       func() error
+		wantStatus int
+		wantCode   codes.Code
+	}{
+		{
+			name: "missing book",
+			rest: func(t *testing.T) (p models.Problem) {
+				s.call(t, "GET", "/api/v1/books/999", login.GetToken(), "", http.StatusNotFound, &p)
+				return p
+			},
+			grpc: func() error {
+				_, err := s.catalog.GetBook(ctx, &libraryv1.GetBookRequest{Id: 999})
+				return err
+			},
+			wantStatus: http.StatusNotFound,
+			wantCode:   codes.NotFound,
+		},
+		{
+			name: "duplicate username",
+			rest: func(t *testing.T) (p models.Problem) {
+				s.call(t, "POST", "/api/v1/auth/register", "",
+					`{"username":"rita","email":"other@example.com","password":"correct-Horse-1"}`, http.StatusConflict, &p)
+				return p
+			},
+			grpc: func() error {
+				_, err := s.auth.Register(context.Background(), &libraryv1.RegisterRequest{
+					Username: "rita", Email: "other@example.com", Password: "correct-Horse-1",
+				})
+				return err
+			},
+			wantStatus: http.StatusConflict,
+			wantCode:   codes.AlreadyExists,
+		},
+		{
+			name: "wrong password",
+			rest: func(t *testing.T) (p models.Problem) {
+				s.call(t, "POST", "/api/v1/auth/login", "", `{"username":"rita","password":"wrong"}`, http.StatusUnauthorized, &p)
+				return p
+			},
+			grpc: func() error {
+				_, err := s.auth.Login(context.Background(), &libraryv1.LoginRequest{Username: "rita", Password: "wrong"})
+				return err
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   codes.Unauthenticated,
+		},
+		{
+			name: "invalid token",
+			rest: func(t *testing.T) (p models.Problem) {
+				s.call(t, "GET", "/api/v1/books", "not-a-token", "", http.StatusUnauthorized, &p)
+				return p
+			},
+			grpc: func() error {
+				_, err := s.catalog.ListBooks(withToken("not-a-token"), &libraryv1.ListBooksRequest{})
+				return err
+			},
+			wantStatus: http.StatusUnauthorized,
+			wantCode:   codes.Unauthenticated,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p := tc.rest(t)
+			st := status.Convert(tc.grpc())
+			if st.Code() != tc.wantCode {
+				t.Fatalf("gRPC code %s, want %s", st.Code(), tc.wantCode)
+			}
+			if p.Status != tc.wantStatus || p.Detail != st.Message() {
+				t.Errorf("REST answered %d %q, gRPC %s %q", p.Status, p.Detail, st.Code(), st.Message())
+			}
+		})
+	}
+}
+
+func TestGRPCRequiresToken(t *testing.T) {
+	s := newParitySuite(t)
+
+	_, err := s.catalog.ListBooks(context.Background(), &libraryv1.ListBooksRequest{})
+	if code := status.Code(err); code != codes.Unauthenticated {
+		t.Fatalf("ListBooks without a token: %s, want %s", code, codes.Unauthenticated)
+	}
+}
+
+func TestGRPCRateLimitsLogin(t *testing.T) {
+	cfg := config.Default(config.ProfileTest)
+	cfg.RateLimit.Enabled = true
+	cfg.RateLimit.Auth = 2
+	s := newParitySuiteWith(t, cfg)
+
+	login := &libraryv1.LoginRequest{Username: "mallory", Password: "guess"}
+	for i := 0; i < cfg.RateLimit.Auth; i++ {
+		var header metadata.MD
+		_, err := s.auth.Login(context.Background(), login, grpc.Header(&header))
+		if code := status.Code(err); code != codes.Unauthenticated {
+			t.Fatalf("attempt %d: %s, want %s", i+1, code, codes.Unauthenticated)
+		}
+		if got, want := header.Get("ratelimit-remaining"), fmt.Sprint(cfg.RateLimit.Auth-i-1); len(got) != 1 || got[0] != want {
+			t.Errorf("attempt %d: ratelimit-remaining %v, want %s", i+1, got, want)
+		}
+	}
+
+	var header metadata.MD
+	_, err := s.auth.Login(context.Background(), login, grpc.Header(&header))
+	if code := status.Code(err); code != codes.ResourceExhausted {
+		t.Fatalf("attempt over the limit: %s, want %s", code, codes.ResourceExhausted)
+	}
+	if retryAfter := header.Get("retry-after"); len(retryAfter) != 1 || retryAfter[0] != "30" {
+		t.Errorf("retry-after %v, want 30", retryAfter)
+	}
+
+	// Register draws from its own bucket, like its REST counterpart
+	_, err = s.auth.Register(context.Background(), &libraryv1.RegisterRequest{})
+	if code := status.Code(err); code != codes.InvalidArgument {
+		t.Errorf("Register after Login was limited: %s, want %s", code, codes.InvalidArgument)
+	}
+}
+
+func assertSame(t *testing.T, what string, rest, grpc interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(rest, grpc) {
+		restJSON, _ := json.MarshalIndent(rest, "", "  ")
+		grpcJSON, _ := json.MarshalIndent(grpc, "", "  ")
+		t.Errorf("%s differs\nREST: %s\ngRPC: %s", what, restJSON, grpcJSON)
+	}
+}
+
+// The conversions below are written against the protobuf definitions rather
+// than shared with the server, so the tests catch a server-side mapping bug
+
+func bookFromGRPC(book *libraryv1.Book) models.Book {
+	return models.Book{
+		ID:          int(book.GetId()),
+		ISBN:        book.GetIsbn(),
+		Title:       book.GetTitle(),
+		Author:      book.GetAuthor(),
+		Publisher:   book.GetPublisher(),
+		PublishedAt: timeFromGRPC(book.GetPublishedAt()),
+		Genre:       book.GetGenre(),
+		Language:    book.GetLanguage(),
+		Pages:       int(book.GetPages()),
+		Description: book.GetDescription(),
+		CoverURL:    book.GetCoverUrl(),
+		Location:    book.GetLocation(),
+		CreatedAt:   timeFromGRPC(book.GetCreatedAt()),
+		UpdatedAt:   timeFromGRPC(book.GetUpdatedAt()),
+	}
+}
+
+func revisionFromGRPC(revision *libraryv1.BookRevision) models.BookRevision {
+	out := models.BookRevision{
+		BookID:        int(revision.GetBookId()),
+		Rev:           int(revision.GetRev()),
+		Book:          bookFromGRPC(revision.GetBook()),
+		CreatedAt:     timeFromGRPC(revision.GetCreatedAt()),
+		ActorUsername: revision.GetActorUsername(),
+		RevertedFrom:  int(revision.GetRevertedFrom()),
+	}
+	if revision.GetActorId() != "" {
+		out.ActorID, _ = primitive.ObjectIDFromHex(revision.GetActorId())
+	}
+	if len(revision.GetChanges()) > 0 {
+		out.Changes = make(map[string]models.FieldChange)
+		for field, change := range revision.GetChanges() {
+			out.Changes[field] = models.FieldChange{
+				Before: change.GetBefore().AsInterface(),
+				After:  change.GetAfter().AsInterface(),
+			}
+		}
+	}
+	return out
+}
+
+func userFromGRPC(user *libraryv1.User) models.User {
+	id, _ := primitive.ObjectIDFromHex(user.GetId())
+	return models.User{
+		ID:                 id,
+		Username:           user.GetUsername(),
+		Email:              user.GetEmail(),
+		Role:               user.GetRole(),
+		IsActive:           user.GetIsActive(),
+		CreatedAt:          timeFromGRPC(user.GetCreatedAt()),
+		UpdatedAt:          timeFromGRPC(user.GetUpdatedAt()),
+		MustChangePassword: user.GetMustChangePassword(),
+		AuthProvider:       user.GetAuthProvider(),
+	}
+}
+
+func timeFromGRPC(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
+
+// REST times decode in the offset they were written with; gRPC's are UTC
+
+func utcBook(book models.Book) models.Book {
+	book.PublishedAt = book.PublishedAt.UTC()
+	book.CreatedAt = book.CreatedAt.UTC()
+	book.UpdatedAt = book.UpdatedAt.UTC()
+	return book
+}
+
+func utcRevision(revision models.BookRevision) models.BookRevision {
+	revision.Book = utcBook(revision.Book)
+	revision.CreatedAt = revision.CreatedAt.UTC()
+	return revision
+}
+
+func utcUser(user models.User) models.User {
+	user.CreatedAt = user.CreatedAt.UTC()
+	user.UpdatedAt = user.UpdatedAt.UTC()
+	return user
+}
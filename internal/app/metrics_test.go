@@ -0,0 +1,113 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/logger"
+)
+
+// scrape reads /metrics and returns every sample keyed by its name and
+// labels as they appear in the text format, e.g.
+// auth_logins_total{method="password",outcome="success"}
+func scrape(t *testing.T, s *paritySuite) map[string]float64 {
+	t.Helper()
+
+	resp, err := http.Get(s.rest.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /metrics: status %d", resp.StatusCode)
+	}
+
+	samples := map[string]float64{}
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		value, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			t.Fatalf("sample %q: %v", line, err)
+		}
+		samples[line[:i]] = value
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return samples
+}
+
+func TestMetricsLabelRequestsWithTheRouteTemplate(t *testing.T) {
+	s := newParitySuite(t)
+
+	for _, id := range []string{"41", "42"} {
+		var p map[string]interface{}
+		s.call(t, "GET", "/api/v1/books/"+id, "", "", http.StatusUnauthorized, &p)
+	}
+
+	samples := scrape(t, s)
+	series := `http_requests_total{method="GET",route="/api/v1/books/{id}",status="401"}`
+	if samples[series] < 2 {
+		t.Errorf("%s = %v, want both requests counted", series, samples[series])
+	}
+	for name := range samples {
+		if strings.Contains(name, "/books/41") || strings.Contains(name, "/books/42") {
+			t.Errorf("series %s is labelled with the raw path", name)
+		}
+	}
+}
+
+func TestMetricsCountLogins(t *testing.T) {
+	s := newParitySuite(t)
+
+	var registered map[string]interface{}
+	s.call(t, "POST", "/api/v1/auth/register", "",
+		`{"username":"mia","email":"mia@example.com","password":"correct-Horse-1"}`, http.StatusCreated, &registered)
+
+	success := `auth_logins_total{method="password",outcome="success"}`
+	failure := `auth_logins_total{method="password",outcome="failure"}`
+	before := scrape(t, s)
+
+	var p map[string]interface{}
+	s.call(t, "POST", "/api/v1/auth/login", "", `{"username":"mia","password":"correct-Horse-1"}`, http.StatusOK, &p)
+	s.call(t, "POST", "/api/v1/auth/login", "", `{"username":"mia","password":"wrong"}`, http.StatusUnauthorized, &p)
+	s.call(t, "POST", "/api/v1/auth/login", "", `{"username":"nobody","password":"wrong"}`, http.StatusUnauthorized, &p)
+
+	after := scrape(t, s)
+	if got := after[success] - before[success]; got != 1 {
+		t.Errorf("%s went up by %v, want 1", success, got)
+	}
+	if got := after[failure] - before[failure]; got != 2 {
+		t.Errorf("%s went up by %v, want 2", failure, got)
+	}
+}
+
+func TestMetricsRecordSubMillisecondDatabaseOperations(t *testing.T) {
+	s := newParitySuite(t)
+
+	// The repositories log every operation; the in-memory ones used here
+	// don't, so log one as a repository would
+	logger.LogDatabaseOperation(context.Background(), "find_one", "metrics_test", nil, 300*time.Microsecond, nil)
+
+	samples := scrape(t, s)
+	labels := `collection="metrics_test",operation="find_one",outcome="success"`
+	if count := samples["mongodb_operation_duration_seconds_count{"+labels+"}"]; count != 1 {
+		t.Fatalf("histogram count = %v, want 1", count)
+	}
+	if bucket := samples[`mongodb_operation_duration_seconds_bucket{`+labels+`,le="0.001"}`]; bucket != 1 {
+		t.Errorf("le=0.001 bucket = %v, want the operation in it", bucket)
+	}
+	if sum := samples["mongodb_operation_duration_seconds_sum{"+labels+"}"]; sum < 0.0002 || sum > 0.0004 {
+		t.Errorf("histogram sum = %vs, want 0.0003s", sum)
+	}
+}
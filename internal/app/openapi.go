@@ -0,0 +1,473 @@
+package app
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/4Noyis/my-library/internal/openapi"
+)
+
+// newSpec describes every route registered by newRouter. TestSpecCoversEveryRoute
+// fails when the two disagree.
+func newSpec() *openapi.Document {
+	doc := openapi.New(openapi.Info{
+		Title:   "My Library API",
+		Version: "1.0.0",
+		Description: "Catalog, accounts and administration of the library. Errors are RFC 7807 problem details. " +
+			"Under /api/v2 every response body is a data envelope and every ID is a string.",
+	})
+	doc.Tags = []openapi.Tag{
+		{Name: "auth", Description: "Registration and login"},
+		{Name: "books", Description: "The catalog"},
+		{Name: "account", Description: "The current user"},
+		{Name: "admin", Description: "User administration and audit log"},
+		{Name: "webhooks", Description: "Webhook subscriptions"},
+		{Name: "graphql", Description: "The catalog and accounts as a GraphQL API"},
+		{Name: "operations", Description: "Probes, metrics and this document"},
+	}
+
+	doc.Components.SecuritySchemes["bearerAuth"] = &openapi.SecurityScheme{Type: "http", Scheme: "bearer", BearerFormat: "JWT"}
+	bearer := []map[string][]string{{"bearerAuth": {}}}
+
+	problemJSON := &openapi.MediaType{Schema: doc.Schema(models.Problem{})}
+	problemResponse := func(description string) *openapi.Response {
+		return &openapi.Response{
+			Description: description,
+			Content:     map[string]*openapi.MediaType{"application/problem+json": problemJSON},
+		}
+	}
+	doc.Components.Responses["BadRequest"] = problemResponse("The request is malformed or fails validation")
+	doc.Components.Responses["Unauthorized"] = problemResponse("Missing, invalid or expired credentials")
+	doc.Components.Responses["Forbidden"] = problemResponse("The caller may not perform this operation")
+	doc.Components.Responses["NotFound"] = problemResponse("The resource does not exist")
+	doc.Components.Responses["Conflict"] = problemResponse("The change conflicts with an existing resource")
+	tooManyRequests := problemResponse("The caller exceeded its rate limit")
+	tooManyRequests.Headers = map[string]*openapi.Header{
+		"Retry-After": {Description: "Seconds until a request will be allowed", Schema: openapi.Integer()},
+	}
+	doc.Components.Responses["TooManyRequests"] = tooManyRequests
+
+	// Clients cannot set the identity and timestamps of a book
+	for _, v := range []interface{}{models.Book{}, models.BookV2{}} {
+		book := doc.Resolve(doc.Schema(v))
+		for _, name := range []string{"id", "created_at", "updated_at"} {
+			book.Properties[name].ReadOnly = true
+		}
+	}
+
+	// envelope is the models.UserResponse wrapper around data
+	envelope := func(description string, status int, data *openapi.Schema) map[string]*openapi.Response {
+		schema := openapi.Object(map[string]*openapi.Schema{
+			"status":  openapi.String(),
+			"message": openapi.String(),
+			"data":    data,
+		}, "status", "message", "data")
+		return map[string]*openapi.Response{
+			strconv.Itoa(status): {
+				Description: description,
+				Content:     map[string]*openapi.MediaType{"application/json": {Schema: schema}},
+			},
+		}
+	}
+	null := &openapi.Schema{Type: "null"}
+
+	// data is the models.Envelope of a v2 response
+	data := func(description string, status int, data *openapi.Schema) map[string]*openapi.Response {
+		schema := openapi.Object(map[string]*openapi.Schema{"data": data}, "data")
+		return map[string]*openapi.Response{
+			strconv.Itoa(status): {
+				Description: description,
+				Content:     map[string]*openapi.MediaType{"application/json": {Schema: schema}},
+			},
+		}
+	}
+	noContent := func(description string) map[string]*openapi.Response {
+		return map[string]*openapi.Response{"204": {Description: description}}
+	}
+
+	// with adds the shared error responses to an operation's responses
+	with := func(responses map[string]*openapi.Response, errors ...string) map[string]*openapi.Response {
+		for _, name := range errors {
+			responses[errorStatus[name]] = openapi.ResponseRef(name)
+		}
+		return responses
+	}
+	ok := func(description string, v interface{}) map[string]*openapi.Response {
+		return map[string]*openapi.Response{"200": doc.JSON(description, v)}
+	}
+
+	bookID := openapi.PathParam("id", "Book ID", openapi.Integer())
+	objectID := func(name, description string) *openapi.Parameter {
+		return openapi.PathParam(name, description, openapi.ObjectID())
+	}
+	page := openapi.QueryParam("page", "Page number, starting at 1", openapi.Integer())
+	limit := openapi.QueryParam("limit", "Page size", openapi.Integer())
+
+	// Operations
+	health := doc.JSON("Every check passed", models.HealthReport{})
+	unhealthy := doc.JSON("A check failed", models.HealthReport{})
+	doc.Add("GET", "/healthz", &openapi.Operation{
+		OperationID: "liveness", Summary: "Liveness probe", Tags: []string{"operations"},
+		Responses: map[string]*openapi.Response{"200": health, "503": unhealthy},
+	})
+	doc.Add("GET", "/readyz", &openapi.Operation{
+		OperationID: "readiness", Summary: "Readiness probe, including MongoDB", Tags: []string{"operations"},
+		Responses: map[string]*openapi.Response{"200": health, "503": unhealthy},
+	})
+	doc.Add("GET", "/metrics", &openapi.Operation{
+		OperationID: "metrics", Summary: "Prometheus metrics", Tags: []string{"operations"},
+		Responses: map[string]*openapi.Response{"200": {
+			Description: "Metrics in the Prometheus text format",
+			Content:     map[string]*openapi.MediaType{"text/plain": {Schema: openapi.String()}},
+		}},
+	})
+	doc.Add("GET", "/openapi.json", &openapi.Operation{
+		OperationID: "openapi", Summary: "This document", Tags: []string{"operations"},
+		Responses: map[string]*openapi.Response{"200": {
+			Description: "The OpenAPI document",
+			Content:     map[string]*openapi.MediaType{"application/json": {Schema: &openapi.Schema{Type: "object"}}},
+		}},
+	})
+	doc.Add("GET", "/docs", &openapi.Operation{
+		OperationID: "docs", Summary: "Interactive API reference", Tags: []string{"operations"},
+		Responses: map[string]*openapi.Response{"200": {
+			Description: "An HTML page rendering this document",
+			Content:     map[string]*openapi.MediaType{"text/html": {Schema: openapi.String()}},
+		}},
+	})
+
+	// Authentication
+	doc.Add("POST", "/api/v1/auth/register", &openapi.Operation{
+		OperationID: "register", Summary: "Create an account", Tags: []string{"auth"},
+		RequestBody: doc.Body(models.RegisterRequest{}),
+		Responses:   with(envelope("The new user", http.StatusCreated, doc.Schema(models.User{})), "BadRequest", "Conflict", "TooManyRequests"),
+	})
+	doc.Add("POST", "/api/v1/auth/login", &openapi.Operation{
+		OperationID: "login", Summary: "Log in with a username and password", Tags: []string{"auth"},
+		RequestBody: doc.Body(models.LoginRequest{}),
+		Responses:   with(envelope("A token for the user", http.StatusOK, doc.Schema(models.LoginResponse{})), "BadRequest", "Unauthorized", "TooManyRequests"),
+	})
+	doc.Add("GET", "/api/v1/auth/oidc/login", &openapi.Operation{
+		OperationID: "oidcLogin", Summary: "Start single sign-on", Tags: []string{"auth"},
+		Responses: with(map[string]*openapi.Response{"302": {
+			Description: "Redirect to the identity provider",
+			Headers:     map[string]*openapi.Header{"Location": {Schema: openapi.String()}},
+		}}, "NotFound", "TooManyRequests"),
+	})
+	doc.Add("GET", "/api/v1/auth/oidc/callback", &openapi.Operation{
+		OperationID: "oidcCallback", Summary: "Finish single sign-on", Tags: []string{"auth"},
+		Parameters: []*openapi.Parameter{
+			openapi.QueryParam("code", "Authorization code", openapi.String()),
+			openapi.QueryParam("state", "Login state", openapi.String()),
+			openapi.QueryParam("error", "Error reported by the identity provider", openapi.String()),
+			openapi.QueryParam("error_description", "Description of the error", openapi.String()),
+		},
+		Responses: with(envelope("A token for the user", http.StatusOK, doc.Schema(models.LoginResponse{})), "BadRequest", "Unauthorized", "Conflict", "TooManyRequests"),
+	})
+
+	// Books
+	doc.Add("GET", "/api/v1/books", &openapi.Operation{
+		OperationID: "listBooks", Summary: "List every book", Tags: []string{"books"}, Security: bearer,
+		Responses: with(ok("The catalog", []models.Book{}), "Unauthorized", "TooManyRequests"),
+	})
+	doc.Add("POST", "/api/v1/books", &openapi.Operation{
+		OperationID: "createBook", Summary: "Add a book", Tags: []string{"books"}, Security: bearer,
+		RequestBody: doc.Body(models.Book{}),
+		Responses:   with(ok("The new book", models.Response{}), "BadRequest", "Unauthorized", "TooManyRequests"),
+	})
+	doc.Add("GET", "/api/v1/books/{id}", &openapi.Operation{
+		OperationID: "getBook", Summary: "Get a book", Tags: []string{"books"}, Security: bearer,
+		Parameters: []*openapi.Parameter{bookID},
+		Responses:  with(ok("The book", models.Book{}), "BadRequest", "Unauthorized", "NotFound", "TooManyRequests"),
+	})
+	doc.Add("PATCH", "/api/v1/books/{id}", &openapi.Operation{
+		OperationID: "updateBook", Summary: "Update a book", Description: "Fields left empty keep their value.",
+		Tags: []string{"books"}, Security: bearer,
+		Parameters:  []*openapi.Parameter{bookID},
+		RequestBody: doc.Body(models.Book{}),
+		Responses:   with(ok("The updated book", models.Response{}), "BadRequest", "Unauthorized", "NotFound", "TooManyRequests"),
+	})
+	doc.Add("DELETE", "/api/v1/books/{id}", &openapi.Operation{
+		OperationID: "deleteBook", Summary: "Delete a book", Tags: []string{"books"}, Security: bearer,
+		Parameters: []*openapi.Parameter{bookID},
+		Responses:  with(ok("The deleted book", models.Response{}), "BadRequest", "Unauthorized", "NotFound", "TooManyRequests"),
+	})
+	doc.Add("GET", "/api/v1/books/{id}/history", &openapi.Operation{
+		OperationID: "bookHistory", Summary: "List the revisions of a book", Tags: []string{"books"}, Security: bearer,
+		Parameters: []*openapi.Parameter{bookID},
+		Responses:  with(ok("Revisions, oldest first", models.BookHistoryResponse{}), "BadRequest", "Unauthorized", "NotFound", "TooManyRequests"),
+	})
+	doc.Add("POST", "/api/v1/books/{id}/revert/{rev}", &openapi.Operation{
+		OperationID: "revertBook", Summary: "Restore an earlier revision of a book", Tags: []string{"books"}, Security: bearer,
+		Parameters: []*openapi.Parameter{bookID, openapi.PathParam("rev", "Revision number", openapi.Integer())},
+		Responses:  with(ok("The restored book", models.Response{}), "BadRequest", "Unauthorized", "NotFound", "TooManyRequests"),
+	})
+	doc.Add("GET", "/api/v1/events/stream", &openapi.Operation{
+		OperationID: "eventStream", Summary: "Stream catalog changes as server-sent events", Tags: []string{"books"}, Security: bearer,
+		Parameters: []*openapi.Parameter{
+			openapi.QueryParam("types", "Comma separated event types to receive", openapi.String()),
+			openapi.QueryParam("last_event_id", "Resume after this event", openapi.String()),
+			{Name: "Last-Event-ID", In: "header", Description: "Resume after this event", Schema: openapi.String()},
+		},
+		Responses: with(map[string]*openapi.Response{
+			"200": {
+				Description: "An endless stream of book.created, book.updated and book.deleted events",
+				Content:     map[string]*openapi.MediaType{"text/event-stream": {Schema: openapi.String()}},
+			},
+			"503": problemResponse("The server is shutting down"),
+		}, "BadRequest", "Unauthorized", "TooManyRequests"),
+	})
+
+	// Current user
+	doc.Add("GET", "/api/v1/me", &openapi.Operation{
+		OperationID: "getMe", Summary: "Get the current user", Tags: []string{"account"}, Security: bearer,
+		Responses: with(envelope("The current user", http.StatusOK, doc.Schema(models.User{})), "Unauthorized", "TooManyRequests"),
+	})
+	doc.Add("PATCH", "/api/v1/me", &openapi.Operation{
+		OperationID: "updateMe", Summary: "Change the username or email", Tags: []string{"account"}, Security: bearer,
+		RequestBody: doc.Body(models.UpdateProfileRequest{}),
+		Responses:   with(envelope("The updated user", http.StatusOK, doc.Schema(models.User{})), "BadRequest", "Unauthorized", "Conflict", "TooManyRequests"),
+	})
+	doc.Add("POST", "/api/v1/me/password", &openapi.Operation{
+		OperationID: "changePassword", Summary: "Change the password", Tags: []string{"account"}, Security: bearer,
+		RequestBody: doc.Body(models.ChangePasswordRequest{}),
+		Responses:   with(envelope("Password changed", http.StatusOK, null), "BadRequest", "Unauthorized", "TooManyRequests"),
+	})
+	doc.Add("GET", "/api/v1/me/sessions", &openapi.Operation{
+		OperationID: "listSessions", Summary: "List active sessions", Tags: []string{"account"}, Security: bearer,
+		Responses: with(envelope("Sessions, most recently used first", http.StatusOK, doc.Schema([]models.Session{})), "Unauthorized", "TooManyRequests"),
+	})
+	doc.Add("DELETE", "/api/v1/me/sessions/{id}", &openapi.Operation{
+		OperationID: "deleteSession", Summary: "Log out a session", Tags: []string{"account"}, Security: bearer,
+		Parameters: []*openapi.Parameter{objectID("id", "Session ID")},
+		Responses:  with(envelope("Session terminated", http.StatusOK, null), "BadRequest", "Unauthorized", "NotFound", "TooManyRequests"),
+	})
+
+	// Administration
+	userID := objectID("id", "User ID")
+	adminErrors := []string{"BadRequest", "Unauthorized", "Forbidden", "NotFound", "TooManyRequests"}
+	doc.Add("GET", "/api/v1/admin/users", &openapi.Operation{
+		OperationID: "listUsers", Summary: "List users", Tags: []string{"admin"}, Security: bearer,
+		Parameters: []*openapi.Parameter{
+			openapi.QueryParam("q", "Matched against username and email", openapi.String()),
+			openapi.QueryParam("role", "Only users with this role", &openapi.Schema{Type: "string", Enum: []string{"admin", "user"}}),
+			openapi.QueryParam("active", "Only active or deactivated users", openapi.Boolean()),
+			page, limit,
+		},
+		Responses: with(envelope("A page of users", http.StatusOK, doc.Schema(models.UserListResponse{})), "BadRequest", "Unauthorized", "Forbidden", "TooManyRequests"),
+	})
+	doc.Add("GET", "/api/v1/admin/users/{id}", &openapi.Operation{
+		OperationID: "getUser", Summary: "Get a user", Tags: []string{"admin"}, Security: bearer,
+		Parameters: []*openapi.Parameter{userID},
+		Responses:  with(envelope("The user", http.StatusOK, doc.Schema(models.User{})), adminErrors...),
+	})
+	doc.Add("DELETE", "/api/v1/admin/users/{id}", &openapi.Operation{
+		OperationID: "deleteUser", Summary: "Delete a user", Tags: []string{"admin"}, Security: bearer,
+		Parameters: []*openapi.Parameter{userID},
+		Responses:  with(envelope("User deleted", http.StatusOK, null), adminErrors...),
+	})
+	doc.Add("PATCH", "/api/v1/admin/users/{id}/role", &openapi.Operation{
+		OperationID: "updateUserRole", Summary: "Change the role of a user", Tags: []string{"admin"}, Security: bearer,
+		Parameters:  []*openapi.Parameter{userID},
+		RequestBody: doc.Body(models.UpdateRoleRequest{}),
+		Responses:   with(envelope("The updated user", http.StatusOK, doc.Schema(models.User{})), adminErrors...),
+	})
+	doc.Add("PATCH", "/api/v1/admin/users/{id}/status", &openapi.Operation{
+		OperationID: "updateUserStatus", Summary: "Activate or deactivate a user", Tags: []string{"admin"}, Security: bearer,
+		Parameters:  []*openapi.Parameter{userID},
+		RequestBody: doc.Body(models.UpdateStatusRequest{}),
+		Responses:   with(envelope("The updated user", http.StatusOK, doc.Schema(models.User{})), adminErrors...),
+	})
+	doc.Add("POST", "/api/v1/admin/users/{id}/reset-password", &openapi.Operation{
+		OperationID: "resetUserPassword", Summary: "Reset a user's password", Tags: []string{"admin"}, Security: bearer,
+		Description: "The user has to choose a new password at their next login.",
+		Parameters:  []*openapi.Parameter{userID},
+		Responses:   with(envelope("A temporary password", http.StatusOK, doc.Schema(models.PasswordResetResponse{})), adminErrors...),
+	})
+	doc.Add("GET", "/api/v1/admin/audit", &openapi.Operation{
+		OperationID: "listAuditEvents", Summary: "Query the audit log, newest first", Tags: []string{"admin"}, Security: bearer,
+		Parameters: []*openapi.Parameter{
+			openapi.QueryParam("actor", "ID of the user who made the change", openapi.ObjectID()),
+			openapi.QueryParam("target_type", "Kind of object changed, e.g. book", openapi.String()),
+			openapi.QueryParam("target", "ID of the object changed", openapi.String()),
+			openapi.QueryParam("action", "e.g. book.updated", openapi.String()),
+			openapi.QueryParam("from", "Earliest time, RFC 3339", &openapi.Schema{Type: "string", Format: "date-time"}),
+			openapi.QueryParam("to", "Latest time, RFC 3339", &openapi.Schema{Type: "string", Format: "date-time"}),
+			page, limit,
+		},
+		Responses: with(envelope("A page of audit events", http.StatusOK, doc.Schema(models.AuditListResponse{})), "BadRequest", "Unauthorized", "Forbidden", "TooManyRequests"),
+	})
+
+	// Webhooks
+	webhookID := objectID("id", "Subscription ID")
+	doc.Add("GET", "/api/v1/admin/webhooks", &openapi.Operation{
+		OperationID: "listWebhooks", Summary: "List webhook subscriptions", Tags: []string{"webhooks"}, Security: bearer,
+		Responses: with(envelope("Every subscription", http.StatusOK, doc.Schema([]models.WebhookSubscription{})), "Unauthorized", "Forbidden", "TooManyRequests"),
+	})
+	doc.Add("POST", "/api/v1/admin/webhooks", &openapi.Operation{
+		OperationID: "createWebhook", Summary: "Subscribe a URL to events", Tags: []string{"webhooks"}, Security: bearer,
+		Description: "The signing secret is generated when omitted and only returned here.",
+		RequestBody: doc.Body(models.WebhookSubscriptionRequest{}),
+		Responses:   with(envelope("The new subscription", http.StatusCreated, doc.Schema(models.WebhookSubscription{})), "BadRequest", "Unauthorized", "Forbidden", "TooManyRequests"),
+	})
+	doc.Add("GET", "/api/v1/admin/webhooks/{id}", &openapi.Operation{
+		OperationID: "getWebhook", Summary: "Get a webhook subscription", Tags: []string{"webhooks"}, Security: bearer,
+		Parameters: []*openapi.Parameter{webhookID},
+		Responses:  with(envelope("The subscription", http.StatusOK, doc.Schema(models.WebhookSubscription{})), adminErrors...),
+	})
+	doc.Add("PATCH", "/api/v1/admin/webhooks/{id}", &openapi.Operation{
+		OperationID: "updateWebhook", Summary: "Update a webhook subscription", Tags: []string{"webhooks"}, Security: bearer,
+		Description: "Fields left out keep their value.",
+		Parameters:  []*openapi.Parameter{webhookID},
+		RequestBody: &openapi.RequestBody{Required: true, Content: map[string]*openapi.MediaType{
+			"application/json": {Schema: partial(doc, models.WebhookSubscriptionRequest{})},
+		}},
+		Responses: with(envelope("The updated subscription", http.StatusOK, doc.Schema(models.WebhookSubscription{})), adminErrors...),
+	})
+	doc.Add("DELETE", "/api/v1/admin/webhooks/{id}", &openapi.Operation{
+		OperationID: "deleteWebhook", Summary: "Delete a webhook subscription", Tags: []string{"webhooks"}, Security: bearer,
+		Parameters: []*openapi.Parameter{webhookID},
+		Responses:  with(envelope("Subscription deleted", http.StatusOK, null), adminErrors...),
+	})
+	doc.Add("GET", "/api/v1/admin/webhooks/{id}/deliveries", &openapi.Operation{
+		OperationID: "listWebhookDeliveries", Summary: "List deliveries, newest first", Tags: []string{"webhooks"}, Security: bearer,
+		Parameters: []*openapi.Parameter{
+			webhookID,
+			openapi.QueryParam("status", "Only deliveries in this state", &openapi.Schema{
+				Type: "string", Enum: []string{models.DeliveryPending, models.DeliverySucceeded, models.DeliveryDead},
+			}),
+			page, limit,
+		},
+		Responses: with(envelope("A page of deliveries", http.StatusOK, doc.Schema(models.WebhookDeliveryListResponse{})), adminErrors...),
+	})
+	doc.Add("POST", "/api/v1/admin/webhooks/{id}/deliveries/{deliveryId}/retry", &openapi.Operation{
+		OperationID: "retryWebhookDelivery", Summary: "Requeue a dead-lettered delivery", Tags: []string{"webhooks"}, Security: bearer,
+		Parameters: []*openapi.Parameter{webhookID, objectID("deliveryId", "Delivery ID")},
+		Responses:  with(envelope("Delivery requeued", http.StatusAccepted, null), adminErrors...),
+	})
+	doc.Add("POST", "/api/v1/admin/webhooks/{id}/ping", &openapi.Operation{
+		OperationID: "pingWebhook", Summary: "Send a test event", Tags: []string{"webhooks"}, Security: bearer,
+		Parameters: []*openapi.Parameter{webhookID},
+		Responses:  with(envelope("The ping delivery, whether or not it succeeded", http.StatusOK, doc.Schema(models.WebhookDelivery{})), adminErrors...),
+	})
+
+	// Version 2
+	bookIDV2 := openapi.PathParam("id", "Book ID", openapi.String())
+	doc.Add("POST", "/api/v2/auth/register", &openapi.Operation{
+		OperationID: "registerV2", Summary: "Create an account", Tags: []string{"auth"},
+		RequestBody: doc.Body(models.RegisterRequest{}),
+		Responses:   with(data("The new user", http.StatusCreated, doc.Schema(models.User{})), "BadRequest", "Conflict", "TooManyRequests"),
+	})
+	doc.Add("POST", "/api/v2/auth/login", &openapi.Operation{
+		OperationID: "loginV2", Summary: "Log in with a username and password", Tags: []string{"auth"},
+		RequestBody: doc.Body(models.LoginRequest{}),
+		Responses:   with(data("A token for the user", http.StatusOK, doc.Schema(models.LoginResponse{})), "BadRequest", "Unauthorized", "TooManyRequests"),
+	})
+	doc.Add("GET", "/api/v2/books", &openapi.Operation{
+		OperationID: "listBooksV2", Summary: "List the catalog a page at a time", Tags: []string{"books"}, Security: bearer,
+		Parameters: []*openapi.Parameter{
+			openapi.QueryParam("q", "Matched against title, author and ISBN", openapi.String()),
+			openapi.QueryParam("author", "Only books by this author", openapi.String()),
+			openapi.QueryParam("genre", "Only books of this genre", openapi.String()),
+			openapi.QueryParam("language", "Only books in this language", openapi.String()),
+			page, limit,
+		},
+		Responses: with(map[string]*openapi.Response{"200": {
+			Description: "A page of books",
+			Content: map[string]*openapi.MediaType{"application/json": {Schema: openapi.Object(map[string]*openapi.Schema{
+				"data": doc.Schema([]models.BookV2{}),
+				"meta": doc.Schema(models.PageMeta{}),
+			}, "data", "meta")}},
+		}}, "BadRequest", "Unauthorized", "TooManyRequests"),
+	})
+	doc.Add("POST", "/api/v2/books", &openapi.Operation{
+		OperationID: "createBookV2", Summary: "Add a book", Tags: []string{"books"}, Security: bearer,
+		RequestBody: doc.Body(models.BookV2{}),
+		Responses: with(map[string]*openapi.Response{"201": {
+			Description: "The new book",
+			Headers:     map[string]*openapi.Header{"Location": {Description: "URL of the new book", Schema: openapi.String()}},
+			Content: map[string]*openapi.MediaType{"application/json": {Schema: openapi.Object(map[string]*openapi.Schema{
+				"data": doc.Schema(models.BookV2{}),
+			}, "data")}},
+		}}, "BadRequest", "Unauthorized", "TooManyRequests"),
+	})
+	doc.Add("GET", "/api/v2/books/{id}", &openapi.Operation{
+		OperationID: "getBookV2", Summary: "Get a book", Tags: []string{"books"}, Security: bearer,
+		Parameters: []*openapi.Parameter{bookIDV2},
+		Responses:  with(data("The book", http.StatusOK, doc.Schema(models.BookV2{})), "BadRequest", "Unauthorized", "NotFound", "TooManyRequests"),
+	})
+	doc.Add("PATCH", "/api/v2/books/{id}", &openapi.Operation{
+		OperationID: "updateBookV2", Summary: "Update a book", Description: "Fields left empty keep their value.",
+		Tags: []string{"books"}, Security: bearer,
+		Parameters:  []*openapi.Parameter{bookIDV2},
+		RequestBody: doc.Body(models.BookV2{}),
+		Responses:   with(data("The updated book", http.StatusOK, doc.Schema(models.BookV2{})), "BadRequest", "Unauthorized", "NotFound", "TooManyRequests"),
+	})
+	doc.Add("DELETE", "/api/v2/books/{id}", &openapi.Operation{
+		OperationID: "deleteBookV2", Summary: "Delete a book", Tags: []string{"books"}, Security: bearer,
+		Parameters: []*openapi.Parameter{bookIDV2},
+		Responses:  with(data("The deleted book", http.StatusOK, doc.Schema(models.BookV2{})), "BadRequest", "Unauthorized", "NotFound", "TooManyRequests"),
+	})
+	doc.Add("GET", "/api/v2/books/{id}/history", &openapi.Operation{
+		OperationID: "bookHistoryV2", Summary: "List the revisions of a book", Tags: []string{"books"}, Security: bearer,
+		Parameters: []*openapi.Parameter{bookIDV2},
+		Responses:  with(data("Revisions, oldest first", http.StatusOK, doc.Schema([]models.BookRevisionV2{})), "BadRequest", "Unauthorized", "NotFound", "TooManyRequests"),
+	})
+	doc.Add("POST", "/api/v2/books/{id}/revert/{rev}", &openapi.Operation{
+		OperationID: "revertBookV2", Summary: "Restore an earlier revision of a book", Tags: []string{"books"}, Security: bearer,
+		Parameters: []*openapi.Parameter{bookIDV2, openapi.PathParam("rev", "Revision number", openapi.Integer())},
+		Responses:  with(data("The restored book", http.StatusOK, doc.Schema(models.BookV2{})), "BadRequest", "Unauthorized", "NotFound", "TooManyRequests"),
+	})
+	doc.Add("GET", "/api/v2/me", &openapi.Operation{
+		OperationID: "getMeV2", Summary: "Get the current user", Tags: []string{"account"}, Security: bearer,
+		Responses: with(data("The current user", http.StatusOK, doc.Schema(models.User{})), "Unauthorized", "TooManyRequests"),
+	})
+	doc.Add("PATCH", "/api/v2/me", &openapi.Operation{
+		OperationID: "updateMeV2", Summary: "Change the username or email", Tags: []string{"account"}, Security: bearer,
+		RequestBody: doc.Body(models.UpdateProfileRequest{}),
+		Responses:   with(data("The updated user", http.StatusOK, doc.Schema(models.User{})), "BadRequest", "Unauthorized", "Conflict", "TooManyRequests"),
+	})
+	doc.Add("POST", "/api/v2/me/password", &openapi.Operation{
+		OperationID: "changePasswordV2", Summary: "Change the password", Tags: []string{"account"}, Security: bearer,
+		RequestBody: doc.Body(models.ChangePasswordRequest{}),
+		Responses:   with(noContent("Password changed"), "BadRequest", "Unauthorized", "TooManyRequests"),
+	})
+	doc.Add("GET", "/api/v2/me/sessions", &openapi.Operation{
+		OperationID: "listSessionsV2", Summary: "List active sessions", Tags: []string{"account"}, Security: bearer,
+		Responses: with(data("Sessions, most recently used first", http.StatusOK, doc.Schema([]models.Session{})), "Unauthorized", "TooManyRequests"),
+	})
+	doc.Add("DELETE", "/api/v2/me/sessions/{id}", &openapi.Operation{
+		OperationID: "deleteSessionV2", Summary: "Log out a session", Tags: []string{"account"}, Security: bearer,
+		Parameters: []*openapi.Parameter{objectID("id", "Session ID")},
+		Responses:  with(noContent("Session terminated"), "BadRequest", "Unauthorized", "NotFound", "TooManyRequests"),
+	})
+
+	// GraphQL
+	doc.Add("POST", "/graphql", &openapi.Operation{
+		OperationID: "graphql", Summary: "Run a GraphQL query or mutation", Tags: []string{"graphql"}, Security: bearer,
+		Description: "The schema is in internal/graphql/schema.graphql and can be introspected. " +
+			"Failed fields are listed in errors, with the status the REST API would have answered in their extensions.",
+		RequestBody: doc.Body(models.GraphQLRequest{}),
+		Responses:   with(ok("The result", models.GraphQLResponse{}), "BadRequest", "Unauthorized", "TooManyRequests"),
+	})
+
+	return doc
+}
+
+// Status codes of the shared error responses
+var errorStatus = map[string]string{
+	"BadRequest":      "400",
+	"Unauthorized":    "401",
+	"Forbidden":       "403",
+	"NotFound":        "404",
+	"Conflict":        "409",
+	"TooManyRequests": "429",
+}
+
+// partial is the schema of v for updates, where every field is optional
+func partial(doc *openapi.Document, v interface{}) *openapi.Schema {
+	schema := *doc.Resolve(doc.Schema(v))
+	schema.Required = nil
+	return &schema
+}
@@ -0,0 +1,119 @@
+package app
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/4Noyis/my-library/internal/config"
+	"github.com/4Noyis/my-library/internal/openapi"
+	"github.com/gorilla/mux"
+)
+
+var pathVariable = regexp.MustCompile(`\{([^}]+)\}`)
+
+// TestSpecCoversEveryRoute fails when a registered route is missing from the
+// OpenAPI document, or the document describes a route that does not exist
+func TestSpecCoversEveryRoute(t *testing.T) {
+	a := NewWithStorage(config.Default(config.ProfileTest), memoryStorage())
+	defer a.Close()
+
+	routed := map[string]bool{}
+	a.router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil // a path prefix holding a subrouter
+		}
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, method := range methods {
+			routed[method+" "+template] = true
+
+			op := a.spec.Operation(method, template)
+			if op == nil {
+				t.Errorf("%s %s is not in the OpenAPI document", method, template)
+				continue
+			}
+			for _, match := range pathVariable.FindAllStringSubmatch(template, -1) {
+				if !hasParameter(op, match[1], "path") {
+					t.Errorf("%s %s does not document path parameter %s", method, template, match[1])
+				}
+			}
+		}
+		return nil
+	})
+
+	for path, item := range a.spec.Paths {
+		for method := range *item {
+			if !routed[strings.ToUpper(method)+" "+path] {
+				t.Errorf("the OpenAPI document describes %s %s, which has no route", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
+
+// TestSpecReferencesResolve fails when the document refers to a component
+// it does not define
+func TestSpecReferencesResolve(t *testing.T) {
+	spec := newSpec()
+
+	var check func(where string, s *openapi.Schema)
+	check = func(where string, s *openapi.Schema) {
+		if s == nil {
+			return
+		}
+		if s.Ref != "" {
+			if spec.Resolve(s) == nil {
+				t.Errorf("%s: unresolved reference %s", where, s.Ref)
+			}
+			return
+		}
+		for name, property := range s.Properties {
+			check(where+"."+name, property)
+		}
+		check(where+"[]", s.Items)
+		if additional, ok := s.AdditionalProperties.(*openapi.Schema); ok {
+			check(where+"{}", additional)
+		}
+	}
+
+	for name, schema := range spec.Components.Schemas {
+		check(name, schema)
+	}
+	for path, item := range spec.Paths {
+		for method, op := range *item {
+			where := strings.ToUpper(method) + " " + path
+			for _, param := range op.Parameters {
+				check(where+" "+param.Name, param.Schema)
+			}
+			if op.RequestBody != nil {
+				for _, media := range op.RequestBody.Content {
+					check(where+" body", media.Schema)
+				}
+			}
+			for status, response := range op.Responses {
+				if response.Ref != "" {
+					if _, ok := spec.Components.Responses[strings.TrimPrefix(response.Ref, "#/components/responses/")]; !ok {
+						t.Errorf("%s %s: unresolved reference %s", where, status, response.Ref)
+					}
+					continue
+				}
+				for _, media := range response.Content {
+					check(where+" "+status, media.Schema)
+				}
+			}
+		}
+	}
+}
+
+func hasParameter(op *openapi.Operation, name, in string) bool {
+	for _, param := range op.Parameters {
+		if param.Name == name && param.In == in {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package app
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/4Noyis/my-library/internal/config"
+)
+
+func TestV1Deprecation(t *testing.T) {
+	cfg := config.Default(config.ProfileTest)
+	cfg.Versioning.V1Deprecated = "2026-07-01"
+	cfg.Versioning.V1Sunset = "2027-01-31"
+	a := NewWithStorage(cfg, memoryStorage())
+	defer a.Close()
+	handler := a.Handler()
+
+	cases := []struct {
+		method, path string
+		successor    string // empty when the route must not be deprecated
+	}{
+		{"GET", "/api/v1/books", "/api/v2/books"},
+		{"POST", "/api/v1/books/7/revert/2", "/api/v2/books/7/revert/2"},
+		{"POST", "/api/v1/auth/login", "/api/v2/auth/login"},
+		{"DELETE", "/api/v1/me/sessions/0123456789abcdef01234567", "/api/v2/me/sessions/0123456789abcdef01234567"},
+		// Not in v2 yet
+		{"GET", "/api/v1/admin/users", ""},
+		{"GET", "/api/v1/events/stream", ""},
+		{"GET", "/api/v1/auth/oidc/login", ""},
+		// Same path as a v2 route, other method
+		{"PUT", "/api/v1/books/7", ""},
+		{"GET", "/api/v2/books", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, nil))
+			header := rec.Header()
+
+			if tc.successor == "" {
+				for _, name := range []string{"Deprecation", "Sunset", "Link"} {
+					if v := header.Get(name); v != "" {
+						t.Errorf("%s: %q, want none", name, v)
+					}
+				}
+				return
+			}
+			// 2026-07-01T00:00:00Z
+			if got := header.Get("Deprecation"); got != "@1782864000" {
+				t.Errorf("Deprecation: %q, want @1782864000", got)
+			}
+			if got, want := header.Get("Sunset"), "Sun, 31 Jan 2027 00:00:00 GMT"; got != want {
+				t.Errorf("Sunset: %q, want %q", got, want)
+			}
+			if got, want := header.Get("Link"), "<"+tc.successor+`>; rel="successor-version"`; got != want {
+				t.Errorf("Link: %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestAPIVersionMetrics(t *testing.T) {
+	a := NewWithStorage(config.Default(config.ProfileTest), memoryStorage())
+	defer a.Close()
+	handler := a.Handler()
+
+	requests := []string{"/api/v1/books", "/api/v1/books", "/api/v1/admin/users", "/api/v2/books"}
+	before := apiVersionRequests(t, handler)
+	for _, path := range requests {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	after := apiVersionRequests(t, handler)
+
+	want := map[string]float64{
+		`superseded="true",version="v1"`:  2,
+		`superseded="false",version="v1"`: 1,
+		`superseded="false",version="v2"`: 1,
+	}
+	for labels, n := range want {
+		if got := after[labels] - before[labels]; got != n {
+			t.Errorf("api_version_requests_total{%s} grew by %v, want %v", labels, got, n)
+		}
+	}
+}
+
+// apiVersionRequests reads api_version_requests_total from /metrics, keyed
+// by labels
+func apiVersionRequests(t *testing.T, handler http.Handler) map[string]float64 {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	counts := map[string]float64{}
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		line, ok := strings.CutPrefix(scanner.Text(), "api_version_requests_total{")
+		if !ok {
+			continue
+		}
+		labels, value, _ := strings.Cut(line, "} ")
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			t.Fatalf("metric line %q: %v", scanner.Text(), err)
+		}
+		counts[labels] = n
+	}
+	return counts
+}
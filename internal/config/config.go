@@ -0,0 +1,285 @@
+package config
+
+import (
+	"time"
+)
+
+// Profiles select the built-in defaults and the profiles section of the
+// config file that is applied
+const (
+	ProfileDevelopment = "development"
+	ProfileProduction  = "production"
+	ProfileTest        = "test"
+)
+
+// Config holds every setting of the server. Each field can be set in the
+// config file (by its yaml key), by its environment variable (env tag) and by
+// a command line flag named after its dotted yaml path, e.g. -server.port.
+// Durations are Go durations ("15s"); in environment variables a bare number
+// is read in the field's unit (seconds unless the unit tag says otherwise),
+// as the variables always have been.
+type Config struct {
+	Profile    string     `yaml:"profile" env:"APP_ENV"`
+	Server     Server     `yaml:"server"`
+	Log        Log        `yaml:"log"`
+	Mongo      Mongo      `yaml:"mongo"`
+	Auth       Auth       `yaml:"auth"`
+	Password   Password   `yaml:"password"`
+	LDAP       LDAP       `yaml:"ldap"`
+	OIDC       OIDC       `yaml:"oidc"`
+	Webhooks   Webhooks   `yaml:"webhooks"`
+	Outbox     Outbox     `yaml:"outbox"`
+	Stream     Stream     `yaml:"stream"`
+	RateLimit  RateLimit  `yaml:"rate_limit"`
+	Validation Validation `yaml:"validation"`
+	Versioning Versioning `yaml:"versioning"`
+	Tracing    Tracing    `yaml:"tracing"`
+}
+
+type Server struct {
+	Port            int           `yaml:"port" env:"PORT"`
+	ReadTimeout     time.Duration `yaml:"read_timeout" env:"HTTP_READ_TIMEOUT_SECONDS"`
+	WriteTimeout    time.Duration `yaml:"write_timeout" env:"HTTP_WRITE_TIMEOUT_SECONDS"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT_SECONDS"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT_SECONDS"`
+	// Time between failing the readiness probe and closing the listener
+	ShutdownDrain time.Duration `yaml:"shutdown_drain" env:"SHUTDOWN_DRAIN_SECONDS"`
+	// Port of the gRPC API; 0 turns it off
+	GRPCPort int `yaml:"grpc_port" env:"GRPC_PORT"`
+}
+
+type Log struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL"`   // debug, info, warn, error or fatal
+	Format string `yaml:"format" env:"LOG_FORMAT"` // text or json
+}
+
+type Mongo struct {
+	URI              string        `yaml:"uri" env:"MONGO_URI" secret:"true"`
+	Database         string        `yaml:"database" env:"MONGO_DATABASE"`
+	OperationTimeout time.Duration `yaml:"operation_timeout" env:"MONGO_OPERATION_TIMEOUT_SECONDS"`
+	// Write changes and their outbox events without a transaction when the
+	// server is a standalone mongod instead of refusing to start
+	AllowStandalone bool `yaml:"allow_standalone" env:"MONGO_ALLOW_STANDALONE"`
+}
+
+type Auth struct {
+	JWTSecret string `yaml:"jwt_secret" env:"JWT_SECRET" secret:"true"`
+	// Login authenticator chain, e.g. [local, ldap]. Defaults to local,
+	// followed by LDAP when ldap.url is set.
+	Backends   []string `yaml:"backends" env:"AUTH_BACKENDS"`
+	BcryptCost int      `yaml:"bcrypt_cost" env:"BCRYPT_COST"`
+}
+
+type Password struct {
+	MinLength      int  `yaml:"min_length" env:"PASSWORD_MIN_LENGTH"`
+	MinCharClasses int  `yaml:"min_char_classes" env:"PASSWORD_MIN_CHAR_CLASSES"`
+	BreachCheck    bool `yaml:"breach_check" env:"PASSWORD_BREACH_CHECK"`
+	// Replacement for the bundled breached password list
+	BreachedPasswordsFile string `yaml:"breached_passwords_file" env:"BREACHED_PASSWORDS_FILE"`
+}
+
+type LDAP struct {
+	URL      string `yaml:"url" env:"LDAP_URL"` // ldap:// or ldaps://
+	StartTLS bool   `yaml:"start_tls" env:"LDAP_START_TLS"`
+
+	// Service account used to look users up; anonymous search when empty
+	BindDN       string `yaml:"bind_dn" env:"LDAP_BIND_DN"`
+	BindPassword string `yaml:"bind_password" env:"LDAP_BIND_PASSWORD" secret:"true"`
+
+	BaseDN            string   `yaml:"base_dn" env:"LDAP_BASE_DN"`
+	UserFilter        string   `yaml:"user_filter" env:"LDAP_USER_FILTER"` // "%s" is replaced by the escaped username
+	UsernameAttribute string   `yaml:"username_attribute" env:"LDAP_USERNAME_ATTRIBUTE"`
+	EmailAttribute    string   `yaml:"email_attribute" env:"LDAP_EMAIL_ATTRIBUTE"`
+	GroupAttribute    string   `yaml:"group_attribute" env:"LDAP_GROUP_ATTRIBUTE"`
+	AdminGroups       []string `yaml:"admin_groups" env:"LDAP_ADMIN_GROUPS"` // group DNs or common names mapped to the admin role
+
+	AutoProvision bool          `yaml:"auto_provision" env:"LDAP_AUTO_PROVISION"`
+	Timeout       time.Duration `yaml:"timeout" env:"LDAP_TIMEOUT_SECONDS"`
+
+	// Trust the directory's email attribute to link a directory user to an
+	// existing account without a local password
+	LinkByEmail bool `yaml:"link_by_email" env:"LDAP_LINK_BY_EMAIL"`
+}
+
+// OIDC single sign-on stays disabled while IssuerURL is empty
+type OIDC struct {
+	IssuerURL     string   `yaml:"issuer_url" env:"OIDC_ISSUER_URL"`
+	ClientID      string   `yaml:"client_id" env:"OIDC_CLIENT_ID"`
+	ClientSecret  string   `yaml:"client_secret" env:"OIDC_CLIENT_SECRET" secret:"true"`
+	RedirectURL   string   `yaml:"redirect_url" env:"OIDC_REDIRECT_URL"`
+	Scopes        []string `yaml:"scopes" env:"OIDC_SCOPES"`
+	GroupsClaim   string   `yaml:"groups_claim" env:"OIDC_GROUPS_CLAIM"`
+	AdminGroups   []string `yaml:"admin_groups" env:"OIDC_ADMIN_GROUPS"` // members of any of these groups get the admin role
+	AutoProvision bool     `yaml:"auto_provision" env:"OIDC_AUTO_PROVISION"`
+
+	// Link a user whose email the provider verified to an existing account
+	// without a local password
+	LinkByEmail bool `yaml:"link_by_email" env:"OIDC_LINK_BY_EMAIL"`
+}
+
+type Webhooks struct {
+	MaxAttempts  int           `yaml:"max_attempts" env:"WEBHOOK_MAX_ATTEMPTS"`
+	BaseBackoff  time.Duration `yaml:"base_backoff" env:"WEBHOOK_BASE_BACKOFF_SECONDS"`
+	MaxBackoff   time.Duration `yaml:"max_backoff" env:"WEBHOOK_MAX_BACKOFF_SECONDS"`
+	PollInterval time.Duration `yaml:"poll_interval" env:"WEBHOOK_POLL_INTERVAL_SECONDS"`
+	Timeout      time.Duration `yaml:"timeout" env:"WEBHOOK_TIMEOUT_SECONDS"`
+}
+
+type Outbox struct {
+	MaxAttempts  int           `yaml:"max_attempts" env:"OUTBOX_MAX_ATTEMPTS"`
+	BaseBackoff  time.Duration `yaml:"base_backoff" env:"OUTBOX_BASE_BACKOFF_SECONDS"`
+	MaxBackoff   time.Duration `yaml:"max_backoff" env:"OUTBOX_MAX_BACKOFF_SECONDS"`
+	PollInterval time.Duration `yaml:"poll_interval" env:"OUTBOX_POLL_INTERVAL_SECONDS"`
+	Lease        time.Duration `yaml:"lease" env:"OUTBOX_LEASE_SECONDS"`
+	Retention    time.Duration `yaml:"retention" env:"OUTBOX_RETENTION_HOURS" unit:"h"`
+}
+
+type Stream struct {
+	// Events kept for clients resuming with Last-Event-ID
+	ReplayBuffer int `yaml:"replay_buffer" env:"SSE_REPLAY_BUFFER"`
+}
+
+// RateLimit limits are requests per minute
+type RateLimit struct {
+	Enabled       bool `yaml:"enabled" env:"RATE_LIMIT_ENABLED"`
+	Anonymous     int  `yaml:"anonymous" env:"RATE_LIMIT_ANONYMOUS_PER_MINUTE"`
+	Authenticated int  `yaml:"authenticated" env:"RATE_LIMIT_AUTHENTICATED_PER_MINUTE"`
+	Staff         int  `yaml:"staff" env:"RATE_LIMIT_STAFF_PER_MINUTE"`
+	// Login, registration and OIDC login attempts per IP address
+	Auth int `yaml:"auth" env:"RATE_LIMIT_AUTH_PER_MINUTE"`
+}
+
+// Validation checks traffic against the OpenAPI document served at
+// /openapi.json
+type Validation struct {
+	// Reject requests whose parameters or body do not match the document
+	Requests bool `yaml:"requests" env:"VALIDATE_REQUESTS"`
+	// Replace responses that do not match the document with a 500. Responses
+	// are buffered to be checked, so this is meant for tests.
+	Responses bool `yaml:"responses" env:"VALIDATE_RESPONSES"`
+}
+
+// Versioning announces the retirement of API v1 on the v1 routes that have
+// a v2 successor. Dates are RFC 3339 dates or timestamps; leave them empty
+// until they are announced.
+type Versioning struct {
+	// Sent in the Deprecation header
+	V1Deprecated string `yaml:"v1_deprecated" env:"API_V1_DEPRECATED"`
+	// When v1 stops working, sent in the Sunset header
+	V1Sunset string `yaml:"v1_sunset" env:"API_V1_SUNSET"`
+}
+
+// ParseDate reads an RFC 3339 date, e.g. 2027-01-31, or timestamp. Empty is
+// the zero time.
+func ParseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// Tracing selects the span exporter. The exporter itself is configured with
+// the standard OTEL_EXPORTER_OTLP_* and OTEL_TRACES_SAMPLER* variables.
+type Tracing struct {
+	Exporter    string `yaml:"exporter" env:"OTEL_TRACES_EXPORTER"` // otlp, stdout or none
+	ServiceName string `yaml:"service_name" env:"OTEL_SERVICE_NAME"`
+}
+
+// Default returns the built-in settings of profile
+func Default(profile string) *Config {
+	cfg := &Config{
+		Profile: profile,
+		Server: Server{
+			Port:            8080,
+			GRPCPort:        9090,
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    15 * time.Second,
+			IdleTimeout:     60 * time.Second,
+			ShutdownTimeout: 30 * time.Second,
+		},
+		Log: Log{
+			Level:  "info",
+			Format: "text",
+		},
+		Mongo: Mongo{
+			Database:         "library",
+			OperationTimeout: 10 * time.Second,
+		},
+		Auth: Auth{
+			BcryptCost: 10,
+		},
+		Password: Password{
+			MinLength:      8,
+			MinCharClasses: 2,
+			BreachCheck:    true,
+		},
+		LDAP: LDAP{
+			UserFilter:        "(uid=%s)",
+			UsernameAttribute: "uid",
+			EmailAttribute:    "mail",
+			GroupAttribute:    "memberOf",
+			AutoProvision:     true,
+			Timeout:           5 * time.Second,
+		},
+		OIDC: OIDC{
+			Scopes:        []string{"profile", "email"},
+			GroupsClaim:   "groups",
+			AutoProvision: true,
+		},
+		Webhooks: Webhooks{
+			MaxAttempts:  8,
+			BaseBackoff:  30 * time.Second,
+			MaxBackoff:   time.Hour,
+			PollInterval: 5 * time.Second,
+			Timeout:      10 * time.Second,
+		},
+		Outbox: Outbox{
+			MaxAttempts:  10,
+			BaseBackoff:  5 * time.Second,
+			MaxBackoff:   10 * time.Minute,
+			PollInterval: 2 * time.Second,
+			Lease:        time.Minute,
+			Retention:    72 * time.Hour,
+		},
+		Stream: Stream{
+			ReplayBuffer: 1000,
+		},
+		RateLimit: RateLimit{
+			Enabled:       true,
+			Anonymous:     60,
+			Authenticated: 300,
+			Staff:         1200,
+			Auth:          10,
+		},
+		Validation: Validation{
+			Requests: true,
+		},
+		Tracing: Tracing{
+			Exporter:    "none",
+			ServiceName: "my-library",
+		},
+	}
+
+	switch profile {
+	case ProfileDevelopment:
+		cfg.Auth.JWTSecret = developmentJWTSecret
+		cfg.Mongo.AllowStandalone = true
+	case ProfileProduction:
+		cfg.Log.Format = "json"
+	case ProfileTest:
+		cfg.Auth.JWTSecret = developmentJWTSecret
+		cfg.Log.Level = "warn"
+		cfg.Auth.BcryptCost = 4 // bcrypt's minimum, keeps tests fast
+		cfg.Mongo.AllowStandalone = true
+		cfg.RateLimit.Enabled = false
+		cfg.Validation.Responses = true
+	}
+
+	return cfg
+}
+
+// Only ever used outside production, where a missing JWT secret is an error
+const developmentJWTSecret = "your-super-secret-jwt-key-change-in-production"
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func load(t *testing.T, args ...string) (*Config, error) {
+	t.Helper()
+	return Load(flag.NewFlagSet("test", flag.ContinueOnError), args)
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfigFile(t, `
+server:
+  port: 9000
+  read_timeout: 20s
+  idle_timeout: 90s
+mongo:
+  uri: mongodb://localhost:27017
+profiles:
+  production:
+    auth:
+      jwt_secret: from-file
+    server:
+      idle_timeout: 2m
+`)
+	t.Setenv("APP_ENV", "production")
+	t.Setenv("HTTP_READ_TIMEOUT_SECONDS", "30")
+	t.Setenv("OUTBOX_RETENTION_HOURS", "24")
+	t.Setenv("AUTH_BACKENDS", "local, ldap")
+	t.Setenv("LDAP_URL", "ldap://directory")
+	t.Setenv("LDAP_BASE_DN", "dc=example,dc=org")
+
+	cfg, err := load(t, "-config", path, "-server.port", "7000", "-rate_limit.enabled=false")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checks := []struct {
+		name      string
+		got, want interface{}
+	}{
+		{"profile", cfg.Profile, ProfileProduction},
+		{"flag over file", cfg.Server.Port, 7000},
+		{"env over file", cfg.Server.ReadTimeout, 30 * time.Second},
+		{"profile section over file", cfg.Server.IdleTimeout, 2 * time.Minute},
+		{"profile section", cfg.Auth.JWTSecret, "from-file"},
+		{"profile default", cfg.Log.Format, "json"},
+		{"transactions required in production", cfg.Mongo.AllowStandalone, false},
+		{"env in hours", cfg.Outbox.Retention, 24 * time.Hour},
+		{"env list", strings.Join(cfg.Auth.Backends, ","), "local,ldap"},
+		{"bool flag", cfg.RateLimit.Enabled, false},
+		{"default", cfg.Server.WriteTimeout, 15 * time.Second},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestLoadAppliesTheSelectedProfileSection(t *testing.T) {
+	path := writeConfigFile(t, `
+profile: development
+mongo:
+  uri: mongodb://localhost:27017
+auth:
+  jwt_secret: from-file
+profiles:
+  development:
+    log:
+      level: debug
+  production:
+    log:
+      level: warn
+`)
+
+	tests := []struct {
+		name        string
+		env         string
+		wantProfile string
+		wantLevel   string
+	}{
+		{"file's profile key", "", ProfileDevelopment, "debug"},
+		{"env over the file's profile key", ProfileProduction, ProfileProduction, "warn"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("APP_ENV", tt.env)
+
+			cfg, err := load(t, "-config", path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Profile != tt.wantProfile || cfg.Log.Level != tt.wantLevel {
+				t.Errorf("got profile %s with log level %s, want %s with %s", cfg.Profile, cfg.Log.Level, tt.wantProfile, tt.wantLevel)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsInvalidSettings(t *testing.T) {
+	t.Setenv("APP_ENV", "production")
+	t.Setenv("BCRYPT_COST", "99")
+	t.Setenv("LOG_LEVEL", "loud")
+	t.Setenv("API_V1_SUNSET", "soon")
+
+	_, err := load(t)
+	if err == nil {
+		t.Fatal("expected a validation error")
+	}
+	for _, want := range []string{"mongo.uri is required", "auth.jwt_secret is required", "auth.bcrypt_cost", "log.level", "versioning.v1_sunset"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestLoadRejectsUnknownKeys(t *testing.T) {
+	path := writeConfigFile(t, "server:\n  prot: 9000\n")
+
+	if _, err := load(t, "-config", path); err == nil || !strings.Contains(err.Error(), "prot") {
+		t.Fatalf("expected an error about the unknown key, got %v", err)
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := Default(ProfileDevelopment)
+	cfg.Mongo.URI = "mongodb://app:hunter2@db:27017/library"
+	cfg.OIDC.ClientSecret = "client-secret"
+
+	redacted := cfg.Redacted()
+	if strings.Contains(redacted.Mongo.URI, "hunter2") || !strings.Contains(redacted.Mongo.URI, "app:") {
+		t.Errorf("mongo.uri not redacted: %s", redacted.Mongo.URI)
+	}
+	if redacted.Auth.JWTSecret != "REDACTED" || redacted.OIDC.ClientSecret != "REDACTED" {
+		t.Errorf("secrets not redacted: %+v %+v", redacted.Auth, redacted.OIDC)
+	}
+	if redacted.LDAP.BindPassword != "" {
+		t.Errorf("empty secret should stay empty, got %q", redacted.LDAP.BindPassword)
+	}
+	if cfg.Auth.JWTSecret == "REDACTED" {
+		t.Error("Redacted modified the original")
+	}
+}
@@ -0,0 +1,294 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Load builds the configuration from, in increasing order of precedence, the
+// profile's defaults, the config file, the file's profiles.<profile> section,
+// environment variables and command line flags. It registers -config,
+// -profile and a flag per setting on fs and parses args into it; callers may
+// add flags of their own to fs beforehand. The result is validated.
+//
+// The profile is taken from -profile, APP_ENV, GO_ENV or the file's profile
+// key, in that order, and defaults to development. The file is named by
+// -config or CONFIG_FILE; without either only defaults, environment and flags
+// apply.
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	file := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML config file")
+	profile := fs.String("profile", "", "configuration profile: development, production or test")
+
+	overrides := map[string]string{}
+	visitFields(reflect.ValueOf(&Config{}).Elem(), "", func(field reflect.Value, info fieldInfo) {
+		if info.path == "profile" {
+			return
+		}
+		fs.Var(&overrideFlag{overrides: overrides, path: info.path, isBool: field.Kind() == reflect.Bool}, info.path, info.usage())
+	})
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	if *file != "" {
+		var err error
+		if data, err = os.ReadFile(*file); err != nil {
+			return nil, fmt.Errorf("reading config file: %w", err)
+		}
+	}
+
+	name, err := selectProfile(*profile, data)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := Default(name)
+	if data != nil {
+		if err := cfg.applyFile(data, name); err != nil {
+			return nil, fmt.Errorf("config file %s: %w", *file, err)
+		}
+	}
+	cfg.Profile = name
+
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	if err := cfg.applyOverrides(overrides); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func selectProfile(flagValue string, data []byte) (string, error) {
+	for _, name := range []string{flagValue, os.Getenv("APP_ENV"), os.Getenv("GO_ENV")} {
+		if name != "" {
+			return name, nil
+		}
+	}
+
+	if data != nil {
+		var file struct {
+			Profile string `yaml:"profile"`
+		}
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return "", fmt.Errorf("reading config file: %w", err)
+		}
+		if file.Profile != "" {
+			return file.Profile, nil
+		}
+	}
+	return ProfileDevelopment, nil
+}
+
+// applyFile overlays the settings in data, followed by the section of its
+// profiles map for profile. The file's own profile key doesn't pick the
+// section, since the environment or a flag may have chosen another profile.
+func (c *Config) applyFile(data []byte, profile string) error {
+	file := struct {
+		Config   `yaml:",inline"`
+		Profiles map[string]yaml.Node `yaml:"profiles"`
+	}{Config: *c}
+
+	if err := decodeStrict(data, &file); err != nil {
+		return err
+	}
+	*c = file.Config
+
+	section, ok := file.Profiles[profile]
+	if !ok {
+		return nil
+	}
+	data, err := yaml.Marshal(&section)
+	if err != nil {
+		return err
+	}
+	if err := decodeStrict(data, c); err != nil {
+		return fmt.Errorf("profiles.%s: %w", profile, err)
+	}
+	return nil
+}
+
+// decodeStrict rejects unknown keys, so a typo doesn't silently fall back to
+// the default
+func decodeStrict(data []byte, out interface{}) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	return decoder.Decode(out)
+}
+
+func (c *Config) applyEnv() error {
+	var errs []error
+	visitFields(reflect.ValueOf(c).Elem(), "", func(field reflect.Value, info fieldInfo) {
+		if info.env == "" {
+			return
+		}
+		// Empty variables count as unset
+		if value := os.Getenv(info.env); value != "" {
+			if err := setField(field, value, info.unit); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", info.env, err))
+			}
+		}
+	})
+	return errors.Join(errs...)
+}
+
+func (c *Config) applyOverrides(overrides map[string]string) error {
+	var errs []error
+	visitFields(reflect.ValueOf(c).Elem(), "", func(field reflect.Value, info fieldInfo) {
+		if value, ok := overrides[info.path]; ok {
+			if err := setField(field, value, info.unit); err != nil {
+				errs = append(errs, fmt.Errorf("-%s: %w", info.path, err))
+			}
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// Redacted returns a copy safe to print or log: secrets are masked and
+// passwords are removed from connection strings
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	visitFields(reflect.ValueOf(&redacted).Elem(), "", func(field reflect.Value, info fieldInfo) {
+		if !info.secret || field.String() == "" {
+			return
+		}
+		if u, err := url.Parse(field.String()); err == nil && u.User != nil {
+			field.SetString(u.Redacted())
+			return
+		}
+		field.SetString("REDACTED")
+	})
+	return &redacted
+}
+
+type fieldInfo struct {
+	path   string // dotted yaml keys, e.g. server.port
+	env    string
+	unit   time.Duration
+	secret bool
+}
+
+func (info fieldInfo) usage() string {
+	if info.env == "" {
+		return "overrides " + info.path
+	}
+	return "overrides " + info.path + " (env " + info.env + ")"
+}
+
+// visitFields calls fn for every setting in the struct v, depth first
+func visitFields(v reflect.Value, prefix string, fn func(reflect.Value, fieldInfo)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		key, _, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+		if key == "" || key == "-" {
+			continue
+		}
+
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			visitFields(field, path, fn)
+			continue
+		}
+
+		info := fieldInfo{
+			path:   path,
+			env:    sf.Tag.Get("env"),
+			unit:   time.Second,
+			secret: sf.Tag.Get("secret") == "true",
+		}
+		if sf.Tag.Get("unit") == "h" {
+			info.unit = time.Hour
+		}
+		fn(field, info)
+	}
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setField parses value into field. Lists are comma separated; durations
+// are Go durations, or a bare number in unit.
+func setField(field reflect.Value, value string, unit time.Duration) error {
+	switch {
+	case field.Type() == durationType:
+		if n, err := strconv.Atoi(value); err == nil {
+			field.SetInt(int64(time.Duration(n) * unit))
+			return nil
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		field.SetInt(int64(d))
+	case field.Kind() == reflect.String:
+		field.SetString(value)
+	case field.Kind() == reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		field.SetInt(int64(n))
+	case field.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		field.SetBool(b)
+	case field.Kind() == reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", field.Type())
+	}
+	return nil
+}
+
+// overrideFlag records a setting given on the command line, so it can be
+// applied after the file and the environment
+type overrideFlag struct {
+	overrides map[string]string
+	path      string
+	isBool    bool
+}
+
+func (f *overrideFlag) String() string {
+	if f == nil || f.overrides == nil {
+		return ""
+	}
+	return f.overrides[f.path]
+}
+
+func (f *overrideFlag) Set(value string) error {
+	f.overrides[f.path] = value
+	return nil
+}
+
+func (f *overrideFlag) IsBoolFlag() bool {
+	return f.isBool
+}
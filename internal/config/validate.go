@@ -0,0 +1,116 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Validate reports every invalid setting at once
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+	positive := func(name string, d time.Duration) {
+		check(d > 0, "%s must be positive", name)
+	}
+
+	check(oneOf(c.Profile, ProfileDevelopment, ProfileProduction, ProfileTest),
+		"profile must be development, production or test, got %q", c.Profile)
+
+	check(c.Server.Port > 0 && c.Server.Port <= 65535, "server.port must be between 1 and 65535")
+	check(c.Server.GRPCPort >= 0 && c.Server.GRPCPort <= 65535, "server.grpc_port must be between 0 and 65535")
+	check(c.Server.GRPCPort == 0 || c.Server.GRPCPort != c.Server.Port, "server.grpc_port must differ from server.port")
+	positive("server.read_timeout", c.Server.ReadTimeout)
+	positive("server.write_timeout", c.Server.WriteTimeout)
+	positive("server.idle_timeout", c.Server.IdleTimeout)
+	positive("server.shutdown_timeout", c.Server.ShutdownTimeout)
+	check(c.Server.ShutdownDrain >= 0, "server.shutdown_drain must not be negative")
+
+	check(oneOf(strings.ToLower(c.Log.Level), "debug", "info", "warn", "warning", "error", "fatal"),
+		"log.level must be debug, info, warn, error or fatal, got %q", c.Log.Level)
+	check(oneOf(strings.ToLower(c.Log.Format), "text", "json"), "log.format must be text or json, got %q", c.Log.Format)
+
+	check(c.Mongo.URI != "", "mongo.uri is required")
+	check(c.Mongo.URI == "" || strings.HasPrefix(c.Mongo.URI, "mongodb://") || strings.HasPrefix(c.Mongo.URI, "mongodb+srv://"),
+		"mongo.uri must be a mongodb:// or mongodb+srv:// connection string")
+	check(c.Mongo.Database != "", "mongo.database is required")
+	positive("mongo.operation_timeout", c.Mongo.OperationTimeout)
+
+	check(c.Auth.JWTSecret != "", "auth.jwt_secret is required")
+	if c.Profile == ProfileProduction {
+		check(c.Auth.JWTSecret != developmentJWTSecret, "auth.jwt_secret must not be the development secret in production")
+	}
+	// bcrypt.MinCost and bcrypt.MaxCost
+	check(c.Auth.BcryptCost >= 4 && c.Auth.BcryptCost <= 31, "auth.bcrypt_cost must be between 4 and 31")
+	for _, backend := range c.Auth.Backends {
+		check(oneOf(strings.ToLower(backend), "local", "ldap"), "auth.backends: unknown backend %q", backend)
+		if strings.EqualFold(backend, "ldap") {
+			check(c.LDAP.URL != "", "ldap.url is required when the ldap backend is enabled")
+		}
+	}
+
+	check(c.Password.MinLength >= 1, "password.min_length must be at least 1")
+	check(c.Password.MinCharClasses >= 0 && c.Password.MinCharClasses <= 4, "password.min_char_classes must be between 0 and 4")
+
+	if c.LDAP.URL != "" {
+		check(strings.HasPrefix(c.LDAP.URL, "ldap://") || strings.HasPrefix(c.LDAP.URL, "ldaps://"), "ldap.url must be an ldap:// or ldaps:// URL")
+		check(c.LDAP.BaseDN != "", "ldap.base_dn is required when ldap.url is set")
+		check(strings.Contains(c.LDAP.UserFilter, "%s"), "ldap.user_filter must contain %%s")
+		positive("ldap.timeout", c.LDAP.Timeout)
+	}
+
+	if c.OIDC.IssuerURL != "" {
+		check(c.OIDC.ClientID != "", "oidc.client_id is required when oidc.issuer_url is set")
+		check(c.OIDC.RedirectURL != "", "oidc.redirect_url is required when oidc.issuer_url is set")
+	}
+
+	check(c.Webhooks.MaxAttempts >= 1, "webhooks.max_attempts must be at least 1")
+	positive("webhooks.base_backoff", c.Webhooks.BaseBackoff)
+	positive("webhooks.max_backoff", c.Webhooks.MaxBackoff)
+	positive("webhooks.poll_interval", c.Webhooks.PollInterval)
+	positive("webhooks.timeout", c.Webhooks.Timeout)
+
+	check(c.Outbox.MaxAttempts >= 1, "outbox.max_attempts must be at least 1")
+	positive("outbox.base_backoff", c.Outbox.BaseBackoff)
+	positive("outbox.max_backoff", c.Outbox.MaxBackoff)
+	positive("outbox.poll_interval", c.Outbox.PollInterval)
+	positive("outbox.lease", c.Outbox.Lease)
+	positive("outbox.retention", c.Outbox.Retention)
+
+	check(c.Stream.ReplayBuffer >= 1, "stream.replay_buffer must be at least 1")
+
+	if c.RateLimit.Enabled {
+		check(c.RateLimit.Anonymous >= 1, "rate_limit.anonymous must be at least 1")
+		check(c.RateLimit.Authenticated >= 1, "rate_limit.authenticated must be at least 1")
+		check(c.RateLimit.Staff >= 1, "rate_limit.staff must be at least 1")
+		check(c.RateLimit.Auth >= 1, "rate_limit.auth must be at least 1")
+	}
+
+	deprecated, err := ParseDate(c.Versioning.V1Deprecated)
+	check(err == nil, "versioning.v1_deprecated must be an RFC 3339 date or timestamp, got %q", c.Versioning.V1Deprecated)
+	sunset, err := ParseDate(c.Versioning.V1Sunset)
+	check(err == nil, "versioning.v1_sunset must be an RFC 3339 date or timestamp, got %q", c.Versioning.V1Sunset)
+	check(sunset.IsZero() || deprecated.IsZero() || sunset.After(deprecated), "versioning.v1_sunset must be after versioning.v1_deprecated")
+
+	check(oneOf(strings.ToLower(c.Tracing.Exporter), "otlp", "stdout", "none"),
+		"tracing.exporter must be otlp, stdout or none, got %q", c.Tracing.Exporter)
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration:\n  " + strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+func oneOf(value string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
@@ -3,80 +3,156 @@ package database
 import (
 	"context"
 	"errors"
-	"os"
 	"time"
 
+	"github.com/4Noyis/my-library/internal/config"
 	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/tracing"
 	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
-var client *mongo.Client
+// DB is a connection to the library database. Repositories get their
+// collections from it, so every App has its own.
+type DB struct {
+	client   *mongo.Client
+	database string
 
-func GetClient() *mongo.Client {
-	return client
+	// Upper bound for a single repository operation, see OperationContext
+	operationTimeout time.Duration
+
+	// Multi-document transactions need a replica set or sharded cluster
+	transactionsSupported bool
 }
 
-func ConnectMongoDB() error {
-	uri := os.Getenv("MONGO_URI")
+func ConnectMongoDB(cfg config.Mongo) (*DB, error) {
+	uri := cfg.URI
 	if uri == "" {
-		return errors.New("cannot get uri address")
+		return nil, errors.New("cannot get uri address")
 	}
 
-	logger.LogDebug("Attempting to connect to MongoDB", logrus.Fields{
+	logger.LogDebug(context.Background(), "Attempting to connect to MongoDB", logrus.Fields{
 		"operation": "ConnectMongoDB",
 		"uri_set":   uri != "",
 	})
 
-	opts := options.Client().ApplyURI(uri)
+	opts := options.Client().ApplyURI(uri).SetMonitor(tracing.MongoMonitor())
 
-	Client, err := mongo.Connect(opts)
+	client, err := mongo.Connect(opts)
 	if err != nil {
-		logger.LogError("ConnectMongoDB", err, logrus.Fields{
+		logger.LogError(context.Background(), "ConnectMongoDB", err, logrus.Fields{
 			"operation": "mongo.Connect",
 		})
-		return err
+		return nil, err
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	err = Client.Ping(ctx, nil)
+	err = client.Ping(ctx, nil)
 	if err != nil {
-		logger.LogError("ConnectMongoDB", err, logrus.Fields{
+		logger.LogError(context.Background(), "ConnectMongoDB", err, logrus.Fields{
 			"operation": "ping",
 		})
-		return err
+		client.Disconnect(ctx)
+		return nil, err
 	}
 
-	client = Client
+	db := &DB{
+		client:           client,
+		database:         cfg.Database,
+		operationTimeout: cfg.OperationTimeout,
+	}
+	db.transactionsSupported = db.detectTransactions(ctx)
 
-	logger.LogInfo("Connected to MongoDB successfully", logrus.Fields{
-		"operation": "ConnectMongoDB",
-		"database":  "library",
+	logger.LogInfo(context.Background(), "Connected to MongoDB successfully", logrus.Fields{
+		"operation":    "ConnectMongoDB",
+		"database":     db.database,
+		"transactions": db.transactionsSupported,
 	})
-	return nil
+	if !db.transactionsSupported {
+		if !cfg.AllowStandalone {
+			client.Disconnect(ctx)
+			return nil, errors.New("MongoDB is standalone but transactions need a replica set; set mongo.allow_standalone to write without them")
+		}
+		logger.Logger.WithFields(logrus.Fields{
+			"operation": "ConnectMongoDB",
+			"type":      "startup",
+		}).Warn("MongoDB is standalone, changes and their outbox events are written without a transaction")
+	}
+	return db, nil
 }
 
-func DisconnectMongoDB() {
-	if client != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-
-		err := client.Disconnect(ctx)
-		if err != nil {
-			logger.LogError("DisconnectMongoDB", err, logrus.Fields{
-				"operation": "disconnect",
-			})
-		} else {
-			logger.LogInfo("Disconnected from MongoDB", logrus.Fields{
-				"operation": "DisconnectMongoDB",
-			})
-		}
+func (db *DB) detectTransactions(ctx context.Context) bool {
+	var hello struct {
+		SetName string `bson:"setName"`
+		Msg     string `bson:"msg"`
+	}
+	err := db.client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello)
+	if err != nil {
+		logger.LogError(context.Background(), "ConnectMongoDB", err, logrus.Fields{
+			"operation": "hello",
+		})
+		return false
+	}
+	return hello.SetName != "" || hello.Msg == "isdbgrid"
+}
+
+// Client returns the underlying MongoDB client
+func (db *DB) Client() *mongo.Client {
+	return db.client
+}
+
+// Ping checks that the server is reachable
+func (db *DB) Ping(ctx context.Context) error {
+	return db.client.Ping(ctx, nil)
+}
+
+// WithTransaction runs fn in a transaction; every operation that should be
+// part of it has to use the context passed to fn. On a standalone server,
+// which mongo.allow_standalone has to permit, fn runs without a transaction.
+func (db *DB) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if !db.transactionsSupported {
+		return fn(ctx)
 	}
+
+	session, err := db.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(ctx context.Context) (interface{}, error) {
+		return nil, fn(ctx)
+	})
+	return err
+}
+
+func (db *DB) Disconnect() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := db.client.Disconnect(ctx)
+	if err != nil {
+		logger.LogError(context.Background(), "DisconnectMongoDB", err, logrus.Fields{
+			"operation": "disconnect",
+		})
+	} else {
+		logger.LogInfo(context.Background(), "Disconnected from MongoDB", logrus.Fields{
+			"operation": "DisconnectMongoDB",
+		})
+	}
+}
+
+func (db *DB) Collection(collectionName string) *mongo.Collection {
+	return db.client.Database(db.database).Collection(collectionName)
 }
 
-func Collection(collectionName string) *mongo.Collection {
-	return client.Database("library").Collection(collectionName)
+// OperationContext bounds one database operation by the configured timeout.
+// ctx is normally the request context, so the operation is also cancelled
+// when the client goes away.
+func (db *DB) OperationContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, db.operationTimeout)
 }
@@ -0,0 +1,215 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// Event is anything published on the bus. EventName must not depend on the
+// receiver's fields, subscriptions are keyed by the name of the zero value.
+type Event interface {
+	EventName() string
+	Metadata() Meta
+}
+
+// Handler receives published events. Events relayed from the outbox may be
+// delivered more than once; handlers use Meta.ID to deduplicate.
+type Handler func(ctx context.Context, event Event) error
+
+// AllEvents subscribes a handler to every event
+const AllEvents = "*"
+
+// Queue size of each asynchronous subscriber. Events that find the queue
+// full are dropped and counted in event_bus_dropped_total.
+const asyncBuffer = 1024
+
+type subscriber struct {
+	name    string
+	handler Handler
+	queue   chan queuedEvent // nil for synchronous subscribers
+}
+
+type queuedEvent struct {
+	ctx   context.Context
+	event Event
+}
+
+// Bus delivers events to synchronous subscribers on the publishing goroutine
+// and to asynchronous subscribers on a dedicated goroutine each, in order.
+// A panicking subscriber is logged and never affects the publisher or the
+// other subscribers.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[string][]*subscriber
+	wg          sync.WaitGroup
+	closed      bool
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		subscribers: make(map[string][]*subscriber),
+	}
+}
+
+// Subscribe runs handler synchronously for every event called eventName
+// (or every event for AllEvents) before Deliver returns
+func (b *Bus) Subscribe(eventName, subscriberName string, handler Handler) {
+	b.add(eventName, &subscriber{name: subscriberName, handler: handler})
+}
+
+// SubscribeAsync queues events for handler and runs it on its own goroutine.
+// Its errors are only logged, so it doesn't get the outbox's retries.
+func (b *Bus) SubscribeAsync(eventName, subscriberName string, handler Handler) {
+	sub := &subscriber{
+		name:    subscriberName,
+		handler: handler,
+		queue:   make(chan queuedEvent, asyncBuffer),
+	}
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		for queued := range sub.queue {
+			_ = sub.deliver(queued.ctx, queued.event)
+		}
+	}()
+
+	b.add(eventName, sub)
+}
+
+func (b *Bus) add(eventName string, sub *subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers[eventName] = append(b.subscribers[eventName], sub)
+}
+
+// Deliver hands event to every matching subscriber and returns the errors of
+// the synchronous ones, so the caller can retry. Asynchronous subscribers
+// only have the event queued.
+func (b *Bus) Deliver(ctx context.Context, event Event) error {
+	// Queueing never blocks, so it happens under the lock that keeps Close
+	// from closing the queues. Synchronous handlers run after it's released,
+	// so a slow one doesn't hold up Subscribe and Close.
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		err := errors.New("event bus is closed")
+		logger.LogError(ctx, "PublishEvent", err, logrus.Fields{
+			"event": event.EventName(),
+		})
+		return err
+	}
+
+	var subs []*subscriber
+	for _, matching := range [][]*subscriber{b.subscribers[event.EventName()], b.subscribers[AllEvents]} {
+		for _, sub := range matching {
+			if sub.queue == nil {
+				subs = append(subs, sub)
+				continue
+			}
+			// The publisher may be done with ctx before the event is handled
+			select {
+			case sub.queue <- queuedEvent{ctx: context.WithoutCancel(ctx), event: event}:
+			default:
+				sub.drop(ctx, event)
+			}
+		}
+	}
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, sub := range subs {
+		if err := sub.deliver(ctx, event); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sub.name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Close stops accepting events and waits until asynchronous subscribers
+// have drained their queues
+func (b *Bus) Close() {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return
+	}
+	b.closed = true
+	for _, subs := range b.subscribers {
+		for _, sub := range subs {
+			if sub.queue != nil {
+				close(sub.queue)
+			}
+		}
+	}
+	b.mu.Unlock()
+
+	b.wg.Wait()
+}
+
+// drop records an event an asynchronous subscriber had no room for
+func (s *subscriber) drop(ctx context.Context, event Event) {
+	metrics.RecordDroppedEvent(s.name, event.EventName())
+	logger.Logger.WithContext(ctx).WithFields(logrus.Fields{
+		"event":      event.EventName(),
+		"event_id":   event.Metadata().ID,
+		"subscriber": s.name,
+		"type":       "event_bus",
+	}).Warn("Event subscriber queue full, event dropped")
+}
+
+func (s *subscriber) deliver(ctx context.Context, event Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Logger.WithContext(ctx).WithFields(logrus.Fields{
+				"event":      event.EventName(),
+				"event_id":   event.Metadata().ID,
+				"subscriber": s.name,
+				"panic":      fmt.Sprint(r),
+				"stack":      string(debug.Stack()),
+				"type":       "event_bus",
+			}).Error("Event subscriber panicked")
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	if err = s.handler(ctx, event); err != nil {
+		logger.LogError(ctx, "HandleEvent", err, logrus.Fields{
+			"event":      event.EventName(),
+			"event_id":   event.Metadata().ID,
+			"subscriber": s.name,
+		})
+	}
+	return err
+}
+
+// On subscribes a typed handler synchronously, e.g.
+//
+//	events.On(bus, "audit", func(ctx context.Context, e events.BookCreated) error { ... })
+func On[E Event](b *Bus, subscriberName string, handler func(context.Context, E) error) {
+	var zero E
+	b.Subscribe(zero.EventName(), subscriberName, typed(handler))
+}
+
+// OnAsync subscribes a typed handler asynchronously
+func OnAsync[E Event](b *Bus, subscriberName string, handler func(context.Context, E) error) {
+	var zero E
+	b.SubscribeAsync(zero.EventName(), subscriberName, typed(handler))
+}
+
+func typed[E Event](handler func(context.Context, E) error) Handler {
+	return func(ctx context.Context, event Event) error {
+		if e, ok := event.(E); ok {
+			return handler(ctx, e)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,194 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/models"
+)
+
+func TestMain(m *testing.M) {
+	logger.Logger.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func bookCreated(id int) BookCreated {
+	return BookCreated{Meta: NewMeta(context.Background()), Book: models.Book{ID: id}}
+}
+
+func TestSyncSubscribersRunInOrder(t *testing.T) {
+	bus := NewBus()
+	defer bus.Close()
+
+	var calls []string
+	record := func(name string) Handler {
+		return func(ctx context.Context, event Event) error {
+			calls = append(calls, name+" "+event.EventName())
+			return nil
+		}
+	}
+	bus.Subscribe(AllEvents, "audit", record("audit"))
+	On(bus, "first", func(ctx context.Context, e BookCreated) error {
+		calls = append(calls, "first book.created")
+		return nil
+	})
+	bus.Subscribe("book.created", "second", record("second"))
+	bus.Subscribe("book.deleted", "deleted", record("deleted"))
+
+	if err := bus.Deliver(context.Background(), bookCreated(1)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Handlers for the event itself come first, then those for every event,
+	// each in the order they subscribed
+	want := []string{"first book.created", "second book.created", "audit book.created"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestPanickingSubscriberIsIsolated(t *testing.T) {
+	bus := NewBus()
+
+	var syncCalls int
+	bus.Subscribe("book.created", "panics", func(ctx context.Context, event Event) error {
+		panic("boom")
+	})
+	bus.Subscribe("book.created", "fails", func(ctx context.Context, event Event) error {
+		return errors.New("index unavailable")
+	})
+	bus.Subscribe("book.created", "works", func(ctx context.Context, event Event) error {
+		syncCalls++
+		return nil
+	})
+
+	var asyncCalls []int
+	OnAsync(bus, "async panics", func(ctx context.Context, e BookCreated) error {
+		if e.Book.ID == 1 {
+			panic("boom")
+		}
+		asyncCalls = append(asyncCalls, e.Book.ID)
+		return nil
+	})
+
+	err := bus.Deliver(context.Background(), bookCreated(1))
+	if err == nil {
+		t.Fatal("failing subscribers were not reported")
+	}
+	for _, want := range []string{"panics: panic: boom", "fails: index unavailable"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("err = %q, want it to contain %q", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "works") {
+		t.Errorf("err = %q blames the working subscriber", err)
+	}
+	if syncCalls != 1 {
+		t.Errorf("subscriber after the panic ran %d times, want 1", syncCalls)
+	}
+
+	// The asynchronous subscriber's goroutine survives its panic
+	if err := bus.Deliver(context.Background(), bookCreated(2)); err == nil {
+		t.Fatal("failing subscribers were not reported")
+	}
+	bus.Close()
+	if !reflect.DeepEqual(asyncCalls, []int{2}) {
+		t.Errorf("async subscriber handled %v, want [2]", asyncCalls)
+	}
+}
+
+func TestCloseDrainsAsyncSubscribers(t *testing.T) {
+	bus := NewBus()
+
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var handled []int
+	var ctxErrs []error
+	OnAsync(bus, "slow", func(ctx context.Context, e BookCreated) error {
+		<-release
+		mu.Lock()
+		defer mu.Unlock()
+		handled = append(handled, e.Book.ID)
+		ctxErrs = append(ctxErrs, ctx.Err())
+		return nil
+	})
+
+	// The publisher's context ending doesn't cancel the queued events
+	ctx, cancel := context.WithCancel(context.Background())
+	for id := 1; id <= 3; id++ {
+		if err := bus.Deliver(ctx, bookCreated(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cancel()
+
+	closed := make(chan struct{})
+	go func() {
+		bus.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		t.Fatal("Close returned before the queue was drained")
+	default:
+	}
+	close(release)
+	<-closed
+
+	if !reflect.DeepEqual(handled, []int{1, 2, 3}) {
+		t.Errorf("handled %v, want [1 2 3] in order", handled)
+	}
+	for i, err := range ctxErrs {
+		if err != nil {
+			t.Errorf("event %d handled with a done context: %v", i+1, err)
+		}
+	}
+
+	if err := bus.Deliver(context.Background(), bookCreated(4)); err == nil {
+		t.Error("delivered after Close")
+	}
+	bus.Close()
+}
+
+func TestFullAsyncQueueDropsEvents(t *testing.T) {
+	bus := NewBus()
+
+	release := make(chan struct{})
+	var handled int
+	bus.SubscribeAsync("book.created", "stuck", func(ctx context.Context, event Event) error {
+		<-release
+		handled++
+		return nil
+	})
+	var syncCalls int
+	bus.Subscribe("book.created", "sync", func(ctx context.Context, event Event) error {
+		syncCalls++
+		return nil
+	})
+
+	// One event is taken off the queue by the blocked handler, at most
+	// asyncBuffer more wait in it, and the rest are dropped without blocking
+	// the publisher
+	total := asyncBuffer + 10
+	for id := 1; id <= total; id++ {
+		if err := bus.Deliver(context.Background(), bookCreated(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(release)
+	bus.Close()
+
+	if syncCalls != total {
+		t.Errorf("sync subscriber ran %d times, want %d", syncCalls, total)
+	}
+	if handled > asyncBuffer+1 || handled < asyncBuffer {
+		t.Errorf("async subscriber handled %d events, want %d or %d", handled, asyncBuffer, asyncBuffer+1)
+	}
+}
@@ -0,0 +1,46 @@
+package events
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+var decoders = map[string]func(bson.Raw) (Event, error){}
+
+// Register makes E decodable by Decode. Every event type that goes through
+// the outbox has to be registered.
+func Register[E Event]() {
+	var zero E
+	decoders[zero.EventName()] = func(payload bson.Raw) (Event, error) {
+		var event E
+		if err := bson.Unmarshal(payload, &event); err != nil {
+			return nil, err
+		}
+		return event, nil
+	}
+}
+
+func init() {
+	Register[BookCreated]()
+	Register[BookUpdated]()
+	Register[BookDeleted]()
+	Register[UserRegistered]()
+	Register[UserLoggedIn]()
+	Register[UserUpdated]()
+	Register[UserDeleted]()
+}
+
+// Encode serializes event for storage in the outbox
+func Encode(event Event) (bson.Raw, error) {
+	return bson.Marshal(event)
+}
+
+// Decode restores an event stored by Encode
+func Decode(name string, payload bson.Raw) (Event, error) {
+	decode, ok := decoders[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown event %q", name)
+	}
+	return decode(payload)
+}
@@ -0,0 +1,100 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Meta is carried by every event. ID is unique per event and stays the same
+// when the event is redelivered, so subscribers use it as idempotency key.
+// RequestID is the request that caused the event, for correlating logs.
+type Meta struct {
+	ID         string    `bson:"id"`
+	OccurredAt time.Time `bson:"occurred_at"`
+	RequestID  string    `bson:"request_id,omitempty"`
+}
+
+func NewMeta(ctx context.Context) Meta {
+	return Meta{
+		ID:         primitive.NewObjectID().Hex(),
+		OccurredAt: time.Now(),
+		RequestID:  logger.RequestIDFromContext(ctx),
+	}
+}
+
+func (m Meta) Metadata() Meta { return m }
+
+type BookCreated struct {
+	Meta `bson:",inline"`
+
+	Book  models.Book
+	Actor models.Actor
+}
+
+func (BookCreated) EventName() string { return "book.created" }
+
+type BookUpdated struct {
+	Meta `bson:",inline"`
+
+	Before       models.Book
+	After        models.Book
+	RevertedFrom int // revision restored by this update, 0 for regular edits
+	Actor        models.Actor
+}
+
+func (BookUpdated) EventName() string { return "book.updated" }
+
+type BookDeleted struct {
+	Meta `bson:",inline"`
+
+	Book  models.Book
+	Actor models.Actor
+}
+
+func (BookDeleted) EventName() string { return "book.deleted" }
+
+type UserRegistered struct {
+	Meta `bson:",inline"`
+
+	User models.User
+}
+
+func (UserRegistered) EventName() string { return "user.registered" }
+
+type UserLoggedIn struct {
+	Meta `bson:",inline"`
+
+	User   models.User
+	Method string // authenticator or identity provider that verified the login
+	Client models.ClientInfo
+}
+
+func (UserLoggedIn) EventName() string { return "user.logged_in" }
+
+// UserUpdated covers account changes; Action names the kind of change.
+// Before and After are nil when the change has no visible fields, such as a
+// password change.
+type UserUpdated struct {
+	Meta `bson:",inline"`
+
+	UserID primitive.ObjectID
+	Action string
+	Before *models.User
+	After  *models.User
+	Actor  models.Actor
+}
+
+func (UserUpdated) EventName() string { return "user.updated" }
+
+type UserDeleted struct {
+	Meta `bson:",inline"`
+
+	User  models.User
+	Actor models.Actor
+}
+
+func (UserDeleted) EventName() string { return "user.deleted" }
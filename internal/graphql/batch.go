@@ -0,0 +1,44 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+)
+
+// batch loads a field for a group of sibling objects, such as the history of
+// every book on a page, with one call the first time any of them asks for it.
+// The other siblings wait for that call and read their share of its result,
+// so resolving a list costs one service call per field instead of one per
+// item.
+type batch[K comparable, V any] struct {
+	keys  []K
+	fetch func(ctx context.Context, keys []K) (map[K]V, error)
+
+	once   sync.Once
+	values map[K]V
+	err    error
+}
+
+func newBatch[K comparable, V any](keys []K, fetch func(ctx context.Context, keys []K) (map[K]V, error)) *batch[K, V] {
+	return &batch[K, V]{keys: unique(keys), fetch: fetch}
+}
+
+// load returns the value of key, which must be one of the batch's keys
+func (b *batch[K, V]) load(ctx context.Context, key K) (V, error) {
+	b.once.Do(func() {
+		b.values, b.err = b.fetch(ctx, b.keys)
+	})
+	return b.values[key], b.err
+}
+
+func unique[K comparable](keys []K) []K {
+	seen := make(map[K]bool, len(keys))
+	var out []K
+	for _, key := range keys {
+		if !seen[key] {
+			seen[key] = true
+			out = append(out, key)
+		}
+	}
+	return out
+}
@@ -0,0 +1,162 @@
+// Package graphql serves the catalog and user accounts as a GraphQL API. It
+// is built on the same services as the REST handlers, so both APIs enforce the
+// same rules and record the same audit trail.
+package graphql
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/middleware"
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/4Noyis/my-library/internal/problem"
+	"github.com/4Noyis/my-library/internal/services"
+	graphql "github.com/graph-gophers/graphql-go"
+	"github.com/sirupsen/logrus"
+)
+
+//go:embed schema.graphql
+var Schema string
+
+// Services are the services behind the resolvers
+type Services struct {
+	Books *services.BookService
+	Users *services.UserService
+}
+
+// Handler executes GraphQL queries and mutations posted as JSON. It must run
+// behind AuthMiddleware: every resolver acts as the authenticated user, and
+// admin-only fields check their role like AdminMiddleware does.
+func Handler(svc Services) http.Handler {
+	schema := graphql.MustParseSchema(Schema, &resolver{svc: svc},
+		graphql.UseStringDescriptions(),
+		graphql.MaxDepth(10),
+		// Enough to resolve a full page of books side by side
+		graphql.MaxParallelism(100),
+	)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req models.GraphQLRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			problem.Write(w, r, http.StatusBadRequest, "invalid JSON")
+			return
+		}
+
+		user, ok := middleware.GetUserFromContext(r)
+		if !ok {
+			problem.Write(w, r, http.StatusInternalServerError, "User context not found")
+			return
+		}
+		ctx := context.WithValue(r.Context(), viewerKey{}, &viewer{
+			user:  user,
+			actor: actorFromRequest(r, user),
+		})
+
+		response := schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+
+		logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
+			"operation": req.OperationName,
+			"user_id":   user.ID.Hex(),
+			"errors":    len(response.Errors),
+			"type":      "graphql",
+		}).Debug("GraphQL request executed")
+
+		// Errors are part of a GraphQL response, which is sent with 200
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	})
+}
+
+type viewerKey struct{}
+
+// viewer is the authenticated user a request runs as
+type viewer struct {
+	user  *models.User
+	actor models.Actor
+}
+
+func viewerFrom(ctx context.Context) *viewer {
+	return ctx.Value(viewerKey{}).(*viewer)
+}
+
+func (v *viewer) isAdmin() bool {
+	return v.user.Role == "admin"
+}
+
+// actorFromRequest identifies the user making a change, for the audit log
+func actorFromRequest(r *http.Request, user *models.User) models.Actor {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return models.Actor{
+		UserID:    user.ID,
+		Username:  user.Username,
+		IPAddress: ip,
+		RequestID: logger.RequestIDFromContext(r.Context()),
+	}
+}
+
+var errAdminOnly = &services.Error{Kind: services.ErrForbidden, Message: "Admin access required"}
+
+// Error is a resolver failure as reported to clients. Its extensions carry
+// the status code the REST API would have answered with, and a code
+// derived from it, so clients can tell a missing book from a denied request.
+type Error struct {
+	status  int
+	message string
+}
+
+func (e *Error) Error() string {
+	return e.message
+}
+
+func (e *Error) Extensions() map[string]interface{} {
+	return map[string]interface{}{
+		"code":   errorCodes[e.status],
+		"status": e.status,
+	}
+}
+
+var errorCodes = map[int]string{
+	http.StatusBadRequest:          "BAD_REQUEST",
+	http.StatusUnauthorized:        "UNAUTHENTICATED",
+	http.StatusForbidden:           "FORBIDDEN",
+	http.StatusNotFound:            "NOT_FOUND",
+	http.StatusConflict:            "CONFLICT",
+	http.StatusBadGateway:          "UPSTREAM_FAILURE",
+	http.StatusServiceUnavailable:  "UNAVAILABLE",
+	http.StatusInternalServerError: "INTERNAL",
+}
+
+// resolverError reports the failure of operation the way problem.WriteError
+// does: errors of an unknown kind are logged and hidden behind a generic
+// message
+func resolverError(ctx context.Context, operation string, err error) error {
+	var reported *Error
+	if errors.As(err, &reported) {
+		return reported
+	}
+
+	status := problem.Status(err)
+	if status == http.StatusInternalServerError {
+		logger.LogError(ctx, operation, err, logrus.Fields{"type": "graphql"})
+		return &Error{status: status, message: "internal server error"}
+	}
+	return &Error{status: status, message: err.Error()}
+}
+
+// invalid reports a malformed argument
+func invalid(message string) error {
+	return &Error{status: http.StatusBadRequest, message: message}
+}
+
+// isNotFound reports whether err is a service error of the not found kind
+func isNotFound(err error) bool {
+	return errors.Is(err, services.ErrNotFound)
+}
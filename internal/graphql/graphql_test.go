@@ -0,0 +1,127 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/4Noyis/my-library/internal/config"
+	"github.com/4Noyis/my-library/internal/middleware"
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/4Noyis/my-library/internal/repositories/memory"
+	"github.com/4Noyis/my-library/internal/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// countingRevisions counts the revision lookups made by resolvers
+type countingRevisions struct {
+	*memory.BookRevisionRepository
+	single, batched atomic.Int32
+}
+
+func (c *countingRevisions) GetRevisions(ctx context.Context, bookID int) ([]models.BookRevision, error) {
+	c.single.Add(1)
+	return c.BookRevisionRepository.GetRevisions(ctx, bookID)
+}
+
+func (c *countingRevisions) GetRevisionsOfBooks(ctx context.Context, bookIDs []int) ([]models.BookRevision, error) {
+	c.batched.Add(1)
+	return c.BookRevisionRepository.GetRevisionsOfBooks(ctx, bookIDs)
+}
+
+// countingUsers counts the user lookups made by resolvers
+type countingUsers struct {
+	*memory.UserRepository
+	single, batched atomic.Int32
+}
+
+func (c *countingUsers) GetUserByID(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	c.single.Add(1)
+	return c.UserRepository.GetUserByID(ctx, id)
+}
+
+func (c *countingUsers) GetUsersByIDs(ctx context.Context, ids []primitive.ObjectID) ([]models.User, error) {
+	c.batched.Add(1)
+	return c.UserRepository.GetUsersByIDs(ctx, ids)
+}
+
+func TestBooksBatchHistoriesAndAuthors(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewDB()
+	outbox := services.NewOutbox(memory.NewOutboxRepository())
+	revisions := &countingRevisions{BookRevisionRepository: memory.NewBookRevisionRepository()}
+	users := &countingUsers{UserRepository: memory.NewUserRepository()}
+
+	books := services.NewBookService(db, memory.NewBookRepository(), revisions, outbox)
+	svc := Services{
+		Books: books,
+		Users: services.NewUserService(config.Default(config.ProfileTest), db, users, memory.NewSessionRepository(), outbox),
+	}
+
+	// Five books, each edited by two of three users
+	var authors []*models.User
+	for _, name := range []string{"ann", "ben", "cat"} {
+		user := &models.User{ID: primitive.NewObjectID(), Username: name, Email: name + "@example.com", Role: "user", IsActive: true}
+		if err := users.CreateUser(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+		authors = append(authors, user)
+	}
+	for i := 0; i < 5; i++ {
+		first, second := authors[i%3], authors[(i+1)%3]
+		book, err := books.AddNewBook(ctx, models.Book{Title: "Book", Pages: i + 1}, models.Actor{UserID: first.ID, Username: first.Username})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := books.UpdateBook(ctx, book.ID, models.Book{Location: "A-1"}, models.Actor{UserID: second.ID, Username: second.Username}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	body := `{"query":"{ books { books { id history { rev changedBy { username } } } } }"}`
+	req := httptest.NewRequest("POST", "/graphql", strings.NewReader(body))
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserContextKey, authors[0]))
+	w := httptest.NewRecorder()
+	Handler(svc).ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var response struct {
+		Data struct {
+			Books struct {
+				Books []struct {
+					History []struct {
+						ChangedBy *struct{ Username string }
+					}
+				}
+			}
+		}
+		Errors []interface{}
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Errors) > 0 {
+		t.Fatalf("errors: %v", response.Errors)
+	}
+	if got := len(response.Data.Books.Books); got != 5 {
+		t.Fatalf("got %d books, want 5", got)
+	}
+	for _, book := range response.Data.Books.Books {
+		if len(book.History) != 2 || book.History[0].ChangedBy == nil || book.History[1].ChangedBy == nil {
+			t.Fatalf("history not resolved: %s", w.Body)
+		}
+	}
+
+	if single, batched := revisions.single.Load(), revisions.batched.Load(); single != 0 || batched != 1 {
+		t.Errorf("revisions loaded with %d single and %d batched calls, want 0 and 1", single, batched)
+	}
+	if single, batched := users.single.Load(), users.batched.Load(); single != 0 || batched != 1 {
+		t.Errorf("users loaded with %d single and %d batched calls, want 0 and 1", single, batched)
+	}
+}
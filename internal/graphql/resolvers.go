@@ -0,0 +1,329 @@
+package graphql
+
+import (
+	"context"
+	"strings"
+
+	"github.com/4Noyis/my-library/internal/models"
+	graphql "github.com/graph-gophers/graphql-go"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// resolver is the root of the schema: its methods are the fields of Query
+// and Mutation
+type resolver struct {
+	svc Services
+}
+
+// Queries
+
+func (r *resolver) Book(ctx context.Context, args struct{ ID int32 }) (*bookResolver, error) {
+	book, err := r.svc.Books.GetOneBook(ctx, int(args.ID))
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, resolverError(ctx, "GraphQL.book", err)
+	}
+	return r.books([]models.Book{book})[0], nil
+}
+
+type bookFilter struct {
+	Search   *string
+	Author   *string
+	Genre    *string
+	Language *string
+}
+
+func (r *resolver) Books(ctx context.Context, args struct {
+	Filter *bookFilter
+	Page   int32
+	Limit  int32
+}) (*bookPageResolver, error) {
+	query := models.BookListQuery{Page: int(args.Page), Limit: int(args.Limit)}
+	if f := args.Filter; f != nil {
+		query.Search = stringValue(f.Search)
+		query.Author = stringValue(f.Author)
+		query.Genre = stringValue(f.Genre)
+		query.Language = stringValue(f.Language)
+	}
+
+	page, err := r.svc.Books.ListBooks(ctx, query)
+	if err != nil {
+		return nil, resolverError(ctx, "GraphQL.books", err)
+	}
+	return &bookPageResolver{page: page, books: r.books(page.Books)}, nil
+}
+
+func (r *resolver) Me(ctx context.Context) *userResolver {
+	v := viewerFrom(ctx)
+	return &userResolver{user: v.user, viewer: v}
+}
+
+func (r *resolver) User(ctx context.Context, args struct{ ID graphql.ID }) (*userResolver, error) {
+	v := viewerFrom(ctx)
+	if !v.isAdmin() {
+		return nil, resolverError(ctx, "GraphQL.user", errAdminOnly)
+	}
+	id, err := userID(args.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := r.svc.Users.GetUser(ctx, id)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, resolverError(ctx, "GraphQL.user", err)
+	}
+	return &userResolver{user: user, viewer: v}, nil
+}
+
+type userFilter struct {
+	Search *string
+	Role   *string
+	Active *bool
+}
+
+func (r *resolver) Users(ctx context.Context, args struct {
+	Filter *userFilter
+	Page   int32
+	Limit  int32
+}) (*userPageResolver, error) {
+	v := viewerFrom(ctx)
+	if !v.isAdmin() {
+		return nil, resolverError(ctx, "GraphQL.users", errAdminOnly)
+	}
+
+	query := models.UserListQuery{Page: int(args.Page), Limit: int(args.Limit)}
+	if f := args.Filter; f != nil {
+		query.Search = stringValue(f.Search)
+		query.Role = strings.ToLower(stringValue(f.Role))
+		query.IsActive = f.Active
+	}
+
+	page, err := r.svc.Users.ListUsers(ctx, query)
+	if err != nil {
+		return nil, resolverError(ctx, "GraphQL.users", err)
+	}
+	users := make([]*userResolver, len(page.Users))
+	for i := range page.Users {
+		users[i] = &userResolver{user: &page.Users[i], viewer: v}
+	}
+	return &userPageResolver{page: page, users: users}, nil
+}
+
+// Book mutations
+
+type bookInput struct {
+	ISBN        *string
+	Title       *string
+	Author      *string
+	Publisher   *string
+	PublishedAt *graphql.Time
+	Genre       *string
+	Language    *string
+	Pages       *int32
+	Description *string
+	CoverURL    *string
+	Location    *string
+}
+
+// book holds the fields set in input; the others are left zero, which the
+// services read as "unchanged" on update
+func (in bookInput) book() models.Book {
+	book := models.Book{
+		ISBN:        stringValue(in.ISBN),
+		Title:       stringValue(in.Title),
+		Author:      stringValue(in.Author),
+		Publisher:   stringValue(in.Publisher),
+		Genre:       stringValue(in.Genre),
+		Language:    stringValue(in.Language),
+		Pages:       intValue(in.Pages),
+		Description: stringValue(in.Description),
+		CoverURL:    stringValue(in.CoverURL),
+		Location:    stringValue(in.Location),
+	}
+	if in.PublishedAt != nil {
+		book.PublishedAt = in.PublishedAt.Time
+	}
+	return book
+}
+
+func (r *resolver) CreateBook(ctx context.Context, args struct{ Input bookInput }) (*bookResolver, error) {
+	book, err := r.svc.Books.AddNewBook(ctx, args.Input.book(), viewerFrom(ctx).actor)
+	if err != nil {
+		return nil, resolverError(ctx, "GraphQL.createBook", err)
+	}
+	return r.books([]models.Book{book})[0], nil
+}
+
+func (r *resolver) UpdateBook(ctx context.Context, args struct {
+	ID    int32
+	Input bookInput
+}) (*bookResolver, error) {
+	book, err := r.svc.Books.UpdateBook(ctx, int(args.ID), args.Input.book(), viewerFrom(ctx).actor)
+	if err != nil {
+		return nil, resolverError(ctx, "GraphQL.updateBook", err)
+	}
+	return r.books([]models.Book{book})[0], nil
+}
+
+func (r *resolver) DeleteBook(ctx context.Context, args struct{ ID int32 }) (*bookResolver, error) {
+	book, err := r.svc.Books.DeleteBook(ctx, int(args.ID), viewerFrom(ctx).actor)
+	if err != nil {
+		return nil, resolverError(ctx, "GraphQL.deleteBook", err)
+	}
+	return r.books([]models.Book{book})[0], nil
+}
+
+func (r *resolver) RevertBook(ctx context.Context, args struct {
+	ID  int32
+	Rev int32
+}) (*bookResolver, error) {
+	book, err := r.svc.Books.RevertBook(ctx, int(args.ID), int(args.Rev), viewerFrom(ctx).actor)
+	if err != nil {
+		return nil, resolverError(ctx, "GraphQL.revertBook", err)
+	}
+	return r.books([]models.Book{book})[0], nil
+}
+
+// Account mutations
+
+func (r *resolver) UpdateMe(ctx context.Context, args struct {
+	Input struct {
+		Username *string
+		Email    *string
+	}
+}) (*userResolver, error) {
+	v := viewerFrom(ctx)
+	user, err := r.svc.Users.UpdateProfile(ctx, v.actor, &models.UpdateProfileRequest{
+		Username: stringValue(args.Input.Username),
+		Email:    stringValue(args.Input.Email),
+	})
+	if err != nil {
+		return nil, resolverError(ctx, "GraphQL.updateMe", err)
+	}
+	return &userResolver{user: user, viewer: v}, nil
+}
+
+func (r *resolver) ChangePassword(ctx context.Context, args struct {
+	CurrentPassword string
+	NewPassword     string
+}) (bool, error) {
+	err := r.svc.Users.ChangePassword(ctx, viewerFrom(ctx).actor, &models.ChangePasswordRequest{
+		CurrentPassword: args.CurrentPassword,
+		NewPassword:     args.NewPassword,
+	})
+	if err != nil {
+		return false, resolverError(ctx, "GraphQL.changePassword", err)
+	}
+	return true, nil
+}
+
+// Admin mutations
+
+func (r *resolver) UpdateUserRole(ctx context.Context, args struct {
+	ID   graphql.ID
+	Role string
+}) (*userResolver, error) {
+	v, id, err := r.admin(ctx, args.ID)
+	if err != nil {
+		return nil, resolverError(ctx, "GraphQL.updateUserRole", err)
+	}
+	user, err := r.svc.Users.UpdateRole(ctx, v.actor, id, strings.ToLower(args.Role))
+	if err != nil {
+		return nil, resolverError(ctx, "GraphQL.updateUserRole", err)
+	}
+	return &userResolver{user: user, viewer: v}, nil
+}
+
+func (r *resolver) SetUserActive(ctx context.Context, args struct {
+	ID     graphql.ID
+	Active bool
+}) (*userResolver, error) {
+	v, id, err := r.admin(ctx, args.ID)
+	if err != nil {
+		return nil, resolverError(ctx, "GraphQL.setUserActive", err)
+	}
+	user, err := r.svc.Users.SetActive(ctx, v.actor, id, args.Active)
+	if err != nil {
+		return nil, resolverError(ctx, "GraphQL.setUserActive", err)
+	}
+	return &userResolver{user: user, viewer: v}, nil
+}
+
+func (r *resolver) ResetUserPassword(ctx context.Context, args struct{ ID graphql.ID }) (string, error) {
+	v, id, err := r.admin(ctx, args.ID)
+	if err != nil {
+		return "", resolverError(ctx, "GraphQL.resetUserPassword", err)
+	}
+	password, err := r.svc.Users.ForcePasswordReset(ctx, v.actor, id)
+	if err != nil {
+		return "", resolverError(ctx, "GraphQL.resetUserPassword", err)
+	}
+	return password, nil
+}
+
+func (r *resolver) DeleteUser(ctx context.Context, args struct{ ID graphql.ID }) (bool, error) {
+	v, id, err := r.admin(ctx, args.ID)
+	if err != nil {
+		return false, resolverError(ctx, "GraphQL.deleteUser", err)
+	}
+	if err := r.svc.Users.DeleteUser(ctx, v.actor, id); err != nil {
+		return false, resolverError(ctx, "GraphQL.deleteUser", err)
+	}
+	return true, nil
+}
+
+// admin checks that the viewer is an admin and parses the ID of the user
+// they act on
+func (r *resolver) admin(ctx context.Context, id graphql.ID) (*viewer, primitive.ObjectID, error) {
+	v := viewerFrom(ctx)
+	if !v.isAdmin() {
+		return nil, primitive.NilObjectID, errAdminOnly
+	}
+	userID, err := userID(id)
+	if err != nil {
+		return nil, primitive.NilObjectID, err
+	}
+	return v, userID, nil
+}
+
+// books wraps a list of sibling books, which load their histories together
+func (r *resolver) books(books []models.Book) []*bookResolver {
+	ids := make([]int, len(books))
+	for i, book := range books {
+		ids[i] = book.ID
+	}
+	group := &bookGroup{svc: r.svc, histories: newBatch(ids, r.svc.Books.GetBookHistories)}
+
+	resolvers := make([]*bookResolver, len(books))
+	for i := range books {
+		resolvers[i] = &bookResolver{book: books[i], group: group}
+	}
+	return resolvers
+}
+
+func userID(id graphql.ID) (primitive.ObjectID, error) {
+	oid, err := primitive.ObjectIDFromHex(string(id))
+	if err != nil {
+		return primitive.NilObjectID, invalid("invalid id format")
+	}
+	return oid, nil
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func intValue(n *int32) int {
+	if n == nil {
+		return 0
+	}
+	return int(*n)
+}
@@ -0,0 +1,223 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/models"
+	graphql "github.com/graph-gophers/graphql-go"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// bookGroup is the state shared by sibling books, e.g. the books of one page
+type bookGroup struct {
+	svc       Services
+	histories *batch[int, []models.BookRevision]
+
+	authorsOnce sync.Once
+	authors     *batch[primitive.ObjectID, *models.User]
+}
+
+// authorsOf returns the batch of the users who made the revisions loaded for
+// the group, created once every history is in
+func (g *bookGroup) authorsOf() *batch[primitive.ObjectID, *models.User] {
+	g.authorsOnce.Do(func() {
+		var ids []primitive.ObjectID
+		for _, history := range g.histories.values {
+			for _, revision := range history {
+				if !revision.ActorID.IsZero() {
+					ids = append(ids, revision.ActorID)
+				}
+			}
+		}
+		g.authors = newBatch(ids, g.svc.Users.GetUsers)
+	})
+	return g.authors
+}
+
+type bookResolver struct {
+	book  models.Book
+	group *bookGroup
+}
+
+func (b *bookResolver) ID() int32           { return int32(b.book.ID) }
+func (b *bookResolver) ISBN() string        { return b.book.ISBN }
+func (b *bookResolver) Title() string       { return b.book.Title }
+func (b *bookResolver) Author() string      { return b.book.Author }
+func (b *bookResolver) Publisher() string   { return b.book.Publisher }
+func (b *bookResolver) Genre() string       { return b.book.Genre }
+func (b *bookResolver) Language() string    { return b.book.Language }
+func (b *bookResolver) Pages() int32        { return int32(b.book.Pages) }
+func (b *bookResolver) Description() string { return b.book.Description }
+func (b *bookResolver) CoverURL() string    { return b.book.CoverURL }
+func (b *bookResolver) Location() string    { return b.book.Location }
+
+func (b *bookResolver) PublishedAt() *graphql.Time {
+	return optionalTime(b.book.PublishedAt)
+}
+
+func (b *bookResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: b.book.CreatedAt}
+}
+
+func (b *bookResolver) UpdatedAt() graphql.Time {
+	return graphql.Time{Time: b.book.UpdatedAt}
+}
+
+func (b *bookResolver) History(ctx context.Context) ([]*revisionResolver, error) {
+	history, err := b.group.histories.load(ctx, b.book.ID)
+	if err != nil {
+		return nil, resolverError(ctx, "GraphQL.Book.history", err)
+	}
+
+	authors := b.group.authorsOf()
+	resolvers := make([]*revisionResolver, len(history))
+	for i := range history {
+		resolvers[i] = &revisionResolver{revision: history[i], authors: authors}
+	}
+	return resolvers, nil
+}
+
+type revisionResolver struct {
+	revision models.BookRevision
+	authors  *batch[primitive.ObjectID, *models.User]
+}
+
+func (r *revisionResolver) Rev() int32 { return int32(r.revision.Rev) }
+
+func (r *revisionResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: r.revision.CreatedAt}
+}
+
+func (r *revisionResolver) ChangedBy(ctx context.Context) (*userResolver, error) {
+	if r.revision.ActorID.IsZero() {
+		return nil, nil
+	}
+	user, err := r.authors.load(ctx, r.revision.ActorID)
+	if err != nil {
+		return nil, resolverError(ctx, "GraphQL.BookRevision.changedBy", err)
+	}
+	if user == nil {
+		return nil, nil
+	}
+	return &userResolver{user: user, viewer: viewerFrom(ctx)}, nil
+}
+
+func (r *revisionResolver) ChangedByUsername() string { return r.revision.ActorUsername }
+
+func (r *revisionResolver) RevertedFrom() *int32 {
+	if r.revision.RevertedFrom == 0 {
+		return nil
+	}
+	rev := int32(r.revision.RevertedFrom)
+	return &rev
+}
+
+// Changes lists the changed fields in alphabetical order
+func (r *revisionResolver) Changes() []*fieldChangeResolver {
+	fields := make([]string, 0, len(r.revision.Changes))
+	for field := range r.revision.Changes {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	changes := make([]*fieldChangeResolver, len(fields))
+	for i, field := range fields {
+		changes[i] = &fieldChangeResolver{field: field, change: r.revision.Changes[field]}
+	}
+	return changes
+}
+
+type fieldChangeResolver struct {
+	field  string
+	change models.FieldChange
+}
+
+func (c *fieldChangeResolver) Field() string   { return c.field }
+func (c *fieldChangeResolver) Before() *string { return formatValue(c.change.Before) }
+func (c *fieldChangeResolver) After() *string  { return formatValue(c.change.After) }
+
+// formatValue renders a changed field value as a string, times in RFC 3339.
+// Unset times are null.
+func formatValue(value interface{}) *string {
+	var s string
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		s = v
+	case time.Time:
+		if v.IsZero() {
+			return nil
+		}
+		s = v.Format(time.RFC3339)
+	default:
+		s = fmt.Sprint(v)
+	}
+	return &s
+}
+
+type userResolver struct {
+	user   *models.User
+	viewer *viewer
+}
+
+func (u *userResolver) ID() graphql.ID           { return graphql.ID(u.user.ID.Hex()) }
+func (u *userResolver) Username() string         { return u.user.Username }
+func (u *userResolver) Role() string             { return strings.ToUpper(u.user.Role) }
+func (u *userResolver) IsActive() bool           { return u.user.IsActive }
+func (u *userResolver) MustChangePassword() bool { return u.user.MustChangePassword }
+
+// Email is private to the user and to admins
+func (u *userResolver) Email() *string {
+	if u.viewer.user.ID != u.user.ID && !u.viewer.isAdmin() {
+		return nil
+	}
+	return &u.user.Email
+}
+
+func (u *userResolver) AuthProvider() *string {
+	if u.user.AuthProvider == "" {
+		return nil
+	}
+	return &u.user.AuthProvider
+}
+
+func (u *userResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: u.user.CreatedAt}
+}
+
+func (u *userResolver) UpdatedAt() graphql.Time {
+	return graphql.Time{Time: u.user.UpdatedAt}
+}
+
+type bookPageResolver struct {
+	page  *models.BookListResponse
+	books []*bookResolver
+}
+
+func (p *bookPageResolver) Books() []*bookResolver { return p.books }
+func (p *bookPageResolver) Total() int32           { return int32(p.page.Total) }
+func (p *bookPageResolver) Page() int32            { return int32(p.page.Page) }
+func (p *bookPageResolver) Limit() int32           { return int32(p.page.Limit) }
+
+type userPageResolver struct {
+	page  *models.UserListResponse
+	users []*userResolver
+}
+
+func (p *userPageResolver) Users() []*userResolver { return p.users }
+func (p *userPageResolver) Total() int32           { return int32(p.page.Total) }
+func (p *userPageResolver) Page() int32            { return int32(p.page.Page) }
+func (p *userPageResolver) Limit() int32           { return int32(p.page.Limit) }
+
+func optionalTime(t time.Time) *graphql.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &graphql.Time{Time: t}
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/middleware"
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/4Noyis/my-library/internal/problem"
+	"github.com/sirupsen/logrus"
+)
+
+func (h *Handlers) GetMeHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	currentUser, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		problem.Write(w, r, http.StatusInternalServerError, "User context not found")
+		return
+	}
+
+	user, err := h.userService.GetUser(r.Context(), currentUser.ID)
+	if err != nil {
+		logger.LogError(r.Context(), "GetMe", err, logrus.Fields{
+			"handler": "GetMeHandler",
+			"user_id": currentUser.ID.Hex(),
+		})
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	writeUserResponse(w, http.StatusOK, "success", "User retrieved successfully", user)
+}
+
+func (h *Handlers) UpdateMeHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	currentUser, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		problem.Write(w, r, http.StatusInternalServerError, "User context not found")
+		return
+	}
+
+	var req models.UpdateProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
+			"error": err.Error(),
+			"type":  "validation",
+		}).Error("Invalid request body for profile update")
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.userService.UpdateProfile(r.Context(), actorFromRequest(r), &req)
+	if err != nil {
+		logger.LogError(r.Context(), "UpdateMe", err, logrus.Fields{
+			"handler": "UpdateMeHandler",
+			"user_id": currentUser.ID.Hex(),
+		})
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
+		"user_id":  user.ID.Hex(),
+		"username": user.Username,
+		"type":     "account",
+	}).Info("User profile updated")
+
+	writeUserResponse(w, http.StatusOK, "success", "Profile updated successfully", user)
+}
+
+func (h *Handlers) ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	currentUser, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		problem.Write(w, r, http.StatusInternalServerError, "User context not found")
+		return
+	}
+
+	var req models.ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
+			"error": err.Error(),
+			"type":  "validation",
+		}).Error("Invalid request body for password change")
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.userService.ChangePassword(r.Context(), actorFromRequest(r), &req); err != nil {
+		logger.LogError(r.Context(), "ChangePassword", err, logrus.Fields{
+			"handler": "ChangePasswordHandler",
+			"user_id": currentUser.ID.Hex(),
+		})
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
+		"user_id":  currentUser.ID.Hex(),
+		"username": currentUser.Username,
+		"type":     "account",
+	}).Info("User password changed")
+
+	writeUserResponse(w, http.StatusOK, "success", "Password changed successfully", nil)
+}
+
+func (h *Handlers) ListSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	currentUser, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		problem.Write(w, r, http.StatusInternalServerError, "User context not found")
+		return
+	}
+	currentSession, _ := middleware.GetSessionFromContext(r)
+
+	sessions, err := h.userService.ListSessions(r.Context(), currentUser.ID, currentSession.ID)
+	if err != nil {
+		logger.LogError(r.Context(), "ListSessions", err, logrus.Fields{
+			"handler": "ListSessionsHandler",
+			"user_id": currentUser.ID.Hex(),
+		})
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	writeUserResponse(w, http.StatusOK, "success", "Sessions retrieved successfully", sessions)
+}
+
+func (h *Handlers) DeleteSessionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	currentUser, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		problem.Write(w, r, http.StatusInternalServerError, "User context not found")
+		return
+	}
+
+	sessionID, ok := parseObjectID(r, "id")
+	if !ok {
+		problem.Write(w, r, http.StatusBadRequest, "invalid id format")
+		return
+	}
+
+	if err := h.userService.RevokeSession(r.Context(), currentUser.ID, sessionID); err != nil {
+		logger.LogError(r.Context(), "RevokeSession", err, logrus.Fields{
+			"handler":    "DeleteSessionHandler",
+			"user_id":    currentUser.ID.Hex(),
+			"session_id": sessionID.Hex(),
+		})
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
+		"user_id":    currentUser.ID.Hex(),
+		"session_id": sessionID.Hex(),
+		"type":       "account",
+	}).Info("Session terminated")
+
+	writeUserResponse(w, http.StatusOK, "success", "Session terminated successfully", nil)
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/middleware"
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/4Noyis/my-library/internal/problem"
+	"github.com/sirupsen/logrus"
+)
+
+func (h *Handlers) RegisterV2Handler(w http.ResponseWriter, r *http.Request) {
+	var req models.RegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.userService.RegisterUser(r.Context(), &req)
+	if err != nil {
+		logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
+			"error":    err.Error(),
+			"username": req.Username,
+			"email":    req.Email,
+			"type":     "registration",
+		}).Error("User registration failed")
+
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
+		"user_id":  user.ID.Hex(),
+		"username": user.Username,
+		"email":    user.Email,
+		"type":     "registration",
+	}).Info("User registered successfully")
+
+	writeData(w, http.StatusCreated, user, nil)
+}
+
+func (h *Handlers) LoginV2Handler(w http.ResponseWriter, r *http.Request) {
+	var req models.LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	loginResponse, err := h.userService.LoginUser(r.Context(), &req, clientInfo(r))
+	if err != nil {
+		logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
+			"error":    err.Error(),
+			"username": req.Username,
+			"type":     "login",
+		}).Error("User login failed")
+
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
+		"user_id":  loginResponse.User.ID.Hex(),
+		"username": loginResponse.User.Username,
+		"type":     "login",
+	}).Info("User logged in successfully")
+
+	writeData(w, http.StatusOK, loginResponse, nil)
+}
+
+func (h *Handlers) GetMeV2Handler(w http.ResponseWriter, r *http.Request) {
+	currentUser, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		problem.Write(w, r, http.StatusInternalServerError, "User context not found")
+		return
+	}
+
+	user, err := h.userService.GetUser(r.Context(), currentUser.ID)
+	if err != nil {
+		logger.LogError(r.Context(), "GetMe", err, logrus.Fields{
+			"handler": "GetMeV2Handler",
+			"user_id": currentUser.ID.Hex(),
+		})
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	writeData(w, http.StatusOK, user, nil)
+}
+
+func (h *Handlers) UpdateMeV2Handler(w http.ResponseWriter, r *http.Request) {
+	currentUser, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		problem.Write(w, r, http.StatusInternalServerError, "User context not found")
+		return
+	}
+
+	var req models.UpdateProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.userService.UpdateProfile(r.Context(), actorFromRequest(r), &req)
+	if err != nil {
+		logger.LogError(r.Context(), "UpdateMe", err, logrus.Fields{
+			"handler": "UpdateMeV2Handler",
+			"user_id": currentUser.ID.Hex(),
+		})
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
+		"user_id":  user.ID.Hex(),
+		"username": user.Username,
+		"type":     "account",
+	}).Info("User profile updated")
+
+	writeData(w, http.StatusOK, user, nil)
+}
+
+// ChangePasswordV2Handler answers 204: there is nothing to return
+func (h *Handlers) ChangePasswordV2Handler(w http.ResponseWriter, r *http.Request) {
+	currentUser, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		problem.Write(w, r, http.StatusInternalServerError, "User context not found")
+		return
+	}
+
+	var req models.ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.userService.ChangePassword(r.Context(), actorFromRequest(r), &req); err != nil {
+		logger.LogError(r.Context(), "ChangePassword", err, logrus.Fields{
+			"handler": "ChangePasswordV2Handler",
+			"user_id": currentUser.ID.Hex(),
+		})
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
+		"user_id":  currentUser.ID.Hex(),
+		"username": currentUser.Username,
+		"type":     "account",
+	}).Info("User password changed")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handlers) ListSessionsV2Handler(w http.ResponseWriter, r *http.Request) {
+	currentUser, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		problem.Write(w, r, http.StatusInternalServerError, "User context not found")
+		return
+	}
+	currentSession, _ := middleware.GetSessionFromContext(r)
+
+	sessions, err := h.userService.ListSessions(r.Context(), currentUser.ID, currentSession.ID)
+	if err != nil {
+		logger.LogError(r.Context(), "ListSessions", err, logrus.Fields{
+			"handler": "ListSessionsV2Handler",
+			"user_id": currentUser.ID.Hex(),
+		})
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	if sessions == nil {
+		sessions = []models.Session{}
+	}
+	writeData(w, http.StatusOK, sessions, nil)
+}
+
+// DeleteSessionV2Handler answers 204: there is nothing to return
+func (h *Handlers) DeleteSessionV2Handler(w http.ResponseWriter, r *http.Request) {
+	currentUser, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		problem.Write(w, r, http.StatusInternalServerError, "User context not found")
+		return
+	}
+
+	sessionID, ok := parseObjectID(r, "id")
+	if !ok {
+		problem.Write(w, r, http.StatusBadRequest, "invalid id format")
+		return
+	}
+
+	if err := h.userService.RevokeSession(r.Context(), currentUser.ID, sessionID); err != nil {
+		logger.LogError(r.Context(), "RevokeSession", err, logrus.Fields{
+			"handler":    "DeleteSessionV2Handler",
+			"user_id":    currentUser.ID.Hex(),
+			"session_id": sessionID.Hex(),
+		})
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
+		"user_id":    currentUser.ID.Hex(),
+		"session_id": sessionID.Hex(),
+		"type":       "account",
+	}).Info("Session terminated")
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/middleware"
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/4Noyis/my-library/internal/problem"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// parseUserID reads the {id} path variable as a MongoDB ObjectID
+func parseUserID(r *http.Request) (primitive.ObjectID, bool) {
+	return parseObjectID(r, "id")
+}
+
+// parseObjectID reads a path variable as a MongoDB ObjectID
+func parseObjectID(r *http.Request, name string) (primitive.ObjectID, bool) {
+	id, err := primitive.ObjectIDFromHex(mux.Vars(r)[name])
+	if err != nil {
+		return primitive.NilObjectID, false
+	}
+	return id, true
+}
+
+func (h *Handlers) ListUsersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.URL.Query()
+	query := models.UserListQuery{
+		Search: params.Get("q"),
+		Role:   params.Get("role"),
+	}
+
+	if v := params.Get("active"); v != "" {
+		active, err := strconv.ParseBool(v)
+		if err != nil {
+			problem.Write(w, r, http.StatusBadRequest, "invalid active filter")
+			return
+		}
+		query.IsActive = &active
+	}
+	if v := params.Get("page"); v != "" {
+		page, err := strconv.Atoi(v)
+		if err != nil {
+			problem.Write(w, r, http.StatusBadRequest, "invalid page")
+			return
+		}
+		query.Page = page
+	}
+	if v := params.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			problem.Write(w, r, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		query.Limit = limit
+	}
+
+	result, err := h.userService.ListUsers(r.Context(), query)
+	if err != nil {
+		logger.LogError(r.Context(), "ListUsers", err, logrus.Fields{
+			"handler": "ListUsersHandler",
+		})
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	logger.LogDebug(r.Context(), "Retrieved users", logrus.Fields{
+		"handler": "ListUsersHandler",
+		"count":   len(result.Users),
+		"total":   result.Total,
+	})
+
+	writeUserResponse(w, http.StatusOK, "success", "Users retrieved successfully", result)
+}
+
+func (h *Handlers) GetUserHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, ok := parseUserID(r)
+	if !ok {
+		problem.Write(w, r, http.StatusBadRequest, "invalid id format")
+		return
+	}
+
+	user, err := h.userService.GetUser(r.Context(), id)
+	if err != nil {
+		logger.LogError(r.Context(), "GetUser", err, logrus.Fields{
+			"handler": "GetUserHandler",
+			"id":      id.Hex(),
+		})
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	writeUserResponse(w, http.StatusOK, "success", "User retrieved successfully", user)
+}
+
+func (h *Handlers) UpdateUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	admin, _ := middleware.GetUserFromContext(r)
+	id, ok := parseUserID(r)
+	if !ok {
+		problem.Write(w, r, http.StatusBadRequest, "invalid id format")
+		return
+	}
+
+	var req models.UpdateRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.userService.UpdateRole(r.Context(), actorFromRequest(r), id, req.Role)
+	if err != nil {
+		logger.LogError(r.Context(), "UpdateUserRole", err, logrus.Fields{
+			"handler": "UpdateUserRoleHandler",
+			"id":      id.Hex(),
+			"role":    req.Role,
+		})
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
+		"admin_id": admin.ID.Hex(),
+		"user_id":  user.ID.Hex(),
+		"role":     user.Role,
+		"type":     "admin",
+	}).Info("User role updated")
+
+	writeUserResponse(w, http.StatusOK, "success", "User role updated successfully", user)
+}
+
+func (h *Handlers) UpdateUserStatusHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	admin, _ := middleware.GetUserFromContext(r)
+	id, ok := parseUserID(r)
+	if !ok {
+		problem.Write(w, r, http.StatusBadRequest, "invalid id format")
+		return
+	}
+
+	var req models.UpdateStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.IsActive == nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.userService.SetActive(r.Context(), actorFromRequest(r), id, *req.IsActive)
+	if err != nil {
+		logger.LogError(r.Context(), "UpdateUserStatus", err, logrus.Fields{
+			"handler":   "UpdateUserStatusHandler",
+			"id":        id.Hex(),
+			"is_active": *req.IsActive,
+		})
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
+		"admin_id":  admin.ID.Hex(),
+		"user_id":   user.ID.Hex(),
+		"is_active": user.IsActive,
+		"type":      "admin",
+	}).Info("User status updated")
+
+	writeUserResponse(w, http.StatusOK, "success", "User status updated successfully", user)
+}
+
+func (h *Handlers) ResetUserPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	admin, _ := middleware.GetUserFromContext(r)
+	id, ok := parseUserID(r)
+	if !ok {
+		problem.Write(w, r, http.StatusBadRequest, "invalid id format")
+		return
+	}
+
+	tempPassword, err := h.userService.ForcePasswordReset(r.Context(), actorFromRequest(r), id)
+	if err != nil {
+		logger.LogError(r.Context(), "ResetUserPassword", err, logrus.Fields{
+			"handler": "ResetUserPasswordHandler",
+			"id":      id.Hex(),
+		})
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
+		"admin_id": admin.ID.Hex(),
+		"user_id":  id.Hex(),
+		"type":     "admin",
+	}).Info("User password reset forced")
+
+	writeUserResponse(w, http.StatusOK, "success", "Password reset successfully", models.PasswordResetResponse{
+		TemporaryPassword: tempPassword,
+	})
+}
+
+func (h *Handlers) DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	admin, _ := middleware.GetUserFromContext(r)
+	id, ok := parseUserID(r)
+	if !ok {
+		problem.Write(w, r, http.StatusBadRequest, "invalid id format")
+		return
+	}
+
+	if err := h.userService.DeleteUser(r.Context(), actorFromRequest(r), id); err != nil {
+		logger.LogError(r.Context(), "DeleteUser", err, logrus.Fields{
+			"handler": "DeleteUserHandler",
+			"id":      id.Hex(),
+		})
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
+		"admin_id": admin.ID.Hex(),
+		"user_id":  id.Hex(),
+		"type":     "admin",
+	}).Info("User deleted")
+
+	writeUserResponse(w, http.StatusOK, "success", "User deleted successfully", nil)
+}
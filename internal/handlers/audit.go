@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/4Noyis/my-library/internal/problem"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ListAuditEventsHandler queries the audit log, newest first. Supported
+// filters: actor, target_type, target, action, from and to (RFC 3339).
+func (h *Handlers) ListAuditEventsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	params := r.URL.Query()
+	query := models.AuditQuery{
+		TargetType: params.Get("target_type"),
+		TargetID:   params.Get("target"),
+		Action:     params.Get("action"),
+	}
+
+	if v := params.Get("actor"); v != "" {
+		actorID, err := primitive.ObjectIDFromHex(v)
+		if err != nil {
+			problem.Write(w, r, http.StatusBadRequest, "invalid actor id")
+			return
+		}
+		query.ActorID = &actorID
+	}
+
+	var err error
+	if v := params.Get("from"); v != "" {
+		if query.From, err = time.Parse(time.RFC3339, v); err != nil {
+			problem.Write(w, r, http.StatusBadRequest, "invalid from time, expected RFC 3339")
+			return
+		}
+	}
+	if v := params.Get("to"); v != "" {
+		if query.To, err = time.Parse(time.RFC3339, v); err != nil {
+			problem.Write(w, r, http.StatusBadRequest, "invalid to time, expected RFC 3339")
+			return
+		}
+	}
+	if v := params.Get("page"); v != "" {
+		if query.Page, err = strconv.Atoi(v); err != nil {
+			problem.Write(w, r, http.StatusBadRequest, "invalid page")
+			return
+		}
+	}
+	if v := params.Get("limit"); v != "" {
+		if query.Limit, err = strconv.Atoi(v); err != nil {
+			problem.Write(w, r, http.StatusBadRequest, "invalid limit")
+			return
+		}
+	}
+
+	result, err := h.auditService.ListEvents(r.Context(), query)
+	if err != nil {
+		logger.LogError(r.Context(), "ListAuditEvents", err, logrus.Fields{
+			"handler": "ListAuditEventsHandler",
+		})
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	writeUserResponse(w, http.StatusOK, "success", "Audit events retrieved successfully", result)
+}
@@ -7,66 +7,68 @@ import (
 
 	"github.com/4Noyis/my-library/internal/logger"
 	"github.com/4Noyis/my-library/internal/models"
-	"github.com/4Noyis/my-library/internal/services"
+	"github.com/4Noyis/my-library/internal/problem"
 	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 )
 
-var bookService = services.NewBookService()
-
-func GetAllBooksHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) GetAllBooksHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	books, err := bookService.GetAllBooks()
+	books, err := h.bookService.GetAllBooks(r.Context())
 	if err != nil {
-		logger.LogError("GetAllBooks", err, logrus.Fields{
+		logger.LogError(r.Context(), "GetAllBooks", err, logrus.Fields{
 			"handler": "GetAllBooksHandler",
 		})
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.WriteError(w, r, err)
 		return
 	}
 
-	logger.LogDebug("Retrieved all books", logrus.Fields{
+	logger.LogDebug(r.Context(), "Retrieved all books", logrus.Fields{
 		"handler": "GetAllBooksHandler",
 		"count":   len(books),
 	})
 
+	// An empty catalog is an empty array, not null
+	if books == nil {
+		books = []models.Book{}
+	}
 	json.NewEncoder(w).Encode(books)
 }
 
-func GetOneBookHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) GetOneBookHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	vars := mux.Vars(r)
 	idStr := vars["id"]
 	if idStr == "" {
-		logger.LogError("GetOneBook", nil, logrus.Fields{
+		logger.LogError(r.Context(), "GetOneBook", nil, logrus.Fields{
 			"handler": "GetOneBookHandler",
 			"error":   "id parameter required",
 		})
-		http.Error(w, "id parameter required", http.StatusBadRequest)
+		problem.Write(w, r, http.StatusBadRequest, "id parameter required")
 		return
 	}
 
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		logger.LogError("GetOneBook", err, logrus.Fields{
+		logger.LogError(r.Context(), "GetOneBook", err, logrus.Fields{
 			"handler": "GetOneBookHandler",
 			"id_str":  idStr,
 		})
-		http.Error(w, "invalid id format", http.StatusBadRequest)
+		problem.Write(w, r, http.StatusBadRequest, "invalid id format")
 		return
 	}
 
-	book, err := bookService.GetOneBook(id)
+	book, err := h.bookService.GetOneBook(r.Context(), id)
 	if err != nil {
-		logger.LogError("GetOneBook", err, logrus.Fields{
+		logger.LogError(r.Context(), "GetOneBook", err, logrus.Fields{
 			"handler": "GetOneBookHandler",
 			"id":      id,
 		})
-		http.Error(w, "book not found", http.StatusNotFound)
+		problem.WriteError(w, r, err)
 		return
 	}
 
-	logger.LogDebug("Successfully retrieved book", logrus.Fields{
+	logger.LogDebug(r.Context(), "Successfully retrieved book", logrus.Fields{
 		"handler": "GetOneBookHandler",
 		"id":      id,
 		"title":   book.Title,
@@ -75,43 +77,40 @@ func GetOneBookHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(book)
 }
 
-func DeleteBookHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) DeleteBookHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	vars := mux.Vars(r)
 	idStr := vars["id"]
 	if idStr == "" {
-		logger.LogError("DeleteBook", nil, logrus.Fields{
+		logger.LogError(r.Context(), "DeleteBook", nil, logrus.Fields{
 			"handler": "DeleteBookHandler",
 			"error":   "id parameter required",
 		})
-		http.Error(w, "id parameter required", http.StatusBadRequest)
+		problem.Write(w, r, http.StatusBadRequest, "id parameter required")
 		return
 	}
 
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		logger.LogError("DeleteBook", err, logrus.Fields{
+		logger.LogError(r.Context(), "DeleteBook", err, logrus.Fields{
 			"handler": "DeleteBookHandler",
 			"id_str":  idStr,
 		})
-		http.Error(w, "invalid id format", http.StatusBadRequest)
+		problem.Write(w, r, http.StatusBadRequest, "invalid id format")
 		return
 	}
 
-	deletedBook, err := bookService.DeleteBook(id)
+	deletedBook, err := h.bookService.DeleteBook(r.Context(), id, actorFromRequest(r))
 	if err != nil {
-		logger.LogError("DeleteBook", err, logrus.Fields{
+		logger.LogError(r.Context(), "DeleteBook", err, logrus.Fields{
 			"handler": "DeleteBookHandler",
 			"id":      id,
 		})
-		json.NewEncoder(w).Encode(models.Response{
-			Status:  "failed",
-			Message: "book not found on database",
-		})
+		problem.WriteError(w, r, err)
 		return
 	}
 
-	logger.LogInfo("Book deleted successfully", logrus.Fields{
+	logger.LogInfo(r.Context(), "Book deleted successfully", logrus.Fields{
 		"handler": "DeleteBookHandler",
 		"id":      id,
 		"title":   deletedBook.Title,
@@ -124,31 +123,31 @@ func DeleteBookHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func CreateBookHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) CreateBookHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	var newBook models.Book
 	err := json.NewDecoder(r.Body).Decode(&newBook)
 	if err != nil {
-		logger.LogError("CreateBook", err, logrus.Fields{
+		logger.LogError(r.Context(), "CreateBook", err, logrus.Fields{
 			"handler":     "CreateBookHandler",
 			"remote_addr": r.RemoteAddr,
 		})
-		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		problem.Write(w, r, http.StatusBadRequest, "invalid JSON")
 		return
 	}
 
-	createdBook, err := bookService.AddNewBook(newBook)
+	createdBook, err := h.bookService.AddNewBook(r.Context(), newBook, actorFromRequest(r))
 	if err != nil {
-		logger.LogError("CreateBook", err, logrus.Fields{
+		logger.LogError(r.Context(), "CreateBook", err, logrus.Fields{
 			"handler": "CreateBookHandler",
 			"title":   newBook.Title,
 			"isbn":    newBook.ISBN,
 		})
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.WriteError(w, r, err)
 		return
 	}
 
-	logger.LogInfo("Book created successfully", logrus.Fields{
+	logger.LogInfo(r.Context(), "Book created successfully", logrus.Fields{
 		"handler": "CreateBookHandler",
 		"id":      createdBook.ID,
 		"title":   createdBook.Title,
@@ -162,52 +161,52 @@ func CreateBookHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func UpdateBookHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) UpdateBookHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	vars := mux.Vars(r)
 	idStr := vars["id"]
 	if idStr == "" {
-		logger.LogError("UpdateBook", nil, logrus.Fields{
+		logger.LogError(r.Context(), "UpdateBook", nil, logrus.Fields{
 			"handler": "UpdateBookHandler",
 			"error":   "id parameter required",
 		})
-		http.Error(w, "id parameter required", http.StatusBadRequest)
+		problem.Write(w, r, http.StatusBadRequest, "id parameter required")
 		return
 	}
 
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		logger.LogError("UpdateBook", err, logrus.Fields{
+		logger.LogError(r.Context(), "UpdateBook", err, logrus.Fields{
 			"handler": "UpdateBookHandler",
 			"id_str":  idStr,
 		})
-		http.Error(w, "invalid id format", http.StatusBadRequest)
+		problem.Write(w, r, http.StatusBadRequest, "invalid id format")
 		return
 	}
 
 	var updates models.Book
 	err = json.NewDecoder(r.Body).Decode(&updates)
 	if err != nil {
-		logger.LogError("UpdateBook", err, logrus.Fields{
+		logger.LogError(r.Context(), "UpdateBook", err, logrus.Fields{
 			"handler":     "UpdateBookHandler",
 			"id":          id,
 			"remote_addr": r.RemoteAddr,
 		})
-		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		problem.Write(w, r, http.StatusBadRequest, "invalid JSON")
 		return
 	}
 
-	updatedBook, err := bookService.UpdateBook(id, updates)
+	updatedBook, err := h.bookService.UpdateBook(r.Context(), id, updates, actorFromRequest(r))
 	if err != nil {
-		logger.LogError("UpdateBook", err, logrus.Fields{
+		logger.LogError(r.Context(), "UpdateBook", err, logrus.Fields{
 			"handler": "UpdateBookHandler",
 			"id":      id,
 		})
-		http.Error(w, "book not found", http.StatusNotFound)
+		problem.WriteError(w, r, err)
 		return
 	}
 
-	logger.LogInfo("Book updated successfully", logrus.Fields{
+	logger.LogInfo(r.Context(), "Book updated successfully", logrus.Fields{
 		"handler": "UpdateBookHandler",
 		"id":      id,
 		"title":   updatedBook.Title,
@@ -219,3 +218,91 @@ func UpdateBookHandler(w http.ResponseWriter, r *http.Request) {
 		Book:    &updatedBook,
 	})
 }
+
+func (h *Handlers) BookHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	vars := mux.Vars(r)
+	idStr := vars["id"]
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		logger.LogError(r.Context(), "BookHistory", err, logrus.Fields{
+			"handler": "BookHistoryHandler",
+			"id_str":  idStr,
+		})
+		problem.Write(w, r, http.StatusBadRequest, "invalid id format")
+		return
+	}
+
+	revisions, err := h.bookService.GetBookHistory(r.Context(), id)
+	if err != nil {
+		logger.LogError(r.Context(), "BookHistory", err, logrus.Fields{
+			"handler": "BookHistoryHandler",
+			"id":      id,
+		})
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	logger.LogDebug(r.Context(), "Retrieved book history", logrus.Fields{
+		"handler":   "BookHistoryHandler",
+		"id":        id,
+		"revisions": len(revisions),
+	})
+
+	json.NewEncoder(w).Encode(models.BookHistoryResponse{
+		Status:    "success",
+		Message:   "book history retrieved successfully",
+		BookID:    id,
+		Revisions: revisions,
+	})
+}
+
+func (h *Handlers) RevertBookHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	vars := mux.Vars(r)
+
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		logger.LogError(r.Context(), "RevertBook", err, logrus.Fields{
+			"handler": "RevertBookHandler",
+			"id_str":  vars["id"],
+		})
+		problem.Write(w, r, http.StatusBadRequest, "invalid id format")
+		return
+	}
+
+	rev, err := strconv.Atoi(vars["rev"])
+	if err != nil {
+		logger.LogError(r.Context(), "RevertBook", err, logrus.Fields{
+			"handler": "RevertBookHandler",
+			"rev_str": vars["rev"],
+		})
+		problem.Write(w, r, http.StatusBadRequest, "invalid revision format")
+		return
+	}
+
+	restoredBook, err := h.bookService.RevertBook(r.Context(), id, rev, actorFromRequest(r))
+	if err != nil {
+		logger.LogError(r.Context(), "RevertBook", err, logrus.Fields{
+			"handler": "RevertBookHandler",
+			"id":      id,
+			"rev":     rev,
+		})
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	logger.LogInfo(r.Context(), "Book reverted successfully", logrus.Fields{
+		"handler": "RevertBookHandler",
+		"id":      id,
+		"rev":     rev,
+		"title":   restoredBook.Title,
+	})
+
+	json.NewEncoder(w).Encode(models.Response{
+		Status:  "success",
+		Message: "book reverted successfully",
+		Book:    &restoredBook,
+	})
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/4Noyis/my-library/internal/problem"
+	"github.com/sirupsen/logrus"
+)
+
+// ListBooksV2Handler returns a page of the catalog, filtered like the
+// GraphQL books query
+func (h *Handlers) ListBooksV2Handler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	query := models.BookListQuery{
+		Search:   params.Get("q"),
+		Author:   params.Get("author"),
+		Genre:    params.Get("genre"),
+		Language: params.Get("language"),
+	}
+	if v := params.Get("page"); v != "" {
+		page, err := strconv.Atoi(v)
+		if err != nil {
+			problem.Write(w, r, http.StatusBadRequest, "invalid page")
+			return
+		}
+		query.Page = page
+	}
+	if v := params.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			problem.Write(w, r, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		query.Limit = limit
+	}
+
+	result, err := h.bookService.ListBooks(r.Context(), query)
+	if err != nil {
+		logger.LogError(r.Context(), "ListBooks", err, logrus.Fields{
+			"handler": "ListBooksV2Handler",
+		})
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	writeData(w, http.StatusOK, models.NewBooksV2(result.Books), &models.PageMeta{
+		Total: result.Total,
+		Page:  result.Page,
+		Limit: result.Limit,
+	})
+}
+
+func (h *Handlers) GetBookV2Handler(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIntVar(r, "id")
+	if !ok {
+		problem.Write(w, r, http.StatusBadRequest, "invalid id format")
+		return
+	}
+
+	book, err := h.bookService.GetOneBook(r.Context(), id)
+	if err != nil {
+		logger.LogError(r.Context(), "GetOneBook", err, logrus.Fields{
+			"handler": "GetBookV2Handler",
+			"id":      id,
+		})
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	writeData(w, http.StatusOK, models.NewBookV2(book), nil)
+}
+
+func (h *Handlers) CreateBookV2Handler(w http.ResponseWriter, r *http.Request) {
+	var newBook models.BookV2
+	if err := json.NewDecoder(r.Body).Decode(&newBook); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	createdBook, err := h.bookService.AddNewBook(r.Context(), newBook.Book(), actorFromRequest(r))
+	if err != nil {
+		logger.LogError(r.Context(), "CreateBook", err, logrus.Fields{
+			"handler": "CreateBookV2Handler",
+			"title":   newBook.Title,
+			"isbn":    newBook.ISBN,
+		})
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	logger.LogInfo(r.Context(), "Book created successfully", logrus.Fields{
+		"handler": "CreateBookV2Handler",
+		"id":      createdBook.ID,
+		"title":   createdBook.Title,
+		"isbn":    createdBook.ISBN,
+	})
+
+	w.Header().Set("Location", "/api/v2/books/"+strconv.Itoa(createdBook.ID))
+	writeData(w, http.StatusCreated, models.NewBookV2(createdBook), nil)
+}
+
+func (h *Handlers) UpdateBookV2Handler(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIntVar(r, "id")
+	if !ok {
+		problem.Write(w, r, http.StatusBadRequest, "invalid id format")
+		return
+	}
+
+	var updates models.BookV2
+	if err := json.NewDecoder(r.Body).Decode(&updates); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	updatedBook, err := h.bookService.UpdateBook(r.Context(), id, updates.Book(), actorFromRequest(r))
+	if err != nil {
+		logger.LogError(r.Context(), "UpdateBook", err, logrus.Fields{
+			"handler": "UpdateBookV2Handler",
+			"id":      id,
+		})
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	logger.LogInfo(r.Context(), "Book updated successfully", logrus.Fields{
+		"handler": "UpdateBookV2Handler",
+		"id":      id,
+		"title":   updatedBook.Title,
+	})
+
+	writeData(w, http.StatusOK, models.NewBookV2(updatedBook), nil)
+}
+
+func (h *Handlers) DeleteBookV2Handler(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIntVar(r, "id")
+	if !ok {
+		problem.Write(w, r, http.StatusBadRequest, "invalid id format")
+		return
+	}
+
+	deletedBook, err := h.bookService.DeleteBook(r.Context(), id, actorFromRequest(r))
+	if err != nil {
+		logger.LogError(r.Context(), "DeleteBook", err, logrus.Fields{
+			"handler": "DeleteBookV2Handler",
+			"id":      id,
+		})
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	logger.LogInfo(r.Context(), "Book deleted successfully", logrus.Fields{
+		"handler": "DeleteBookV2Handler",
+		"id":      id,
+		"title":   deletedBook.Title,
+	})
+
+	writeData(w, http.StatusOK, models.NewBookV2(deletedBook), nil)
+}
+
+func (h *Handlers) BookHistoryV2Handler(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIntVar(r, "id")
+	if !ok {
+		problem.Write(w, r, http.StatusBadRequest, "invalid id format")
+		return
+	}
+
+	revisions, err := h.bookService.GetBookHistory(r.Context(), id)
+	if err != nil {
+		logger.LogError(r.Context(), "BookHistory", err, logrus.Fields{
+			"handler": "BookHistoryV2Handler",
+			"id":      id,
+		})
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	writeData(w, http.StatusOK, models.NewBookRevisionsV2(revisions), nil)
+}
+
+func (h *Handlers) RevertBookV2Handler(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIntVar(r, "id")
+	if !ok {
+		problem.Write(w, r, http.StatusBadRequest, "invalid id format")
+		return
+	}
+	rev, ok := parseIntVar(r, "rev")
+	if !ok {
+		problem.Write(w, r, http.StatusBadRequest, "invalid revision format")
+		return
+	}
+
+	restoredBook, err := h.bookService.RevertBook(r.Context(), id, rev, actorFromRequest(r))
+	if err != nil {
+		logger.LogError(r.Context(), "RevertBook", err, logrus.Fields{
+			"handler": "RevertBookV2Handler",
+			"id":      id,
+			"rev":     rev,
+		})
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	logger.LogInfo(r.Context(), "Book reverted successfully", logrus.Fields{
+		"handler": "RevertBookV2Handler",
+		"id":      id,
+		"rev":     rev,
+		"title":   restoredBook.Title,
+	})
+
+	writeData(w, http.StatusOK, models.NewBookV2(restoredBook), nil)
+}
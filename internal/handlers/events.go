@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/middleware"
+	"github.com/4Noyis/my-library/internal/problem"
+	"github.com/4Noyis/my-library/internal/services"
+	"github.com/sirupsen/logrus"
+)
+
+// Comment lines sent while idle keep proxies from closing the connection
+const streamHeartbeat = 15 * time.Second
+
+// EventStreamHandler streams catalog changes as server-sent events. Clients
+// may filter with ?types=book.created,book.deleted and resume with the
+// Last-Event-ID header (or ?last_event_id=).
+func (h *Handlers) EventStreamHandler(w http.ResponseWriter, r *http.Request) {
+	types, err := services.ParseStreamEventTypes(r.URL.Query().Get("types"))
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+
+	sub, backlog, resumed, err := h.eventStream.Subscribe(types, lastEventID)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		problem.WriteError(w, r, err)
+		return
+	}
+	defer sub.Close()
+
+	// The stream outlives the server's write timeout
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprint(w, "retry: 3000\n\n")
+	if lastEventID != "" && !resumed {
+		// Missed events are gone from the replay buffer; the client has to refetch
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, event := range backlog {
+		writeStreamEvent(w, event)
+	}
+	if err := rc.Flush(); err != nil {
+		logger.LogError(r.Context(), "EventStreamHandler", err, logrus.Fields{
+			"operation": "flush",
+		})
+		return
+	}
+
+	fields := logrus.Fields{
+		"backlog": len(backlog),
+		"resumed": resumed,
+		"type":    "event_stream",
+	}
+	if user, ok := middleware.GetUserFromContext(r); ok {
+		fields["user_id"] = user.ID.Hex()
+	}
+	logger.LogInfo(r.Context(), "Event stream opened", fields)
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sub.Done:
+			return
+		case event := <-sub.Events:
+			writeStreamEvent(w, event)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeStreamEvent(w http.ResponseWriter, event services.StreamEvent) {
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, event.Data)
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"github.com/4Noyis/my-library/internal/services"
+)
+
+// Services are the dependencies of the HTTP handlers
+type Services struct {
+	Users       *services.UserService
+	OIDC        *services.OIDCService
+	Books       *services.BookService
+	Audit       *services.AuditService
+	Webhooks    *services.WebhookService
+	Health      *services.HealthService
+	EventStream *services.EventStream
+}
+
+// Handlers serves the HTTP API; each route is one of its methods
+type Handlers struct {
+	userService    *services.UserService
+	oidcService    *services.OIDCService
+	bookService    *services.BookService
+	auditService   *services.AuditService
+	webhookService *services.WebhookService
+	healthService  *services.HealthService
+	eventStream    *services.EventStream
+}
+
+func New(s Services) *Handlers {
+	return &Handlers{
+		userService:    s.Users,
+		oidcService:    s.OIDC,
+		bookService:    s.Books,
+		auditService:   s.Audit,
+		webhookService: s.Webhooks,
+		healthService:  s.Health,
+		eventStream:    s.EventStream,
+	}
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// LivenessHandler reports that the process is alive. It checks no
+// dependencies, so a database outage doesn't get the server restarted.
+func (h *Handlers) LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	writeHealthReport(w, h.healthService.Live())
+}
+
+// ReadinessHandler reports whether the server should receive traffic, with
+// the status and latency of each dependency
+func (h *Handlers) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	report := h.healthService.Ready(r.Context())
+	if report.Status != models.HealthOK {
+		logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
+			"checks": report.Checks,
+			"type":   "health",
+		}).Warn("Readiness check failed")
+	}
+	writeHealthReport(w, report)
+}
+
+func writeHealthReport(w http.ResponseWriter, report models.HealthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status == models.HealthOK {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
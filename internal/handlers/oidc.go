@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/problem"
+	"github.com/sirupsen/logrus"
+)
+
+const oidcStateCookie = "oidc_login"
+
+// OIDCLoginHandler starts single sign-on by redirecting to the identity provider
+func (h *Handlers) OIDCLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if !h.oidcService.Enabled() {
+		w.Header().Set("Content-Type", "application/json")
+		problem.Write(w, r, http.StatusNotFound, "OIDC login is not configured")
+		return
+	}
+
+	authURL, signedState, err := h.oidcService.BeginLogin(r.Context())
+	if err != nil {
+		logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
+			"error": err.Error(),
+			"type":  "oidc",
+		}).Error("Failed to start OIDC login")
+
+		w.Header().Set("Content-Type", "application/json")
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    signedState,
+		Path:     "/api/v1/auth/oidc",
+		MaxAge:   600,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDCCallbackHandler finishes single sign-on and returns our own JWT
+func (h *Handlers) OIDCCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// The state cookie is single use
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Path:     "/api/v1/auth/oidc",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
+			"error":       providerErr,
+			"description": query.Get("error_description"),
+			"type":        "oidc",
+		}).Error("Identity provider rejected login")
+		problem.Write(w, r, http.StatusUnauthorized, "Login rejected by identity provider")
+		return
+	}
+
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "invalid oidc state")
+		return
+	}
+
+	loginResponse, err := h.oidcService.CompleteLogin(r.Context(), query.Get("code"), query.Get("state"), cookie.Value, clientInfo(r))
+	if err != nil {
+		logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
+			"error": err.Error(),
+			"type":  "oidc",
+		}).Error("OIDC login failed")
+
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
+		"user_id":  loginResponse.User.ID.Hex(),
+		"username": loginResponse.User.Username,
+		"type":     "oidc",
+	}).Info("User logged in via OIDC")
+
+	writeUserResponse(w, http.StatusOK, "success", "Login successful", loginResponse)
+}
@@ -2,61 +2,44 @@ package handlers
 
 import (
 	"encoding/json"
+	"net"
 	"net/http"
 
 	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/middleware"
 	"github.com/4Noyis/my-library/internal/models"
-	"github.com/4Noyis/my-library/internal/services"
+	"github.com/4Noyis/my-library/internal/problem"
 	"github.com/sirupsen/logrus"
 )
 
-var userService = services.NewUserService()
-
-func RegisterHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	var req models.RegisterRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		logger.Logger.WithFields(logrus.Fields{
+		logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
 			"error": err.Error(),
 			"type":  "validation",
 		}).Error("Invalid request body for registration")
 
-		response := models.UserResponse{
-			Status:  "error",
-			Message: "Invalid request body",
-		}
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(response)
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
-	user, err := userService.RegisterUser(&req)
+	user, err := h.userService.RegisterUser(r.Context(), &req)
 	if err != nil {
-		logger.Logger.WithFields(logrus.Fields{
+		logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
 			"error":    err.Error(),
 			"username": req.Username,
 			"email":    req.Email,
 			"type":     "registration",
 		}).Error("User registration failed")
 
-		var statusCode int
-		if err.Error() == "username already exists" || err.Error() == "email already exists" {
-			statusCode = http.StatusConflict
-		} else {
-			statusCode = http.StatusInternalServerError
-		}
-
-		response := models.UserResponse{
-			Status:  "error",
-			Message: err.Error(),
-		}
-		w.WriteHeader(statusCode)
-		json.NewEncoder(w).Encode(response)
+		problem.WriteError(w, r, err)
 		return
 	}
 
-	logger.Logger.WithFields(logrus.Fields{
+	logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
 		"user_id":  user.ID.Hex(),
 		"username": user.Username,
 		"email":    user.Email,
@@ -72,50 +55,33 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-func LoginHandler(w http.ResponseWriter, r *http.Request) {
+func (h *Handlers) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
 	var req models.LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		logger.Logger.WithFields(logrus.Fields{
+		logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
 			"error": err.Error(),
 			"type":  "validation",
 		}).Error("Invalid request body for login")
 
-		response := models.UserResponse{
-			Status:  "error",
-			Message: "Invalid request body",
-		}
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(response)
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request body")
 		return
 	}
 
-	loginResponse, err := userService.LoginUser(&req)
+	loginResponse, err := h.userService.LoginUser(r.Context(), &req, clientInfo(r))
 	if err != nil {
-		logger.Logger.WithFields(logrus.Fields{
+		logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
 			"error":    err.Error(),
 			"username": req.Username,
 			"type":     "login",
 		}).Error("User login failed")
 
-		var statusCode int
-		if err.Error() == "invalid credentials" || err.Error() == "account is deactivated" {
-			statusCode = http.StatusUnauthorized
-		} else {
-			statusCode = http.StatusInternalServerError
-		}
-
-		response := models.UserResponse{
-			Status:  "error",
-			Message: err.Error(),
-		}
-		w.WriteHeader(statusCode)
-		json.NewEncoder(w).Encode(response)
+		problem.WriteError(w, r, err)
 		return
 	}
 
-	logger.Logger.WithFields(logrus.Fields{
+	logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
 		"user_id":  loginResponse.User.ID.Hex(),
 		"username": loginResponse.User.Username,
 		"type":     "login",
@@ -129,3 +95,38 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(response)
 }
+
+// clientInfo describes the device making the request, recorded on new sessions
+func clientInfo(r *http.Request) models.ClientInfo {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return models.ClientInfo{
+		UserAgent: r.UserAgent(),
+		IPAddress: ip,
+	}
+}
+
+// actorFromRequest identifies the authenticated user making a change, for the audit log
+func actorFromRequest(r *http.Request) models.Actor {
+	client := clientInfo(r)
+	actor := models.Actor{
+		IPAddress: client.IPAddress,
+		RequestID: logger.RequestIDFromContext(r.Context()),
+	}
+	if user, ok := middleware.GetUserFromContext(r); ok {
+		actor.UserID = user.ID
+		actor.Username = user.Username
+	}
+	return actor
+}
+
+func writeUserResponse(w http.ResponseWriter, statusCode int, status, message string, data interface{}) {
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(models.UserResponse{
+		Status:  status,
+		Message: message,
+		Data:    data,
+	})
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/gorilla/mux"
+)
+
+// The V2 handlers serve /api/v2. They run the same services as their v1
+// counterparts and differ only in what they answer: every body is a
+// models.Envelope, IDs are strings, and creating a resource answers 201.
+// Errors are problem details in both versions.
+
+// writeData writes a v2 response: data, and the pagination of lists, in an
+// envelope
+func writeData(w http.ResponseWriter, statusCode int, data interface{}, meta *models.PageMeta) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(models.Envelope{Data: data, Meta: meta})
+}
+
+// parseIntVar reads a path variable as an integer
+func parseIntVar(r *http.Request, name string) (int, bool) {
+	n, err := strconv.Atoi(mux.Vars(r)[name])
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/4Noyis/my-library/internal/problem"
+	"github.com/sirupsen/logrus"
+)
+
+func (h *Handlers) ListWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	subs, err := h.webhookService.ListSubscriptions(r.Context())
+	if err != nil {
+		logger.LogError(r.Context(), "ListWebhooks", err, logrus.Fields{
+			"handler": "ListWebhooksHandler",
+		})
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	writeUserResponse(w, http.StatusOK, "success", "Webhook subscriptions retrieved successfully", subs)
+}
+
+func (h *Handlers) CreateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	var req models.WebhookSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	sub, err := h.webhookService.CreateSubscription(r.Context(), &req, actorFromRequest(r))
+	if err != nil {
+		logger.LogError(r.Context(), "CreateWebhook", err, logrus.Fields{
+			"handler": "CreateWebhookHandler",
+			"url":     req.URL,
+		})
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
+		"subscription_id": sub.ID.Hex(),
+		"url":             sub.URL,
+		"event_types":     sub.EventTypes,
+		"type":            "webhook",
+	}).Info("Webhook subscription created")
+
+	// The secret is only ever returned here
+	writeUserResponse(w, http.StatusCreated, "success", "Webhook subscription created successfully", sub)
+}
+
+func (h *Handlers) GetWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, ok := parseObjectID(r, "id")
+	if !ok {
+		problem.Write(w, r, http.StatusBadRequest, "invalid id format")
+		return
+	}
+
+	sub, err := h.webhookService.GetSubscription(r.Context(), id)
+	if err != nil {
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	writeUserResponse(w, http.StatusOK, "success", "Webhook subscription retrieved successfully", sub)
+}
+
+func (h *Handlers) UpdateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, ok := parseObjectID(r, "id")
+	if !ok {
+		problem.Write(w, r, http.StatusBadRequest, "invalid id format")
+		return
+	}
+
+	var req models.WebhookSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	sub, err := h.webhookService.UpdateSubscription(r.Context(), id, &req)
+	if err != nil {
+		logger.LogError(r.Context(), "UpdateWebhook", err, logrus.Fields{
+			"handler":         "UpdateWebhookHandler",
+			"subscription_id": id.Hex(),
+		})
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	writeUserResponse(w, http.StatusOK, "success", "Webhook subscription updated successfully", sub)
+}
+
+func (h *Handlers) DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, ok := parseObjectID(r, "id")
+	if !ok {
+		problem.Write(w, r, http.StatusBadRequest, "invalid id format")
+		return
+	}
+
+	if err := h.webhookService.DeleteSubscription(r.Context(), id); err != nil {
+		logger.LogError(r.Context(), "DeleteWebhook", err, logrus.Fields{
+			"handler":         "DeleteWebhookHandler",
+			"subscription_id": id.Hex(),
+		})
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
+		"subscription_id": id.Hex(),
+		"type":            "webhook",
+	}).Info("Webhook subscription deleted")
+
+	writeUserResponse(w, http.StatusOK, "success", "Webhook subscription deleted successfully", nil)
+}
+
+// ListWebhookDeliveriesHandler returns the delivery log of a subscription,
+// optionally filtered by ?status=pending|succeeded|dead
+func (h *Handlers) ListWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, ok := parseObjectID(r, "id")
+	if !ok {
+		problem.Write(w, r, http.StatusBadRequest, "invalid id format")
+		return
+	}
+
+	params := r.URL.Query()
+	page, _ := strconv.Atoi(params.Get("page"))
+	limit, _ := strconv.Atoi(params.Get("limit"))
+
+	result, err := h.webhookService.ListDeliveries(r.Context(), id, params.Get("status"), page, limit)
+	if err != nil {
+		logger.LogError(r.Context(), "ListWebhookDeliveries", err, logrus.Fields{
+			"handler":         "ListWebhookDeliveriesHandler",
+			"subscription_id": id.Hex(),
+		})
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	writeUserResponse(w, http.StatusOK, "success", "Webhook deliveries retrieved successfully", result)
+}
+
+// RetryWebhookDeliveryHandler requeues a dead-lettered delivery
+func (h *Handlers) RetryWebhookDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, ok := parseObjectID(r, "id")
+	if !ok {
+		problem.Write(w, r, http.StatusBadRequest, "invalid id format")
+		return
+	}
+	deliveryID, ok := parseObjectID(r, "deliveryId")
+	if !ok {
+		problem.Write(w, r, http.StatusBadRequest, "invalid delivery id format")
+		return
+	}
+
+	if err := h.webhookService.RetryDelivery(r.Context(), id, deliveryID); err != nil {
+		logger.LogError(r.Context(), "RetryWebhookDelivery", err, logrus.Fields{
+			"handler":     "RetryWebhookDeliveryHandler",
+			"delivery_id": deliveryID.Hex(),
+		})
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	writeUserResponse(w, http.StatusAccepted, "success", "Delivery requeued", nil)
+}
+
+// PingWebhookHandler sends a test event to the subscription and returns the attempt
+func (h *Handlers) PingWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id, ok := parseObjectID(r, "id")
+	if !ok {
+		problem.Write(w, r, http.StatusBadRequest, "invalid id format")
+		return
+	}
+
+	delivery, err := h.webhookService.Ping(r.Context(), id)
+	if err != nil {
+		logger.LogError(r.Context(), "PingWebhook", err, logrus.Fields{
+			"handler":         "PingWebhookHandler",
+			"subscription_id": id.Hex(),
+		})
+		problem.WriteError(w, r, err)
+		return
+	}
+
+	message := "Ping delivered"
+	if delivery.Status != models.DeliverySucceeded {
+		message = "Ping failed"
+	}
+	writeUserResponse(w, http.StatusOK, "success", message, delivery)
+}
@@ -1,20 +1,34 @@
 package logger
 
 import (
+	"context"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/4Noyis/my-library/internal/config"
+	"github.com/4Noyis/my-library/internal/metrics"
 	"github.com/sirupsen/logrus"
 )
 
 var Logger *logrus.Logger
 
 func init() {
+	// Defaults until Configure applies the loaded configuration
 	Logger = logrus.New()
+	Logger.SetLevel(logrus.InfoLevel)
+	Logger.SetFormatter(&logrus.TextFormatter{
+		FullTimestamp: true,
+	})
+	Logger.SetOutput(os.Stdout)
+	Logger.AddHook(contextHook{})
+}
 
-	// Set log level from environment variable (default: INFO)
-	level := strings.ToLower(os.Getenv("LOG_LEVEL"))
-	switch level {
+// Configure applies the log level and format. The format comes from the
+// active profile unless set explicitly: text in development, JSON in
+// production.
+func Configure(cfg config.Log) {
+	switch strings.ToLower(cfg.Level) {
 	case "debug":
 		Logger.SetLevel(logrus.DebugLevel)
 	case "warn", "warning":
@@ -27,22 +41,19 @@ func init() {
 		Logger.SetLevel(logrus.InfoLevel)
 	}
 
-	// Set format from environment variable (default: JSON in production, text in development)
-	format := strings.ToLower(os.Getenv("LOG_FORMAT"))
-	if format == "text" || (format == "" && os.Getenv("GO_ENV") != "production") {
+	if strings.ToLower(cfg.Format) == "json" {
+		Logger.SetFormatter(&logrus.JSONFormatter{})
+	} else {
 		Logger.SetFormatter(&logrus.TextFormatter{
 			FullTimestamp: true,
 		})
-	} else {
-		Logger.SetFormatter(&logrus.JSONFormatter{})
 	}
-
-	Logger.SetOutput(os.Stdout)
 }
 
-// Convenience functions for common log patterns
-func LogRequest(method, path, remoteAddr string, statusCode int) {
-	Logger.WithFields(logrus.Fields{
+// Convenience functions for common log patterns. ctx carries the request
+// ID and trace into the entry; pass context.Background() outside requests.
+func LogRequest(ctx context.Context, method, path, remoteAddr string, statusCode int) {
+	Logger.WithContext(ctx).WithFields(logrus.Fields{
 		"method":      method,
 		"path":        path,
 		"remote_addr": remoteAddr,
@@ -51,11 +62,16 @@ func LogRequest(method, path, remoteAddr string, statusCode int) {
 	}).Info("HTTP request")
 }
 
-func LogDatabaseOperation(operation, collection string, id interface{}, duration int64, err error) {
+// LogDatabaseOperation logs a MongoDB operation and records its latency in
+// the metrics. The log keeps whole milliseconds; the histogram gets the full
+// duration, so fast operations don't all land at zero.
+func LogDatabaseOperation(ctx context.Context, operation, collection string, id interface{}, duration time.Duration, err error) {
+	metrics.ObserveDatabaseOperation(operation, collection, duration, err)
+
 	fields := logrus.Fields{
 		"operation":   operation,
 		"collection":  collection,
-		"duration_ms": duration,
+		"duration_ms": duration.Milliseconds(),
 		"type":        "database",
 	}
 
@@ -65,33 +81,35 @@ func LogDatabaseOperation(operation, collection string, id interface{}, duration
 
 	if err != nil {
 		fields["error"] = err.Error()
-		Logger.WithFields(fields).Error("Database operation failed")
+		Logger.WithContext(ctx).WithFields(fields).Error("Database operation failed")
 	} else {
-		Logger.WithFields(fields).Debug("Database operation completed")
+		Logger.WithContext(ctx).WithFields(fields).Debug("Database operation completed")
 	}
 }
 
-func LogError(operation string, err error, fields logrus.Fields) {
+func LogError(ctx context.Context, operation string, err error, fields logrus.Fields) {
 	if fields == nil {
 		fields = logrus.Fields{}
 	}
 	fields["operation"] = operation
-	fields["error"] = err.Error()
+	if err != nil {
+		fields["error"] = err.Error()
+	}
 	fields["type"] = "error"
 
-	Logger.WithFields(fields).Error("Operation failed")
+	Logger.WithContext(ctx).WithFields(fields).Error("Operation failed")
 }
 
-func LogInfo(message string, fields logrus.Fields) {
+func LogInfo(ctx context.Context, message string, fields logrus.Fields) {
 	if fields == nil {
 		fields = logrus.Fields{}
 	}
-	Logger.WithFields(fields).Info(message)
+	Logger.WithContext(ctx).WithFields(fields).Info(message)
 }
 
-func LogDebug(message string, fields logrus.Fields) {
+func LogDebug(ctx context.Context, message string, fields logrus.Fields) {
 	if fields == nil {
 		fields = logrus.Fields{}
 	}
-	Logger.WithFields(fields).Debug(message)
+	Logger.WithContext(ctx).WithFields(fields).Debug(message)
 }
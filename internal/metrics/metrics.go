@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds every metric exposed on /metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by method, route template and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	apiRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "api_version_requests_total",
+		Help: "REST API requests by API version and whether the route has a successor in a later version.",
+	}, []string{"version", "superseded"})
+
+	dbDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mongodb_operation_duration_seconds",
+		Help:    "MongoDB operation latency by operation, collection and outcome.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"operation", "collection", "outcome"})
+
+	droppedEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "event_bus_dropped_total",
+		Help: "Events dropped because an asynchronous subscriber's queue was full, by subscriber and event.",
+	}, []string{"subscriber", "event"})
+
+	logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "auth_logins_total",
+		Help: "Login attempts by method and outcome.",
+	}, []string{"method", "outcome"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		apiRequests,
+		dbDuration,
+		droppedEvents,
+		logins,
+	)
+}
+
+// Handler serves the registry in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// ObserveRequest records a finished HTTP request. route must be the route
+// template, not the raw path, to keep the number of series bounded.
+func ObserveRequest(method, route string, status int, duration time.Duration) {
+	code := strconv.Itoa(status)
+	httpRequests.WithLabelValues(method, route, code).Inc()
+	httpDuration.WithLabelValues(method, route, code).Observe(duration.Seconds())
+}
+
+// RecordAPIRequest counts a request to version of the REST API, e.g. "v1".
+// superseded requests could have used a later version: once they stop, the
+// routes can be retired.
+func RecordAPIRequest(version string, superseded bool) {
+	apiRequests.WithLabelValues(version, strconv.FormatBool(superseded)).Inc()
+}
+
+// ObserveDatabaseOperation records the latency of one MongoDB operation
+func ObserveDatabaseOperation(operation, collection string, duration time.Duration, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+	dbDuration.WithLabelValues(operation, collection, outcome).Observe(duration.Seconds())
+}
+
+// RecordDroppedEvent counts an event an asynchronous subscriber had no room
+// for
+func RecordDroppedEvent(subscriber, event string) {
+	droppedEvents.WithLabelValues(subscriber, event).Inc()
+}
+
+// RecordLogin counts a login attempt; method is e.g. "password" or "oidc"
+func RecordLogin(method string, success bool) {
+	outcome := "success"
+	if !success {
+		outcome = "failure"
+	}
+	logins.WithLabelValues(method, outcome).Inc()
+}
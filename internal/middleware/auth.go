@@ -2,98 +2,101 @@ package middleware
 
 import (
 	"context"
-	"encoding/json"
 	"net/http"
+	"regexp"
 	"strings"
 
 	"github.com/4Noyis/my-library/internal/logger"
 	"github.com/4Noyis/my-library/internal/models"
+	"github.com/4Noyis/my-library/internal/problem"
 	"github.com/4Noyis/my-library/internal/services"
+	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 )
 
 type contextKey string
 
 const UserContextKey contextKey = "user"
+const SessionContextKey contextKey = "session"
+
+// AuthMiddleware authenticates requests by their bearer token, validated
+// with userService, and adds the user and session to the request context
+func AuthMiddleware(userService *services.UserService) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Get the Authorization header
+			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" {
+				logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
+					"path":   r.URL.Path,
+					"method": r.Method,
+					"type":   "auth",
+				}).Error("Missing authorization header")
+
+				problem.Write(w, r, http.StatusUnauthorized, "Authorization header required")
+				return
+			}
 
-var userService = services.NewUserService()
-
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-
-		// Get the Authorization header
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			logger.Logger.WithFields(logrus.Fields{
-				"path":   r.URL.Path,
-				"method": r.Method,
-				"type":   "auth",
-			}).Error("Missing authorization header")
-
-			response := models.Response{
-				Status:  "error",
-				Message: "Authorization header required",
+			// Check if the header starts with "Bearer "
+			tokenParts := strings.Split(authHeader, " ")
+			if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
+				logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
+					"path":   r.URL.Path,
+					"method": r.Method,
+					"type":   "auth",
+				}).Error("Invalid authorization header format")
+
+				problem.Write(w, r, http.StatusUnauthorized, "Invalid authorization header format")
+				return
 			}
-			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode(response)
-			return
-		}
 
-		// Check if the header starts with "Bearer "
-		tokenParts := strings.Split(authHeader, " ")
-		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
-			logger.Logger.WithFields(logrus.Fields{
-				"path":   r.URL.Path,
-				"method": r.Method,
-				"type":   "auth",
-			}).Error("Invalid authorization header format")
-
-			response := models.Response{
-				Status:  "error",
-				Message: "Invalid authorization header format",
+			// Validate the JWT token
+			token := tokenParts[1]
+			user, session, err := userService.ValidateJWT(r.Context(), token)
+			if err != nil {
+				logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
+					"path":   r.URL.Path,
+					"method": r.Method,
+					"error":  err.Error(),
+					"type":   "auth",
+				}).Error("Token validation failed")
+
+				problem.Write(w, r, http.StatusUnauthorized, "Invalid or expired token")
+				return
 			}
-			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode(response)
-			return
-		}
 
-		// Validate the JWT token
-		token := tokenParts[1]
-		user, err := userService.ValidateJWT(token)
-		if err != nil {
-			logger.Logger.WithFields(logrus.Fields{
-				"path":   r.URL.Path,
-				"method": r.Method,
-				"error":  err.Error(),
-				"type":   "auth",
-			}).Error("Token validation failed")
-
-			response := models.Response{
-				Status:  "error",
-				Message: "Invalid or expired token",
+			// Users whose password was reset by an admin may only view their
+			// account and set a new password
+			if user.MustChangePassword && !passwordChangeAllowed(r) {
+				logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
+					"path":    r.URL.Path,
+					"method":  r.Method,
+					"user_id": user.ID.Hex(),
+					"type":    "auth",
+				}).Error("Password change required")
+
+				problem.Write(w, r, http.StatusForbidden, "Password change required")
+				return
 			}
-			w.WriteHeader(http.StatusUnauthorized)
-			json.NewEncoder(w).Encode(response)
-			return
-		}
 
-		// Add user to request context
-		ctx := context.WithValue(r.Context(), UserContextKey, user)
-		r = r.WithContext(ctx)
+			// Add user to request context
+			ctx := context.WithValue(r.Context(), UserContextKey, user)
+			ctx = context.WithValue(ctx, SessionContextKey, session)
+			r = r.WithContext(ctx)
 
-		logger.Logger.WithFields(logrus.Fields{
-			"path":     r.URL.Path,
-			"method":   r.Method,
-			"user_id":  user.ID.Hex(),
-			"username": user.Username,
-			"role":     user.Role,
-			"type":     "auth",
-		}).Info("User authenticated successfully")
+			logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
+				"path":     r.URL.Path,
+				"method":   r.Method,
+				"user_id":  user.ID.Hex(),
+				"username": user.Username,
+				"role":     user.Role,
+				"type":     "auth",
+			}).Info("User authenticated successfully")
 
-		// Call the next handler
-		next.ServeHTTP(w, r)
-	})
+			// Call the next handler
+			next.ServeHTTP(w, r)
+		})
+	}
 }
 
 // AdminMiddleware ensures only admin users can access the route
@@ -101,17 +104,12 @@ func AdminMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user, ok := r.Context().Value(UserContextKey).(*models.User)
 		if !ok {
-			response := models.Response{
-				Status:  "error",
-				Message: "User context not found",
-			}
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(response)
+			problem.Write(w, r, http.StatusInternalServerError, "User context not found")
 			return
 		}
 
 		if user.Role != "admin" {
-			logger.Logger.WithFields(logrus.Fields{
+			logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
 				"path":     r.URL.Path,
 				"method":   r.Method,
 				"user_id":  user.ID.Hex(),
@@ -120,12 +118,7 @@ func AdminMiddleware(next http.Handler) http.Handler {
 				"type":     "authorization",
 			}).Error("Access denied: admin role required")
 
-			response := models.Response{
-				Status:  "error",
-				Message: "Admin access required",
-			}
-			w.WriteHeader(http.StatusForbidden)
-			json.NewEncoder(w).Encode(response)
+			problem.Write(w, r, http.StatusForbidden, "Admin access required")
 			return
 		}
 
@@ -137,4 +130,31 @@ func AdminMiddleware(next http.Handler) http.Handler {
 func GetUserFromContext(r *http.Request) (*models.User, bool) {
 	user, ok := r.Context().Value(UserContextKey).(*models.User)
 	return user, ok
-}
\ No newline at end of file
+}
+
+// GetSessionFromContext extracts the session of the current token from the request context
+func GetSessionFromContext(r *http.Request) (*models.Session, bool) {
+	session, ok := r.Context().Value(SessionContextKey).(*models.Session)
+	return session, ok
+}
+
+// passwordChangeAllowed reports whether the request is one a user with a
+// pending forced password reset may still make
+func passwordChangeAllowed(r *http.Request) bool {
+	switch {
+	case apiPath(r.URL.Path) == "/me" && r.Method == http.MethodGet:
+		return true
+	case apiPath(r.URL.Path) == "/me/password" && r.Method == http.MethodPost:
+		return true
+	}
+	return false
+}
+
+// apiVersionPrefix matches the prefix of every version of the REST API
+var apiVersionPrefix = regexp.MustCompile(`^/api/v[0-9]+`)
+
+// apiPath strips the version prefix from a REST API path, e.g. /api/v2/me
+// becomes /me
+func apiPath(path string) string {
+	return apiVersionPrefix.ReplaceAllString(path, "")
+}
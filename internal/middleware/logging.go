@@ -5,6 +5,8 @@ import (
 	"time"
 
 	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/metrics"
+	"github.com/gorilla/mux"
 	"github.com/sirupsen/logrus"
 )
 
@@ -25,6 +27,12 @@ func (rw *responseWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to
+// flush server-sent events
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
@@ -36,7 +44,7 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 		}
 
 		// Log the incoming request
-		logger.Logger.WithFields(logrus.Fields{
+		logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
 			"method":      r.Method,
 			"path":        r.URL.Path,
 			"remote_addr": r.RemoteAddr,
@@ -49,9 +57,10 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 
 		// Calculate duration
 		duration := time.Since(start)
+		metrics.ObserveRequest(r.Method, routeTemplate(r), wrapped.statusCode, duration)
 
 		// Log the completed request
-		logger.Logger.WithFields(logrus.Fields{
+		logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
 			"method":        r.Method,
 			"path":          r.URL.Path,
 			"remote_addr":   r.RemoteAddr,
@@ -62,3 +71,14 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 		}).Info("Request completed")
 	})
 }
+
+// routeTemplate returns the matched route's path template, e.g.
+// /api/v1/books/{id}, so metrics don't get a series per book ID
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return "unmatched"
+}
@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/problem"
+	"github.com/4Noyis/my-library/internal/services"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// RateLimitMiddleware limits requests with limiter and reports the caller's
+// quota in the RateLimit-* headers. Mount it after AuthMiddleware on
+// protected routes, so callers are limited per user rather than per IP.
+func RateLimitMiddleware(limiter *services.RateLimiter) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !limiter.Enabled() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			user, _ := GetUserFromContext(r)
+			route := r.Method + " " + routeTemplate(r)
+			policy, result, err := limiter.Take(r.Context(), route, user, remoteIP(r))
+			if err != nil {
+				// A broken store shouldn't take the API down with it
+				logger.LogError(r.Context(), "RateLimit", err, logrus.Fields{
+					"route": route,
+				})
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("RateLimit-Policy", strconv.Itoa(policy.Requests)+";w="+FormatSeconds(policy.Window))
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(policy.Requests))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			w.Header().Set("RateLimit-Reset", FormatSeconds(result.Reset))
+
+			if !result.Allowed {
+				logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
+					"path":   r.URL.Path,
+					"method": r.Method,
+					"route":  route,
+					"type":   "rate_limit",
+				}).Warn("Rate limit exceeded")
+
+				w.Header().Set("Retry-After", FormatSeconds(result.RetryAfter))
+				problem.Write(w, r, http.StatusTooManyRequests, "Too many requests")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func remoteIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return ip
+}
+
+// FormatSeconds renders d in whole seconds, rounded up, as the rate limit
+// headers of both the HTTP and gRPC APIs expect
+func FormatSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/services"
+	"github.com/gorilla/mux"
+)
+
+func TestMain(m *testing.M) {
+	logger.Logger.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// stubStore answers every Take with result, or err
+type stubStore struct {
+	result services.RateLimitResult
+	err    error
+	keys   []string
+}
+
+func (s *stubStore) Take(ctx context.Context, key string, policy services.RateLimitPolicy) (services.RateLimitResult, error) {
+	s.keys = append(s.keys, key)
+	return s.result, s.err
+}
+
+func serveLimited(t *testing.T, store services.RateLimitStore, enabled bool) *httptest.ResponseRecorder {
+	t.Helper()
+
+	limiter := services.NewRateLimiter(store, services.RateLimitConfig{
+		Enabled:   enabled,
+		Anonymous: services.RateLimitPolicy{Requests: 6, Window: time.Minute},
+	})
+	r := mux.NewRouter()
+	r.Use(RateLimitMiddleware(limiter))
+	r.HandleFunc("/books/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/books/42", nil)
+	req.RemoteAddr = "192.0.2.1:51234"
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	tests := []struct {
+		name       string
+		result     services.RateLimitResult
+		wantStatus int
+		want       map[string]string
+	}{
+		{
+			name:       "allowed",
+			result:     services.RateLimitResult{Allowed: true, Remaining: 2, Reset: 40 * time.Second},
+			wantStatus: http.StatusNoContent,
+			want: map[string]string{
+				"RateLimit-Policy":    "6;w=60",
+				"RateLimit-Limit":     "6",
+				"RateLimit-Remaining": "2",
+				"RateLimit-Reset":     "40",
+				"Retry-After":         "",
+			},
+		},
+		{
+			// Fractions round up, so clients never retry too early
+			name:       "allowed, fractional reset",
+			result:     services.RateLimitResult{Allowed: true, Remaining: 5, Reset: 10*time.Second + time.Millisecond},
+			wantStatus: http.StatusNoContent,
+			want: map[string]string{
+				"RateLimit-Remaining": "5",
+				"RateLimit-Reset":     "11",
+				"Retry-After":         "",
+			},
+		},
+		{
+			name:       "denied",
+			result:     services.RateLimitResult{Remaining: 0, Reset: 59500 * time.Millisecond, RetryAfter: 9500 * time.Millisecond},
+			wantStatus: http.StatusTooManyRequests,
+			want: map[string]string{
+				"RateLimit-Policy":    "6;w=60",
+				"RateLimit-Remaining": "0",
+				"RateLimit-Reset":     "60",
+				"Retry-After":         "10",
+			},
+		},
+		{
+			name:       "denied, almost refilled",
+			result:     services.RateLimitResult{Reset: 50 * time.Second, RetryAfter: time.Millisecond},
+			wantStatus: http.StatusTooManyRequests,
+			want: map[string]string{
+				"Retry-After": "1",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &stubStore{result: tt.result}
+			rec := serveLimited(t, store, true)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			for header, want := range tt.want {
+				if got := rec.Header().Get(header); got != want {
+					t.Errorf("%s = %q, want %q", header, got, want)
+				}
+			}
+			if len(store.keys) != 1 || store.keys[0] != "anonymous:ip:192.0.2.1" {
+				t.Errorf("took from %v, want the caller's address", store.keys)
+			}
+		})
+	}
+}
+
+func TestRateLimitPassesThrough(t *testing.T) {
+	tests := []struct {
+		name    string
+		store   *stubStore
+		enabled bool
+	}{
+		{"disabled", &stubStore{}, false},
+		// A broken store doesn't take the API down with it
+		{"store failing", &stubStore{err: errors.New("connection refused")}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serveLimited(t, tt.store, tt.enabled)
+			if rec.Code != http.StatusNoContent {
+				t.Errorf("status %d, want %d", rec.Code, http.StatusNoContent)
+			}
+			for _, header := range []string{"RateLimit-Limit", "Retry-After"} {
+				if got := rec.Header().Get(header); got != "" {
+					t.Errorf("%s = %q, want none", header, got)
+				}
+			}
+		})
+	}
+}
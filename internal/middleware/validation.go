@@ -0,0 +1,228 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/4Noyis/my-library/internal/openapi"
+	"github.com/4Noyis/my-library/internal/problem"
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+)
+
+// RequestValidationMiddleware checks the path and query parameters and the
+// JSON body of each request against its operation in spec, and rejects
+// invalid requests with a 400 listing every problem found. Routes spec does
+// not describe are passed through.
+func RequestValidationMiddleware(spec *openapi.Document) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			op := spec.Operation(r.Method, routeTemplate(r))
+			if op == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			invalid := validateParams(spec, op, r)
+			if op.RequestBody != nil {
+				body, err := io.ReadAll(r.Body)
+				if err != nil {
+					problem.Write(w, r, http.StatusBadRequest, "cannot read request body")
+					return
+				}
+				// Handlers decode the body again
+				r.Body = io.NopCloser(bytes.NewReader(body))
+				invalid = append(invalid, validateBody(spec, op.RequestBody, body)...)
+			}
+
+			if len(invalid) > 0 {
+				logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
+					"path":           r.URL.Path,
+					"method":         r.Method,
+					"invalid_params": invalid,
+					"type":           "validation",
+				}).Warn("Request failed validation")
+
+				problem.WriteInvalid(w, r, invalid)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func validateParams(spec *openapi.Document, op *openapi.Operation, r *http.Request) []models.InvalidParam {
+	var invalid []models.InvalidParam
+	vars := mux.Vars(r)
+	query := r.URL.Query()
+
+	for _, param := range op.Parameters {
+		var raw string
+		switch param.In {
+		case "path":
+			raw = vars[param.Name]
+		case "query":
+			if !query.Has(param.Name) {
+				if param.Required {
+					invalid = append(invalid, models.InvalidParam{In: param.In, Name: param.Name, Reason: "is required"})
+				}
+				continue
+			}
+			raw = query.Get(param.Name)
+		default:
+			continue
+		}
+
+		for _, v := range spec.ValidateParam(param.Schema, raw) {
+			invalid = append(invalid, models.InvalidParam{In: param.In, Name: param.Name, Reason: v.Message})
+		}
+	}
+	return invalid
+}
+
+func validateBody(spec *openapi.Document, requestBody *openapi.RequestBody, body []byte) []models.InvalidParam {
+	media, ok := requestBody.Content["application/json"]
+	if !ok {
+		return nil
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		if requestBody.Required {
+			return []models.InvalidParam{{In: "body", Reason: "is required"}}
+		}
+		return nil
+	}
+
+	value, err := decodeJSON(body)
+	if err != nil {
+		return []models.InvalidParam{{In: "body", Reason: "is not valid JSON"}}
+	}
+
+	var invalid []models.InvalidParam
+	for _, v := range spec.ValidateRequest(media.Schema, value) {
+		invalid = append(invalid, models.InvalidParam{In: "body", Name: v.Pointer, Reason: v.Message})
+	}
+	return invalid
+}
+
+// decodeJSON decodes exactly one JSON value, keeping numbers as json.Number
+func decodeJSON(body []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return value, nil
+}
+
+// ResponseValidationMiddleware checks each response against its operation in
+// spec. A response with an undocumented status, content type or body is
+// logged and replaced by a 500, so tests notice the spec drifting from the
+// handlers. Responses are buffered, so it is meant for tests rather than
+// production; server-sent event streams are passed through unchecked.
+func ResponseValidationMiddleware(spec *openapi.Document) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			op := spec.Operation(r.Method, routeTemplate(r))
+			if op == nil || streams(op) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			recorder := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+
+			if violations := validateResponse(spec, op, recorder); len(violations) > 0 {
+				logger.Logger.WithContext(r.Context()).WithFields(logrus.Fields{
+					"path":        r.URL.Path,
+					"method":      r.Method,
+					"status_code": recorder.statusCode,
+					"violations":  violations,
+					"type":        "validation",
+				}).Error("Response does not match the OpenAPI document")
+
+				w.Header().Del("Content-Length")
+				problem.Write(w, r, http.StatusInternalServerError, "response does not match the OpenAPI document: "+strings.Join(violations, "; "))
+				return
+			}
+
+			w.WriteHeader(recorder.statusCode)
+			w.Write(recorder.body.Bytes())
+		})
+	}
+}
+
+// streams reports whether op responds with server-sent events
+func streams(op *openapi.Operation) bool {
+	for _, response := range op.Responses {
+		if _, ok := response.Content["text/event-stream"]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func validateResponse(spec *openapi.Document, op *openapi.Operation, recorder *responseRecorder) []string {
+	status := strconv.Itoa(recorder.statusCode)
+	response := spec.ResolveResponse(op.Responses[status])
+	if response == nil {
+		return []string{"status " + status + " is not documented"}
+	}
+	if len(response.Content) == 0 {
+		return nil
+	}
+
+	contentType, _, _ := mime.ParseMediaType(recorder.Header().Get("Content-Type"))
+	media, ok := response.Content[contentType]
+	if !ok {
+		return []string{"content type " + strconv.Quote(contentType) + " is not documented for status " + status}
+	}
+	if contentType != "application/json" && contentType != problem.ContentType {
+		return nil
+	}
+
+	value, err := decodeJSON(recorder.body.Bytes())
+	if err != nil {
+		return []string{"body is not valid JSON"}
+	}
+	var violations []string
+	for _, v := range spec.ValidateResponse(media.Schema, value) {
+		if v.Pointer == "" {
+			violations = append(violations, "body "+v.Message)
+		} else {
+			violations = append(violations, "body field "+v.Pointer+" "+v.Message)
+		}
+	}
+	return violations
+}
+
+// responseRecorder holds a response back until it has been validated
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rr *responseRecorder) WriteHeader(code int) {
+	if !rr.wroteHeader {
+		rr.statusCode = code
+		rr.wroteHeader = true
+	}
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	rr.wroteHeader = true
+	return rr.body.Write(b)
+}
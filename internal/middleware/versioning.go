@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/metrics"
+	"github.com/gorilla/mux"
+)
+
+// APIVersion describes a version of the REST API mounted under its own
+// path prefix
+type APIVersion struct {
+	Name string // e.g. v1, the label of its usage metrics
+
+	// When the version was deprecated and when it stops working; zero until
+	// announced
+	Deprecated time.Time
+	Sunset     time.Time
+
+	// Successor returns the path replacing the request's in the next
+	// version, if it has one. Nil for the latest version.
+	Successor func(r *http.Request) (string, bool)
+}
+
+// VersionMiddleware counts the requests to version and, once the version is
+// deprecated, announces it on the routes that have a successor with the
+// Deprecation (RFC 9745) and Sunset (RFC 8594) headers and a
+// successor-version link. Routes without a successor are not deprecated:
+// there is nothing to move to yet.
+func VersionMiddleware(version APIVersion) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var successor string
+			superseded := false
+			if version.Successor != nil {
+				successor, superseded = version.Successor(r)
+			}
+			metrics.RecordAPIRequest(version.Name, superseded)
+
+			if superseded && !version.Deprecated.IsZero() {
+				w.Header().Set("Deprecation", "@"+strconv.FormatInt(version.Deprecated.Unix(), 10))
+				w.Header().Add("Link", "<"+successor+`>; rel="successor-version"`)
+				if !version.Sunset.IsZero() {
+					w.Header().Set("Sunset", version.Sunset.UTC().Format(http.TimeFormat))
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Actor identifies who made a change and from where
+type Actor struct {
+	UserID    primitive.ObjectID
+	Username  string
+	IPAddress string
+	RequestID string
+}
+
+// AuditEvent is an append-only record of one catalog or account change
+type AuditEvent struct {
+	ID            primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	EventID       string                 `bson:"event_id,omitempty" json:"event_id,omitempty"`
+	Timestamp     time.Time              `bson:"timestamp" json:"timestamp"`
+	ActorID       primitive.ObjectID     `bson:"actor_id" json:"actor_id"`
+	ActorUsername string                 `bson:"actor_username" json:"actor_username"`
+	Action        string                 `bson:"action" json:"action"`
+	TargetType    string                 `bson:"target_type" json:"target_type"`
+	TargetID      string                 `bson:"target_id" json:"target_id"`
+	Changes       map[string]FieldChange `bson:"changes,omitempty" json:"changes,omitempty"`
+	RequestID     string                 `bson:"request_id,omitempty" json:"request_id,omitempty"`
+	IPAddress     string                 `bson:"ip_address,omitempty" json:"ip_address,omitempty"`
+}
+
+// FieldChange holds a field's value before and after a change
+type FieldChange struct {
+	Before interface{} `bson:"before" json:"before"`
+	After  interface{} `bson:"after" json:"after"`
+}
+
+// AuditQuery holds the filters accepted by the audit log API
+type AuditQuery struct {
+	ActorID    *primitive.ObjectID
+	TargetType string
+	TargetID   string
+	Action     string
+	From       time.Time
+	To         time.Time
+	Page       int
+	Limit      int
+}
+
+type AuditListResponse struct {
+	Events []AuditEvent `json:"events"`
+	Total  int64        `json:"total"`
+	Page   int          `json:"page"`
+	Limit  int          `json:"limit"`
+}
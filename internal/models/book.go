@@ -19,3 +19,20 @@ type Book struct {
 	CreatedAt time.Time `json:"created_at" bson:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
 }
+
+// BookListQuery holds the filters accepted when listing the catalog
+type BookListQuery struct {
+	Search   string // matched against title, author and ISBN
+	Author   string
+	Genre    string
+	Language string
+	Page     int
+	Limit    int
+}
+
+type BookListResponse struct {
+	Books []Book `json:"books"`
+	Total int64  `json:"total"`
+	Page  int    `json:"page"`
+	Limit int    `json:"limit"`
+}
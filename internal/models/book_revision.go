@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BookRevision is a snapshot of a catalog record after one change
+type BookRevision struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	BookID        int                `bson:"book_id" json:"book_id"`
+	Rev           int                `bson:"rev" json:"rev"`
+	Book          Book               `bson:"book" json:"book"`
+	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
+	ActorID       primitive.ObjectID `bson:"actor_id,omitempty" json:"actor_id,omitempty"`
+	ActorUsername string             `bson:"actor_username,omitempty" json:"actor_username,omitempty"`
+	RevertedFrom  int                `bson:"reverted_from,omitempty" json:"reverted_from,omitempty"` // set when this revision restored an earlier one
+
+	// Field-level changes relative to the previous revision, filled in when listing history
+	Changes map[string]FieldChange `bson:"-" json:"changes,omitempty"`
+}
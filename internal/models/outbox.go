@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// Outbox record states
+const (
+	OutboxPending   = "pending"
+	OutboxDelivered = "delivered"
+	OutboxDead      = "dead" // gave up after the maximum number of attempts
+)
+
+// OutboxRecord is a domain event written in the same transaction as the
+// change it describes, waiting to be relayed to the event bus
+type OutboxRecord struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty"`
+	EventID       string             `bson:"event_id"`
+	EventName     string             `bson:"event_name"`
+	Payload       bson.Raw           `bson:"payload"`
+	Status        string             `bson:"status"`
+	Attempts      int                `bson:"attempts"`
+	LastError     string             `bson:"last_error,omitempty"`
+	NextAttemptAt time.Time          `bson:"next_attempt_at"`
+	LockedUntil   time.Time          `bson:"locked_until"`
+	CreatedAt     time.Time          `bson:"created_at"`
+	DeliveredAt   *time.Time         `bson:"delivered_at,omitempty"`
+}
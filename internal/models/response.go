@@ -11,3 +11,30 @@ type UserResponse struct {
 	Message string      `json:"message"`
 	Data    interface{} `json:"data"`
 }
+
+// Problem is an RFC 7807 problem details object, the body of every error response
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+
+	// Set when a request fails validation, one entry per problem found
+	InvalidParams []InvalidParam `json:"invalid_params,omitempty"`
+}
+
+// InvalidParam is one reason a request failed validation
+type InvalidParam struct {
+	In     string `json:"in"`             // path, query or body
+	Name   string `json:"name,omitempty"` // parameter name, or JSON pointer into the body
+	Reason string `json:"reason"`
+}
+
+type BookHistoryResponse struct {
+	Status    string         `json:"status"`
+	Message   string         `json:"message"`
+	BookID    int            `json:"book_id"`
+	Revisions []BookRevision `json:"revisions"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session is one issued login token. Revoking it invalidates the token
+// before it expires.
+type Session struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID     primitive.ObjectID `bson:"user_id" json:"-"`
+	UserAgent  string             `bson:"user_agent" json:"user_agent"`
+	IPAddress  string             `bson:"ip_address" json:"ip_address"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	LastSeenAt time.Time          `bson:"last_seen_at" json:"last_seen_at"`
+	ExpiresAt  time.Time          `bson:"expires_at" json:"expires_at"`
+	RevokedAt  *time.Time         `bson:"revoked_at,omitempty" json:"-"`
+
+	Current bool `bson:"-" json:"current"` // whether this is the session making the request
+}
+
+// ClientInfo describes the device a login came from
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
@@ -15,6 +15,12 @@ type User struct {
 	IsActive  bool               `bson:"is_active" json:"is_active"`
 	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
 	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+
+	MustChangePassword bool `bson:"must_change_password" json:"must_change_password"` // set by an admin password reset
+
+	// Set for accounts that sign in through an external identity provider
+	AuthProvider string `bson:"auth_provider,omitempty" json:"auth_provider,omitempty"`
+	ExternalID   string `bson:"external_id,omitempty" json:"-"`
 }
 
 type LoginRequest struct {
@@ -25,11 +31,48 @@ type LoginRequest struct {
 type RegisterRequest struct {
 	Username string `json:"username" validate:"required,min=3,max=50"`
 	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required,min=6"`
-	Role     string `json:"role,omitempty"` // Optional, defaults to "user"
+	Password string `json:"password" validate:"required"` // length is up to the password policy
 }
 
 type LoginResponse struct {
 	Token string `json:"token"`
 	User  User   `json:"user"`
 }
+
+// UserListQuery holds the filters accepted by the admin user listing
+type UserListQuery struct {
+	Search   string // matched against username and email
+	Role     string
+	IsActive *bool
+	Page     int
+	Limit    int
+}
+
+type UserListResponse struct {
+	Users []User `json:"users"`
+	Total int64  `json:"total"`
+	Page  int    `json:"page"`
+	Limit int    `json:"limit"`
+}
+
+type UpdateProfileRequest struct {
+	Username string `json:"username,omitempty" validate:"omitempty,min=3,max=50"`
+	Email    string `json:"email,omitempty" validate:"omitempty,email"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"` // length is up to the password policy
+}
+
+type UpdateRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=admin user"`
+}
+
+type UpdateStatusRequest struct {
+	IsActive *bool `json:"is_active" validate:"required"`
+}
+
+type PasswordResetResponse struct {
+	TemporaryPassword string `json:"temporary_password"`
+}
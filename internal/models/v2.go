@@ -0,0 +1,143 @@
+package models
+
+import (
+	"strconv"
+	"time"
+)
+
+// Version 2 of the REST API wraps every response body in an envelope and
+// identifies every resource by a string. The types below are the v2 shapes
+// of resources whose v1 shape differs; the others, such as User, are sent
+// as they are.
+
+// Envelope is the body of every successful v2 response
+type Envelope struct {
+	Data interface{} `json:"data"`
+	Meta *PageMeta   `json:"meta,omitempty"` // set on paginated lists
+}
+
+type PageMeta struct {
+	Total int64 `json:"total"`
+	Page  int   `json:"page"`
+	Limit int   `json:"limit"`
+}
+
+// BookV2 is a Book identified by a string
+type BookV2 struct {
+	ID          string    `json:"id"`
+	ISBN        string    `json:"isbn"`
+	Title       string    `json:"title"`
+	Author      string    `json:"author"`
+	Publisher   string    `json:"publisher"`
+	PublishedAt time.Time `json:"published_at"`
+	Genre       string    `json:"genre"`
+	Language    string    `json:"language"`
+	Pages       int       `json:"pages"`
+	Description string    `json:"description"`
+	CoverURL    string    `json:"cover_url"`
+
+	Location  string    `json:"location"` // shelf location
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func NewBookV2(book Book) BookV2 {
+	return BookV2{
+		ID:          strconv.Itoa(book.ID),
+		ISBN:        book.ISBN,
+		Title:       book.Title,
+		Author:      book.Author,
+		Publisher:   book.Publisher,
+		PublishedAt: book.PublishedAt,
+		Genre:       book.Genre,
+		Language:    book.Language,
+		Pages:       book.Pages,
+		Description: book.Description,
+		CoverURL:    book.CoverURL,
+		Location:    book.Location,
+		CreatedAt:   book.CreatedAt,
+		UpdatedAt:   book.UpdatedAt,
+	}
+}
+
+func NewBooksV2(books []Book) []BookV2 {
+	out := make([]BookV2, len(books))
+	for i, book := range books {
+		out[i] = NewBookV2(book)
+	}
+	return out
+}
+
+// Book returns the fields clients may set; the ID and timestamps are left
+// zero
+func (b BookV2) Book() Book {
+	return Book{
+		ISBN:        b.ISBN,
+		Title:       b.Title,
+		Author:      b.Author,
+		Publisher:   b.Publisher,
+		PublishedAt: b.PublishedAt,
+		Genre:       b.Genre,
+		Language:    b.Language,
+		Pages:       b.Pages,
+		Description: b.Description,
+		CoverURL:    b.CoverURL,
+		Location:    b.Location,
+	}
+}
+
+// BookRevisionV2 is a BookRevision identified by strings
+type BookRevisionV2 struct {
+	BookID        string                 `json:"book_id"`
+	Rev           int                    `json:"rev"`
+	Book          BookV2                 `json:"book"`
+	CreatedAt     time.Time              `json:"created_at"`
+	ActorID       string                 `json:"actor_id,omitempty"`
+	ActorUsername string                 `json:"actor_username,omitempty"`
+	RevertedFrom  int                    `json:"reverted_from,omitempty"` // set when this revision restored an earlier one
+	Changes       map[string]FieldChange `json:"changes,omitempty"`
+}
+
+func NewBookRevisionsV2(revisions []BookRevision) []BookRevisionV2 {
+	out := make([]BookRevisionV2, len(revisions))
+	for i, revision := range revisions {
+		out[i] = BookRevisionV2{
+			BookID:        strconv.Itoa(revision.BookID),
+			Rev:           revision.Rev,
+			Book:          NewBookV2(revision.Book),
+			CreatedAt:     revision.CreatedAt,
+			ActorUsername: revision.ActorUsername,
+			RevertedFrom:  revision.RevertedFrom,
+			Changes:       changesV2(revision.Changes),
+		}
+		if !revision.ActorID.IsZero() {
+			out[i].ActorID = revision.ActorID.Hex()
+		}
+	}
+	return out
+}
+
+// changesV2 returns changes with the book ID as a string, like the rest of
+// the revision
+func changesV2(changes map[string]FieldChange) map[string]FieldChange {
+	change, ok := changes["id"]
+	if !ok {
+		return changes
+	}
+	out := make(map[string]FieldChange, len(changes))
+	for field, c := range changes {
+		out[field] = c
+	}
+	out["id"] = FieldChange{Before: idString(change.Before), After: idString(change.After)}
+	return out
+}
+
+func idString(v interface{}) interface{} {
+	switch id := v.(type) {
+	case int:
+		return strconv.Itoa(id)
+	case float64:
+		return strconv.FormatFloat(id, 'f', -1, 64)
+	}
+	return v
+}
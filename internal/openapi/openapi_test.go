@@ -0,0 +1,85 @@
+package openapi
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type testAuthor struct {
+	Name string `json:"name"`
+}
+
+type testBook struct {
+	ID        primitive.ObjectID `json:"id"`
+	Title     string             `json:"title" validate:"required,min=1,max=200"`
+	Format    string             `json:"format,omitempty" validate:"omitempty,oneof=paper ebook"`
+	Contact   string             `json:"contact" validate:"email"`
+	Pages     int                `json:"pages" validate:"min=1"`
+	Tags      []string           `json:"tags"`
+	Author    *testAuthor        `json:"author"`
+	Extra     map[string]int     `json:"extra"`
+	Published time.Time          `json:"published"`
+	Secret    string             `json:"-"`
+	internal  string
+}
+
+func TestSchemaFromStruct(t *testing.T) {
+	doc := New(Info{Title: "test", Version: "1"})
+
+	ref := doc.Schema(testBook{})
+	if ref.Ref != "#/components/schemas/testBook" {
+		t.Fatalf("Schema returned %+v, want a reference to testBook", ref)
+	}
+	book := doc.Resolve(ref)
+	if book == nil {
+		t.Fatal("testBook is not in the components")
+	}
+
+	if book.Type != "object" || book.AdditionalProperties != false {
+		t.Errorf("struct schema is %q with additionalProperties %v, want a closed object", book.Type, book.AdditionalProperties)
+	}
+	if !reflect.DeepEqual(book.Required, []string{"title"}) {
+		t.Errorf("required = %v, want [title]", book.Required)
+	}
+	for _, name := range []string{"Secret", "internal", "-"} {
+		if _, ok := book.Properties[name]; ok {
+			t.Errorf("property %q should not be documented", name)
+		}
+	}
+
+	tests := []struct {
+		property string
+		want     Schema
+	}{
+		{"id", Schema{Type: "string", Pattern: ObjectIDPattern}},
+		{"title", Schema{Type: "string", MinLength: intPtr(1), MaxLength: intPtr(200)}},
+		{"format", Schema{Type: "string", Enum: []string{"paper", "ebook"}}},
+		{"contact", Schema{Type: "string", Format: "email"}},
+		{"pages", Schema{Type: "integer", Minimum: floatPtr(1)}},
+		{"tags", Schema{Type: "array", Items: &Schema{Type: "string"}}},
+		{"author", Schema{Ref: "#/components/schemas/testAuthor"}},
+		{"extra", Schema{Type: "object", AdditionalProperties: &Schema{Type: "integer"}}},
+		{"published", Schema{Type: "string", Format: "date-time"}},
+	}
+	for _, tt := range tests {
+		got := book.Properties[tt.property]
+		if got == nil {
+			t.Errorf("property %q is missing", tt.property)
+			continue
+		}
+		if !reflect.DeepEqual(*got, tt.want) {
+			t.Errorf("property %q = %+v, want %+v", tt.property, *got, tt.want)
+		}
+	}
+
+	if doc.Resolve(book.Properties["author"]) == nil {
+		t.Error("nested struct testAuthor is not in the components")
+	}
+}
+
+func intPtr(n int) *int { return &n }
+
+func floatPtr(f float64) *float64 { return &f }
@@ -0,0 +1,203 @@
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Schema is the subset of JSON Schema the API uses
+type Schema struct {
+	Ref         string             `json:"$ref,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Pattern     string             `json:"pattern,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	MinLength   *int               `json:"minLength,omitempty"`
+	MaxLength   *int               `json:"maxLength,omitempty"`
+	Minimum     *float64           `json:"minimum,omitempty"`
+	Maximum     *float64           `json:"maximum,omitempty"`
+	ReadOnly    bool               `json:"readOnly,omitempty"`
+
+	// AdditionalProperties is false for structs, which have a fixed set of
+	// fields, and the value schema for maps
+	AdditionalProperties interface{} `json:"additionalProperties,omitempty"`
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	objectIDType = reflect.TypeOf(primitive.ObjectID{})
+)
+
+// ObjectIDPattern matches the hex form of a MongoDB ObjectID
+const ObjectIDPattern = "^[0-9a-f]{24}$"
+
+// String, Integer and Boolean are schemas for plain parameters
+func String() *Schema  { return &Schema{Type: "string"} }
+func Integer() *Schema { return &Schema{Type: "integer"} }
+func Boolean() *Schema { return &Schema{Type: "boolean"} }
+
+// ObjectID is the schema of a MongoDB ObjectID
+func ObjectID() *Schema {
+	return &Schema{Type: "string", Pattern: ObjectIDPattern}
+}
+
+// Array is the schema of a list of items
+func Array(items *Schema) *Schema {
+	return &Schema{Type: "array", Items: items}
+}
+
+// Object is the schema of a fixed set of properties
+func Object(properties map[string]*Schema, required ...string) *Schema {
+	return &Schema{Type: "object", Properties: properties, Required: required, AdditionalProperties: false}
+}
+
+// Schema returns the schema of v's type. Structs are added to the
+// components under their type name and returned as a reference. Besides the
+// json tags, the required, min, max, email, url and oneof rules of validate
+// tags are carried over.
+func (d *Document) Schema(v interface{}) *Schema {
+	if v == nil {
+		return &Schema{}
+	}
+	return d.schemaOf(reflect.TypeOf(v))
+}
+
+// Resolve follows a component reference
+func (d *Document) Resolve(s *Schema) *Schema {
+	for s != nil && s.Ref != "" {
+		s = d.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+func (d *Document) schemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case objectIDType:
+		return ObjectID()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return String()
+	case reflect.Bool:
+		return Boolean()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Integer()
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return Array(d.schemaOf(t.Elem()))
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.structSchema(t)
+		}
+		ref := &Schema{Ref: "#/components/schemas/" + t.Name()}
+		if _, ok := d.Components.Schemas[t.Name()]; !ok {
+			// Registered before the fields are walked, for recursive types
+			d.Components.Schemas[t.Name()] = &Schema{}
+			*d.Components.Schemas[t.Name()] = *d.structSchema(t)
+		}
+		return ref
+	}
+
+	// interface{} and anything else accepts any value
+	return &Schema{}
+}
+
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	s := Object(map[string]*Schema{})
+	d.addFields(s, t)
+	return s
+}
+
+func (d *Document) addFields(s *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			d.addFields(s, field.Type)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := d.schemaOf(field.Type)
+		if validate := field.Tag.Get("validate"); validate != "" {
+			if property.Ref == "" {
+				property = applyRules(property, validate)
+			}
+			if hasRule(validate, "required") {
+				s.Required = append(s.Required, name)
+			}
+		}
+		s.Properties[name] = property
+	}
+}
+
+// applyRules adds the constraints of a validate tag to a copy of s
+func applyRules(s *Schema, validate string) *Schema {
+	c := *s
+	for _, rule := range strings.Split(validate, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		switch name {
+		case "email":
+			c.Format = "email"
+		case "url":
+			c.Format = "uri"
+		case "oneof":
+			c.Enum = strings.Fields(arg)
+		case "min", "max":
+			n, err := strconv.Atoi(arg)
+			if err != nil {
+				continue
+			}
+			switch {
+			case c.Type == "string" && name == "min":
+				c.MinLength = &n
+			case c.Type == "string":
+				c.MaxLength = &n
+			case name == "min":
+				f := float64(n)
+				c.Minimum = &f
+			default:
+				f := float64(n)
+				c.Maximum = &f
+			}
+		}
+	}
+	return &c
+}
+
+func hasRule(validate, rule string) bool {
+	for _, r := range strings.Split(validate, ",") {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
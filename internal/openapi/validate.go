@@ -0,0 +1,231 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Violation is one way a value fails its schema
+type Violation struct {
+	Pointer string // JSON pointer to the offending value; empty for the value itself
+	Message string
+}
+
+// ValidateRequest checks a request body, decoded with json.Decoder.UseNumber,
+// against s. Besides the schema rules, read-only properties are rejected.
+func (d *Document) ValidateRequest(s *Schema, value interface{}) []Violation {
+	var violations []Violation
+	d.validate(s, value, "", true, &violations)
+	return violations
+}
+
+// ValidateResponse checks a response body, decoded with
+// json.Decoder.UseNumber, against s
+func (d *Document) ValidateResponse(s *Schema, value interface{}) []Violation {
+	var violations []Violation
+	d.validate(s, value, "", false, &violations)
+	return violations
+}
+
+// ValidateParam checks the raw value of a path or query parameter against s
+func (d *Document) ValidateParam(s *Schema, raw string) []Violation {
+	s = d.Resolve(s)
+	if s == nil {
+		return nil
+	}
+
+	var value interface{} = raw
+	switch s.Type {
+	case "integer", "number":
+		value = json.Number(raw)
+	case "boolean":
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return []Violation{{Message: "must be a boolean"}}
+		}
+		value = b
+	}
+	return d.ValidateRequest(s, value)
+}
+
+func (d *Document) validate(s *Schema, value interface{}, pointer string, request bool, out *[]Violation) {
+	s = d.Resolve(s)
+	if s == nil {
+		return
+	}
+	fail := func(format string, args ...interface{}) {
+		*out = append(*out, Violation{Pointer: pointer, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch s.Type {
+	case "":
+		// Any value
+	case "null":
+		if value != nil {
+			fail("must be null, not %s", kindOf(value))
+		}
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			fail("must be an object, not %s", kindOf(value))
+			return
+		}
+		for _, name := range s.Required {
+			if _, ok := object[name]; !ok {
+				*out = append(*out, Violation{Pointer: pointer + "/" + escapePointer(name), Message: "is required"})
+			}
+		}
+
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			child := pointer + "/" + escapePointer(name)
+			property, known := s.Properties[name]
+			switch {
+			case known && request && property.ReadOnly:
+				*out = append(*out, Violation{Pointer: child, Message: "is read-only"})
+			case known:
+				d.validate(property, object[name], child, request, out)
+			case s.AdditionalProperties == false:
+				*out = append(*out, Violation{Pointer: child, Message: "is not a known field"})
+			default:
+				if additional, ok := s.AdditionalProperties.(*Schema); ok {
+					d.validate(additional, object[name], child, request, out)
+				}
+			}
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			fail("must be an array, not %s", kindOf(value))
+			return
+		}
+		for i, item := range items {
+			d.validate(s.Items, item, pointer+"/"+strconv.Itoa(i), request, out)
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			fail("must be a string, not %s", kindOf(value))
+			return
+		}
+		length := utf8.RuneCountInString(str)
+		switch {
+		case s.MinLength != nil && length < *s.MinLength:
+			fail("must be at least %d characters", *s.MinLength)
+		case s.MaxLength != nil && length > *s.MaxLength:
+			fail("must be at most %d characters", *s.MaxLength)
+		case len(s.Enum) > 0 && !contains(s.Enum, str):
+			fail("must be one of %s", strings.Join(s.Enum, ", "))
+		case s.Pattern == ObjectIDPattern && !compilePattern(s.Pattern).MatchString(str):
+			fail("must be an ObjectID of 24 hex digits")
+		case s.Pattern != "" && !compilePattern(s.Pattern).MatchString(str):
+			fail("must match %s", s.Pattern)
+		case s.Format != "" && !validFormat(s.Format, str):
+			fail("must be a valid %s", s.Format)
+		}
+	case "integer", "number":
+		number, ok := value.(json.Number)
+		if !ok {
+			fail("must be %s, not %s", article(s.Type), kindOf(value))
+			return
+		}
+		if s.Type == "integer" {
+			if _, err := number.Int64(); err != nil {
+				fail("must be an integer")
+				return
+			}
+		}
+		f, err := number.Float64()
+		switch {
+		case err != nil:
+			fail("must be a number")
+		case s.Minimum != nil && f < *s.Minimum:
+			fail("must be at least %v", *s.Minimum)
+		case s.Maximum != nil && f > *s.Maximum:
+			fail("must be at most %v", *s.Maximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("must be a boolean, not %s", kindOf(value))
+		}
+	}
+}
+
+// kindOf names the JSON type of a decoded value, for messages
+func kindOf(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "an array"
+	case string:
+		return "a string"
+	case json.Number:
+		return "a number"
+	case bool:
+		return "a boolean"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func article(schemaType string) string {
+	if schemaType == "integer" {
+		return "an integer"
+	}
+	return "a " + schemaType
+}
+
+func validFormat(format, value string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, value)
+		return err == nil
+	case "email":
+		address, err := mail.ParseAddress(value)
+		return err == nil && address.Address == value
+	case "uri":
+		u, err := url.Parse(value)
+		return err == nil && u.Scheme != "" && u.Host != ""
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// escapePointer escapes a property name as a JSON pointer token
+func escapePointer(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
+
+var patterns sync.Map // pattern string → *regexp.Regexp
+
+func compilePattern(pattern string) *regexp.Regexp {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re := regexp.MustCompile(pattern)
+	patterns.Store(pattern, re)
+	return re
+}
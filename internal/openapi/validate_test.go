@@ -0,0 +1,96 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testShelf struct {
+	ID    int               `json:"id"`
+	Label string            `json:"label" validate:"required,max=5"`
+	Kind  string            `json:"kind,omitempty" validate:"omitempty,oneof=wall floor"`
+	Books []testBook        `json:"books"`
+	Notes map[string]string `json:"notes"`
+	Open  bool              `json:"open"`
+}
+
+func TestValidateRequest(t *testing.T) {
+	doc := New(Info{Title: "test", Version: "1"})
+	schema := doc.Schema(testShelf{})
+	doc.Resolve(schema).Properties["id"].ReadOnly = true
+
+	tests := []struct {
+		name string
+		body string
+		want []Violation
+	}{
+		{"valid", `{"label":"A-1","kind":"wall","notes":{"x":"y"},"open":true}`, nil},
+		{"not an object", `[]`, []Violation{{"", "must be an object, not an array"}}},
+		{"missing required", `{}`, []Violation{{"/label", "is required"}}},
+		{"unknown field", `{"label":"A","colour":"red"}`, []Violation{{"/colour", "is not a known field"}}},
+		{"read-only field", `{"id":1,"label":"A"}`, []Violation{{"/id", "is read-only"}}},
+		{"type mismatch", `{"label":1,"open":"yes"}`, []Violation{
+			{"/label", "must be a string, not a number"},
+			{"/open", "must be a boolean, not a string"},
+		}},
+		{"too long", `{"label":"ABCDEF"}`, []Violation{{"/label", "must be at most 5 characters"}}},
+		{"not in enum", `{"label":"A","kind":"roof"}`, []Violation{{"/kind", "must be one of wall, floor"}}},
+		{"map values", `{"label":"A","notes":{"x":1}}`, []Violation{{"/notes/x", "must be a string, not a number"}}},
+		{"nested items", `{"label":"A","books":[{"title":"T","pages":1.5,"id":"zz"}]}`, []Violation{
+			{"/books/0/id", "must be an ObjectID of 24 hex digits"},
+			{"/books/0/pages", "must be an integer"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoder := json.NewDecoder(strings.NewReader(tt.body))
+			decoder.UseNumber()
+			var value interface{}
+			if err := decoder.Decode(&value); err != nil {
+				t.Fatal(err)
+			}
+			if got := doc.ValidateRequest(schema, value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateRequest(%s) = %v, want %v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateResponseAllowsReadOnly(t *testing.T) {
+	doc := New(Info{Title: "test", Version: "1"})
+	schema := doc.Schema(testShelf{})
+	doc.Resolve(schema).Properties["id"].ReadOnly = true
+
+	value := map[string]interface{}{"id": json.Number("1"), "label": "A"}
+	if got := doc.ValidateResponse(schema, value); got != nil {
+		t.Errorf("ValidateResponse = %v, want no violations", got)
+	}
+}
+
+func TestValidateParam(t *testing.T) {
+	doc := New(Info{Title: "test", Version: "1"})
+	tests := []struct {
+		schema *Schema
+		raw    string
+		want   string
+	}{
+		{Integer(), "12", ""},
+		{Integer(), "x", "must be an integer"},
+		{Boolean(), "true", ""},
+		{Boolean(), "maybe", "must be a boolean"},
+		{ObjectID(), "5f1d7f5b2c3a4b5c6d7e8f90", ""},
+		{&Schema{Type: "string", Format: "date-time"}, "2024-01-02T03:04:05Z", ""},
+		{&Schema{Type: "string", Format: "date-time"}, "yesterday", "must be a valid date-time"},
+	}
+	for _, tt := range tests {
+		var got string
+		if violations := doc.ValidateParam(tt.schema, tt.raw); len(violations) > 0 {
+			got = violations[0].Message
+		}
+		if got != tt.want {
+			t.Errorf("ValidateParam(%s, %q) = %q, want %q", tt.schema.Type, tt.raw, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+// Package problem writes error responses as RFC 7807 problem details, so
+// every failure the API returns has the same application/problem+json shape.
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/4Noyis/my-library/internal/services"
+)
+
+const ContentType = "application/problem+json"
+
+// Write responds with a problem of the given status. The title is the
+// standard status text and detail explains this occurrence to the client.
+// The request ID is included so the error can be matched to the server logs.
+func Write(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	write(w, newProblem(r, status, detail))
+}
+
+// WriteInvalid responds with a 400 listing every reason the request failed
+// validation
+func WriteInvalid(w http.ResponseWriter, r *http.Request, params []models.InvalidParam) {
+	reasons := make([]string, len(params))
+	for i, param := range params {
+		reasons[i] = describe(param)
+	}
+
+	p := newProblem(r, http.StatusBadRequest, strings.Join(reasons, "; "))
+	p.InvalidParams = params
+	write(w, p)
+}
+
+// WriteError responds with the problem for a service error. Errors of an
+// unknown kind become a 500 whose detail does not repeat the error message.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	status := Status(err)
+	detail := err.Error()
+	if status == http.StatusInternalServerError {
+		detail = "internal server error"
+	}
+	Write(w, r, status, detail)
+}
+
+func newProblem(r *http.Request, status int, detail string) models.Problem {
+	return models.Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		RequestID: logger.RequestIDFromContext(r.Context()),
+	}
+}
+
+func write(w http.ResponseWriter, p models.Problem) {
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// describe words an invalid parameter for the problem detail, e.g. "query
+// parameter page must be an integer" or "body field /title is required"
+func describe(param models.InvalidParam) string {
+	switch {
+	case param.In == "body" && param.Name == "":
+		return "body " + param.Reason
+	case param.In == "body":
+		return "body field " + param.Name + " " + param.Reason
+	}
+	return param.In + " parameter " + param.Name + " " + param.Reason
+}
+
+// Status maps the kind of a service error to an HTTP status code
+func Status(err error) int {
+	switch {
+	case errors.Is(err, services.ErrValidation):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, services.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrUpstream):
+		return http.StatusBadGateway
+	case errors.Is(err, services.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// NotFoundHandler answers requests for routes that do not exist
+func NotFoundHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Write(w, r, http.StatusNotFound, "no route matches "+r.URL.Path)
+	})
+}
+
+// MethodNotAllowedHandler answers requests whose route exists for other methods
+func MethodNotAllowedHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Write(w, r, http.StatusMethodNotAllowed, r.Method+" is not supported on "+r.URL.Path)
+	})
+}
@@ -0,0 +1,109 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/database"
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// AuditRepository only ever inserts and reads; audit events are never
+// updated or deleted
+type AuditRepository struct {
+	db         *database.DB
+	collection string
+}
+
+func NewAuditRepository(db *database.DB) *AuditRepository {
+	return &AuditRepository{
+		db:         db,
+		collection: "audit_events",
+	}
+}
+
+func (ar *AuditRepository) InsertEvent(ctx context.Context, event *models.AuditEvent) error {
+	start := time.Now()
+	ctx, cancel := ar.db.OperationContext(ctx)
+	defer cancel()
+
+	event.ID = primitive.NewObjectID()
+
+	if event.EventID == "" {
+		_, err := ar.db.Collection(ar.collection).InsertOne(ctx, event)
+		logger.LogDatabaseOperation(ctx, "insert", ar.collection, event.ID.Hex(), time.Since(start), err)
+		return err
+	}
+
+	// Redelivered events find their entry and leave it untouched
+	_, err := ar.db.Collection(ar.collection).UpdateOne(ctx,
+		bson.D{{Key: "event_id", Value: event.EventID}},
+		bson.D{{Key: "$setOnInsert", Value: event}},
+		options.UpdateOne().SetUpsert(true),
+	)
+	logger.LogDatabaseOperation(ctx, "upsert", ar.collection, event.EventID, time.Since(start), err)
+	return err
+}
+
+func (ar *AuditRepository) FindEvents(ctx context.Context, query models.AuditQuery) ([]models.AuditEvent, int64, error) {
+	start := time.Now()
+	ctx, cancel := ar.db.OperationContext(ctx)
+	defer cancel()
+
+	filter := bson.D{}
+	if query.ActorID != nil {
+		filter = append(filter, bson.E{Key: "actor_id", Value: *query.ActorID})
+	}
+	if query.TargetType != "" {
+		filter = append(filter, bson.E{Key: "target_type", Value: query.TargetType})
+	}
+	if query.TargetID != "" {
+		filter = append(filter, bson.E{Key: "target_id", Value: query.TargetID})
+	}
+	if query.Action != "" {
+		filter = append(filter, bson.E{Key: "action", Value: query.Action})
+	}
+
+	timeRange := bson.D{}
+	if !query.From.IsZero() {
+		timeRange = append(timeRange, bson.E{Key: "$gte", Value: query.From})
+	}
+	if !query.To.IsZero() {
+		timeRange = append(timeRange, bson.E{Key: "$lte", Value: query.To})
+	}
+	if len(timeRange) > 0 {
+		filter = append(filter, bson.E{Key: "timestamp", Value: timeRange})
+	}
+
+	collection := ar.db.Collection(ar.collection)
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		logger.LogDatabaseOperation(ctx, "count", ar.collection, nil, time.Since(start), err)
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "timestamp", Value: -1}}).
+		SetSkip(int64((query.Page - 1) * query.Limit)).
+		SetLimit(int64(query.Limit))
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		logger.LogDatabaseOperation(ctx, "find", ar.collection, nil, time.Since(start), err)
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	events := []models.AuditEvent{}
+	if err = cursor.All(ctx, &events); err != nil {
+		logger.LogDatabaseOperation(ctx, "find", ar.collection, nil, time.Since(start), err)
+		return nil, 0, err
+	}
+
+	logger.LogDatabaseOperation(ctx, "find", ar.collection, nil, time.Since(start), nil)
+	return events, total, nil
+}
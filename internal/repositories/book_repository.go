@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"context"
+	"regexp"
 	"time"
 
 	"github.com/4Noyis/my-library/internal/database"
@@ -14,53 +15,110 @@ import (
 )
 
 type BookRepository struct {
+	db         *database.DB
 	collection string
 }
 
-func NewBookCollection() *BookRepository {
+func NewBookCollection(db *database.DB) *BookRepository {
 	return &BookRepository{
+		db:         db,
 		collection: "books",
 	}
 }
 
-func (br *BookRepository) GetAllBooks() ([]models.Book, error) {
+func (br *BookRepository) GetAllBooks(ctx context.Context) ([]models.Book, error) {
 	start := time.Now()
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := br.db.OperationContext(ctx)
 	defer cancel()
 
-	collection := database.Collection("books")
+	collection := br.db.Collection("books")
 	cursor, err := collection.Find(ctx, bson.D{})
 	if err != nil {
-		logger.LogDatabaseOperation("find_all", "books", nil, time.Since(start).Milliseconds(), err)
+		logger.LogDatabaseOperation(ctx, "find_all", "books", nil, time.Since(start), err)
 		return nil, err
 	}
 	defer cursor.Close(ctx)
 
 	var books []models.Book
 	if err = cursor.All(ctx, &books); err != nil {
-		logger.LogDatabaseOperation("find_all", "books", nil, time.Since(start).Milliseconds(), err)
+		logger.LogDatabaseOperation(ctx, "find_all", "books", nil, time.Since(start), err)
 		return nil, err
 	}
 
-	logger.LogDatabaseOperation("find_all", "books", nil, time.Since(start).Milliseconds(), nil)
-	logger.LogDebug("Retrieved all books from database", logrus.Fields{
+	logger.LogDatabaseOperation(ctx, "find_all", "books", nil, time.Since(start), nil)
+	logger.LogDebug(ctx, "Retrieved all books from database", logrus.Fields{
 		"count":    len(books),
-		"duration": time.Since(start).Milliseconds(),
+		"duration": time.Since(start),
 	})
 
 	return books, nil
 }
 
-func (br *BookRepository) GetOneBook(id int) (models.Book, error) {
+// ListBooks returns one page of the books matching query, in ID order, and
+// the number of matches
+func (br *BookRepository) ListBooks(ctx context.Context, query models.BookListQuery) ([]models.Book, int64, error) {
 	start := time.Now()
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := br.db.OperationContext(ctx)
 	defer cancel()
 
-	collection := database.Collection("books")
+	filter := bson.D{}
+	if query.Search != "" {
+		pattern := bson.Regex{Pattern: regexp.QuoteMeta(query.Search), Options: "i"}
+		filter = append(filter, bson.E{Key: "$or", Value: bson.A{
+			bson.D{{Key: "title", Value: pattern}},
+			bson.D{{Key: "author", Value: pattern}},
+			bson.D{{Key: "isbn", Value: pattern}},
+		}})
+	}
+	if query.Author != "" {
+		filter = append(filter, bson.E{Key: "author", Value: query.Author})
+	}
+	if query.Genre != "" {
+		filter = append(filter, bson.E{Key: "genre", Value: query.Genre})
+	}
+	if query.Language != "" {
+		filter = append(filter, bson.E{Key: "language", Value: query.Language})
+	}
+
+	collection := br.db.Collection("books")
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		logger.LogDatabaseOperation(ctx, "count", "books", nil, time.Since(start), err)
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "id", Value: 1}}).
+		SetSkip(int64((query.Page - 1) * query.Limit)).
+		SetLimit(int64(query.Limit))
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		logger.LogDatabaseOperation(ctx, "find", "books", nil, time.Since(start), err)
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	books := []models.Book{}
+	err = cursor.All(ctx, &books)
+	logger.LogDatabaseOperation(ctx, "find", "books", nil, time.Since(start), err)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return books, total, nil
+}
+
+func (br *BookRepository) GetOneBook(ctx context.Context, id int) (models.Book, error) {
+	start := time.Now()
+	ctx, cancel := br.db.OperationContext(ctx)
+	defer cancel()
+
+	collection := br.db.Collection("books")
 	var book models.Book
 	err := collection.FindOne(ctx, bson.M{"id": id}).Decode(&book)
 
-	logger.LogDatabaseOperation("find_one", "books", id, time.Since(start).Milliseconds(), err)
+	logger.LogDatabaseOperation(ctx, "find_one", "books", id, time.Since(start), err)
 
 	if err != nil {
 		return models.Book{}, err
@@ -69,23 +127,25 @@ func (br *BookRepository) GetOneBook(id int) (models.Book, error) {
 	return book, nil
 }
 
-func (br *BookRepository) AddNewBook(book models.Book) (models.Book, error) {
+func (br *BookRepository) AddNewBook(ctx context.Context, book models.Book) (models.Book, error) {
 	start := time.Now()
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := br.db.OperationContext(ctx)
 	defer cancel()
 
-	collection := database.Collection("books")
-	// Find the highest existing ID
-	var lastBook models.Book
-	opts := options.FindOne().SetSort(bson.D{{Key: "id", Value: -1}})
-
-	err := collection.FindOne(ctx, bson.D{}, opts).Decode(&lastBook)
-	nextID := 1
-	if err == nil {
-		nextID = lastBook.ID + 1
-	} else if err != mongo.ErrNoDocuments {
-		logger.LogError("AddNewBook", err, logrus.Fields{
-			"operation": "find_last_id",
+	collection := br.db.Collection("books")
+	// IDs of deleted books are never handed out again, so a new book can't
+	// inherit the revisions, audit trail or webhooks of an old one
+	nextID, err := nextSequence(ctx, br.db, "books", func(ctx context.Context) (int, error) {
+		lastID, err := maxValue(ctx, br.db, "books", bson.D{}, "id")
+		if err != nil {
+			return 0, err
+		}
+		lastRevisedID, err := maxValue(ctx, br.db, "book_revisions", bson.D{}, "book_id")
+		return max(lastID, lastRevisedID), err
+	})
+	if err != nil {
+		logger.LogError(ctx, "AddNewBook", err, logrus.Fields{
+			"operation": "next_id",
 		})
 		return book, err
 	}
@@ -97,38 +157,38 @@ func (br *BookRepository) AddNewBook(book models.Book) (models.Book, error) {
 
 	ss, err := collection.InsertOne(ctx, book)
 
-	logger.LogDatabaseOperation("insert", "books", book.ID, time.Since(start).Milliseconds(), err)
+	logger.LogDatabaseOperation(ctx, "insert", "books", book.ID, time.Since(start), err)
 
 	if err != nil {
-		logger.LogError("AddNewBook", err, logrus.Fields{
+		logger.LogError(ctx, "AddNewBook", err, logrus.Fields{
 			"operation": "insert_one",
 			"book_id":   book.ID,
 		})
 		return book, err
 	}
 
-	logger.LogInfo("Book added successfully", logrus.Fields{
+	logger.LogInfo(ctx, "Book added successfully", logrus.Fields{
 		"book_id":     book.ID,
 		"inserted_id": ss.InsertedID,
 		"title":       book.Title,
-		"duration_ms": time.Since(start).Milliseconds(),
+		"duration_ms": time.Since(start),
 	})
 
 	return book, nil
 }
 
-func (br *BookRepository) UpdateBook(id int, updates models.Book) (models.Book, error) {
+func (br *BookRepository) UpdateBook(ctx context.Context, id int, updates models.Book) (models.Book, error) {
 	start := time.Now()
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := br.db.OperationContext(ctx)
 	defer cancel()
 
-	collection := database.Collection("books")
+	collection := br.db.Collection("books")
 
 	// First check if book exists
 	var existingBook models.Book
 	err := collection.FindOne(ctx, bson.M{"id": id}).Decode(&existingBook)
 	if err != nil {
-		logger.LogDatabaseOperation("find_for_update", "books", id, time.Since(start).Milliseconds(), err)
+		logger.LogDatabaseOperation(ctx, "find_for_update", "books", id, time.Since(start), err)
 		return models.Book{}, err
 	}
 
@@ -175,7 +235,7 @@ func (br *BookRepository) UpdateBook(id int, updates models.Book) (models.Book,
 	// Perform the update
 	_, err = collection.UpdateOne(ctx, bson.M{"id": id}, bson.M{"$set": updateDoc})
 	if err != nil {
-		logger.LogDatabaseOperation("update", "books", id, time.Since(start).Milliseconds(), err)
+		logger.LogDatabaseOperation(ctx, "update", "books", id, time.Since(start), err)
 		return models.Book{}, err
 	}
 
@@ -183,44 +243,87 @@ func (br *BookRepository) UpdateBook(id int, updates models.Book) (models.Book,
 	var updatedBook models.Book
 	err = collection.FindOne(ctx, bson.M{"id": id}).Decode(&updatedBook)
 	if err != nil {
-		logger.LogError("UpdateBook", err, logrus.Fields{
+		logger.LogError(ctx, "UpdateBook", err, logrus.Fields{
 			"operation": "retrieve_updated",
 			"book_id":   id,
 		})
 		return models.Book{}, err
 	}
 
-	logger.LogDatabaseOperation("update", "books", id, time.Since(start).Milliseconds(), nil)
-	logger.LogInfo("Book updated successfully", logrus.Fields{
+	logger.LogDatabaseOperation(ctx, "update", "books", id, time.Since(start), nil)
+	logger.LogInfo(ctx, "Book updated successfully", logrus.Fields{
 		"book_id":     id,
 		"title":       updatedBook.Title,
-		"duration_ms": time.Since(start).Milliseconds(),
+		"duration_ms": time.Since(start),
 	})
 
 	return updatedBook, nil
 }
 
-func (br *BookRepository) DeleteBook(id int) (models.Book, error) {
+// ReplaceBookFields overwrites every catalog field of a book, including empty
+// ones, keeping its ID and creation time. Used to restore earlier revisions.
+func (br *BookRepository) ReplaceBookFields(ctx context.Context, id int, book models.Book) (models.Book, error) {
+	start := time.Now()
+	ctx, cancel := br.db.OperationContext(ctx)
+	defer cancel()
+
+	collection := br.db.Collection("books")
+
+	updateDoc := bson.M{
+		"isbn":         book.ISBN,
+		"title":        book.Title,
+		"author":       book.Author,
+		"publisher":    book.Publisher,
+		"published_at": book.PublishedAt,
+		"genre":        book.Genre,
+		"language":     book.Language,
+		"pages":        book.Pages,
+		"description":  book.Description,
+		"coverURL":     book.CoverURL,
+		"location":     book.Location,
+		"updated_at":   time.Now(),
+	}
+
+	result, err := collection.UpdateOne(ctx, bson.M{"id": id}, bson.M{"$set": updateDoc})
+	if err == nil && result.MatchedCount == 0 {
+		err = mongo.ErrNoDocuments
+	}
+	if err != nil {
+		logger.LogDatabaseOperation(ctx, "replace", "books", id, time.Since(start), err)
+		return models.Book{}, err
+	}
+
+	var updatedBook models.Book
+	err = collection.FindOne(ctx, bson.M{"id": id}).Decode(&updatedBook)
+	logger.LogDatabaseOperation(ctx, "replace", "books", id, time.Since(start), err)
+	if err != nil {
+		return models.Book{}, err
+	}
+
+	return updatedBook, nil
+}
+
+func (br *BookRepository) DeleteBook(ctx context.Context, id int) (models.Book, error) {
 	start := time.Now()
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := br.db.OperationContext(ctx)
 	defer cancel()
 
-	collection := database.Collection("books")
+	collection := br.db.Collection("books")
 	var book models.Book
 	err := collection.FindOne(ctx, bson.M{"id": id}).Decode(&book)
 	if err != nil {
-		logger.LogDatabaseOperation("find_for_delete", "books", id, time.Since(start).Milliseconds(), err)
+		logger.LogDatabaseOperation(ctx, "find_for_delete", "books", id, time.Since(start), err)
 		return models.Book{}, err
 	}
 
 	deleted, err := collection.DeleteOne(ctx, bson.M{"id": id})
 	if err != nil {
-		logger.LogDatabaseOperation("delete", "books", id, time.Since(start).Milliseconds(), err)
+		logger.LogDatabaseOperation(ctx, "delete", "books", id, time.Since(start), err)
 		return models.Book{}, err
 	}
 
 	if deleted.DeletedCount == 0 {
-		logger.LogError("DeleteBook", mongo.ErrNoDocuments, logrus.Fields{
+		logger.LogError(ctx, "DeleteBook", mongo.ErrNoDocuments, logrus.Fields{
 			"operation":     "delete_one",
 			"book_id":       id,
 			"deleted_count": deleted.DeletedCount,
@@ -228,12 +331,12 @@ func (br *BookRepository) DeleteBook(id int) (models.Book, error) {
 		return models.Book{}, mongo.ErrNoDocuments
 	}
 
-	logger.LogDatabaseOperation("delete", "books", id, time.Since(start).Milliseconds(), nil)
-	logger.LogInfo("Book deleted successfully", logrus.Fields{
+	logger.LogDatabaseOperation(ctx, "delete", "books", id, time.Since(start), nil)
+	logger.LogInfo(ctx, "Book deleted successfully", logrus.Fields{
 		"book_id":       id,
 		"title":         book.Title,
 		"deleted_count": deleted.DeletedCount,
-		"duration_ms":   time.Since(start).Milliseconds(),
+		"duration_ms":   time.Since(start),
 	})
 
 	return book, nil
@@ -0,0 +1,129 @@
+package repositories
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/database"
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type BookRevisionRepository struct {
+	db         *database.DB
+	collection string
+}
+
+func NewBookRevisionRepository(db *database.DB) *BookRevisionRepository {
+	return &BookRevisionRepository{
+		db:         db,
+		collection: "book_revisions",
+	}
+}
+
+// AddRevision stores revision as the next revision number of its book
+func (rr *BookRevisionRepository) AddRevision(ctx context.Context, revision *models.BookRevision) error {
+	start := time.Now()
+	ctx, cancel := rr.db.OperationContext(ctx)
+	defer cancel()
+
+	// Concurrent writers draw distinct numbers from the book's counter; the
+	// unique (book_id, rev) index backs it up
+	rev, err := nextSequence(ctx, rr.db, "book_revisions:"+strconv.Itoa(revision.BookID), func(ctx context.Context) (int, error) {
+		return maxValue(ctx, rr.db, rr.collection, bson.D{{Key: "book_id", Value: revision.BookID}}, "rev")
+	})
+	if err != nil {
+		logger.LogDatabaseOperation(ctx, "next_rev", rr.collection, revision.BookID, time.Since(start), err)
+		return err
+	}
+
+	revision.ID = primitive.NewObjectID()
+	revision.Rev = rev
+	revision.CreatedAt = time.Now()
+
+	_, err = rr.db.Collection(rr.collection).InsertOne(ctx, revision)
+	logger.LogDatabaseOperation(ctx, "insert", rr.collection, revision.BookID, time.Since(start), err)
+	return err
+}
+
+// GetRevisions returns all revisions of a book, oldest first
+func (rr *BookRevisionRepository) GetRevisions(ctx context.Context, bookID int) ([]models.BookRevision, error) {
+	start := time.Now()
+	ctx, cancel := rr.db.OperationContext(ctx)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "rev", Value: 1}})
+	cursor, err := rr.db.Collection(rr.collection).Find(ctx, bson.D{{Key: "book_id", Value: bookID}}, opts)
+	if err != nil {
+		logger.LogDatabaseOperation(ctx, "find_revisions", rr.collection, bookID, time.Since(start), err)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	revisions := []models.BookRevision{}
+	err = cursor.All(ctx, &revisions)
+	logger.LogDatabaseOperation(ctx, "find_revisions", rr.collection, bookID, time.Since(start), err)
+	if err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// GetRevisionsOfBooks returns the revisions of several books in one query,
+// ordered by book ID and then oldest first
+func (rr *BookRevisionRepository) GetRevisionsOfBooks(ctx context.Context, bookIDs []int) ([]models.BookRevision, error) {
+	start := time.Now()
+	ctx, cancel := rr.db.OperationContext(ctx)
+	defer cancel()
+
+	filter := bson.D{{Key: "book_id", Value: bson.D{{Key: "$in", Value: bookIDs}}}}
+	opts := options.Find().SetSort(bson.D{{Key: "book_id", Value: 1}, {Key: "rev", Value: 1}})
+	cursor, err := rr.db.Collection(rr.collection).Find(ctx, filter, opts)
+	if err != nil {
+		logger.LogDatabaseOperation(ctx, "find_revisions", rr.collection, bookIDs, time.Since(start), err)
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	revisions := []models.BookRevision{}
+	err = cursor.All(ctx, &revisions)
+	logger.LogDatabaseOperation(ctx, "find_revisions", rr.collection, bookIDs, time.Since(start), err)
+	if err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+func (rr *BookRevisionRepository) GetRevision(ctx context.Context, bookID, rev int) (*models.BookRevision, error) {
+	start := time.Now()
+	ctx, cancel := rr.db.OperationContext(ctx)
+	defer cancel()
+
+	var revision models.BookRevision
+	filter := bson.D{
+		{Key: "book_id", Value: bookID},
+		{Key: "rev", Value: rev},
+	}
+
+	err := rr.db.Collection(rr.collection).FindOne(ctx, filter).Decode(&revision)
+	logger.LogDatabaseOperation(ctx, "find_revision", rr.collection, bookID, time.Since(start), err)
+	if err != nil {
+		return nil, err
+	}
+
+	return &revision, nil
+}
+
+// CountRevisions returns how many revisions a book has
+func (rr *BookRevisionRepository) CountRevisions(ctx context.Context, bookID int) (int64, error) {
+	ctx, cancel := rr.db.OperationContext(ctx)
+	defer cancel()
+
+	return rr.db.Collection(rr.collection).CountDocuments(ctx, bson.D{{Key: "book_id", Value: bookID}})
+}
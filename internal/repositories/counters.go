@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/4Noyis/my-library/internal/database"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// counters holds one document per sequence, {_id: name, seq: last value}
+const counters = "counters"
+
+// nextSequence atomically allocates the next value of the named sequence,
+// so concurrent writers never get the same one and values are never reused.
+// A sequence that doesn't exist yet continues from floor, the highest value
+// handed out before the sequence was introduced.
+func nextSequence(ctx context.Context, db *database.DB, name string, floor func(ctx context.Context) (int, error)) (int, error) {
+	collection := db.Collection(counters)
+	increment := bson.D{{Key: "$inc", Value: bson.D{{Key: "seq", Value: 1}}}}
+	after := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var counter struct {
+		Seq int `bson:"seq"`
+	}
+	err := collection.FindOneAndUpdate(ctx, bson.D{{Key: "_id", Value: name}}, increment, after).Decode(&counter)
+	if err != mongo.ErrNoDocuments {
+		return counter.Seq, err
+	}
+
+	start, err := floor(ctx)
+	if err != nil {
+		return 0, err
+	}
+	// $max keeps a value seeded concurrently by another writer
+	_, err = collection.UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: name}},
+		bson.D{{Key: "$max", Value: bson.D{{Key: "seq", Value: start}}}},
+		options.UpdateOne().SetUpsert(true),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	err = collection.FindOneAndUpdate(ctx, bson.D{{Key: "_id", Value: name}}, increment, after).Decode(&counter)
+	return counter.Seq, err
+}
+
+// maxValue returns the highest value of the integer field in the documents
+// of collection matching filter, or 0 when there are none
+func maxValue(ctx context.Context, db *database.DB, collection string, filter bson.D, field string) (int, error) {
+	var doc bson.M
+	opts := options.FindOne().
+		SetSort(bson.D{{Key: field, Value: -1}}).
+		SetProjection(bson.D{{Key: field, Value: 1}})
+	err := db.Collection(collection).FindOne(ctx, filter, opts).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	switch value := doc[field].(type) {
+	case int32:
+		return int(value), nil
+	case int64:
+		return int(value), nil
+	case float64:
+		return int(value), nil
+	}
+	return 0, nil
+}
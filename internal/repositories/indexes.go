@@ -0,0 +1,58 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/database"
+	"github.com/4Noyis/my-library/internal/logger"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// uniqueIndexes back the upserts that make redelivered events idempotent
+// and keep book IDs and revision numbers unique, keyed by collection
+var uniqueIndexes = []struct {
+	collection string
+	index      mongo.IndexModel
+}{
+	{"books", mongo.IndexModel{
+		Keys:    bson.D{{Key: "id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}},
+	{"outbox", mongo.IndexModel{
+		Keys:    bson.D{{Key: "event_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}},
+	{"audit_events", mongo.IndexModel{
+		Keys: bson.D{{Key: "event_id", Value: 1}},
+		// Entries recorded outside the event bus have no event ID
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.D{{Key: "event_id", Value: bson.D{{Key: "$exists", Value: true}}}}),
+	}},
+	{"webhook_deliveries", mongo.IndexModel{
+		Keys:    bson.D{{Key: "subscription_id", Value: 1}, {Key: "event_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}},
+	{"book_revisions", mongo.IndexModel{
+		Keys:    bson.D{{Key: "book_id", Value: 1}, {Key: "rev", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}},
+}
+
+// EnsureIndexes creates the unique indexes the repositories rely on. Indexes
+// that already exist are left alone, so it runs on every start.
+func EnsureIndexes(ctx context.Context, db *database.DB) error {
+	for _, unique := range uniqueIndexes {
+		start := time.Now()
+		opCtx, cancel := db.OperationContext(ctx)
+		name, err := db.Collection(unique.collection).Indexes().CreateOne(opCtx, unique.index)
+		cancel()
+		logger.LogDatabaseOperation(ctx, "create_index", unique.collection, name, time.Since(start), err)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
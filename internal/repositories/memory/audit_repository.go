@@ -0,0 +1,64 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/4Noyis/my-library/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type AuditRepository struct {
+	mu     sync.Mutex
+	events []models.AuditEvent
+}
+
+func NewAuditRepository() *AuditRepository {
+	return &AuditRepository{}
+}
+
+func (ar *AuditRepository) InsertEvent(ctx context.Context, event *models.AuditEvent) error {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	event.ID = primitive.NewObjectID()
+	if event.EventID != "" {
+		// Redelivered events find their entry and leave it untouched
+		for _, existing := range ar.events {
+			if existing.EventID == event.EventID {
+				return nil
+			}
+		}
+	}
+
+	ar.events = append(ar.events, *event)
+	return nil
+}
+
+func (ar *AuditRepository) FindEvents(ctx context.Context, query models.AuditQuery) ([]models.AuditEvent, int64, error) {
+	ar.mu.Lock()
+	defer ar.mu.Unlock()
+
+	// Newest first; events are stored with millisecond timestamps, so
+	// iterate backwards to keep ties in reverse insertion order too
+	matched := []models.AuditEvent{}
+	for i := len(ar.events) - 1; i >= 0; i-- {
+		event := ar.events[i]
+		switch {
+		case query.ActorID != nil && event.ActorID != *query.ActorID:
+		case query.TargetType != "" && event.TargetType != query.TargetType:
+		case query.TargetID != "" && event.TargetID != query.TargetID:
+		case query.Action != "" && event.Action != query.Action:
+		case !query.From.IsZero() && event.Timestamp.Before(query.From):
+		case !query.To.IsZero() && event.Timestamp.After(query.To):
+		default:
+			matched = append(matched, event)
+		}
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].Timestamp.After(matched[j].Timestamp)
+	})
+	return paginate(matched, query.Page, query.Limit), int64(len(matched)), nil
+}
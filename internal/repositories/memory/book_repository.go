@@ -0,0 +1,165 @@
+package memory
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/models"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type BookRepository struct {
+	mu     sync.Mutex
+	books  []models.Book // in insertion order, which is also ID order
+	lastID int           // IDs of deleted books are not reused
+}
+
+func NewBookRepository() *BookRepository {
+	return &BookRepository{}
+}
+
+func (br *BookRepository) GetAllBooks(ctx context.Context) ([]models.Book, error) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	var books []models.Book
+	return append(books, br.books...), nil
+}
+
+func (br *BookRepository) ListBooks(ctx context.Context, query models.BookListQuery) ([]models.Book, int64, error) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	search := strings.ToLower(query.Search)
+	matched := []models.Book{}
+	for _, book := range br.books {
+		if search != "" && !strings.Contains(strings.ToLower(book.Title), search) &&
+			!strings.Contains(strings.ToLower(book.Author), search) && !strings.Contains(strings.ToLower(book.ISBN), search) {
+			continue
+		}
+		if query.Author != "" && book.Author != query.Author {
+			continue
+		}
+		if query.Genre != "" && book.Genre != query.Genre {
+			continue
+		}
+		if query.Language != "" && book.Language != query.Language {
+			continue
+		}
+		matched = append(matched, book)
+	}
+	return paginate(matched, query.Page, query.Limit), int64(len(matched)), nil
+}
+
+func (br *BookRepository) GetOneBook(ctx context.Context, id int) (models.Book, error) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	i := br.find(id)
+	if i < 0 {
+		return models.Book{}, mongo.ErrNoDocuments
+	}
+	return br.books[i], nil
+}
+
+func (br *BookRepository) AddNewBook(ctx context.Context, book models.Book) (models.Book, error) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	br.lastID++
+	book.ID = br.lastID
+	book.CreatedAt = time.Now()
+	book.UpdatedAt = time.Now()
+
+	br.books = append(br.books, book)
+	return book, nil
+}
+
+func (br *BookRepository) UpdateBook(ctx context.Context, id int, updates models.Book) (models.Book, error) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	i := br.find(id)
+	if i < 0 {
+		return models.Book{}, mongo.ErrNoDocuments
+	}
+
+	book := &br.books[i]
+	if updates.ISBN != "" {
+		book.ISBN = updates.ISBN
+	}
+	if updates.Title != "" {
+		book.Title = updates.Title
+	}
+	if updates.Author != "" {
+		book.Author = updates.Author
+	}
+	if updates.Publisher != "" {
+		book.Publisher = updates.Publisher
+	}
+	if !updates.PublishedAt.IsZero() {
+		book.PublishedAt = updates.PublishedAt
+	}
+	if updates.Genre != "" {
+		book.Genre = updates.Genre
+	}
+	if updates.Language != "" {
+		book.Language = updates.Language
+	}
+	if updates.Pages != 0 {
+		book.Pages = updates.Pages
+	}
+	if updates.Description != "" {
+		book.Description = updates.Description
+	}
+	if updates.CoverURL != "" {
+		book.CoverURL = updates.CoverURL
+	}
+	if updates.Location != "" {
+		book.Location = updates.Location
+	}
+	book.UpdatedAt = time.Now()
+
+	return *book, nil
+}
+
+func (br *BookRepository) ReplaceBookFields(ctx context.Context, id int, book models.Book) (models.Book, error) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	i := br.find(id)
+	if i < 0 {
+		return models.Book{}, mongo.ErrNoDocuments
+	}
+
+	book.ID = id
+	book.CreatedAt = br.books[i].CreatedAt
+	book.UpdatedAt = time.Now()
+	br.books[i] = book
+	return book, nil
+}
+
+func (br *BookRepository) DeleteBook(ctx context.Context, id int) (models.Book, error) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	i := br.find(id)
+	if i < 0 {
+		return models.Book{}, mongo.ErrNoDocuments
+	}
+
+	book := br.books[i]
+	br.books = append(br.books[:i], br.books[i+1:]...)
+	return book, nil
+}
+
+func (br *BookRepository) find(id int) int {
+	for i, book := range br.books {
+		if book.ID == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,79 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type BookRevisionRepository struct {
+	mu        sync.Mutex
+	revisions map[int][]models.BookRevision // by book ID, oldest first
+}
+
+func NewBookRevisionRepository() *BookRevisionRepository {
+	return &BookRevisionRepository{
+		revisions: make(map[int][]models.BookRevision),
+	}
+}
+
+func (rr *BookRevisionRepository) AddRevision(ctx context.Context, revision *models.BookRevision) error {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	revision.ID = primitive.NewObjectID()
+	revision.Rev = len(rr.revisions[revision.BookID]) + 1
+	revision.CreatedAt = time.Now()
+
+	stored := *revision
+	stored.Changes = nil
+	rr.revisions[revision.BookID] = append(rr.revisions[revision.BookID], stored)
+	return nil
+}
+
+func (rr *BookRevisionRepository) GetRevisions(ctx context.Context, bookID int) ([]models.BookRevision, error) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	return append([]models.BookRevision{}, rr.revisions[bookID]...), nil
+}
+
+func (rr *BookRevisionRepository) GetRevisionsOfBooks(ctx context.Context, bookIDs []int) ([]models.BookRevision, error) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	ids := append([]int{}, bookIDs...)
+	sort.Ints(ids)
+	revisions := []models.BookRevision{}
+	for i, id := range ids {
+		if i > 0 && id == ids[i-1] {
+			continue
+		}
+		revisions = append(revisions, rr.revisions[id]...)
+	}
+	return revisions, nil
+}
+
+func (rr *BookRevisionRepository) GetRevision(ctx context.Context, bookID, rev int) (*models.BookRevision, error) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	revisions := rr.revisions[bookID]
+	if rev < 1 || rev > len(revisions) {
+		return nil, mongo.ErrNoDocuments
+	}
+	revision := revisions[rev-1]
+	return &revision, nil
+}
+
+func (rr *BookRevisionRepository) CountRevisions(ctx context.Context, bookID int) (int64, error) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	return int64(len(rr.revisions[bookID])), nil
+}
@@ -0,0 +1,83 @@
+// Package memory implements the service stores in process memory, for tests
+// and local experiments without MongoDB. Each repository mirrors the
+// MongoDB one it replaces, including returning mongo.ErrNoDocuments when
+// nothing matches. Nothing is persisted and there are no transactions.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// DB stands in for database.DB
+type DB struct{}
+
+func NewDB() *DB {
+	return &DB{}
+}
+
+// WithTransaction runs fn directly, like database.DB does on a standalone
+// server
+func (db *DB) WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func (db *DB) Ping(ctx context.Context) error {
+	return nil
+}
+
+// setFields applies a MongoDB $set document to the struct doc points to,
+// matching fields by their bson names
+func setFields(doc interface{}, updates bson.D) error {
+	v := reflect.ValueOf(doc).Elem()
+	t := v.Type()
+
+	for _, update := range updates {
+		field := -1
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("bson"), ",")
+			if name == update.Key {
+				field = i
+				break
+			}
+		}
+		if field < 0 {
+			return fmt.Errorf("memory: %s has no field %q", t.Name(), update.Key)
+		}
+
+		target := v.Field(field)
+		value := reflect.ValueOf(update.Value)
+		switch {
+		case !value.IsValid():
+			target.Set(reflect.Zero(target.Type()))
+		case value.Type().AssignableTo(target.Type()):
+			target.Set(value)
+		case value.Type().ConvertibleTo(target.Type()):
+			target.Set(value.Convert(target.Type()))
+		case target.Kind() == reflect.Pointer && value.Type().AssignableTo(target.Type().Elem()):
+			ptr := reflect.New(target.Type().Elem())
+			ptr.Elem().Set(value)
+			target.Set(ptr)
+		default:
+			return fmt.Errorf("memory: cannot set %s.%s to %T", t.Name(), update.Key, update.Value)
+		}
+	}
+	return nil
+}
+
+// paginate returns the items on page, counting pages from 1
+func paginate[T any](items []T, page, limit int) []T {
+	start := (page - 1) * limit
+	if start < 0 || start >= len(items) {
+		return []T{}
+	}
+	end := start + limit
+	if limit <= 0 || end > len(items) {
+		end = len(items)
+	}
+	return items[start:end]
+}
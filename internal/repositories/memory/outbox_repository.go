@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type OutboxRepository struct {
+	mu      sync.Mutex
+	records []*models.OutboxRecord // oldest first
+}
+
+func NewOutboxRepository() *OutboxRepository {
+	return &OutboxRepository{}
+}
+
+func (or *OutboxRepository) Insert(ctx context.Context, record *models.OutboxRecord) error {
+	or.mu.Lock()
+	defer or.mu.Unlock()
+
+	record.ID = primitive.NewObjectID()
+	record.Status = models.OutboxPending
+	record.CreatedAt = time.Now()
+	record.NextAttemptAt = record.CreatedAt
+
+	stored := *record
+	or.records = append(or.records, &stored)
+	return nil
+}
+
+func (or *OutboxRepository) ClaimDue(ctx context.Context, lease time.Duration) (*models.OutboxRecord, error) {
+	or.mu.Lock()
+	defer or.mu.Unlock()
+
+	now := time.Now()
+	for _, record := range or.records {
+		if record.Status == models.OutboxPending && !record.NextAttemptAt.After(now) && !record.LockedUntil.After(now) {
+			record.LockedUntil = now.Add(lease)
+			claimed := *record
+			return &claimed, nil
+		}
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+func (or *OutboxRepository) MarkDelivered(ctx context.Context, id primitive.ObjectID) error {
+	or.mu.Lock()
+	defer or.mu.Unlock()
+
+	if record := or.find(id); record != nil {
+		now := time.Now()
+		record.Status = models.OutboxDelivered
+		record.DeliveredAt = &now
+		record.LockedUntil = time.Time{}
+	}
+	return nil
+}
+
+func (or *OutboxRepository) MarkFailed(ctx context.Context, id primitive.ObjectID, status, lastError string, nextAttemptAt time.Time) error {
+	or.mu.Lock()
+	defer or.mu.Unlock()
+
+	if record := or.find(id); record != nil {
+		record.Status = status
+		record.LastError = lastError
+		record.NextAttemptAt = nextAttemptAt
+		record.LockedUntil = time.Time{}
+		record.Attempts++
+	}
+	return nil
+}
+
+func (or *OutboxRepository) PurgeDelivered(ctx context.Context, before time.Time) (int64, error) {
+	or.mu.Lock()
+	defer or.mu.Unlock()
+
+	var purged int64
+	kept := or.records[:0]
+	for _, record := range or.records {
+		if record.Status == models.OutboxDelivered && record.DeliveredAt != nil && record.DeliveredAt.Before(before) {
+			purged++
+			continue
+		}
+		kept = append(kept, record)
+	}
+	or.records = kept
+	return purged, nil
+}
+
+// Pending returns how many records are waiting to be delivered, so tests can
+// wait for the relay to catch up
+func (or *OutboxRepository) Pending() int {
+	or.mu.Lock()
+	defer or.mu.Unlock()
+
+	pending := 0
+	for _, record := range or.records {
+		if record.Status == models.OutboxPending {
+			pending++
+		}
+	}
+	return pending
+}
+
+func (or *OutboxRepository) find(id primitive.ObjectID) *models.OutboxRecord {
+	for _, record := range or.records {
+		if record.ID == id {
+			return record
+		}
+	}
+	return nil
+}
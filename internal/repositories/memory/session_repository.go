@@ -0,0 +1,110 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type SessionRepository struct {
+	mu       sync.Mutex
+	sessions []*models.Session
+}
+
+func NewSessionRepository() *SessionRepository {
+	return &SessionRepository{}
+}
+
+func (sr *SessionRepository) CreateSession(ctx context.Context, session *models.Session) error {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	session.ID = primitive.NewObjectID()
+	session.CreatedAt = time.Now()
+	session.LastSeenAt = session.CreatedAt
+
+	stored := *session
+	stored.Current = false
+	sr.sessions = append(sr.sessions, &stored)
+	return nil
+}
+
+func (sr *SessionRepository) GetSession(ctx context.Context, id primitive.ObjectID) (*models.Session, error) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	session := sr.find(id)
+	if session == nil {
+		return nil, mongo.ErrNoDocuments
+	}
+	found := *session
+	return &found, nil
+}
+
+func (sr *SessionRepository) ListActiveSessions(ctx context.Context, userID primitive.ObjectID) ([]models.Session, error) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	now := time.Now()
+	sessions := []models.Session{}
+	for _, session := range sr.sessions {
+		if session.UserID == userID && session.RevokedAt == nil && session.ExpiresAt.After(now) {
+			sessions = append(sessions, *session)
+		}
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+	return sessions, nil
+}
+
+func (sr *SessionRepository) TouchSession(ctx context.Context, id primitive.ObjectID, lastSeen time.Time) error {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	if session := sr.find(id); session != nil {
+		session.LastSeenAt = lastSeen
+	}
+	return nil
+}
+
+func (sr *SessionRepository) RevokeSession(ctx context.Context, id, userID primitive.ObjectID) error {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	session := sr.find(id)
+	if session == nil || session.UserID != userID || session.RevokedAt != nil {
+		return mongo.ErrNoDocuments
+	}
+	now := time.Now()
+	session.RevokedAt = &now
+	return nil
+}
+
+func (sr *SessionRepository) RevokeUserSessions(ctx context.Context, userID primitive.ObjectID) error {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+
+	now := time.Now()
+	for _, session := range sr.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			session.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (sr *SessionRepository) find(id primitive.ObjectID) *models.Session {
+	for _, session := range sr.sessions {
+		if session.ID == id {
+			return session
+		}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type UserRepository struct {
+	mu    sync.Mutex
+	users []*models.User
+}
+
+func NewUserRepository() *UserRepository {
+	return &UserRepository{}
+}
+
+func (ur *UserRepository) CreateUser(ctx context.Context, user *models.User) error {
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+
+	user.ID = primitive.NewObjectID()
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = time.Now()
+	user.IsActive = true
+
+	stored := *user
+	ur.users = append(ur.users, &stored)
+	return nil
+}
+
+func (ur *UserRepository) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	return ur.findOne(func(user *models.User) bool { return user.Username == username })
+}
+
+func (ur *UserRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return ur.findOne(func(user *models.User) bool { return user.Email == email })
+}
+
+func (ur *UserRepository) GetUserByID(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	return ur.findOne(func(user *models.User) bool { return user.ID == id })
+}
+
+func (ur *UserRepository) GetUsersByIDs(ctx context.Context, ids []primitive.ObjectID) ([]models.User, error) {
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+
+	wanted := make(map[primitive.ObjectID]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	users := []models.User{}
+	for _, user := range ur.users {
+		if wanted[user.ID] {
+			users = append(users, *user)
+		}
+	}
+	return users, nil
+}
+
+func (ur *UserRepository) GetUserByExternalID(ctx context.Context, provider, externalID string) (*models.User, error) {
+	return ur.findOne(func(user *models.User) bool {
+		return user.AuthProvider == provider && user.ExternalID == externalID
+	})
+}
+
+func (ur *UserRepository) UpdateUser(ctx context.Context, id primitive.ObjectID, updates bson.D) error {
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+
+	for _, user := range ur.users {
+		if user.ID == id {
+			updated := *user
+			updates = append(updates, bson.E{Key: "updated_at", Value: time.Now()})
+			if err := setFields(&updated, updates); err != nil {
+				return err
+			}
+			*user = updated
+			return nil
+		}
+	}
+	// UpdateOne matching nothing is not an error
+	return nil
+}
+
+func (ur *UserRepository) ListUsers(ctx context.Context, query models.UserListQuery) ([]models.User, int64, error) {
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+
+	search := strings.ToLower(query.Search)
+	matched := []models.User{}
+	for _, user := range ur.users {
+		if search != "" && !strings.Contains(strings.ToLower(user.Username), search) && !strings.Contains(strings.ToLower(user.Email), search) {
+			continue
+		}
+		if query.Role != "" && user.Role != query.Role {
+			continue
+		}
+		if query.IsActive != nil && user.IsActive != *query.IsActive {
+			continue
+		}
+		matched = append(matched, *user)
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].CreatedAt.After(matched[j].CreatedAt)
+	})
+	return paginate(matched, query.Page, query.Limit), int64(len(matched)), nil
+}
+
+func (ur *UserRepository) DeleteUser(ctx context.Context, id primitive.ObjectID) error {
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+
+	for i, user := range ur.users {
+		if user.ID == id {
+			ur.users = append(ur.users[:i], ur.users[i+1:]...)
+			return nil
+		}
+	}
+	return mongo.ErrNoDocuments
+}
+
+func (ur *UserRepository) findOne(match func(user *models.User) bool) (*models.User, error) {
+	ur.mu.Lock()
+	defer ur.mu.Unlock()
+
+	for _, user := range ur.users {
+		if match(user) {
+			found := *user
+			return &found, nil
+		}
+	}
+	return nil, mongo.ErrNoDocuments
+}
@@ -0,0 +1,259 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+type WebhookRepository struct {
+	mu            sync.Mutex
+	subscriptions []*models.WebhookSubscription
+	deliveries    []*models.WebhookDelivery
+}
+
+func NewWebhookRepository() *WebhookRepository {
+	return &WebhookRepository{}
+}
+
+func (wr *WebhookRepository) CreateSubscription(ctx context.Context, sub *models.WebhookSubscription) error {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	sub.ID = primitive.NewObjectID()
+	sub.CreatedAt = time.Now()
+	sub.UpdatedAt = sub.CreatedAt
+
+	stored := copySubscription(sub)
+	wr.subscriptions = append(wr.subscriptions, &stored)
+	return nil
+}
+
+func (wr *WebhookRepository) GetSubscription(ctx context.Context, id primitive.ObjectID) (*models.WebhookSubscription, error) {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	sub := wr.findSubscription(id)
+	if sub == nil {
+		return nil, mongo.ErrNoDocuments
+	}
+	found := copySubscription(sub)
+	return &found, nil
+}
+
+func (wr *WebhookRepository) ListSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	subs := []models.WebhookSubscription{}
+	for _, sub := range wr.subscriptions {
+		subs = append(subs, copySubscription(sub))
+	}
+	return subs, nil
+}
+
+func (wr *WebhookRepository) FindSubscribers(ctx context.Context, eventType string) ([]models.WebhookSubscription, error) {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	subs := []models.WebhookSubscription{}
+	for _, sub := range wr.subscriptions {
+		if !sub.Active {
+			continue
+		}
+		for _, t := range sub.EventTypes {
+			if t == eventType || t == "*" {
+				subs = append(subs, copySubscription(sub))
+				break
+			}
+		}
+	}
+	return subs, nil
+}
+
+func (wr *WebhookRepository) UpdateSubscription(ctx context.Context, id primitive.ObjectID, updates bson.D) error {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	sub := wr.findSubscription(id)
+	if sub == nil {
+		return mongo.ErrNoDocuments
+	}
+
+	updated := copySubscription(sub)
+	updates = append(updates, bson.E{Key: "updated_at", Value: time.Now()})
+	if err := setFields(&updated, updates); err != nil {
+		return err
+	}
+	*sub = updated
+	return nil
+}
+
+func (wr *WebhookRepository) DeleteSubscription(ctx context.Context, id primitive.ObjectID) error {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	for i, sub := range wr.subscriptions {
+		if sub.ID == id {
+			wr.subscriptions = append(wr.subscriptions[:i], wr.subscriptions[i+1:]...)
+
+			kept := wr.deliveries[:0]
+			for _, delivery := range wr.deliveries {
+				if delivery.SubscriptionID != id || delivery.Status != models.DeliveryPending {
+					kept = append(kept, delivery)
+				}
+			}
+			wr.deliveries = kept
+			return nil
+		}
+	}
+	return mongo.ErrNoDocuments
+}
+
+func (wr *WebhookRepository) InsertDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	for _, existing := range wr.deliveries {
+		if existing.SubscriptionID == delivery.SubscriptionID && existing.EventID == delivery.EventID {
+			return models.ErrDuplicateDelivery
+		}
+	}
+
+	if delivery.ID.IsZero() {
+		delivery.ID = primitive.NewObjectID()
+	}
+	delivery.CreatedAt = time.Now()
+	if delivery.Attempts == nil {
+		delivery.Attempts = []models.DeliveryAttempt{}
+	}
+
+	stored := copyDelivery(delivery)
+	wr.deliveries = append(wr.deliveries, &stored)
+	return nil
+}
+
+func (wr *WebhookRepository) ClaimDueDelivery(ctx context.Context, lease time.Duration) (*models.WebhookDelivery, error) {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	now := time.Now()
+	var due *models.WebhookDelivery
+	for _, delivery := range wr.deliveries {
+		if delivery.Status != models.DeliveryPending || delivery.NextAttemptAt.After(now) || delivery.LockedUntil.After(now) {
+			continue
+		}
+		if due == nil || delivery.NextAttemptAt.Before(due.NextAttemptAt) {
+			due = delivery
+		}
+	}
+	if due == nil {
+		return nil, mongo.ErrNoDocuments
+	}
+
+	due.LockedUntil = now.Add(lease)
+	claimed := copyDelivery(due)
+	return &claimed, nil
+}
+
+func (wr *WebhookRepository) RecordAttempt(ctx context.Context, id primitive.ObjectID, attempt models.DeliveryAttempt, status string, nextAttemptAt time.Time) error {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	delivery := wr.findDelivery(id)
+	if delivery == nil {
+		return nil
+	}
+
+	delivery.Status = status
+	delivery.NextAttemptAt = nextAttemptAt
+	delivery.LockedUntil = time.Time{}
+	if status != models.DeliveryPending {
+		completedAt := attempt.At
+		delivery.CompletedAt = &completedAt
+	}
+	delivery.Attempts = append(delivery.Attempts, attempt)
+	delivery.AttemptCount++
+	return nil
+}
+
+func (wr *WebhookRepository) GetDelivery(ctx context.Context, subscriptionID, id primitive.ObjectID) (*models.WebhookDelivery, error) {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	delivery := wr.findDelivery(id)
+	if delivery == nil || delivery.SubscriptionID != subscriptionID {
+		return nil, mongo.ErrNoDocuments
+	}
+	found := copyDelivery(delivery)
+	return &found, nil
+}
+
+func (wr *WebhookRepository) RequeueDelivery(ctx context.Context, id primitive.ObjectID) error {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	delivery := wr.findDelivery(id)
+	if delivery == nil || delivery.Status != models.DeliveryDead {
+		return mongo.ErrNoDocuments
+	}
+
+	delivery.Status = models.DeliveryPending
+	delivery.NextAttemptAt = time.Now()
+	delivery.AttemptCount = 0
+	delivery.CompletedAt = nil
+	return nil
+}
+
+func (wr *WebhookRepository) ListDeliveries(ctx context.Context, subscriptionID primitive.ObjectID, status string, page, limit int) ([]models.WebhookDelivery, int64, error) {
+	wr.mu.Lock()
+	defer wr.mu.Unlock()
+
+	matched := []models.WebhookDelivery{}
+	for _, delivery := range wr.deliveries {
+		if delivery.SubscriptionID == subscriptionID && (status == "" || delivery.Status == status) {
+			matched = append(matched, copyDelivery(delivery))
+		}
+	}
+
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].CreatedAt.After(matched[j].CreatedAt)
+	})
+	return paginate(matched, page, limit), int64(len(matched)), nil
+}
+
+func (wr *WebhookRepository) findSubscription(id primitive.ObjectID) *models.WebhookSubscription {
+	for _, sub := range wr.subscriptions {
+		if sub.ID == id {
+			return sub
+		}
+	}
+	return nil
+}
+
+func (wr *WebhookRepository) findDelivery(id primitive.ObjectID) *models.WebhookDelivery {
+	for _, delivery := range wr.deliveries {
+		if delivery.ID == id {
+			return delivery
+		}
+	}
+	return nil
+}
+
+func copySubscription(sub *models.WebhookSubscription) models.WebhookSubscription {
+	c := *sub
+	c.EventTypes = append([]string(nil), sub.EventTypes...)
+	return c
+}
+
+func copyDelivery(delivery *models.WebhookDelivery) models.WebhookDelivery {
+	c := *delivery
+	c.Attempts = append([]models.DeliveryAttempt{}, delivery.Attempts...)
+	return c
+}
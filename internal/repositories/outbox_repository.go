@@ -0,0 +1,122 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/database"
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type OutboxRepository struct {
+	db         *database.DB
+	collection string
+}
+
+func NewOutboxRepository(db *database.DB) *OutboxRepository {
+	return &OutboxRepository{
+		db:         db,
+		collection: "outbox",
+	}
+}
+
+// Insert adds a pending record. Pass the transaction context so the record
+// is only committed together with the change it describes.
+func (or *OutboxRepository) Insert(ctx context.Context, record *models.OutboxRecord) error {
+	start := time.Now()
+	ctx, cancel := or.db.OperationContext(ctx)
+	defer cancel()
+
+	record.ID = primitive.NewObjectID()
+	record.Status = models.OutboxPending
+	record.CreatedAt = time.Now()
+	record.NextAttemptAt = record.CreatedAt
+
+	_, err := or.db.Collection(or.collection).InsertOne(ctx, record)
+	logger.LogDatabaseOperation(ctx, "insert", or.collection, record.EventID, time.Since(start), err)
+	return err
+}
+
+// ClaimDue locks the oldest pending record that is due, so concurrent relays
+// never deliver the same record at once. It returns mongo.ErrNoDocuments when
+// nothing is due. A relay that dies while holding the lock leaves the record
+// to be claimed again once the lease expires.
+func (or *OutboxRepository) ClaimDue(ctx context.Context, lease time.Duration) (*models.OutboxRecord, error) {
+	ctx, cancel := or.db.OperationContext(ctx)
+	defer cancel()
+
+	now := time.Now()
+	filter := bson.D{
+		{Key: "status", Value: models.OutboxPending},
+		{Key: "next_attempt_at", Value: bson.D{{Key: "$lte", Value: now}}},
+		{Key: "locked_until", Value: bson.D{{Key: "$lte", Value: now}}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "locked_until", Value: now.Add(lease)}}}}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var record models.OutboxRecord
+	err := or.db.Collection(or.collection).FindOneAndUpdate(ctx, filter, update, opts).Decode(&record)
+	if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+func (or *OutboxRepository) MarkDelivered(ctx context.Context, id primitive.ObjectID) error {
+	ctx, cancel := or.db.OperationContext(ctx)
+	defer cancel()
+
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: models.OutboxDelivered},
+		{Key: "delivered_at", Value: time.Now()},
+		{Key: "locked_until", Value: time.Time{}},
+	}}}
+
+	_, err := or.db.Collection(or.collection).UpdateOne(ctx, bson.D{{Key: "_id", Value: id}}, update)
+	return err
+}
+
+// MarkFailed records a failed delivery and schedules the next attempt, or
+// moves the record to status, e.g. models.OutboxDead
+func (or *OutboxRepository) MarkFailed(ctx context.Context, id primitive.ObjectID, status, lastError string, nextAttemptAt time.Time) error {
+	ctx, cancel := or.db.OperationContext(ctx)
+	defer cancel()
+
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "status", Value: status},
+			{Key: "last_error", Value: lastError},
+			{Key: "next_attempt_at", Value: nextAttemptAt},
+			{Key: "locked_until", Value: time.Time{}},
+		}},
+		{Key: "$inc", Value: bson.D{{Key: "attempts", Value: 1}}},
+	}
+
+	_, err := or.db.Collection(or.collection).UpdateOne(ctx, bson.D{{Key: "_id", Value: id}}, update)
+	return err
+}
+
+// PurgeDelivered removes records delivered before the given time
+func (or *OutboxRepository) PurgeDelivered(ctx context.Context, before time.Time) (int64, error) {
+	start := time.Now()
+	ctx, cancel := or.db.OperationContext(ctx)
+	defer cancel()
+
+	result, err := or.db.Collection(or.collection).DeleteMany(ctx, bson.D{
+		{Key: "status", Value: models.OutboxDelivered},
+		{Key: "delivered_at", Value: bson.D{{Key: "$lt", Value: before}}},
+	})
+	logger.LogDatabaseOperation(ctx, "delete_many", or.collection, nil, time.Since(start), err)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.DeletedCount, nil
+}
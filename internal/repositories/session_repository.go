@@ -0,0 +1,127 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/database"
+	"github.com/4Noyis/my-library/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type SessionRepository struct {
+	db         *database.DB
+	collection string
+}
+
+func NewSessionRepository(db *database.DB) *SessionRepository {
+	return &SessionRepository{
+		db:         db,
+		collection: "sessions",
+	}
+}
+
+func (sr *SessionRepository) CreateSession(ctx context.Context, session *models.Session) error {
+	ctx, cancel := sr.db.OperationContext(ctx)
+	defer cancel()
+
+	session.ID = primitive.NewObjectID()
+	session.CreatedAt = time.Now()
+	session.LastSeenAt = session.CreatedAt
+
+	_, err := sr.db.Collection(sr.collection).InsertOne(ctx, session)
+	return err
+}
+
+func (sr *SessionRepository) GetSession(ctx context.Context, id primitive.ObjectID) (*models.Session, error) {
+	ctx, cancel := sr.db.OperationContext(ctx)
+	defer cancel()
+
+	var session models.Session
+	filter := bson.D{{Key: "_id", Value: id}}
+
+	err := sr.db.Collection(sr.collection).FindOne(ctx, filter).Decode(&session)
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// ListActiveSessions returns the user's sessions that are neither revoked nor expired
+func (sr *SessionRepository) ListActiveSessions(ctx context.Context, userID primitive.ObjectID) ([]models.Session, error) {
+	ctx, cancel := sr.db.OperationContext(ctx)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "user_id", Value: userID},
+		{Key: "revoked_at", Value: nil},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now()}}},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "last_seen_at", Value: -1}})
+
+	cursor, err := sr.db.Collection(sr.collection).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	sessions := []models.Session{}
+	if err = cursor.All(ctx, &sessions); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+func (sr *SessionRepository) TouchSession(ctx context.Context, id primitive.ObjectID, lastSeen time.Time) error {
+	ctx, cancel := sr.db.OperationContext(ctx)
+	defer cancel()
+
+	filter := bson.D{{Key: "_id", Value: id}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "last_seen_at", Value: lastSeen}}}}
+
+	_, err := sr.db.Collection(sr.collection).UpdateOne(ctx, filter, update)
+	return err
+}
+
+// RevokeSession revokes one of the user's active sessions
+func (sr *SessionRepository) RevokeSession(ctx context.Context, id, userID primitive.ObjectID) error {
+	ctx, cancel := sr.db.OperationContext(ctx)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "user_id", Value: userID},
+		{Key: "revoked_at", Value: nil},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "revoked_at", Value: time.Now()}}}}
+
+	result, err := sr.db.Collection(sr.collection).UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// RevokeUserSessions revokes every active session of the user
+func (sr *SessionRepository) RevokeUserSessions(ctx context.Context, userID primitive.ObjectID) error {
+	ctx, cancel := sr.db.OperationContext(ctx)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "user_id", Value: userID},
+		{Key: "revoked_at", Value: nil},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "revoked_at", Value: time.Now()}}}}
+
+	_, err := sr.db.Collection(sr.collection).UpdateMany(ctx, filter, update)
+	return err
+}
@@ -2,26 +2,31 @@ package repositories
 
 import (
 	"context"
+	"regexp"
 	"time"
 
 	"github.com/4Noyis/my-library/internal/database"
 	"github.com/4Noyis/my-library/internal/models"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
 type UserRepository struct {
+	db         *database.DB
 	collection string
 }
 
-func NewUserRepository() *UserRepository {
+func NewUserRepository(db *database.DB) *UserRepository {
 	return &UserRepository{
+		db:         db,
 		collection: "users",
 	}
 }
 
-func (ur *UserRepository) CreateUser(user *models.User) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (ur *UserRepository) CreateUser(ctx context.Context, user *models.User) error {
+	ctx, cancel := ur.db.OperationContext(ctx)
 	defer cancel()
 
 	user.ID = primitive.NewObjectID()
@@ -29,19 +34,19 @@ func (ur *UserRepository) CreateUser(user *models.User) error {
 	user.UpdatedAt = time.Now()
 	user.IsActive = true
 
-	_, err := database.Collection(ur.collection).InsertOne(ctx, user)
+	_, err := ur.db.Collection(ur.collection).InsertOne(ctx, user)
 	return err
 }
 
-func (ur *UserRepository) GetUserByUsername(username string) (*models.User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (ur *UserRepository) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	ctx, cancel := ur.db.OperationContext(ctx)
 	defer cancel()
 
 	var user models.User
-	filter := bson.D{{"username", username}}
+	filter := bson.D{{Key: "username", Value: username}}
 
 	//err := UserCollection(&mongo.Client{}).FindOne(ctx, filter).Decode(&user)
-	err := database.Collection(ur.collection).FindOne(ctx, filter).Decode(&user)
+	err := ur.db.Collection(ur.collection).FindOne(ctx, filter).Decode(&user)
 	if err != nil {
 		return nil, err
 	}
@@ -49,14 +54,14 @@ func (ur *UserRepository) GetUserByUsername(username string) (*models.User, erro
 	return &user, nil
 }
 
-func (ur *UserRepository) GetUserByEmail(email string) (*models.User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (ur *UserRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	ctx, cancel := ur.db.OperationContext(ctx)
 	defer cancel()
 
 	var user models.User
-	filter := bson.D{{"email", email}}
+	filter := bson.D{{Key: "email", Value: email}}
 
-	err := database.Collection(ur.collection).FindOne(ctx, filter).Decode(&user)
+	err := ur.db.Collection(ur.collection).FindOne(ctx, filter).Decode(&user)
 	if err != nil {
 		return nil, err
 	}
@@ -64,14 +69,14 @@ func (ur *UserRepository) GetUserByEmail(email string) (*models.User, error) {
 	return &user, nil
 }
 
-func (ur *UserRepository) GetUserByID(id primitive.ObjectID) (*models.User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (ur *UserRepository) GetUserByID(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	ctx, cancel := ur.db.OperationContext(ctx)
 	defer cancel()
 
 	var user models.User
-	filter := bson.D{{"_id", id}}
+	filter := bson.D{{Key: "_id", Value: id}}
 
-	err := database.Collection(ur.collection).FindOne(ctx, filter).Decode(&user)
+	err := ur.db.Collection(ur.collection).FindOne(ctx, filter).Decode(&user)
 	if err != nil {
 		return nil, err
 	}
@@ -79,14 +84,114 @@ func (ur *UserRepository) GetUserByID(id primitive.ObjectID) (*models.User, erro
 	return &user, nil
 }
 
-func (ur *UserRepository) UpdateUser(id primitive.ObjectID, updates bson.D) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// GetUsersByIDs returns the users found among ids in one query
+func (ur *UserRepository) GetUsersByIDs(ctx context.Context, ids []primitive.ObjectID) ([]models.User, error) {
+	ctx, cancel := ur.db.OperationContext(ctx)
 	defer cancel()
 
-	filter := bson.D{{"_id", id}}
-	updates = append(updates, bson.E{"updated_at", time.Now()})
-	update := bson.D{{"$set", updates}}
+	filter := bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: ids}}}}
+	cursor, err := ur.db.Collection(ur.collection).Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	users := []models.User{}
+	if err = cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// GetUserByExternalID finds an account linked to an external identity provider
+func (ur *UserRepository) GetUserByExternalID(ctx context.Context, provider, externalID string) (*models.User, error) {
+	ctx, cancel := ur.db.OperationContext(ctx)
+	defer cancel()
+
+	var user models.User
+	filter := bson.D{
+		{Key: "auth_provider", Value: provider},
+		{Key: "external_id", Value: externalID},
+	}
 
-	_, err := database.Collection(ur.collection).UpdateOne(ctx, filter, update)
+	err := ur.db.Collection(ur.collection).FindOne(ctx, filter).Decode(&user)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (ur *UserRepository) UpdateUser(ctx context.Context, id primitive.ObjectID, updates bson.D) error {
+	ctx, cancel := ur.db.OperationContext(ctx)
+	defer cancel()
+
+	filter := bson.D{{Key: "_id", Value: id}}
+	updates = append(updates, bson.E{Key: "updated_at", Value: time.Now()})
+	update := bson.D{{Key: "$set", Value: updates}}
+
+	_, err := ur.db.Collection(ur.collection).UpdateOne(ctx, filter, update)
 	return err
 }
+
+func (ur *UserRepository) ListUsers(ctx context.Context, query models.UserListQuery) ([]models.User, int64, error) {
+	ctx, cancel := ur.db.OperationContext(ctx)
+	defer cancel()
+
+	filter := bson.D{}
+	if query.Search != "" {
+		pattern := bson.Regex{Pattern: regexp.QuoteMeta(query.Search), Options: "i"}
+		filter = append(filter, bson.E{Key: "$or", Value: bson.A{
+			bson.D{{Key: "username", Value: pattern}},
+			bson.D{{Key: "email", Value: pattern}},
+		}})
+	}
+	if query.Role != "" {
+		filter = append(filter, bson.E{Key: "role", Value: query.Role})
+	}
+	if query.IsActive != nil {
+		filter = append(filter, bson.E{Key: "is_active", Value: *query.IsActive})
+	}
+
+	collection := ur.db.Collection(ur.collection)
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64((query.Page - 1) * query.Limit)).
+		SetLimit(int64(query.Limit))
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	users := []models.User{}
+	if err = cursor.All(ctx, &users); err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+func (ur *UserRepository) DeleteUser(ctx context.Context, id primitive.ObjectID) error {
+	ctx, cancel := ur.db.OperationContext(ctx)
+	defer cancel()
+
+	filter := bson.D{{Key: "_id", Value: id}}
+
+	result, err := ur.db.Collection(ur.collection).DeleteOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
@@ -0,0 +1,304 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/database"
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+type WebhookRepository struct {
+	db            *database.DB
+	subscriptions string
+	deliveries    string
+}
+
+func NewWebhookRepository(db *database.DB) *WebhookRepository {
+	return &WebhookRepository{
+		db:            db,
+		subscriptions: "webhook_subscriptions",
+		deliveries:    "webhook_deliveries",
+	}
+}
+
+func (wr *WebhookRepository) CreateSubscription(ctx context.Context, sub *models.WebhookSubscription) error {
+	ctx, cancel := wr.db.OperationContext(ctx)
+	defer cancel()
+
+	sub.ID = primitive.NewObjectID()
+	sub.CreatedAt = time.Now()
+	sub.UpdatedAt = sub.CreatedAt
+
+	_, err := wr.db.Collection(wr.subscriptions).InsertOne(ctx, sub)
+	return err
+}
+
+func (wr *WebhookRepository) GetSubscription(ctx context.Context, id primitive.ObjectID) (*models.WebhookSubscription, error) {
+	ctx, cancel := wr.db.OperationContext(ctx)
+	defer cancel()
+
+	var sub models.WebhookSubscription
+	err := wr.db.Collection(wr.subscriptions).FindOne(ctx, bson.D{{Key: "_id", Value: id}}).Decode(&sub)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sub, nil
+}
+
+func (wr *WebhookRepository) ListSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error) {
+	ctx, cancel := wr.db.OperationContext(ctx)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := wr.db.Collection(wr.subscriptions).Find(ctx, bson.D{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	subs := []models.WebhookSubscription{}
+	if err = cursor.All(ctx, &subs); err != nil {
+		return nil, err
+	}
+
+	return subs, nil
+}
+
+// FindSubscribers returns the active subscriptions interested in eventType
+func (wr *WebhookRepository) FindSubscribers(ctx context.Context, eventType string) ([]models.WebhookSubscription, error) {
+	ctx, cancel := wr.db.OperationContext(ctx)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "active", Value: true},
+		{Key: "event_types", Value: bson.D{{Key: "$in", Value: bson.A{eventType, "*"}}}},
+	}
+
+	cursor, err := wr.db.Collection(wr.subscriptions).Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	subs := []models.WebhookSubscription{}
+	if err = cursor.All(ctx, &subs); err != nil {
+		return nil, err
+	}
+
+	return subs, nil
+}
+
+func (wr *WebhookRepository) UpdateSubscription(ctx context.Context, id primitive.ObjectID, updates bson.D) error {
+	ctx, cancel := wr.db.OperationContext(ctx)
+	defer cancel()
+
+	updates = append(updates, bson.E{Key: "updated_at", Value: time.Now()})
+	result, err := wr.db.Collection(wr.subscriptions).UpdateOne(ctx,
+		bson.D{{Key: "_id", Value: id}},
+		bson.D{{Key: "$set", Value: updates}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// DeleteSubscription removes the subscription and any deliveries still queued for it
+func (wr *WebhookRepository) DeleteSubscription(ctx context.Context, id primitive.ObjectID) error {
+	ctx, cancel := wr.db.OperationContext(ctx)
+	defer cancel()
+
+	result, err := wr.db.Collection(wr.subscriptions).DeleteOne(ctx, bson.D{{Key: "_id", Value: id}})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	_, err = wr.db.Collection(wr.deliveries).DeleteMany(ctx, bson.D{
+		{Key: "subscription_id", Value: id},
+		{Key: "status", Value: models.DeliveryPending},
+	})
+	return err
+}
+
+// InsertDelivery stores delivery, with a new ID unless it has one. It returns
+// models.ErrDuplicateDelivery if the event is already queued for the
+// subscription.
+func (wr *WebhookRepository) InsertDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	start := time.Now()
+	ctx, cancel := wr.db.OperationContext(ctx)
+	defer cancel()
+
+	if delivery.ID.IsZero() {
+		delivery.ID = primitive.NewObjectID()
+	}
+	delivery.CreatedAt = time.Now()
+	if delivery.Attempts == nil {
+		delivery.Attempts = []models.DeliveryAttempt{}
+	}
+
+	// Deliveries are unique per subscription and event, so publishing a
+	// redelivered event does not queue it twice
+	result, err := wr.db.Collection(wr.deliveries).UpdateOne(ctx,
+		bson.D{
+			{Key: "subscription_id", Value: delivery.SubscriptionID},
+			{Key: "event_id", Value: delivery.EventID},
+		},
+		bson.D{{Key: "$setOnInsert", Value: delivery}},
+		options.UpdateOne().SetUpsert(true),
+	)
+	logger.LogDatabaseOperation(ctx, "upsert", wr.deliveries, delivery.ID.Hex(), time.Since(start), err)
+	if err != nil {
+		return err
+	}
+	if result.UpsertedCount == 0 {
+		return models.ErrDuplicateDelivery
+	}
+	return nil
+}
+
+// ClaimDueDelivery locks the oldest pending delivery whose next attempt is
+// due, so that concurrent dispatchers never send the same delivery twice at
+// once. It returns mongo.ErrNoDocuments when nothing is due.
+func (wr *WebhookRepository) ClaimDueDelivery(ctx context.Context, lease time.Duration) (*models.WebhookDelivery, error) {
+	ctx, cancel := wr.db.OperationContext(ctx)
+	defer cancel()
+
+	now := time.Now()
+	filter := bson.D{
+		{Key: "status", Value: models.DeliveryPending},
+		{Key: "next_attempt_at", Value: bson.D{{Key: "$lte", Value: now}}},
+		{Key: "locked_until", Value: bson.D{{Key: "$lte", Value: now}}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "locked_until", Value: now.Add(lease)}}}}
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var delivery models.WebhookDelivery
+	err := wr.db.Collection(wr.deliveries).FindOneAndUpdate(ctx, filter, update, opts).Decode(&delivery)
+	if err != nil {
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+// RecordAttempt appends an attempt to the delivery log and moves the
+// delivery to its next state
+func (wr *WebhookRepository) RecordAttempt(ctx context.Context, id primitive.ObjectID, attempt models.DeliveryAttempt, status string, nextAttemptAt time.Time) error {
+	ctx, cancel := wr.db.OperationContext(ctx)
+	defer cancel()
+
+	set := bson.D{
+		{Key: "status", Value: status},
+		{Key: "next_attempt_at", Value: nextAttemptAt},
+		{Key: "locked_until", Value: time.Time{}},
+	}
+	if status != models.DeliveryPending {
+		set = append(set, bson.E{Key: "completed_at", Value: attempt.At})
+	}
+
+	update := bson.D{
+		{Key: "$set", Value: set},
+		{Key: "$push", Value: bson.D{{Key: "attempts", Value: attempt}}},
+		{Key: "$inc", Value: bson.D{{Key: "attempt_count", Value: 1}}},
+	}
+
+	_, err := wr.db.Collection(wr.deliveries).UpdateOne(ctx, bson.D{{Key: "_id", Value: id}}, update)
+	return err
+}
+
+func (wr *WebhookRepository) GetDelivery(ctx context.Context, subscriptionID, id primitive.ObjectID) (*models.WebhookDelivery, error) {
+	ctx, cancel := wr.db.OperationContext(ctx)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "subscription_id", Value: subscriptionID},
+	}
+
+	var delivery models.WebhookDelivery
+	err := wr.db.Collection(wr.deliveries).FindOne(ctx, filter).Decode(&delivery)
+	if err != nil {
+		return nil, err
+	}
+
+	return &delivery, nil
+}
+
+// RequeueDelivery puts a dead-lettered delivery back into the queue
+func (wr *WebhookRepository) RequeueDelivery(ctx context.Context, id primitive.ObjectID) error {
+	ctx, cancel := wr.db.OperationContext(ctx)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "status", Value: models.DeliveryDead},
+	}
+	update := bson.D{
+		{Key: "$set", Value: bson.D{
+			{Key: "status", Value: models.DeliveryPending},
+			{Key: "next_attempt_at", Value: time.Now()},
+			{Key: "attempt_count", Value: 0},
+		}},
+		{Key: "$unset", Value: bson.D{{Key: "completed_at", Value: ""}}},
+	}
+
+	result, err := wr.db.Collection(wr.deliveries).UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// ListDeliveries returns the delivery log of a subscription, newest first
+func (wr *WebhookRepository) ListDeliveries(ctx context.Context, subscriptionID primitive.ObjectID, status string, page, limit int) ([]models.WebhookDelivery, int64, error) {
+	ctx, cancel := wr.db.OperationContext(ctx)
+	defer cancel()
+
+	filter := bson.D{{Key: "subscription_id", Value: subscriptionID}}
+	if status != "" {
+		filter = append(filter, bson.E{Key: "status", Value: status})
+	}
+
+	collection := wr.db.Collection(wr.deliveries)
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit))
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	deliveries := []models.WebhookDelivery{}
+	if err = cursor.All(ctx, &deliveries); err != nil {
+		return nil, 0, err
+	}
+
+	return deliveries, total, nil
+}
@@ -0,0 +1,115 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/4Noyis/my-library/internal/rpc/libraryv1"
+	"github.com/4Noyis/my-library/internal/services"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// authServer implements AuthService on the UserService
+type authServer struct {
+	libraryv1.UnimplementedAuthServiceServer
+	users *services.UserService
+}
+
+func (s *authServer) Register(ctx context.Context, req *libraryv1.RegisterRequest) (*libraryv1.User, error) {
+	if req.GetUsername() == "" || req.GetEmail() == "" || req.GetPassword() == "" {
+		return nil, invalid("username, email and password are required")
+	}
+
+	user, err := s.users.RegisterUser(ctx, &models.RegisterRequest{
+		Username: req.GetUsername(),
+		Email:    req.GetEmail(),
+		Password: req.GetPassword(),
+	})
+	if err != nil {
+		logger.Logger.WithContext(ctx).WithFields(logrus.Fields{
+			"error":    err.Error(),
+			"username": req.GetUsername(),
+			"email":    req.GetEmail(),
+			"type":     "registration",
+		}).Error("User registration failed")
+
+		return nil, rpcError(ctx, "GRPC.Register", err)
+	}
+
+	logger.Logger.WithContext(ctx).WithFields(logrus.Fields{
+		"user_id":  user.ID.Hex(),
+		"username": user.Username,
+		"email":    user.Email,
+		"type":     "registration",
+	}).Info("User registered successfully")
+	return userToProto(user), nil
+}
+
+func (s *authServer) Login(ctx context.Context, req *libraryv1.LoginRequest) (*libraryv1.LoginResponse, error) {
+	if req.GetUsername() == "" || req.GetPassword() == "" {
+		return nil, invalid("username and password are required")
+	}
+
+	login, err := s.users.LoginUser(ctx, &models.LoginRequest{
+		Username: req.GetUsername(),
+		Password: req.GetPassword(),
+	}, clientInfo(ctx))
+	if err != nil {
+		logger.Logger.WithContext(ctx).WithFields(logrus.Fields{
+			"error":    err.Error(),
+			"username": req.GetUsername(),
+			"type":     "login",
+		}).Error("User login failed")
+
+		return nil, rpcError(ctx, "GRPC.Login", err)
+	}
+
+	logger.Logger.WithContext(ctx).WithFields(logrus.Fields{
+		"user_id":  login.User.ID.Hex(),
+		"username": login.User.Username,
+		"type":     "login",
+	}).Info("User logged in successfully")
+	return &libraryv1.LoginResponse{Token: login.Token, User: userToProto(&login.User)}, nil
+}
+
+func (s *authServer) GetMe(ctx context.Context, req *libraryv1.GetMeRequest) (*libraryv1.User, error) {
+	currentUser, ok := userFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Internal, "User context not found")
+	}
+
+	user, err := s.users.GetUser(ctx, currentUser.ID)
+	if err != nil {
+		return nil, rpcError(ctx, "GRPC.GetMe", err)
+	}
+	return userToProto(user), nil
+}
+
+func (s *authServer) ChangePassword(ctx context.Context, req *libraryv1.ChangePasswordRequest) (*emptypb.Empty, error) {
+	currentUser, ok := userFromContext(ctx)
+	if !ok {
+		return nil, status.Error(codes.Internal, "User context not found")
+	}
+	if req.GetCurrentPassword() == "" || req.GetNewPassword() == "" {
+		return nil, invalid("current_password and new_password are required")
+	}
+
+	err := s.users.ChangePassword(ctx, actorFromContext(ctx), &models.ChangePasswordRequest{
+		CurrentPassword: req.GetCurrentPassword(),
+		NewPassword:     req.GetNewPassword(),
+	})
+	if err != nil {
+		return nil, rpcError(ctx, "GRPC.ChangePassword", err)
+	}
+
+	logger.Logger.WithContext(ctx).WithFields(logrus.Fields{
+		"user_id":  currentUser.ID.Hex(),
+		"username": currentUser.Username,
+		"type":     "account",
+	}).Info("User password changed")
+	return &emptypb.Empty{}, nil
+}
@@ -0,0 +1,123 @@
+package rpc
+
+import (
+	"context"
+
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/4Noyis/my-library/internal/rpc/libraryv1"
+	"github.com/4Noyis/my-library/internal/services"
+	"github.com/sirupsen/logrus"
+)
+
+// catalogServer implements CatalogService on the BookService
+type catalogServer struct {
+	libraryv1.UnimplementedCatalogServiceServer
+	books *services.BookService
+}
+
+func (s *catalogServer) ListBooks(ctx context.Context, req *libraryv1.ListBooksRequest) (*libraryv1.ListBooksResponse, error) {
+	page, err := s.books.ListBooks(ctx, models.BookListQuery{
+		Search:   req.GetSearch(),
+		Author:   req.GetAuthor(),
+		Genre:    req.GetGenre(),
+		Language: req.GetLanguage(),
+		Page:     int(req.GetPage()),
+		Limit:    int(req.GetLimit()),
+	})
+	if err != nil {
+		return nil, rpcError(ctx, "GRPC.ListBooks", err)
+	}
+
+	return &libraryv1.ListBooksResponse{
+		Books: booksToProto(page.Books),
+		Total: page.Total,
+		Page:  int32(page.Page),
+		Limit: int32(page.Limit),
+	}, nil
+}
+
+func (s *catalogServer) GetBook(ctx context.Context, req *libraryv1.GetBookRequest) (*libraryv1.Book, error) {
+	book, err := s.books.GetOneBook(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, rpcError(ctx, "GRPC.GetBook", err)
+	}
+	return bookToProto(book), nil
+}
+
+func (s *catalogServer) CreateBook(ctx context.Context, req *libraryv1.CreateBookRequest) (*libraryv1.Book, error) {
+	if req.GetBook() == nil {
+		return nil, invalid("book is required")
+	}
+
+	book, err := s.books.AddNewBook(ctx, bookFromProto(req.GetBook()), actorFromContext(ctx))
+	if err != nil {
+		return nil, rpcError(ctx, "GRPC.CreateBook", err)
+	}
+
+	logger.Logger.WithContext(ctx).WithFields(logrus.Fields{
+		"book_id": book.ID,
+		"title":   book.Title,
+		"type":    "book",
+	}).Info("Book created")
+	return bookToProto(book), nil
+}
+
+func (s *catalogServer) UpdateBook(ctx context.Context, req *libraryv1.UpdateBookRequest) (*libraryv1.Book, error) {
+	if req.GetBook() == nil {
+		return nil, invalid("book is required")
+	}
+
+	book, err := s.books.UpdateBook(ctx, int(req.GetId()), bookFromProto(req.GetBook()), actorFromContext(ctx))
+	if err != nil {
+		return nil, rpcError(ctx, "GRPC.UpdateBook", err)
+	}
+
+	logger.Logger.WithContext(ctx).WithFields(logrus.Fields{
+		"book_id": book.ID,
+		"type":    "book",
+	}).Info("Book updated")
+	return bookToProto(book), nil
+}
+
+func (s *catalogServer) DeleteBook(ctx context.Context, req *libraryv1.DeleteBookRequest) (*libraryv1.Book, error) {
+	book, err := s.books.DeleteBook(ctx, int(req.GetId()), actorFromContext(ctx))
+	if err != nil {
+		return nil, rpcError(ctx, "GRPC.DeleteBook", err)
+	}
+
+	logger.Logger.WithContext(ctx).WithFields(logrus.Fields{
+		"book_id": book.ID,
+		"type":    "book",
+	}).Info("Book deleted")
+	return bookToProto(book), nil
+}
+
+func (s *catalogServer) GetBookHistory(ctx context.Context, req *libraryv1.GetBookHistoryRequest) (*libraryv1.GetBookHistoryResponse, error) {
+	history, err := s.books.GetBookHistory(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, rpcError(ctx, "GRPC.GetBookHistory", err)
+	}
+
+	revisions := make([]*libraryv1.BookRevision, len(history))
+	for i, revision := range history {
+		if revisions[i], err = revisionToProto(revision); err != nil {
+			return nil, rpcError(ctx, "GRPC.GetBookHistory", err)
+		}
+	}
+	return &libraryv1.GetBookHistoryResponse{Revisions: revisions}, nil
+}
+
+func (s *catalogServer) RevertBook(ctx context.Context, req *libraryv1.RevertBookRequest) (*libraryv1.Book, error) {
+	book, err := s.books.RevertBook(ctx, int(req.GetId()), int(req.GetRev()), actorFromContext(ctx))
+	if err != nil {
+		return nil, rpcError(ctx, "GRPC.RevertBook", err)
+	}
+
+	logger.Logger.WithContext(ctx).WithFields(logrus.Fields{
+		"book_id": book.ID,
+		"rev":     req.GetRev(),
+		"type":    "book",
+	}).Info("Book reverted")
+	return bookToProto(book), nil
+}
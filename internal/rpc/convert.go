@@ -0,0 +1,133 @@
+package rpc
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/4Noyis/my-library/internal/rpc/libraryv1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func bookToProto(book models.Book) *libraryv1.Book {
+	return &libraryv1.Book{
+		Id:          int64(book.ID),
+		Isbn:        book.ISBN,
+		Title:       book.Title,
+		Author:      book.Author,
+		Publisher:   book.Publisher,
+		PublishedAt: timestamp(book.PublishedAt),
+		Genre:       book.Genre,
+		Language:    book.Language,
+		Pages:       int32(book.Pages),
+		Description: book.Description,
+		CoverUrl:    book.CoverURL,
+		Location:    book.Location,
+		CreatedAt:   timestamp(book.CreatedAt),
+		UpdatedAt:   timestamp(book.UpdatedAt),
+	}
+}
+
+// bookFromProto reads the book of a create or update request; fields left
+// unset stay zero, which the services read as "unchanged" on update
+func bookFromProto(book *libraryv1.Book) models.Book {
+	return models.Book{
+		ID:          int(book.GetId()),
+		ISBN:        book.GetIsbn(),
+		Title:       book.GetTitle(),
+		Author:      book.GetAuthor(),
+		Publisher:   book.GetPublisher(),
+		PublishedAt: timeOf(book.GetPublishedAt()),
+		Genre:       book.GetGenre(),
+		Language:    book.GetLanguage(),
+		Pages:       int(book.GetPages()),
+		Description: book.GetDescription(),
+		CoverURL:    book.GetCoverUrl(),
+		Location:    book.GetLocation(),
+		CreatedAt:   timeOf(book.GetCreatedAt()),
+		UpdatedAt:   timeOf(book.GetUpdatedAt()),
+	}
+}
+
+func booksToProto(books []models.Book) []*libraryv1.Book {
+	out := make([]*libraryv1.Book, len(books))
+	for i, book := range books {
+		out[i] = bookToProto(book)
+	}
+	return out
+}
+
+func revisionToProto(revision models.BookRevision) (*libraryv1.BookRevision, error) {
+	out := &libraryv1.BookRevision{
+		BookId:        int64(revision.BookID),
+		Rev:           int32(revision.Rev),
+		Book:          bookToProto(revision.Book),
+		CreatedAt:     timestamp(revision.CreatedAt),
+		ActorUsername: revision.ActorUsername,
+		RevertedFrom:  int32(revision.RevertedFrom),
+	}
+	if !revision.ActorID.IsZero() {
+		out.ActorId = revision.ActorID.Hex()
+	}
+
+	if len(revision.Changes) > 0 {
+		out.Changes = make(map[string]*libraryv1.FieldChange, len(revision.Changes))
+		for field, change := range revision.Changes {
+			before, err := jsonValue(change.Before)
+			if err != nil {
+				return nil, err
+			}
+			after, err := jsonValue(change.After)
+			if err != nil {
+				return nil, err
+			}
+			out.Changes[field] = &libraryv1.FieldChange{Before: before, After: after}
+		}
+	}
+	return out, nil
+}
+
+// jsonValue converts a changed field value to the value the REST API would
+// render for it, e.g. times as RFC 3339 strings
+func jsonValue(v interface{}) (*structpb.Value, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	value := &structpb.Value{}
+	if err := protojson.Unmarshal(data, value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+func userToProto(user *models.User) *libraryv1.User {
+	return &libraryv1.User{
+		Id:                 user.ID.Hex(),
+		Username:           user.Username,
+		Email:              user.Email,
+		Role:               user.Role,
+		IsActive:           user.IsActive,
+		CreatedAt:          timestamp(user.CreatedAt),
+		UpdatedAt:          timestamp(user.UpdatedAt),
+		MustChangePassword: user.MustChangePassword,
+		AuthProvider:       user.AuthProvider,
+	}
+}
+
+// timestamp converts t, leaving unset times unset
+func timestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func timeOf(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
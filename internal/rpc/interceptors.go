@@ -0,0 +1,191 @@
+package rpc
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/middleware"
+	"github.com/4Noyis/my-library/internal/rpc/libraryv1"
+	"github.com/4Noyis/my-library/internal/services"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// requestIDMetadata carries request IDs, like the X-Request-ID header
+const requestIDMetadata = "x-request-id"
+
+// requestIDInterceptor tags every call with an ID the way
+// RequestIDMiddleware does, reusing the caller's x-request-id metadata when
+// it is well formed and sending the ID back in the response header
+func requestIDInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	var requested string
+	if values := incomingMetadata(ctx, requestIDMetadata); len(values) > 0 {
+		requested = values[0]
+	}
+	requestID := middleware.RequestID(requested)
+
+	grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadata, requestID))
+	return handler(logger.WithRequestID(ctx, requestID), req)
+}
+
+// loggingInterceptor logs the start and end of every call with the fields
+// LoggingMiddleware uses for HTTP requests
+func loggingInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	client := clientInfo(ctx)
+
+	logger.Logger.WithContext(ctx).WithFields(logrus.Fields{
+		"method":      info.FullMethod,
+		"remote_addr": client.IPAddress,
+		"user_agent":  client.UserAgent,
+		"type":        "request_start",
+	}).Info("Request started")
+
+	resp, err := handler(ctx, req)
+
+	duration := time.Since(start)
+	logger.Logger.WithContext(ctx).WithFields(logrus.Fields{
+		"method":      info.FullMethod,
+		"remote_addr": client.IPAddress,
+		"status_code": status.Code(err).String(),
+		"duration_ms": duration.Milliseconds(),
+		"type":        "request_complete",
+	}).Info("Request completed")
+
+	return resp, err
+}
+
+// publicMethods may be called without a token
+var publicMethods = map[string]bool{
+	libraryv1.AuthService_Register_FullMethodName: true,
+	libraryv1.AuthService_Login_FullMethodName:    true,
+}
+
+// passwordChangeMethods are the calls a user with a pending forced password
+// reset may still make
+var passwordChangeMethods = map[string]bool{
+	libraryv1.AuthService_GetMe_FullMethodName:          true,
+	libraryv1.AuthService_ChangePassword_FullMethodName: true,
+}
+
+// authInterceptor authenticates calls by the bearer token in their
+// authorization metadata, validated with userService, and adds the user and
+// session to the context like AuthMiddleware
+func authInterceptor(userService *services.UserService) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if publicMethods[info.FullMethod] {
+			return handler(ctx, req)
+		}
+
+		values := incomingMetadata(ctx, "authorization")
+		if len(values) == 0 {
+			logger.Logger.WithContext(ctx).WithFields(logrus.Fields{
+				"method": info.FullMethod,
+				"type":   "auth",
+			}).Error("Missing authorization metadata")
+
+			return nil, status.Error(codes.Unauthenticated, "Authorization metadata required")
+		}
+
+		tokenParts := strings.Split(values[0], " ")
+		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
+			logger.Logger.WithContext(ctx).WithFields(logrus.Fields{
+				"method": info.FullMethod,
+				"type":   "auth",
+			}).Error("Invalid authorization metadata format")
+
+			return nil, status.Error(codes.Unauthenticated, "Invalid authorization metadata format")
+		}
+
+		user, session, err := userService.ValidateJWT(ctx, tokenParts[1])
+		if err != nil {
+			logger.Logger.WithContext(ctx).WithFields(logrus.Fields{
+				"method": info.FullMethod,
+				"error":  err.Error(),
+				"type":   "auth",
+			}).Error("Token validation failed")
+
+			return nil, status.Error(codes.Unauthenticated, "Invalid or expired token")
+		}
+
+		if user.MustChangePassword && !passwordChangeMethods[info.FullMethod] {
+			logger.Logger.WithContext(ctx).WithFields(logrus.Fields{
+				"method":  info.FullMethod,
+				"user_id": user.ID.Hex(),
+				"type":    "auth",
+			}).Error("Password change required")
+
+			return nil, status.Error(codes.PermissionDenied, "Password change required")
+		}
+
+		ctx = context.WithValue(ctx, middleware.UserContextKey, user)
+		ctx = context.WithValue(ctx, middleware.SessionContextKey, session)
+
+		logger.Logger.WithContext(ctx).WithFields(logrus.Fields{
+			"method":   info.FullMethod,
+			"user_id":  user.ID.Hex(),
+			"username": user.Username,
+			"role":     user.Role,
+			"type":     "auth",
+		}).Info("User authenticated successfully")
+
+		return handler(ctx, req)
+	}
+}
+
+// rateLimitInterceptor limits calls with limiter like RateLimitMiddleware,
+// keyed by the full method name so Login and Register get the credential
+// policy, and reports the quota in ratelimit-* response metadata. It runs
+// after authInterceptor, so callers with a token are limited per user.
+func rateLimitInterceptor(limiter *services.RateLimiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !limiter.Enabled() {
+			return handler(ctx, req)
+		}
+
+		user, _ := userFromContext(ctx)
+		policy, result, err := limiter.Take(ctx, info.FullMethod, user, clientInfo(ctx).IPAddress)
+		if err != nil {
+			// A broken store shouldn't take the API down with it
+			logger.LogError(ctx, "RateLimit", err, logrus.Fields{
+				"method": info.FullMethod,
+			})
+			return handler(ctx, req)
+		}
+
+		header := metadata.Pairs(
+			"ratelimit-policy", strconv.Itoa(policy.Requests)+";w="+middleware.FormatSeconds(policy.Window),
+			"ratelimit-limit", strconv.Itoa(policy.Requests),
+			"ratelimit-remaining", strconv.Itoa(result.Remaining),
+			"ratelimit-reset", middleware.FormatSeconds(result.Reset),
+		)
+
+		if !result.Allowed {
+			logger.Logger.WithContext(ctx).WithFields(logrus.Fields{
+				"method": info.FullMethod,
+				"type":   "rate_limit",
+			}).Warn("Rate limit exceeded")
+
+			header.Set("retry-after", middleware.FormatSeconds(result.RetryAfter))
+			grpc.SetHeader(ctx, header)
+			return nil, status.Error(codes.ResourceExhausted, "Too many requests")
+		}
+
+		grpc.SetHeader(ctx, header)
+		return handler(ctx, req)
+	}
+}
+
+func incomingMetadata(ctx context.Context, key string) []string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil
+	}
+	return md.Get(key)
+}
@@ -0,0 +1,115 @@
+// Package rpc serves the catalog and authentication as a gRPC API for
+// internal callers. Like the GraphQL API it is built on the same services as
+// the REST handlers, so every API enforces the same rules and records the
+// same audit trail.
+//
+// The service definitions live in proto/library/v1; regenerate the code in
+// libraryv1 after changing them.
+package rpc
+
+//go:generate protoc -I ../../proto --go_out=../.. --go_opt=module=github.com/4Noyis/my-library --go-grpc_out=../.. --go-grpc_opt=module=github.com/4Noyis/my-library library/v1/catalog.proto library/v1/auth.proto
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/middleware"
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/4Noyis/my-library/internal/rpc/libraryv1"
+	"github.com/4Noyis/my-library/internal/services"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Services are the services behind the gRPC API
+type Services struct {
+	Books   *services.BookService
+	Users   *services.UserService
+	Limiter *services.RateLimiter
+}
+
+// NewServer returns a gRPC server with the catalog and auth services
+// registered. Calls are tagged with a request ID, logged like HTTP requests,
+// authenticated by their bearer token except for Register and Login, and
+// rate limited like the REST API.
+func NewServer(svc Services) *grpc.Server {
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		requestIDInterceptor,
+		loggingInterceptor,
+		authInterceptor(svc.Users),
+		rateLimitInterceptor(svc.Limiter),
+	))
+	libraryv1.RegisterCatalogServiceServer(server, &catalogServer{books: svc.Books})
+	libraryv1.RegisterAuthServiceServer(server, &authServer{users: svc.Users})
+	return server
+}
+
+// userFromContext returns the user added by authInterceptor
+func userFromContext(ctx context.Context) (*models.User, bool) {
+	user, ok := ctx.Value(middleware.UserContextKey).(*models.User)
+	return user, ok
+}
+
+// clientInfo describes the caller of a gRPC call, like its HTTP counterpart
+func clientInfo(ctx context.Context) models.ClientInfo {
+	var client models.ClientInfo
+	if p, ok := peer.FromContext(ctx); ok {
+		client.IPAddress = p.Addr.String()
+		if ip, _, err := net.SplitHostPort(client.IPAddress); err == nil {
+			client.IPAddress = ip
+		}
+	}
+	if values := incomingMetadata(ctx, "user-agent"); len(values) > 0 {
+		client.UserAgent = values[0]
+	}
+	return client
+}
+
+// actorFromContext identifies the authenticated user making a change, for
+// the audit log
+func actorFromContext(ctx context.Context) models.Actor {
+	actor := models.Actor{
+		IPAddress: clientInfo(ctx).IPAddress,
+		RequestID: logger.RequestIDFromContext(ctx),
+	}
+	if user, ok := userFromContext(ctx); ok {
+		actor.UserID = user.ID
+		actor.Username = user.Username
+	}
+	return actor
+}
+
+// rpcError reports the failure of operation the way problem.WriteError
+// does: service errors keep their message under the matching status code,
+// others are logged and hidden behind a generic message
+func rpcError(ctx context.Context, operation string, err error) error {
+	var code codes.Code
+	switch {
+	case errors.Is(err, services.ErrValidation):
+		code = codes.InvalidArgument
+	case errors.Is(err, services.ErrUnauthorized):
+		code = codes.Unauthenticated
+	case errors.Is(err, services.ErrForbidden):
+		code = codes.PermissionDenied
+	case errors.Is(err, services.ErrNotFound):
+		code = codes.NotFound
+	case errors.Is(err, services.ErrConflict):
+		code = codes.AlreadyExists
+	case errors.Is(err, services.ErrUpstream), errors.Is(err, services.ErrUnavailable):
+		code = codes.Unavailable
+	default:
+		logger.LogError(ctx, operation, err, logrus.Fields{"type": "grpc"})
+		return status.Error(codes.Internal, "internal server error")
+	}
+	return status.Error(code, err.Error())
+}
+
+// invalid reports a malformed request
+func invalid(message string) error {
+	return status.Error(codes.InvalidArgument, message)
+}
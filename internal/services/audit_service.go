@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/4Noyis/my-library/internal/tracing"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Audited actions
+const (
+	AuditBookCreate         = "book.create"
+	AuditBookUpdate         = "book.update"
+	AuditBookDelete         = "book.delete"
+	AuditBookRevert         = "book.revert"
+	AuditUserProfileUpdate  = "user.profile_update"
+	AuditUserPasswordChange = "user.password_change"
+	AuditUserRoleChange     = "user.role_change"
+	AuditUserStatusChange   = "user.status_change"
+	AuditUserPasswordReset  = "user.password_reset"
+	AuditUserLink           = "user.link"
+	AuditUserDelete         = "user.delete"
+)
+
+// Bookkeeping fields that change with every write and would only add noise to diffs
+var auditIgnoredFields = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+}
+
+type AuditService struct {
+	auditRepo AuditStore
+}
+
+func NewAuditService(store AuditStore) *AuditService {
+	return &AuditService{
+		auditRepo: store,
+	}
+}
+
+// Record stores an audit event for a change to target. before is nil for
+// creations and after is nil for deletions. eventID is the ID of the domain
+// event being recorded; recording the same event twice is a no-op.
+func (as *AuditService) Record(ctx context.Context, eventID string, occurredAt time.Time, actor models.Actor, action, targetType, targetID string, before, after interface{}) (err error) {
+	ctx, span := tracing.Start(ctx, "AuditService.Record", attribute.String("audit.action", action), attribute.String("event.id", eventID))
+	defer func() { tracing.End(span, err) }()
+
+	event := &models.AuditEvent{
+		EventID:       eventID,
+		Timestamp:     occurredAt,
+		ActorID:       actor.UserID,
+		ActorUsername: actor.Username,
+		Action:        action,
+		TargetType:    targetType,
+		TargetID:      targetID,
+		Changes:       diffFields(before, after),
+		RequestID:     actor.RequestID,
+		IPAddress:     actor.IPAddress,
+	}
+
+	if err := as.auditRepo.InsertEvent(ctx, event); err != nil {
+		logger.LogError(ctx, "RecordAuditEvent", err, logrus.Fields{
+			"action":    action,
+			"target":    targetType,
+			"target_id": targetID,
+			"actor_id":  actor.UserID.Hex(),
+		})
+		return err
+	}
+	return nil
+}
+
+func (as *AuditService) ListEvents(ctx context.Context, query models.AuditQuery) (_ *models.AuditListResponse, err error) {
+	ctx, span := tracing.Start(ctx, "AuditService.ListEvents")
+	defer func() { tracing.End(span, err) }()
+
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.Limit < 1 {
+		query.Limit = 50
+	}
+	if query.Limit > 200 {
+		query.Limit = 200
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && query.To.Before(query.From) {
+		return nil, invalid("invalid time range")
+	}
+
+	events, total, err := as.auditRepo.FindEvents(ctx, query)
+	if err != nil {
+		return nil, errors.New("database error while listing audit events")
+	}
+
+	return &models.AuditListResponse{
+		Events: events,
+		Total:  total,
+		Page:   query.Page,
+		Limit:  query.Limit,
+	}, nil
+}
+
+// diffFields compares the JSON representation of two values field by field
+// and returns the fields that differ
+func diffFields(before, after interface{}) map[string]models.FieldChange {
+	beforeFields := jsonFields(before)
+	afterFields := jsonFields(after)
+
+	changes := map[string]models.FieldChange{}
+	for field, value := range beforeFields {
+		if auditIgnoredFields[field] {
+			continue
+		}
+		if newValue, ok := afterFields[field]; !ok || !reflect.DeepEqual(value, newValue) {
+			changes[field] = models.FieldChange{Before: value, After: afterFields[field]}
+		}
+	}
+	for field, value := range afterFields {
+		if auditIgnoredFields[field] {
+			continue
+		}
+		if _, ok := beforeFields[field]; !ok {
+			changes[field] = models.FieldChange{Before: nil, After: value}
+		}
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+	return changes
+}
+
+func jsonFields(value interface{}) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if value == nil {
+		return fields
+	}
+	if v := reflect.ValueOf(value); v.Kind() == reflect.Ptr && v.IsNil() {
+		return fields
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fields
+	}
+	json.Unmarshal(data, &fields)
+	return fields
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/4Noyis/my-library/internal/config"
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/4Noyis/my-library/internal/tracing"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Authenticator verifies a username and password against one credential
+// store. LoginUser tries each configured authenticator in order.
+type Authenticator interface {
+	Name() string
+	// Authenticate returns errUnknownUser when the user is not managed by
+	// this authenticator, so the next one in the chain gets a chance
+	Authenticate(ctx context.Context, username, password string) (*models.User, error)
+}
+
+// errUnknownUser passes a login on to the next authenticator. If no
+// authenticator recognises the user it is returned to the caller as is.
+var errUnknownUser = unauthorized("invalid credentials")
+
+// LocalAuthenticator checks bcrypt password hashes stored in the users collection
+type LocalAuthenticator struct {
+	userService *UserService
+}
+
+func NewLocalAuthenticator(userService *UserService) *LocalAuthenticator {
+	return &LocalAuthenticator{userService: userService}
+}
+
+func (a *LocalAuthenticator) Name() string {
+	return "local"
+}
+
+func (a *LocalAuthenticator) Authenticate(ctx context.Context, username, password string) (*models.User, error) {
+	user, err := a.userService.userRepo.GetUserByUsername(ctx, username)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, errUnknownUser
+		}
+		return nil, errors.New("database error during login")
+	}
+
+	// Accounts provisioned from a directory or identity provider have no
+	// local password
+	if user.Password == "" {
+		return nil, errUnknownUser
+	}
+
+	// Check if user is active
+	if !user.IsActive {
+		return nil, unauthorized("account is deactivated")
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
+	if err != nil {
+		// Linked accounts may still authenticate with their external password
+		if user.AuthProvider != "" {
+			return nil, errUnknownUser
+		}
+		return nil, unauthorized("invalid credentials")
+	}
+
+	a.userService.upgradePasswordHash(ctx, user, password)
+
+	return user, nil
+}
+
+// UseAuthenticators replaces the login authenticator chain
+func (us *UserService) UseAuthenticators(authenticators ...Authenticator) {
+	us.authenticators = authenticators
+}
+
+// newAuthenticators builds the chain named in auth.backends, e.g.
+// [local, ldap]. By default local passwords are checked first, followed by
+// LDAP when ldap.url is set.
+func (us *UserService) newAuthenticators(auth config.Auth, ldapConfig config.LDAP) []Authenticator {
+	names := auth.Backends
+	if len(names) == 0 {
+		names = []string{"local"}
+		if ldapConfig.URL != "" {
+			names = append(names, "ldap")
+		}
+	}
+
+	var authenticators []Authenticator
+	for _, name := range names {
+		switch strings.ToLower(name) {
+		case "local":
+			authenticators = append(authenticators, NewLocalAuthenticator(us))
+		case "ldap":
+			authenticators = append(authenticators, NewLDAPAuthenticator(us, ldapConfig))
+		default:
+			logger.LogError(context.Background(), "newAuthenticators", errors.New("unknown authentication backend"), logrus.Fields{
+				"backend": name,
+			})
+		}
+	}
+
+	return authenticators
+}
+
+// authenticate runs the authenticator chain until one of them recognises the user
+func (us *UserService) authenticate(ctx context.Context, username, password string) (_ *models.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.Authenticate")
+	defer func() { tracing.End(span, err) }()
+
+	for _, authenticator := range us.authenticators {
+		user, err := authenticator.Authenticate(ctx, username, password)
+		if err == errUnknownUser {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		span.SetAttributes(attribute.String("auth.authenticator", authenticator.Name()))
+		logger.LogDebug(ctx, "User authenticated", logrus.Fields{
+			"username":      username,
+			"authenticator": authenticator.Name(),
+		})
+		return user, nil
+	}
+
+	return nil, errUnknownUser
+}
@@ -0,0 +1,20 @@
+package services
+
+import (
+	"math/rand"
+	"time"
+)
+
+// backoff is the wait before retrying after the given number of failed
+// attempts: base, doubled after every further failure up to max, with up to
+// 10% jitter so retries of many records spread out
+func backoff(attempts int, base, max time.Duration) time.Duration {
+	wait := base
+	for i := 1; i < attempts && wait < max; i++ {
+		wait *= 2
+	}
+	if wait > max {
+		wait = max
+	}
+	return wait + time.Duration(rand.Int63n(int64(wait)/10+1))
+}
@@ -0,0 +1,27 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffDoublesUpToMax(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{50, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		for range 20 {
+			got := backoff(tt.attempts, time.Second, 10*time.Second)
+			if got < tt.want || got > tt.want+tt.want/10 {
+				t.Fatalf("backoff after %d attempts = %v, want %v plus up to 10%%", tt.attempts, got, tt.want)
+			}
+		}
+	}
+}
@@ -1,36 +1,287 @@
 package services
 
 import (
+	"context"
+	"errors"
+
+	"github.com/4Noyis/my-library/internal/events"
+	"github.com/4Noyis/my-library/internal/logger"
 	"github.com/4Noyis/my-library/internal/models"
-	"github.com/4Noyis/my-library/internal/repositories"
+	"github.com/4Noyis/my-library/internal/tracing"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type BookService struct {
-	bookRepo *repositories.BookRepository
+	db           Database
+	bookRepo     BookStore
+	revisionRepo BookRevisionStore
+	outbox       *Outbox
 }
 
-func NewBookService() *BookService {
+func NewBookService(db Database, books BookStore, revisions BookRevisionStore, outbox *Outbox) *BookService {
 	return &BookService{
-		bookRepo: repositories.NewBookCollection(),
+		db:           db,
+		bookRepo:     books,
+		revisionRepo: revisions,
+		outbox:       outbox,
+	}
+}
+
+func (bs *BookService) GetAllBooks(ctx context.Context) (_ []models.Book, err error) {
+	ctx, span := tracing.Start(ctx, "BookService.GetAllBooks")
+	defer func() { tracing.End(span, err) }()
+
+	return bs.bookRepo.GetAllBooks(ctx)
+}
+
+// ListBooks returns one page of the catalog matching query
+func (bs *BookService) ListBooks(ctx context.Context, query models.BookListQuery) (_ *models.BookListResponse, err error) {
+	ctx, span := tracing.Start(ctx, "BookService.ListBooks")
+	defer func() { tracing.End(span, err) }()
+
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.Limit < 1 {
+		query.Limit = 20
 	}
+	if query.Limit > 100 {
+		query.Limit = 100
+	}
+
+	books, total, err := bs.bookRepo.ListBooks(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.BookListResponse{
+		Books: books,
+		Total: total,
+		Page:  query.Page,
+		Limit: query.Limit,
+	}, nil
 }
 
-func (bs *BookService) GetAllBooks() ([]models.Book, error) {
-	return bs.bookRepo.GetAllBooks()
+func (bs *BookService) GetOneBook(ctx context.Context, id int) (_ models.Book, err error) {
+	ctx, span := tracing.Start(ctx, "BookService.GetOneBook", attribute.Int("book.id", id))
+	defer func() { tracing.End(span, err) }()
+
+	book, err := bs.bookRepo.GetOneBook(ctx, id)
+	return book, bookError(err)
 }
 
-func (bs *BookService) GetOneBook(id int) (models.Book, error) {
-	return bs.bookRepo.GetOneBook(id)
+func (bs *BookService) DeleteBook(ctx context.Context, id int, actor models.Actor) (_ models.Book, err error) {
+	ctx, span := tracing.Start(ctx, "BookService.DeleteBook", attribute.Int("book.id", id))
+	defer func() { tracing.End(span, err) }()
+
+	var book models.Book
+	err = bs.db.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		book, err = bs.bookRepo.DeleteBook(ctx, id)
+		if err != nil {
+			return err
+		}
+		return bs.outbox.Enqueue(ctx, events.BookDeleted{Meta: events.NewMeta(ctx), Book: book, Actor: actor})
+	})
+	if err != nil {
+		return book, bookError(err)
+	}
+
+	bs.outbox.Notify()
+	return book, nil
 }
 
-func (bs *BookService) DeleteBook(id int) (models.Book, error) {
-	return bs.bookRepo.DeleteBook(id)
+func (bs *BookService) AddNewBook(ctx context.Context, book models.Book, actor models.Actor) (_ models.Book, err error) {
+	ctx, span := tracing.Start(ctx, "BookService.AddNewBook")
+	defer func() { tracing.End(span, err) }()
+
+	var created models.Book
+	err = bs.db.WithTransaction(ctx, func(ctx context.Context) error {
+		var err error
+		created, err = bs.bookRepo.AddNewBook(ctx, book)
+		if err != nil {
+			return err
+		}
+		if err := bs.addRevision(ctx, created, actor, 0); err != nil {
+			return err
+		}
+		return bs.outbox.Enqueue(ctx, events.BookCreated{Meta: events.NewMeta(ctx), Book: created, Actor: actor})
+	})
+	if err != nil {
+		return created, err
+	}
+
+	bs.outbox.Notify()
+	return created, nil
 }
 
-func (bs *BookService) AddNewBook(book models.Book) (models.Book, error) {
-	return bs.bookRepo.AddNewBook(book)
+func (bs *BookService) UpdateBook(ctx context.Context, id int, updates models.Book, actor models.Actor) (_ models.Book, err error) {
+	ctx, span := tracing.Start(ctx, "BookService.UpdateBook", attribute.Int("book.id", id))
+	defer func() { tracing.End(span, err) }()
+
+	var updated models.Book
+	err = bs.db.WithTransaction(ctx, func(ctx context.Context) error {
+		before, err := bs.bookRepo.GetOneBook(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		// Books created before revision tracking get their prior state as the first revision
+		count, err := bs.revisionRepo.CountRevisions(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		updated, err = bs.bookRepo.UpdateBook(ctx, id, updates)
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			if err := bs.addRevision(ctx, before, models.Actor{}, 0); err != nil {
+				return err
+			}
+		}
+		if err := bs.addRevision(ctx, updated, actor, 0); err != nil {
+			return err
+		}
+		return bs.outbox.Enqueue(ctx, events.BookUpdated{
+			Meta:   events.NewMeta(ctx),
+			Before: before,
+			After:  updated,
+			Actor:  actor,
+		})
+	})
+	if err != nil {
+		return updated, bookError(err)
+	}
+
+	bs.outbox.Notify()
+	return updated, nil
 }
 
-func (bs *BookService) UpdateBook(id int, updates models.Book) (models.Book, error) {
-	return bs.bookRepo.UpdateBook(id, updates)
+// GetBookHistory returns every revision of a book, oldest first, each with
+// the field-level changes relative to the revision before it
+func (bs *BookService) GetBookHistory(ctx context.Context, id int) (_ []models.BookRevision, err error) {
+	ctx, span := tracing.Start(ctx, "BookService.GetBookHistory", attribute.Int("book.id", id))
+	defer func() { tracing.End(span, err) }()
+
+	revisions, err := bs.revisionRepo.GetRevisions(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(revisions) == 0 {
+		if _, err := bs.bookRepo.GetOneBook(ctx, id); err != nil {
+			return nil, bookError(err)
+		}
+	}
+
+	addChanges(revisions)
+	return revisions, nil
+}
+
+// GetBookHistories returns the history of several books, keyed by book ID,
+// with a single repository call. Books without revisions are left out.
+func (bs *BookService) GetBookHistories(ctx context.Context, ids []int) (_ map[int][]models.BookRevision, err error) {
+	ctx, span := tracing.Start(ctx, "BookService.GetBookHistories", attribute.Int("book.count", len(ids)))
+	defer func() { tracing.End(span, err) }()
+
+	revisions, err := bs.revisionRepo.GetRevisionsOfBooks(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	histories := make(map[int][]models.BookRevision)
+	for _, revision := range revisions {
+		histories[revision.BookID] = append(histories[revision.BookID], revision)
+	}
+	for _, history := range histories {
+		addChanges(history)
+	}
+	return histories, nil
+}
+
+// addChanges fills in the changes of each revision, oldest first, relative to
+// the revision before it
+func addChanges(revisions []models.BookRevision) {
+	for i := range revisions {
+		if i == 0 {
+			revisions[i].Changes = diffFields(nil, &revisions[i].Book)
+			continue
+		}
+		revisions[i].Changes = diffFields(&revisions[i-1].Book, &revisions[i].Book)
+	}
+}
+
+// RevertBook restores the catalog fields of an earlier revision. The restore
+// is recorded as a new revision, so history is never rewritten.
+func (bs *BookService) RevertBook(ctx context.Context, id, rev int, actor models.Actor) (_ models.Book, err error) {
+	ctx, span := tracing.Start(ctx, "BookService.RevertBook", attribute.Int("book.id", id), attribute.Int("book.rev", rev))
+	defer func() { tracing.End(span, err) }()
+
+	var restored models.Book
+	err = bs.db.WithTransaction(ctx, func(ctx context.Context) error {
+		current, err := bs.bookRepo.GetOneBook(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		revision, err := bs.revisionRepo.GetRevision(ctx, id, rev)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return notFound("revision not found")
+			}
+			return err
+		}
+
+		restored, err = bs.bookRepo.ReplaceBookFields(ctx, id, revision.Book)
+		if err != nil {
+			return err
+		}
+		if err := bs.addRevision(ctx, restored, actor, rev); err != nil {
+			return err
+		}
+		return bs.outbox.Enqueue(ctx, events.BookUpdated{
+			Meta:         events.NewMeta(ctx),
+			Before:       current,
+			After:        restored,
+			RevertedFrom: rev,
+			Actor:        actor,
+		})
+	})
+	if err != nil {
+		return models.Book{}, bookError(err)
+	}
+
+	bs.outbox.Notify()
+	return restored, nil
+}
+
+// bookError reports a missing book as ErrNotFound
+func bookError(err error) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return notFound("book not found")
+	}
+	return err
+}
+
+// addRevision snapshots book as part of the write that changed it
+func (bs *BookService) addRevision(ctx context.Context, book models.Book, actor models.Actor, revertedFrom int) error {
+	revision := &models.BookRevision{
+		BookID:        book.ID,
+		Book:          book,
+		ActorID:       actor.UserID,
+		ActorUsername: actor.Username,
+		RevertedFrom:  revertedFrom,
+	}
+
+	if err := bs.revisionRepo.AddRevision(ctx, revision); err != nil {
+		logger.LogError(ctx, "AddBookRevision", err, logrus.Fields{
+			"book_id": book.ID,
+		})
+		return err
+	}
+	return nil
 }
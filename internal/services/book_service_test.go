@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/4Noyis/my-library/internal/repositories/memory"
+)
+
+func TestNewBookDoesNotInheritHistoryOfDeletedBook(t *testing.T) {
+	ctx := context.Background()
+	bs := NewBookService(memory.NewDB(), memory.NewBookRepository(), memory.NewBookRevisionRepository(), NewOutbox(&memoryOutbox{}))
+	actor := models.Actor{Username: "librarian"}
+
+	if _, err := bs.AddNewBook(ctx, models.Book{Title: "Dune"}, actor); err != nil {
+		t.Fatal(err)
+	}
+	deleted, err := bs.AddNewBook(ctx, models.Book{Title: "Emma"}, actor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bs.UpdateBook(ctx, deleted.ID, models.Book{Location: "B-2"}, actor); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bs.DeleteBook(ctx, deleted.ID, actor); err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := bs.AddNewBook(ctx, models.Book{Title: "Ubik"}, actor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.ID == deleted.ID {
+		t.Fatalf("new book reused ID %d of a deleted book", created.ID)
+	}
+
+	history, err := bs.GetBookHistory(ctx, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].Rev != 1 || history[0].Book.Title != "Ubik" {
+		t.Errorf("history of the new book = %+v, want only its creation", history)
+	}
+}
+
+func TestBookWritesReportMissingBooksAndRevisions(t *testing.T) {
+	ctx := context.Background()
+	bs := NewBookService(memory.NewDB(), memory.NewBookRepository(), memory.NewBookRevisionRepository(), NewOutbox(&memoryOutbox{}))
+	actor := models.Actor{Username: "librarian"}
+
+	book, err := bs.AddNewBook(ctx, models.Book{Title: "Dune"}, actor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		write   func() (models.Book, error)
+		wantErr string
+	}{
+		{"update a missing book", func() (models.Book, error) {
+			return bs.UpdateBook(ctx, book.ID+1, models.Book{Location: "B-2"}, actor)
+		}, "book not found"},
+		{"revert a missing book", func() (models.Book, error) {
+			return bs.RevertBook(ctx, book.ID+1, 1, actor)
+		}, "book not found"},
+		{"revert to a missing revision", func() (models.Book, error) {
+			return bs.RevertBook(ctx, book.ID, 2, actor)
+		}, "revision not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.write()
+			if !errors.Is(err, ErrNotFound) || err.Error() != tt.wantErr {
+				t.Errorf("err = %v, want %s (%v)", err, tt.wantErr, ErrNotFound)
+			}
+		})
+	}
+
+	history, err := bs.GetBookHistory(ctx, book.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 {
+		t.Errorf("failed writes left %d revisions, want 1", len(history))
+	}
+}
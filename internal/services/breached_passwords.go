@@ -0,0 +1,85 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Bundled list of common and breached passwords, stored as SHA-1 hashes in
+// "PREFIX:SUFFIX" lines where PREFIX is the first five hex characters
+//
+//go:embed data/breached_passwords.txt
+var bundledBreachedPasswords []byte
+
+// BreachedPasswordChecker looks passwords up in a hash-prefix index, the same
+// k-anonymity layout used by the Have I Been Pwned range API, so the list can
+// be swapped for a larger offline dump without changing the lookup.
+type BreachedPasswordChecker struct {
+	ranges map[string]map[string]struct{}
+}
+
+// LoadBreachedPasswords reads the index from path, or the bundled list when
+// path is empty. Lines may also be bare 40 character hashes; an optional
+// ":count" suffix (as in HIBP dumps) is ignored.
+func LoadBreachedPasswords(path string) (*BreachedPasswordChecker, error) {
+	var r io.Reader = bytes.NewReader(bundledBreachedPasswords)
+	if path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open breached password list: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	checker := &BreachedPasswordChecker{ranges: make(map[string]map[string]struct{})}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		parts := strings.Split(text, ":")
+		hash := parts[0]
+		if len(parts[0]) == 5 && len(parts) > 1 {
+			hash = parts[0] + parts[1]
+		}
+		hash = strings.ToUpper(hash)
+		if len(hash) != 40 {
+			return nil, fmt.Errorf("breached password list line %d: invalid hash", line)
+		}
+
+		prefix, suffix := hash[:5], hash[5:]
+		if checker.ranges[prefix] == nil {
+			checker.ranges[prefix] = make(map[string]struct{})
+		}
+		checker.ranges[prefix][suffix] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read breached password list: %w", err)
+	}
+
+	return checker, nil
+}
+
+// IsBreached reports whether password is in the list
+func (c *BreachedPasswordChecker) IsBreached(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	suffixes, ok := c.ranges[hash[:5]]
+	if !ok {
+		return false
+	}
+	_, found := suffixes[hash[5:]]
+	return found
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func sha1Hex(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func writeBreachedList(t *testing.T, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadBreachedPasswordsFormats(t *testing.T) {
+	ranged, counted, bare := sha1Hex("hunter2"), sha1Hex("letmein!"), sha1Hex("qwerty123")
+	// A password sharing the range of another is only a match on its suffix
+	sibling := ranged[:5] + strings.Repeat("0", 35)
+
+	path := writeBreachedList(t,
+		"# comments and blank lines are skipped",
+		"",
+		ranged[:5]+":"+ranged[5:],
+		ranged[:5]+":"+sibling[5:],
+		counted[:5]+":"+counted[5:]+":1024",
+		"  "+strings.ToLower(bare)+"  ",
+	)
+	checker, err := LoadBreachedPasswords(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{"hunter2", true},
+		{"letmein!", true},
+		{"qwerty123", true},
+		{"hunter3", false},
+		{"Hunter2", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := checker.IsBreached(tt.password); got != tt.want {
+			t.Errorf("IsBreached(%q) = %v, want %v", tt.password, got, tt.want)
+		}
+	}
+	if len(checker.ranges[ranged[:5]]) != 2 {
+		t.Errorf("range %s holds %d suffixes, want 2", ranged[:5], len(checker.ranges[ranged[:5]]))
+	}
+}
+
+func TestLoadBreachedPasswordsBundledList(t *testing.T) {
+	checker, err := LoadBreachedPasswords("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, password := range []string{"password", "123456"} {
+		if !checker.IsBreached(password) {
+			t.Errorf("%q is not in the bundled list", password)
+		}
+	}
+	if checker.IsBreached("correct horse battery staple") {
+		t.Error("uncommon password reported as breached")
+	}
+}
+
+func TestLoadBreachedPasswordsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{"missing file", filepath.Join(t.TempDir(), "missing.txt"), "failed to open breached password list"},
+		{"short hash", writeBreachedList(t, "# header", sha1Hex("ok"), "ABCDE:123"), "line 3: invalid hash"},
+		{"prefix too long", writeBreachedList(t, sha1Hex("ok")[:6]+":"+sha1Hex("ok")[6:]), "line 1: invalid hash"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadBreachedPasswords(tt.path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package services
+
+import "errors"
+
+// Kinds of service error. Match them with errors.Is; the HTTP layer maps
+// each kind to a status code and everything else to 500.
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrValidation   = errors.New("validation failed")
+	ErrUnavailable  = errors.New("unavailable")
+	ErrUpstream     = errors.New("upstream failure")
+)
+
+// Error is a failure of a known kind. Its message is written for API
+// clients, so it must not leak internals.
+type Error struct {
+	Kind    error
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+func notFound(message string) error {
+	return &Error{Kind: ErrNotFound, Message: message}
+}
+
+func conflict(message string) error {
+	return &Error{Kind: ErrConflict, Message: message}
+}
+
+func unauthorized(message string) error {
+	return &Error{Kind: ErrUnauthorized, Message: message}
+}
+
+func forbidden(message string) error {
+	return &Error{Kind: ErrForbidden, Message: message}
+}
+
+func invalid(message string) error {
+	return &Error{Kind: ErrValidation, Message: message}
+}
+
+func unavailable(message string) error {
+	return &Error{Kind: ErrUnavailable, Message: message}
+}
+
+func upstream(message string) error {
+	return &Error{Kind: ErrUpstream, Message: message}
+}
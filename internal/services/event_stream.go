@@ -0,0 +1,243 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/config"
+	"github.com/4Noyis/my-library/internal/events"
+	"github.com/4Noyis/my-library/internal/models"
+)
+
+// Events a client can receive from the change stream
+var streamEventTypes = map[string]bool{
+	EventBookCreated: true,
+	EventBookUpdated: true,
+	EventBookDeleted: true,
+}
+
+// Events a client can fall behind by before it is disconnected; it then
+// reconnects and resumes from the replay buffer
+const streamClientBuffer = 64
+
+// StreamEvent is one server-sent event. ID has the form "<epoch>-<seq>", the
+// epoch changes on every restart so IDs from an earlier process are never
+// mistaken for current ones.
+type StreamEvent struct {
+	ID   string
+	Seq  uint64
+	Type string
+	Data []byte
+}
+
+// EventStream fans catalog changes out to connected clients and keeps the
+// most recent ones for clients resuming with Last-Event-ID
+type EventStream struct {
+	mu      sync.Mutex
+	epoch   string
+	seq     uint64
+	buffer  []StreamEvent // ring buffer of the last len(buffer) events
+	next    int
+	seen    map[string]bool // domain event IDs in the buffer, outbox relays at least once
+	ids     []string
+	clients map[*StreamSubscription]struct{}
+	closed  bool
+}
+
+// StreamSubscription is one connected client
+type StreamSubscription struct {
+	Events <-chan StreamEvent
+	Done   <-chan struct{} // closed when the client fell behind or the stream shut down
+
+	stream *EventStream
+	types  map[string]bool
+	events chan StreamEvent
+	done   chan struct{}
+}
+
+// NewEventStream creates a stream keeping cfg.ReplayBuffer events for
+// resuming clients and subscribes it to bus
+func NewEventStream(bus *events.Bus, cfg config.Stream) *EventStream {
+	size := cfg.ReplayBuffer
+	if size < 1 {
+		size = 1
+	}
+
+	s := &EventStream{
+		epoch:   strconv.FormatInt(time.Now().UnixNano(), 36),
+		buffer:  make([]StreamEvent, size),
+		ids:     make([]string, size),
+		seen:    make(map[string]bool),
+		clients: make(map[*StreamSubscription]struct{}),
+	}
+
+	events.On(bus, "event_stream", func(ctx context.Context, e events.BookCreated) error {
+		return s.publish(e, EventBookCreated, e.Book)
+	})
+	events.On(bus, "event_stream", func(ctx context.Context, e events.BookUpdated) error {
+		return s.publish(e, EventBookUpdated, e.After)
+	})
+	events.On(bus, "event_stream", func(ctx context.Context, e events.BookDeleted) error {
+		return s.publish(e, EventBookDeleted, e.Book)
+	})
+
+	return s
+}
+
+// ParseStreamEventTypes validates a comma separated type filter. An empty
+// filter selects every type.
+func ParseStreamEventTypes(value string) (map[string]bool, error) {
+	types := make(map[string]bool)
+	for _, eventType := range splitList(value) {
+		if !streamEventTypes[eventType] {
+			return nil, invalid("unknown event type: " + eventType)
+		}
+		types[eventType] = true
+	}
+	return types, nil
+}
+
+// Subscribe connects a client. When lastEventID is set the events after it
+// are returned as backlog; resumed is false if they are no longer buffered
+// (or the ID is from before a restart) and the client has to refetch.
+func (s *EventStream) Subscribe(types map[string]bool, lastEventID string) (sub *StreamSubscription, backlog []StreamEvent, resumed bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, nil, false, unavailable("event stream is shutting down")
+	}
+
+	sub = &StreamSubscription{
+		stream: s,
+		types:  types,
+		events: make(chan StreamEvent, streamClientBuffer),
+		done:   make(chan struct{}),
+	}
+	sub.Events = sub.events
+	sub.Done = sub.done
+
+	resumed = true
+	if lastEventID != "" {
+		backlog, resumed = s.since(lastEventID)
+		filtered := backlog[:0]
+		for _, event := range backlog {
+			if sub.wants(event) {
+				filtered = append(filtered, event)
+			}
+		}
+		backlog = filtered
+	}
+
+	s.clients[sub] = struct{}{}
+	return sub, backlog, resumed, nil
+}
+
+// Close disconnects the client
+func (sub *StreamSubscription) Close() {
+	sub.stream.mu.Lock()
+	defer sub.stream.mu.Unlock()
+	sub.stream.drop(sub)
+}
+
+func (sub *StreamSubscription) wants(event StreamEvent) bool {
+	return len(sub.types) == 0 || sub.types[event.Type]
+}
+
+// Close disconnects every client, so open streams don't hold up shutdown
+func (s *EventStream) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	for sub := range s.clients {
+		s.drop(sub)
+	}
+}
+
+func (s *EventStream) publish(meta events.Event, eventType string, data interface{}) error {
+	id := meta.Metadata().ID
+	payload, err := json.Marshal(models.WebhookEvent{
+		ID:        id,
+		Type:      eventType,
+		CreatedAt: meta.Metadata().OccurredAt.UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.seen[id] {
+		return nil
+	}
+
+	s.seq++
+	event := StreamEvent{
+		ID:   fmt.Sprintf("%s-%d", s.epoch, s.seq),
+		Seq:  s.seq,
+		Type: eventType,
+		Data: payload,
+	}
+
+	delete(s.seen, s.ids[s.next])
+	s.buffer[s.next] = event
+	s.ids[s.next] = id
+	s.seen[id] = true
+	s.next = (s.next + 1) % len(s.buffer)
+
+	for sub := range s.clients {
+		if !sub.wants(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			// Too slow; it resumes from the replay buffer after reconnecting
+			s.drop(sub)
+		}
+	}
+
+	return nil
+}
+
+// since returns the buffered events after lastEventID, oldest first
+func (s *EventStream) since(lastEventID string) ([]StreamEvent, bool) {
+	epoch, seqText, ok := strings.Cut(lastEventID, "-")
+	if !ok || epoch != s.epoch {
+		return nil, false
+	}
+	lastSeq, err := strconv.ParseUint(seqText, 10, 64)
+	if err != nil || lastSeq > s.seq {
+		return nil, false
+	}
+
+	size := uint64(len(s.buffer))
+	oldest := uint64(1)
+	if s.seq > size {
+		oldest = s.seq - size + 1
+	}
+	if lastSeq+1 < oldest {
+		return nil, false
+	}
+
+	var backlog []StreamEvent
+	for seq := lastSeq + 1; seq <= s.seq; seq++ {
+		backlog = append(backlog, s.buffer[(seq-1)%size])
+	}
+	return backlog, true
+}
+
+func (s *EventStream) drop(sub *StreamSubscription) {
+	if _, ok := s.clients[sub]; ok {
+		delete(s.clients, sub)
+		close(sub.done)
+	}
+}
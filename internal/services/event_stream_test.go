@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/4Noyis/my-library/internal/config"
+	"github.com/4Noyis/my-library/internal/events"
+	"github.com/4Noyis/my-library/internal/models"
+)
+
+// newTestStream returns a stream keeping size events and a function
+// publishing one event through the bus, returning it as the stream sent it
+func newTestStream(t *testing.T, size int) (*EventStream, func(events.Event) StreamEvent) {
+	t.Helper()
+
+	bus := events.NewBus()
+	stream := NewEventStream(bus, config.Stream{ReplayBuffer: size})
+	t.Cleanup(stream.Close)
+
+	// Records every event the stream sends, whatever its type
+	recorder, _, _, err := stream.Subscribe(nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publish := func(event events.Event) StreamEvent {
+		t.Helper()
+		if err := bus.Deliver(context.Background(), event); err != nil {
+			t.Fatalf("deliver: %v", err)
+		}
+		select {
+		case sent := <-recorder.Events:
+			return sent
+		default:
+			t.Fatalf("%s was not streamed", event.EventName())
+			return StreamEvent{}
+		}
+	}
+	return stream, publish
+}
+
+func bookCreated(id int) events.BookCreated {
+	return events.BookCreated{Meta: events.NewMeta(context.Background()), Book: models.Book{ID: id}}
+}
+
+func seqs(backlog []StreamEvent) []uint64 {
+	var seqs []uint64
+	for _, event := range backlog {
+		seqs = append(seqs, event.Seq)
+	}
+	return seqs
+}
+
+func TestEventStreamReplaysFromLastEventID(t *testing.T) {
+	stream, publish := newTestStream(t, 3)
+
+	var sent []StreamEvent
+	for id := 1; id <= 5; id++ {
+		sent = append(sent, publish(bookCreated(id)))
+	}
+	// The buffer now holds events 3 to 5
+	tests := []struct {
+		name        string
+		lastEventID string
+		wantResumed bool
+		wantBacklog []uint64
+	}{
+		{"up to date", sent[4].ID, true, nil},
+		{"missed some", sent[2].ID, true, []uint64{4, 5}},
+		{"missed the whole buffer", sent[1].ID, true, []uint64{3, 4, 5}},
+		{"missed more than the buffer", sent[0].ID, false, nil},
+		{"earlier process", "0-4", false, nil},
+		{"from the future", fmt.Sprintf("%s-%d", stream.epoch, 6), false, nil},
+		{"malformed", "not-an-id", false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, backlog, resumed, err := stream.Subscribe(nil, tt.lastEventID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer sub.Close()
+
+			if resumed != tt.wantResumed {
+				t.Errorf("resumed = %v, want %v", resumed, tt.wantResumed)
+			}
+			if got := seqs(backlog); !reflect.DeepEqual(got, tt.wantBacklog) {
+				t.Errorf("backlog = %v, want %v", got, tt.wantBacklog)
+			}
+		})
+	}
+
+	// The replayed events are the ones sent live, IDs and payloads included
+	_, backlog, _, _ := stream.Subscribe(nil, sent[2].ID)
+	if !reflect.DeepEqual(backlog, sent[3:]) {
+		t.Errorf("backlog = %+v, want %+v", backlog, sent[3:])
+	}
+}
+
+func TestEventStreamFiltersByType(t *testing.T) {
+	stream, publish := newTestStream(t, 10)
+
+	first := publish(bookCreated(1))
+	publish(events.BookDeleted{Meta: events.NewMeta(context.Background()), Book: models.Book{ID: 1}})
+	publish(bookCreated(2))
+
+	sub, backlog, resumed, err := stream.Subscribe(map[string]bool{EventBookDeleted: true}, first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	if !resumed || len(backlog) != 1 || backlog[0].Type != EventBookDeleted {
+		t.Fatalf("backlog = %+v (resumed %v), want the deletion alone", backlog, resumed)
+	}
+
+	publish(bookCreated(3))
+	publish(events.BookUpdated{Meta: events.NewMeta(context.Background()), After: models.Book{ID: 3}})
+	deleted := publish(events.BookDeleted{Meta: events.NewMeta(context.Background()), Book: models.Book{ID: 3}})
+	select {
+	case event := <-sub.Events:
+		if event.ID != deleted.ID {
+			t.Errorf("got %s %s, want the deletion %s", event.Type, event.ID, deleted.ID)
+		}
+	default:
+		t.Fatal("deletion was not sent")
+	}
+	if len(sub.Events) != 0 {
+		t.Errorf("%d more events sent, want none", len(sub.Events))
+	}
+}
+
+func TestEventStreamSkipsRedeliveredEvents(t *testing.T) {
+	stream, publish := newTestStream(t, 2)
+
+	event := bookCreated(1)
+	first := publish(event)
+
+	// The outbox relays at least once; the same domain event is streamed once
+	sub, _, _, err := stream.Subscribe(nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	if err := stream.publish(event, EventBookCreated, event.Book); err != nil {
+		t.Fatal(err)
+	}
+	if len(sub.Events) != 0 {
+		t.Fatalf("redelivered event was streamed again")
+	}
+
+	// Once the event has left the buffer it's no longer recognised, which is
+	// as far as clients can resume from anyway
+	second := publish(bookCreated(2))
+	publish(bookCreated(3))
+	_, backlog, resumed, _ := stream.Subscribe(nil, first.ID)
+	if !resumed || !reflect.DeepEqual(seqs(backlog), []uint64{second.Seq, second.Seq + 1}) {
+		t.Errorf("backlog = %v (resumed %v), want %d and %d", seqs(backlog), resumed, second.Seq, second.Seq+1)
+	}
+}
+
+func TestEventStreamDropsSlowClients(t *testing.T) {
+	stream, publish := newTestStream(t, streamClientBuffer+10)
+
+	slow, _, _, err := stream.Subscribe(nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var last StreamEvent
+	for id := 1; id <= streamClientBuffer+1; id++ {
+		last = publish(bookCreated(id))
+	}
+	select {
+	case <-slow.Done:
+	default:
+		t.Fatal("client that fell behind is still connected")
+	}
+
+	// It reconnects and picks up where it left off from the replay buffer
+	var read []StreamEvent
+	for len(slow.Events) > 0 {
+		read = append(read, <-slow.Events)
+	}
+	_, backlog, resumed, err := stream.Subscribe(nil, read[len(read)-1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resumed || len(backlog) != 1 || backlog[0].ID != last.ID {
+		t.Errorf("backlog = %v (resumed %v), want the event it missed", seqs(backlog), resumed)
+	}
+}
+
+func TestEventStreamCloseDisconnectsClients(t *testing.T) {
+	stream, _ := newTestStream(t, 1)
+
+	sub, _, _, err := stream.Subscribe(nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.Close()
+
+	select {
+	case <-sub.Done:
+	default:
+		t.Error("client still connected after Close")
+	}
+	if _, _, _, err := stream.Subscribe(nil, ""); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("subscribe after Close: err = %v, want %v", err, ErrUnavailable)
+	}
+}
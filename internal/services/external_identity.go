@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// externalIdentity is what an external identity provider (OIDC, LDAP) tells
+// us about a user, mapped onto a models.User by resolveExternalUser
+type externalIdentity struct {
+	Subject       string // stable identifier at the provider
+	Username      string
+	Email         string
+	EmailVerified bool
+	Groups        []string
+}
+
+// externalPolicy is how the identities of one provider map onto local accounts
+type externalPolicy struct {
+	AdminGroups   []string // when set, the role follows the provider's groups
+	AutoProvision bool
+	LinkByEmail   bool
+}
+
+// resolveExternalUser finds the local account for an external identity.
+// Unknown identities are provisioned as a new account when the policy allows
+// it. With LinkByEmail they are linked instead to an existing account with
+// the same verified email, as long as that account has no local password:
+// whoever controls such an account proved it with the password, not the
+// email.
+func (us *UserService) resolveExternalUser(ctx context.Context, provider string, identity *externalIdentity, policy externalPolicy) (*models.User, error) {
+	role := roleForGroups(identity.Groups, policy.AdminGroups)
+
+	user, err := us.userRepo.GetUserByExternalID(ctx, provider, identity.Subject)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, errors.New("database error during login")
+	}
+
+	if user == nil && identity.Email != "" {
+		user, err = us.userRepo.GetUserByEmail(ctx, identity.Email)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return nil, errors.New("database error during login")
+		}
+		if user != nil {
+			if !policy.LinkByEmail || !identity.EmailVerified || user.Password != "" ||
+				(user.AuthProvider != "" && user.AuthProvider != provider) {
+				return nil, conflict("email already registered to another account")
+			}
+			linked := *user
+			linked.AuthProvider = provider
+			linked.ExternalID = identity.Subject
+			err = us.updateUser(ctx, systemActor(ctx, provider), user.ID, AuditUserLink, bson.D{
+				{Key: "auth_provider", Value: provider},
+				{Key: "external_id", Value: identity.Subject},
+			}, user, &linked)
+			if err != nil {
+				return nil, errors.New("failed to link account")
+			}
+			user = &linked
+			logger.LogInfo(ctx, "Linked existing account to external identity", logrus.Fields{
+				"user_id":  user.ID.Hex(),
+				"provider": provider,
+				"type":     "external_auth",
+			})
+		}
+	}
+
+	if user == nil {
+		if !policy.AutoProvision {
+			return nil, unauthorized("no account linked to this identity")
+		}
+		return us.provisionExternalUser(ctx, provider, identity, role)
+	}
+
+	if !user.IsActive {
+		return nil, unauthorized("account is deactivated")
+	}
+
+	if len(policy.AdminGroups) > 0 && user.Role != role {
+		synced := *user
+		synced.Role = role
+		if err := us.updateUser(ctx, systemActor(ctx, provider), user.ID, AuditUserRoleChange, bson.D{{Key: "role", Value: role}}, user, &synced); err != nil {
+			return nil, errors.New("failed to update user")
+		}
+		user = &synced
+	}
+
+	return user, nil
+}
+
+// systemActor is recorded for changes the identity provider makes on its
+// own, such as syncing a role from its groups
+func systemActor(ctx context.Context, provider string) models.Actor {
+	return models.Actor{
+		Username:  "system:" + provider,
+		RequestID: logger.RequestIDFromContext(ctx),
+	}
+}
+
+func (us *UserService) provisionExternalUser(ctx context.Context, provider string, identity *externalIdentity, role string) (*models.User, error) {
+	if identity.Email != "" {
+		existingUser, err := us.userRepo.GetUserByEmail(ctx, identity.Email)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return nil, errors.New("database error during login")
+		}
+		if existingUser != nil {
+			return nil, conflict("email already registered to another account")
+		}
+	}
+
+	base := identity.Username
+	if base == "" {
+		base = strings.SplitN(identity.Email, "@", 2)[0]
+	}
+	username, err := us.uniqueUsername(ctx, base)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		Username:     username,
+		Email:        identity.Email,
+		Role:         role,
+		AuthProvider: provider,
+		ExternalID:   identity.Subject,
+	}
+	if err := us.createUser(ctx, user); err != nil {
+		return nil, errors.New("failed to create user")
+	}
+
+	logger.LogInfo(ctx, "Provisioned user from external identity", logrus.Fields{
+		"user_id":  user.ID.Hex(),
+		"username": user.Username,
+		"role":     user.Role,
+		"provider": provider,
+		"type":     "external_auth",
+	})
+
+	return user, nil
+}
+
+var usernameDisallowed = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// uniqueUsername derives a free username from base, appending a number when
+// the name is already taken
+func (us *UserService) uniqueUsername(ctx context.Context, base string) (string, error) {
+	base = usernameDisallowed.ReplaceAllString(base, "")
+	if len(base) > 45 {
+		base = base[:45]
+	}
+	for len(base) < 3 {
+		base += "_"
+	}
+
+	for i := 1; i < 100; i++ {
+		candidate := base
+		if i > 1 {
+			candidate = fmt.Sprintf("%s%d", base, i)
+		}
+
+		_, err := us.userRepo.GetUserByUsername(ctx, candidate)
+		if err == mongo.ErrNoDocuments {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", errors.New("database error while checking username")
+		}
+	}
+
+	return "", errors.New("could not find a free username")
+}
+
+// roleForGroups maps directory or identity provider groups onto our roles
+func roleForGroups(groups, adminGroups []string) string {
+	for _, group := range groups {
+		for _, adminGroup := range adminGroups {
+			if strings.EqualFold(group, adminGroup) {
+				return "admin"
+			}
+		}
+	}
+	return "user"
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/4Noyis/my-library/internal/config"
+	"github.com/4Noyis/my-library/internal/events"
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/4Noyis/my-library/internal/repositories/memory"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// userUpdates decodes the UserUpdated events written to the outbox
+func userUpdates(t *testing.T, store *memoryOutbox) []events.UserUpdated {
+	t.Helper()
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	var updates []events.UserUpdated
+	for _, record := range store.records {
+		if record.EventName != (events.UserUpdated{}).EventName() {
+			continue
+		}
+		event, err := events.Decode(record.EventName, record.Payload)
+		if err != nil {
+			t.Fatalf("decode %s: %v", record.EventName, err)
+		}
+		updates = append(updates, event.(events.UserUpdated))
+	}
+	return updates
+}
+
+func TestExternalLoginChangesAreAudited(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryOutbox{}
+	users := memory.NewUserRepository()
+	us := NewUserService(config.Default(config.ProfileTest), memory.NewDB(), users, memory.NewSessionRepository(), NewOutbox(store))
+
+	existing := &models.User{Username: "ivan", Email: "ivan@example.org", Role: "user"}
+	if err := users.CreateUser(ctx, existing); err != nil {
+		t.Fatal(err)
+	}
+
+	identity := &externalIdentity{
+		Subject:       "subject-1",
+		Username:      "ivan",
+		Email:         "ivan@example.org",
+		EmailVerified: true,
+		Groups:        []string{"library-admins"},
+	}
+	policy := externalPolicy{AdminGroups: []string{"library-admins"}, LinkByEmail: true}
+	user, err := us.resolveExternalUser(ctx, AuthProviderOIDC, identity, policy)
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if user.Role != "admin" || user.ExternalID != "subject-1" {
+		t.Fatalf("resolved to %s/%s (%s), want a linked admin", user.AuthProvider, user.ExternalID, user.Role)
+	}
+
+	updates := userUpdates(t, store)
+	if len(updates) != 2 {
+		t.Fatalf("got %d user updates, want link and role change", len(updates))
+	}
+	link, role := updates[0], updates[1]
+
+	if link.Action != AuditUserLink || link.Before.AuthProvider != "" || link.After.AuthProvider != AuthProviderOIDC || link.After.ExternalID != "subject-1" {
+		t.Errorf("link = %s %+v -> %+v", link.Action, link.Before, link.After)
+	}
+	if role.Action != AuditUserRoleChange || role.Before.Role != "user" || role.After.Role != "admin" {
+		t.Errorf("role change = %s %s -> %s", role.Action, role.Before.Role, role.After.Role)
+	}
+	for _, update := range updates {
+		if update.UserID != existing.ID {
+			t.Errorf("%s recorded for %s, want %s", update.Action, update.UserID.Hex(), existing.ID.Hex())
+		}
+		if update.Actor.UserID != primitive.NilObjectID || update.Actor.Username != "system:oidc" {
+			t.Errorf("%s recorded as %+v, want the system:oidc actor", update.Action, update.Actor)
+		}
+	}
+
+	// Nothing changes, and nothing is recorded, when the groups still match
+	if _, err := us.resolveExternalUser(ctx, AuthProviderOIDC, identity, policy); err != nil {
+		t.Fatalf("second resolve: %v", err)
+	}
+	if n := len(userUpdates(t, store)); n != 2 {
+		t.Errorf("got %d user updates after an unchanged login, want 2", n)
+	}
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/models"
+)
+
+// Upper bound for each readiness check, so a hung dependency fails the probe
+// instead of timing it out
+const healthCheckTimeout = 2 * time.Second
+
+type healthCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// HealthService answers the orchestrator's liveness and readiness probes
+type HealthService struct {
+	db           Database
+	shuttingDown atomic.Bool
+	checks       []healthCheck
+}
+
+func NewHealthService(db Database) *HealthService {
+	hs := &HealthService{db: db}
+	hs.checks = []healthCheck{
+		{name: "shutdown", check: hs.checkShutdown},
+		{name: "mongodb", check: hs.checkMongoDB},
+	}
+	return hs
+}
+
+// BeginShutdown makes every following readiness check fail, so the
+// orchestrator stops routing traffic here while in-flight requests finish
+func (hs *HealthService) BeginShutdown() {
+	hs.shuttingDown.Store(true)
+}
+
+// Live reports that the process is up and serving requests
+func (hs *HealthService) Live() models.HealthReport {
+	return models.HealthReport{Status: models.HealthOK}
+}
+
+// Ready runs every readiness check and reports each one's status and latency.
+// The report is ok only if all checks pass.
+func (hs *HealthService) Ready(ctx context.Context) models.HealthReport {
+	report := models.HealthReport{
+		Status: models.HealthOK,
+		Checks: make(map[string]models.DependencyHealth, len(hs.checks)),
+	}
+
+	for _, c := range hs.checks {
+		checkCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+		start := time.Now()
+		err := c.check(checkCtx)
+		cancel()
+
+		result := models.DependencyHealth{
+			Status:    models.HealthOK,
+			LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		}
+		if err != nil {
+			result.Status = models.HealthUnavailable
+			result.Error = err.Error()
+			report.Status = models.HealthUnavailable
+		}
+		report.Checks[c.name] = result
+	}
+
+	return report
+}
+
+func (hs *HealthService) checkShutdown(ctx context.Context) error {
+	if hs.shuttingDown.Load() {
+		return errors.New("server is shutting down")
+	}
+	return nil
+}
+
+func (hs *HealthService) checkMongoDB(ctx context.Context) error {
+	return hs.db.Ping(ctx)
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/4Noyis/my-library/internal/repositories/memory"
+)
+
+// pingDB is a database whose Ping fails with err, recording the deadline
+// it was given
+type pingDB struct {
+	*memory.DB
+	err      error
+	deadline time.Time
+}
+
+func (db *pingDB) Ping(ctx context.Context) error {
+	db.deadline, _ = ctx.Deadline()
+	return db.err
+}
+
+func TestReadinessFailsOnceShutdownBegins(t *testing.T) {
+	ctx := context.Background()
+	hs := NewHealthService(&pingDB{DB: memory.NewDB()})
+
+	if report := hs.Ready(ctx); report.Status != models.HealthOK {
+		t.Fatalf("ready before shutdown = %+v, want ok", report)
+	}
+
+	hs.BeginShutdown()
+
+	report := hs.Ready(ctx)
+	if report.Status != models.HealthUnavailable {
+		t.Errorf("ready during shutdown = %s, want %s", report.Status, models.HealthUnavailable)
+	}
+	if check := report.Checks["shutdown"]; check.Status != models.HealthUnavailable || check.Error != "server is shutting down" {
+		t.Errorf("shutdown check = %+v", check)
+	}
+	// Only the shutdown check fails; the dependencies are still reported
+	if check := report.Checks["mongodb"]; check.Status != models.HealthOK {
+		t.Errorf("mongodb check = %+v, want ok", check)
+	}
+
+	// Shutdown is one way; the probe keeps failing until the process exits
+	if report := hs.Ready(ctx); report.Status != models.HealthUnavailable {
+		t.Errorf("second ready during shutdown = %s", report.Status)
+	}
+	if report := hs.Live(); report.Status != models.HealthOK {
+		t.Errorf("live during shutdown = %s, want ok so in-flight requests can finish", report.Status)
+	}
+}
+
+func TestReadinessReportsDatabaseOutage(t *testing.T) {
+	db := &pingDB{DB: memory.NewDB(), err: errors.New("server selection timeout")}
+	hs := NewHealthService(db)
+
+	start := time.Now()
+	report := hs.Ready(context.Background())
+	end := time.Now()
+	if report.Status != models.HealthUnavailable {
+		t.Fatalf("ready = %s, want %s", report.Status, models.HealthUnavailable)
+	}
+	if check := report.Checks["mongodb"]; check.Status != models.HealthUnavailable || check.Error != "server selection timeout" {
+		t.Errorf("mongodb check = %+v", check)
+	}
+	if check := report.Checks["shutdown"]; check.Status != models.HealthOK {
+		t.Errorf("shutdown check = %+v, want ok", check)
+	}
+
+	// A hung database fails the probe instead of timing it out
+	if db.deadline.Before(start.Add(healthCheckTimeout)) || db.deadline.After(end.Add(healthCheckTimeout)) {
+		t.Errorf("ping deadline %v after the check started, want %v", db.deadline.Sub(start), healthCheckTimeout)
+	}
+
+	if report := hs.Live(); report.Status != models.HealthOK {
+		t.Errorf("live during outage = %s, want ok", report.Status)
+	}
+}
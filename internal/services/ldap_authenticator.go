@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/4Noyis/my-library/internal/config"
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/sirupsen/logrus"
+)
+
+// AuthProviderLDAP marks accounts whose password lives in the LDAP directory
+const AuthProviderLDAP = "ldap"
+
+// LDAPAuthenticator looks the user up in the directory and verifies the
+// password by binding as them. Successful logins are mapped onto a local
+// account, which is provisioned on first login.
+type LDAPAuthenticator struct {
+	config      config.LDAP
+	userService *UserService
+}
+
+func NewLDAPAuthenticator(userService *UserService, cfg config.LDAP) *LDAPAuthenticator {
+	return &LDAPAuthenticator{
+		config:      cfg,
+		userService: userService,
+	}
+}
+
+func (a *LDAPAuthenticator) Name() string {
+	return "ldap"
+}
+
+func (a *LDAPAuthenticator) Authenticate(ctx context.Context, username, password string) (*models.User, error) {
+	// An empty password would be an unauthenticated bind, which most
+	// directories report as a success
+	if username == "" || password == "" {
+		return nil, errUnknownUser
+	}
+
+	conn, err := a.dial()
+	if err != nil {
+		logger.LogError(ctx, "LDAPAuthenticate", err, logrus.Fields{
+			"operation": "dial",
+			"url":       a.config.URL,
+		})
+		return nil, errors.New("directory error during login")
+	}
+	defer conn.Close()
+
+	if a.config.BindDN != "" {
+		if err := conn.Bind(a.config.BindDN, a.config.BindPassword); err != nil {
+			logger.LogError(ctx, "LDAPAuthenticate", err, logrus.Fields{
+				"operation": "service_bind",
+			})
+			return nil, errors.New("directory error during login")
+		}
+	}
+
+	filter := strings.ReplaceAll(a.config.UserFilter, "%s", ldap.EscapeFilter(username))
+	search := ldap.NewSearchRequest(
+		a.config.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		2, int(a.config.Timeout.Seconds()), false,
+		filter,
+		[]string{a.config.UsernameAttribute, a.config.EmailAttribute, a.config.GroupAttribute},
+		nil,
+	)
+
+	result, err := conn.Search(search)
+	if err != nil {
+		logger.LogError(ctx, "LDAPAuthenticate", err, logrus.Fields{
+			"operation": "search",
+			"filter":    filter,
+		})
+		return nil, errors.New("directory error during login")
+	}
+	if len(result.Entries) != 1 {
+		if len(result.Entries) > 1 {
+			logger.LogError(ctx, "LDAPAuthenticate", errors.New("user filter matched more than one entry"), logrus.Fields{
+				"operation": "search",
+				"filter":    filter,
+			})
+		}
+		return nil, errUnknownUser
+	}
+	entry := result.Entries[0]
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, unauthorized("invalid credentials")
+		}
+		logger.LogError(ctx, "LDAPAuthenticate", err, logrus.Fields{
+			"operation": "user_bind",
+		})
+		return nil, errors.New("directory error during login")
+	}
+
+	uid := entry.GetAttributeValue(a.config.UsernameAttribute)
+	if uid == "" {
+		uid = username
+	}
+
+	identity := &externalIdentity{
+		Subject:       strings.ToLower(uid),
+		Username:      uid,
+		Email:         entry.GetAttributeValue(a.config.EmailAttribute),
+		EmailVerified: a.config.LinkByEmail, // only if the directory's staff vouch for it
+		Groups:        groupNames(entry.GetAttributeValues(a.config.GroupAttribute)),
+	}
+
+	return a.userService.resolveExternalUser(ctx, AuthProviderLDAP, identity, externalPolicy{
+		AdminGroups:   a.config.AdminGroups,
+		AutoProvision: a.config.AutoProvision,
+		LinkByEmail:   a.config.LinkByEmail,
+	})
+}
+
+func (a *LDAPAuthenticator) dial() (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(a.config.URL, ldap.DialWithDialer(&net.Dialer{Timeout: a.config.Timeout}))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(a.config.Timeout)
+
+	if a.config.StartTLS {
+		parsed, err := url.Parse(a.config.URL)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if err := conn.StartTLS(&tls.Config{ServerName: parsed.Hostname()}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+// groupNames returns each group DN together with its common name so admin
+// groups can be configured either way
+func groupNames(groupDNs []string) []string {
+	var names []string
+	for _, groupDN := range groupDNs {
+		names = append(names, groupDN)
+
+		parsed, err := ldap.ParseDN(groupDN)
+		if err != nil || len(parsed.RDNs) == 0 {
+			continue
+		}
+		for _, attr := range parsed.RDNs[0].Attributes {
+			if strings.EqualFold(attr.Type, "cn") {
+				names = append(names, attr.Value)
+			}
+		}
+	}
+	return names
+}
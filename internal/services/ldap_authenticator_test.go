@@ -0,0 +1,411 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/config"
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/4Noyis/my-library/internal/repositories/memory"
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/jimlambrt/gldap"
+)
+
+const (
+	testBaseDN       = "ou=people,dc=example,dc=org"
+	testReaderDN     = "cn=reader,dc=example,dc=org"
+	testReaderSecret = "reader-secret"
+	testLibrarians   = "cn=librarians,ou=groups,dc=example,dc=org"
+)
+
+// fakeDirectory is an in-process LDAP server. It evaluates search filters
+// for real, so the authenticator's user filter and base DN are exercised,
+// and records every search it answers.
+type fakeDirectory struct {
+	url string
+
+	mu       sync.Mutex
+	entries  map[string]map[string][]string // DN to attributes, userPassword included
+	searches []ldapSearch
+}
+
+type ldapSearch struct {
+	BaseDN string
+	Filter string
+}
+
+func newFakeDirectory(t *testing.T) *fakeDirectory {
+	t.Helper()
+
+	dir := &fakeDirectory{entries: map[string]map[string][]string{}}
+
+	mux, err := gldap.NewMux()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.Bind(dir.bind)
+	mux.Search(dir.search)
+
+	server, err := gldap.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Router(mux)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	go server.Run(addr)
+	deadline := time.Now().Add(5 * time.Second)
+	for !server.Ready() {
+		if time.Now().After(deadline) {
+			t.Fatal("LDAP server did not start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Cleanup(func() { server.Stop() })
+
+	dir.url = "ldap://" + addr
+	return dir
+}
+
+func (d *fakeDirectory) add(dn string, attributes map[string][]string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries[dn] = attributes
+}
+
+func (d *fakeDirectory) lastSearch() ldapSearch {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.searches) == 0 {
+		return ldapSearch{}
+	}
+	return d.searches[len(d.searches)-1]
+}
+
+func (d *fakeDirectory) searchCount() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.searches)
+}
+
+func (d *fakeDirectory) bind(w *gldap.ResponseWriter, r *gldap.Request) {
+	resp := r.NewBindResponse(gldap.WithResponseCode(gldap.ResultInvalidCredentials))
+	defer w.Write(resp)
+
+	m, err := r.GetSimpleBindMessage()
+	if err != nil || m.AuthChoice != gldap.SimpleAuthChoice {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if m.UserName == testReaderDN && string(m.Password) == testReaderSecret {
+		resp.SetResultCode(gldap.ResultSuccess)
+		return
+	}
+	if entry, ok := d.entries[m.UserName]; ok && string(m.Password) != "" {
+		for _, password := range entry["userPassword"] {
+			if password == string(m.Password) {
+				resp.SetResultCode(gldap.ResultSuccess)
+			}
+		}
+	}
+}
+
+func (d *fakeDirectory) search(w *gldap.ResponseWriter, r *gldap.Request) {
+	m, err := r.GetSearchMessage()
+	if err != nil {
+		w.Write(r.NewSearchDoneResponse(gldap.WithResponseCode(gldap.ResultOperationsError)))
+		return
+	}
+	filter, err := ldap.CompileFilter(m.Filter)
+	if err != nil {
+		w.Write(r.NewSearchDoneResponse(gldap.WithResponseCode(gldap.ResultOperationsError)))
+		return
+	}
+
+	d.mu.Lock()
+	d.searches = append(d.searches, ldapSearch{BaseDN: m.BaseDN, Filter: m.Filter})
+	var matches []string
+	for dn, attributes := range d.entries {
+		if strings.HasSuffix(strings.ToLower(dn), ","+strings.ToLower(m.BaseDN)) && matchFilter(filter, attributes) {
+			matches = append(matches, dn)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, dn := range matches {
+		entry := r.NewSearchResponseEntry(dn)
+		for _, name := range m.Attributes {
+			if values := attributeValues(d.entries[dn], name); len(values) > 0 {
+				entry.AddAttribute(name, values)
+			}
+		}
+		w.Write(entry)
+	}
+	w.Write(r.NewSearchDoneResponse(gldap.WithResponseCode(gldap.ResultSuccess)))
+}
+
+// matchFilter evaluates the filter kinds the authenticator's filters use
+func matchFilter(filter *ber.Packet, attributes map[string][]string) bool {
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !matchFilter(child, attributes) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterOr:
+		for _, child := range filter.Children {
+			if matchFilter(child, attributes) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterNot:
+		return !matchFilter(filter.Children[0], attributes)
+	case ldap.FilterEqualityMatch:
+		name, _ := filter.Children[0].Value.(string)
+		want := filter.Children[1].Data.String()
+		for _, value := range attributeValues(attributes, name) {
+			if strings.EqualFold(value, want) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterPresent:
+		return len(attributeValues(attributes, filter.Data.String())) > 0
+	}
+	return false
+}
+
+func attributeValues(attributes map[string][]string, name string) []string {
+	if strings.EqualFold(name, "userPassword") {
+		return nil
+	}
+	for key, values := range attributes {
+		if strings.EqualFold(key, name) {
+			return values
+		}
+	}
+	return nil
+}
+
+func newLDAPTestService(t *testing.T, dir *fakeDirectory, configure func(*config.LDAP)) *UserService {
+	t.Helper()
+
+	cfg := config.Default(config.ProfileTest)
+	cfg.Auth.Backends = []string{"local", "ldap"}
+	cfg.LDAP.URL = dir.url
+	cfg.LDAP.BindDN = testReaderDN
+	cfg.LDAP.BindPassword = testReaderSecret
+	cfg.LDAP.BaseDN = testBaseDN
+	cfg.LDAP.UserFilter = "(&(objectClass=person)(uid=%s))"
+	cfg.LDAP.AdminGroups = []string{"librarians"}
+	if configure != nil {
+		configure(&cfg.LDAP)
+	}
+
+	return NewUserService(cfg, memory.NewDB(), memory.NewUserRepository(), memory.NewSessionRepository(),
+		NewOutbox(memory.NewOutboxRepository()))
+}
+
+func ldapPerson(uid, password, email string, groups ...string) map[string][]string {
+	return map[string][]string{
+		"objectClass":  {"top", "person", "inetOrgPerson"},
+		"uid":          {uid},
+		"mail":         {email},
+		"memberOf":     groups,
+		"userPassword": {password},
+	}
+}
+
+func login(us *UserService, username, password string) (*models.User, error) {
+	resp, err := us.LoginUser(context.Background(), &models.LoginRequest{Username: username, Password: password}, models.ClientInfo{})
+	if err != nil {
+		return nil, err
+	}
+	return &resp.User, nil
+}
+
+func TestLDAPAuthenticatorBindsAsTheUser(t *testing.T) {
+	dir := newFakeDirectory(t)
+	dir.add("uid=alice,"+testBaseDN, ldapPerson("alice", "alice-secret", "alice@example.org"))
+	us := newLDAPTestService(t, dir, nil)
+
+	user, err := login(us, "alice", "alice-secret")
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if user.Username != "alice" || user.Email != "alice@example.org" || user.Role != "user" {
+		t.Errorf("provisioned user = %s <%s> (%s), want alice <alice@example.org> (user)", user.Username, user.Email, user.Role)
+	}
+	if user.AuthProvider != AuthProviderLDAP || user.ExternalID != "alice" {
+		t.Errorf("identity = %s/%s, want ldap/alice", user.AuthProvider, user.ExternalID)
+	}
+
+	search := dir.lastSearch()
+	if search.BaseDN != testBaseDN {
+		t.Errorf("searched base DN %q, want %q", search.BaseDN, testBaseDN)
+	}
+	if search.Filter != "(&(objectClass=person)(uid=alice))" {
+		t.Errorf("searched with filter %q", search.Filter)
+	}
+
+	// The second login finds the provisioned account again
+	again, err := login(us, "alice", "alice-secret")
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+	if again.ID != user.ID {
+		t.Errorf("second login resolved to %s, want %s", again.ID.Hex(), user.ID.Hex())
+	}
+
+	if _, err := login(us, "alice", "wrong-password"); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("wrong password: err = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestLDAPAuthenticatorHonoursFilterAndBaseDN(t *testing.T) {
+	dir := newFakeDirectory(t)
+	dir.add("uid=mallory,ou=contractors,dc=example,dc=org", ldapPerson("mallory", "mallory-secret", "mallory@example.org"))
+	printer := ldapPerson("printer", "printer-secret", "printer@example.org")
+	printer["objectClass"] = []string{"top", "device"}
+	dir.add("uid=printer,"+testBaseDN, printer)
+	dir.add("uid=alice,"+testBaseDN, ldapPerson("alice", "alice-secret", "alice@example.org"))
+	us := newLDAPTestService(t, dir, nil)
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		filter   string
+	}{
+		{"outside base DN", "mallory", "mallory-secret", "(&(objectClass=person)(uid=mallory))"},
+		{"filtered out", "printer", "printer-secret", "(&(objectClass=person)(uid=printer))"},
+		{"filter injection", "*)(uid=*", "alice-secret", `(&(objectClass=person)(uid=\2a\29\28uid=\2a))`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := login(us, tt.username, tt.password); !errors.Is(err, ErrUnauthorized) {
+				t.Errorf("err = %v, want ErrUnauthorized", err)
+			}
+			if got := dir.lastSearch().Filter; got != tt.filter {
+				t.Errorf("searched with filter %q, want %q", got, tt.filter)
+			}
+		})
+	}
+}
+
+func TestLDAPAuthenticatorMapsGroupsToRoles(t *testing.T) {
+	for _, adminGroup := range []string{"librarians", testLibrarians} {
+		t.Run(adminGroup, func(t *testing.T) {
+			dir := newFakeDirectory(t)
+			dir.add("uid=bob,"+testBaseDN, ldapPerson("bob", "bob-secret", "bob@example.org", testLibrarians))
+			us := newLDAPTestService(t, dir, func(cfg *config.LDAP) {
+				cfg.AdminGroups = []string{adminGroup}
+			})
+
+			user, err := login(us, "bob", "bob-secret")
+			if err != nil {
+				t.Fatalf("login: %v", err)
+			}
+			if user.Role != "admin" {
+				t.Errorf("role = %s, want admin", user.Role)
+			}
+
+			// Leaving the group takes the role away at the next login
+			dir.add("uid=bob,"+testBaseDN, ldapPerson("bob", "bob-secret", "bob@example.org"))
+			user, err = login(us, "bob", "bob-secret")
+			if err != nil {
+				t.Fatalf("login after leaving the group: %v", err)
+			}
+			if user.Role != "user" {
+				t.Errorf("role after leaving the group = %s, want user", user.Role)
+			}
+		})
+	}
+}
+
+func TestLoginFallsThroughFromLocalToLDAP(t *testing.T) {
+	dir := newFakeDirectory(t)
+	dir.add("uid=alice,"+testBaseDN, ldapPerson("alice", "alice-secret", "alice@example.org"))
+	us := newLDAPTestService(t, dir, nil)
+
+	_, err := us.RegisterUser(context.Background(), &models.RegisterRequest{
+		Username: "carol",
+		Email:    "carol@example.org",
+		Password: "Tr1cky-Harbour-Lantern",
+	})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	user, err := login(us, "carol", "Tr1cky-Harbour-Lantern")
+	if err != nil {
+		t.Fatalf("local login: %v", err)
+	}
+	if user.AuthProvider != "" {
+		t.Errorf("local user authenticated by %q", user.AuthProvider)
+	}
+	if n := dir.searchCount(); n != 0 {
+		t.Errorf("local login searched the directory %d times", n)
+	}
+
+	// A wrong local password is final and never reaches the directory
+	if _, err := login(us, "carol", "wrong-password"); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("wrong local password: err = %v, want ErrUnauthorized", err)
+	}
+	if n := dir.searchCount(); n != 0 {
+		t.Errorf("failed local login searched the directory %d times", n)
+	}
+
+	user, err = login(us, "alice", "alice-secret")
+	if err != nil {
+		t.Fatalf("directory login: %v", err)
+	}
+	if user.AuthProvider != AuthProviderLDAP {
+		t.Errorf("directory user authenticated by %q", user.AuthProvider)
+	}
+
+	if _, err := login(us, "nobody", "whatever"); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("unknown user: err = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestLDAPAuthenticatorNeverLinksPasswordAccounts(t *testing.T) {
+	dir := newFakeDirectory(t)
+	dir.add("uid=dave.smith,"+testBaseDN, ldapPerson("dave.smith", "dave-secret", "dave@example.org"))
+
+	for _, linkByEmail := range []bool{false, true} {
+		us := newLDAPTestService(t, dir, func(cfg *config.LDAP) {
+			cfg.LinkByEmail = linkByEmail
+		})
+		_, err := us.RegisterUser(context.Background(), &models.RegisterRequest{
+			Username: "dave",
+			Email:    "dave@example.org",
+			Password: "Tr1cky-Harbour-Lantern",
+		})
+		if err != nil {
+			t.Fatalf("register: %v", err)
+		}
+
+		if _, err := login(us, "dave.smith", "dave-secret"); !errors.Is(err, ErrConflict) {
+			t.Errorf("link_by_email=%v: err = %v, want ErrConflict", linkByEmail, err)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/config"
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/metrics"
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+)
+
+// AuthProviderOIDC marks accounts linked to the OpenID Connect provider
+const AuthProviderOIDC = "oidc"
+
+// How long a user has to finish logging in at the provider
+const oidcLoginTimeout = 10 * time.Minute
+
+// OIDCService implements the authorization code flow with PKCE and turns a
+// verified ID token into one of our own JWTs
+type OIDCService struct {
+	config      config.OIDC
+	userService *UserService
+
+	// The provider is discovered lazily so the server can start while the
+	// identity provider is unreachable
+	mu       sync.Mutex
+	verifier *oidc.IDTokenVerifier
+	oauth2   *oauth2.Config
+}
+
+func NewOIDCService(userService *UserService, cfg config.OIDC) *OIDCService {
+	return &OIDCService{
+		config:      cfg,
+		userService: userService,
+	}
+}
+
+func (s *OIDCService) Enabled() bool {
+	return s.config.IssuerURL != ""
+}
+
+// oidcLoginState is what has to survive the round trip to the provider. It is
+// kept client side in a cookie signed with the JWT secret.
+type oidcLoginState struct {
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+	jwt.RegisteredClaims
+}
+
+// BeginLogin returns the provider URL to redirect the user to together with
+// the signed login state that has to be handed back to CompleteLogin
+func (s *OIDCService) BeginLogin(ctx context.Context) (string, string, error) {
+	oauthConfig, _, err := s.client(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := randomToken()
+	if err != nil {
+		return "", "", errors.New("failed to generate login state")
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return "", "", errors.New("failed to generate login state")
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	claims := oidcLoginState{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(oidcLoginTimeout)),
+		},
+	}
+	signedState, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.userService.jwtSecret)
+	if err != nil {
+		return "", "", errors.New("failed to sign login state")
+	}
+
+	authURL := oauthConfig.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+	return authURL, signedState, nil
+}
+
+// CompleteLogin exchanges the authorization code, verifies the ID token and
+// logs in the matching local user, provisioning one on first login
+func (s *OIDCService) CompleteLogin(ctx context.Context, code, state, signedState string, client models.ClientInfo) (*models.LoginResponse, error) {
+	response, err := s.completeLogin(ctx, code, state, signedState, client)
+	metrics.RecordLogin(AuthProviderOIDC, err == nil)
+	return response, err
+}
+
+func (s *OIDCService) completeLogin(ctx context.Context, code, state, signedState string, client models.ClientInfo) (*models.LoginResponse, error) {
+	oauthConfig, verifier, err := s.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var loginState oidcLoginState
+	_, err = jwt.ParseWithClaims(signedState, &loginState, func(token *jwt.Token) (interface{}, error) {
+		return s.userService.jwtSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || state == "" || loginState.State != state {
+		return nil, invalid("invalid oidc state")
+	}
+
+	token, err := oauthConfig.Exchange(ctx, code, oauth2.VerifierOption(loginState.CodeVerifier))
+	if err != nil {
+		logger.LogError(ctx, "OIDCCompleteLogin", err, logrus.Fields{
+			"operation": "exchange_code",
+		})
+		return nil, upstream("failed to exchange authorization code")
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, unauthorized("invalid id token")
+	}
+	idToken, err := verifier.Verify(ctx, rawIDToken)
+	if err != nil || idToken.Nonce != loginState.Nonce {
+		return nil, unauthorized("invalid id token")
+	}
+
+	identity, err := s.identityFromToken(idToken)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userService.resolveExternalUser(ctx, AuthProviderOIDC, identity, externalPolicy{
+		AdminGroups:   s.config.AdminGroups,
+		AutoProvision: s.config.AutoProvision,
+		LinkByEmail:   s.config.LinkByEmail,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	jwtToken, err := s.userService.issueToken(ctx, user, client)
+	if err != nil {
+		return nil, err
+	}
+
+	user.Password = ""
+	s.userService.publishLogin(ctx, user, client)
+	return &models.LoginResponse{
+		Token: jwtToken,
+		User:  *user,
+	}, nil
+}
+
+// client returns the OAuth2 configuration and ID token verifier, running
+// provider discovery on first use
+func (s *OIDCService) client(ctx context.Context) (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	if !s.Enabled() {
+		return nil, nil, notFound("oidc login is not configured")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.oauth2 != nil {
+		return s.oauth2, s.verifier, nil
+	}
+
+	provider, err := oidc.NewProvider(ctx, s.config.IssuerURL)
+	if err != nil {
+		logger.LogError(ctx, "OIDCDiscovery", err, logrus.Fields{
+			"issuer": s.config.IssuerURL,
+		})
+		return nil, nil, upstream("oidc provider unavailable")
+	}
+
+	s.verifier = provider.Verifier(&oidc.Config{ClientID: s.config.ClientID})
+	s.oauth2 = &oauth2.Config{
+		ClientID:     s.config.ClientID,
+		ClientSecret: s.config.ClientSecret,
+		RedirectURL:  s.config.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       append([]string{oidc.ScopeOpenID}, s.config.Scopes...),
+	}
+
+	return s.oauth2, s.verifier, nil
+}
+
+func (s *OIDCService) identityFromToken(idToken *oidc.IDToken) (*externalIdentity, error) {
+	var claims struct {
+		Email             string `json:"email"`
+		EmailVerified     bool   `json:"email_verified"`
+		PreferredUsername string `json:"preferred_username"`
+	}
+	var allClaims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, unauthorized("invalid id token")
+	}
+	if err := idToken.Claims(&allClaims); err != nil {
+		return nil, unauthorized("invalid id token")
+	}
+
+	identity := &externalIdentity{
+		Subject:       idToken.Subject,
+		Username:      claims.PreferredUsername,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+	}
+
+	switch groups := allClaims[s.config.GroupsClaim].(type) {
+	case []interface{}:
+		for _, group := range groups {
+			if name, ok := group.(string); ok {
+				identity.Groups = append(identity.Groups, name)
+			}
+		}
+	case string:
+		identity.Groups = []string{groups}
+	}
+
+	return identity, nil
+}
+
+func randomToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,421 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/config"
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/4Noyis/my-library/internal/repositories/memory"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID     = "library"
+	testClientSecret = "library-secret"
+	testRedirectURL  = "http://library.test/api/v1/auth/oidc/callback"
+)
+
+// fakeIssuer is an OpenID provider serving discovery, its signing keys and
+// the token endpoint. The test plays the user at the authorization
+// endpoint by calling authorize with the claims the ID token should carry.
+type fakeIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]issuedCode
+	next  int
+}
+
+type issuedCode struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &fakeIssuer{key: key, codes: map[string]issuedCode{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("GET /jwks", p.jwks)
+	mux.HandleFunc("POST /token", p.token)
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+
+	return p
+}
+
+func (p *fakeIssuer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                p.server.URL,
+		"authorization_endpoint":                p.server.URL + "/authorize",
+		"token_endpoint":                        p.server.URL + "/token",
+		"jwks_uri":                              p.server.URL + "/jwks",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (p *fakeIssuer) jwks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "test",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+		}},
+	})
+}
+
+// token redeems an authorization code once, checking the PKCE verifier
+// against the challenge it was issued for
+func (p *fakeIssuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != testClientID || clientSecret != testClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	p.mu.Lock()
+	issued, found := p.codes[r.PostForm.Get("code")]
+	delete(p.codes, r.PostForm.Get("code"))
+	p.mu.Unlock()
+
+	verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !found || r.PostForm.Get("redirect_uri") != testRedirectURL ||
+		base64.RawURLEncoding.EncodeToString(verifier[:]) != issued.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, issued.claims)
+	idToken.Header["kid"] = "test"
+	signed, err := idToken.SignedString(p.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     signed,
+	})
+}
+
+// authorize stands in for the user signing in at the provider. It checks the
+// authorization request and returns the code and state the provider would
+// redirect back with. Claims override the ID token defaults.
+func (p *fakeIssuer) authorize(t *testing.T, authURL string, claims jwt.MapClaims) (string, string) {
+	t.Helper()
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parsed.Query()
+	if query.Get("client_id") != testClientID || query.Get("redirect_uri") != testRedirectURL {
+		t.Fatalf("authorization request for client %q at %q", query.Get("client_id"), query.Get("redirect_uri"))
+	}
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("authorization request without an S256 code challenge: %s", authURL)
+	}
+	if query.Get("state") == "" || query.Get("nonce") == "" {
+		t.Fatalf("authorization request without state or nonce: %s", authURL)
+	}
+
+	now := time.Now()
+	idClaims := jwt.MapClaims{
+		"iss":   p.server.URL,
+		"aud":   testClientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+		"nonce": query.Get("nonce"),
+	}
+	for name, value := range claims {
+		idClaims[name] = value
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.next++
+	code := fmt.Sprintf("code-%d", p.next)
+	p.codes[code] = issuedCode{challenge: query.Get("code_challenge"), claims: idClaims}
+
+	return code, query.Get("state")
+}
+
+// login runs the whole authorization code flow
+func (p *fakeIssuer) login(t *testing.T, s *OIDCService, claims jwt.MapClaims) (*models.User, error) {
+	t.Helper()
+
+	ctx := context.Background()
+	authURL, signedState, err := s.BeginLogin(ctx)
+	if err != nil {
+		t.Fatalf("begin login: %v", err)
+	}
+	code, state := p.authorize(t, authURL, claims)
+
+	resp, err := s.CompleteLogin(ctx, code, state, signedState, models.ClientInfo{})
+	if err != nil {
+		return nil, err
+	}
+	return &resp.User, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func newOIDCTestService(t *testing.T, issuer *fakeIssuer, configure func(*config.OIDC)) (*OIDCService, *memory.UserRepository) {
+	t.Helper()
+
+	cfg := config.Default(config.ProfileTest)
+	cfg.OIDC.IssuerURL = issuer.server.URL
+	cfg.OIDC.ClientID = testClientID
+	cfg.OIDC.ClientSecret = testClientSecret
+	cfg.OIDC.RedirectURL = testRedirectURL
+	cfg.OIDC.AdminGroups = []string{"library-admins"}
+	if configure != nil {
+		configure(&cfg.OIDC)
+	}
+
+	users := memory.NewUserRepository()
+	userService := NewUserService(cfg, memory.NewDB(), users, memory.NewSessionRepository(),
+		NewOutbox(memory.NewOutboxRepository()))
+	return NewOIDCService(userService, cfg.OIDC), users
+}
+
+func TestOIDCLoginProvisionsUser(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	s, _ := newOIDCTestService(t, issuer, nil)
+
+	claims := jwt.MapClaims{
+		"sub":                "subject-1",
+		"preferred_username": "erin",
+		"email":              "erin@example.org",
+		"email_verified":     true,
+	}
+	user, err := issuer.login(t, s, claims)
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if user.Username != "erin" || user.Email != "erin@example.org" || user.Role != "user" {
+		t.Errorf("provisioned user = %s <%s> (%s), want erin <erin@example.org> (user)", user.Username, user.Email, user.Role)
+	}
+	if user.AuthProvider != AuthProviderOIDC || user.ExternalID != "subject-1" {
+		t.Errorf("identity = %s/%s, want oidc/subject-1", user.AuthProvider, user.ExternalID)
+	}
+
+	// The subject, not the username, identifies the user from then on
+	claims["preferred_username"] = "erin.renamed"
+	again, err := issuer.login(t, s, claims)
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+	if again.ID != user.ID {
+		t.Errorf("second login resolved to %s, want %s", again.ID.Hex(), user.ID.Hex())
+	}
+}
+
+func TestOIDCLoginWithoutAutoProvision(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	s, _ := newOIDCTestService(t, issuer, func(cfg *config.OIDC) {
+		cfg.AutoProvision = false
+	})
+
+	_, err := issuer.login(t, s, jwt.MapClaims{"sub": "subject-1", "email": "erin@example.org"})
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("err = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestOIDCLoginRejectsForgedRoundTrips(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	s, _ := newOIDCTestService(t, issuer, nil)
+	ctx := context.Background()
+	claims := jwt.MapClaims{"sub": "subject-1", "email": "erin@example.org"}
+
+	t.Run("state mismatch", func(t *testing.T) {
+		authURL, signedState, err := s.BeginLogin(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, _ := issuer.authorize(t, authURL, claims)
+
+		_, err = s.CompleteLogin(ctx, code, "forged-state", signedState, models.ClientInfo{})
+		if !errors.Is(err, ErrValidation) {
+			t.Errorf("err = %v, want ErrValidation", err)
+		}
+	})
+
+	t.Run("unsigned state", func(t *testing.T) {
+		authURL, _, err := s.BeginLogin(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, state := issuer.authorize(t, authURL, claims)
+		forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, oidcLoginState{State: state}).SignedString([]byte("not-the-secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = s.CompleteLogin(ctx, code, state, forged, models.ClientInfo{})
+		if !errors.Is(err, ErrValidation) {
+			t.Errorf("err = %v, want ErrValidation", err)
+		}
+	})
+
+	t.Run("wrong PKCE verifier", func(t *testing.T) {
+		// A code issued to one login attempt redeemed with the state, and so
+		// the code verifier, of another
+		authURL, _, err := s.BeginLogin(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, _ := issuer.authorize(t, authURL, claims)
+
+		otherURL, otherSignedState, err := s.BeginLogin(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, _ := url.Parse(otherURL)
+
+		_, err = s.CompleteLogin(ctx, code, parsed.Query().Get("state"), otherSignedState, models.ClientInfo{})
+		if !errors.Is(err, ErrUpstream) {
+			t.Errorf("err = %v, want ErrUpstream", err)
+		}
+	})
+
+	t.Run("nonce mismatch", func(t *testing.T) {
+		_, err := issuer.login(t, s, jwt.MapClaims{"sub": "subject-1", "nonce": "replayed-nonce"})
+		if !errors.Is(err, ErrUnauthorized) {
+			t.Errorf("err = %v, want ErrUnauthorized", err)
+		}
+	})
+
+	t.Run("other audience", func(t *testing.T) {
+		_, err := issuer.login(t, s, jwt.MapClaims{"sub": "subject-1", "aud": "another-client"})
+		if !errors.Is(err, ErrUnauthorized) {
+			t.Errorf("err = %v, want ErrUnauthorized", err)
+		}
+	})
+}
+
+func TestOIDCLoginMapsGroupsToRoles(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	s, _ := newOIDCTestService(t, issuer, nil)
+
+	steps := []struct {
+		groups interface{}
+		role   string
+	}{
+		{[]string{"staff", "library-admins"}, "admin"},
+		{[]string{"staff"}, "user"},
+		{"library-admins", "admin"}, // some providers send a single group as a string
+		{nil, "user"},
+	}
+	for _, step := range steps {
+		claims := jwt.MapClaims{"sub": "subject-1", "preferred_username": "grace"}
+		if step.groups != nil {
+			claims["groups"] = step.groups
+		}
+		user, err := issuer.login(t, s, claims)
+		if err != nil {
+			t.Fatalf("groups %v: %v", step.groups, err)
+		}
+		if user.Role != step.role {
+			t.Errorf("groups %v: role = %s, want %s", step.groups, user.Role, step.role)
+		}
+	}
+}
+
+func TestOIDCLoginLinksByVerifiedEmail(t *testing.T) {
+	tests := []struct {
+		name          string
+		linkByEmail   bool
+		emailVerified bool
+		password      string
+		provider      string
+		wantErr       error
+	}{
+		{"linked", true, true, "", "", nil},
+		{"relinked after a subject change", true, true, "", AuthProviderOIDC, nil},
+		{"linking off", false, true, "", "", ErrConflict},
+		{"email not verified", true, false, "", "", ErrConflict},
+		{"account has a password", true, true, "$2a$04$hash", "", ErrConflict},
+		{"account of another provider", true, true, "", AuthProviderLDAP, ErrConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer := newFakeIssuer(t)
+			s, users := newOIDCTestService(t, issuer, func(cfg *config.OIDC) {
+				cfg.LinkByEmail = tt.linkByEmail
+			})
+
+			existing := &models.User{
+				Username:     "heidi",
+				Email:        "heidi@example.org",
+				Password:     tt.password,
+				Role:         "user",
+				AuthProvider: tt.provider,
+			}
+			if tt.provider != "" {
+				existing.ExternalID = "old-subject"
+			}
+			if err := users.CreateUser(context.Background(), existing); err != nil {
+				t.Fatal(err)
+			}
+
+			user, err := issuer.login(t, s, jwt.MapClaims{
+				"sub":            "new-subject",
+				"email":          "heidi@example.org",
+				"email_verified": tt.emailVerified,
+			})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("login: %v", err)
+			}
+			if user.ID != existing.ID || user.AuthProvider != AuthProviderOIDC || user.ExternalID != "new-subject" {
+				t.Errorf("logged in as %s (%s/%s), want %s linked to oidc/new-subject",
+					user.ID.Hex(), user.AuthProvider, user.ExternalID, existing.ID.Hex())
+			}
+		})
+	}
+}
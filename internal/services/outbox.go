@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/config"
+	"github.com/4Noyis/my-library/internal/events"
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/4Noyis/my-library/internal/tracing"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// OutboxStore is the persistence the relay needs, implemented by
+// repositories.OutboxRepository
+type OutboxStore interface {
+	Insert(ctx context.Context, record *models.OutboxRecord) error
+	ClaimDue(ctx context.Context, lease time.Duration) (*models.OutboxRecord, error)
+	MarkDelivered(ctx context.Context, id primitive.ObjectID) error
+	MarkFailed(ctx context.Context, id primitive.ObjectID, status, lastError string, nextAttemptAt time.Time) error
+	PurgeDelivered(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Outbox is where services record their domain events, to be delivered by
+// the OutboxRelay created from it
+type Outbox struct {
+	store OutboxStore
+	// Signals the relay that new records were committed, so events don't
+	// wait for the next poll
+	wake chan struct{}
+}
+
+func NewOutbox(store OutboxStore) *Outbox {
+	return &Outbox{
+		store: store,
+		wake:  make(chan struct{}, 1),
+	}
+}
+
+// Enqueue writes event to the outbox. Called with the context of
+// database.DB.WithTransaction it commits or rolls back with the change itself.
+func (o *Outbox) Enqueue(ctx context.Context, event events.Event) (err error) {
+	ctx, span := tracing.Start(ctx, "Outbox.Enqueue", attribute.String("event.name", event.EventName()), attribute.String("event.id", event.Metadata().ID))
+	defer func() { tracing.End(span, err) }()
+
+	payload, err := events.Encode(event)
+	if err != nil {
+		return err
+	}
+
+	return o.store.Insert(ctx, &models.OutboxRecord{
+		EventID:   event.Metadata().ID,
+		EventName: event.EventName(),
+		Payload:   payload,
+	})
+}
+
+// Notify is called after a transaction with outbox records commits
+func (o *Outbox) Notify() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// OutboxRelay delivers outbox records to the event bus. A record is only
+// marked delivered after every synchronous subscriber succeeded; failures are
+// retried with backoff, so those subscribers get each event at least once and
+// must be idempotent on the event ID. Asynchronous subscribers only have the
+// event queued and get it at most once.
+type OutboxRelay struct {
+	store  OutboxStore
+	bus    *events.Bus
+	config config.Outbox
+	wake   <-chan struct{}
+}
+
+func NewOutboxRelay(outbox *Outbox, bus *events.Bus, cfg config.Outbox) *OutboxRelay {
+	return &OutboxRelay{
+		store:  outbox.store,
+		bus:    bus,
+		config: cfg,
+		wake:   outbox.wake,
+	}
+}
+
+// Run relays due records until ctx is cancelled
+func (r *OutboxRelay) Run(ctx context.Context) {
+	logger.LogInfo(ctx, "Outbox relay started", logrus.Fields{
+		"poll_interval": r.config.PollInterval.String(),
+		"max_attempts":  r.config.MaxAttempts,
+	})
+
+	ticker := time.NewTicker(r.config.PollInterval)
+	defer ticker.Stop()
+
+	lastPurge := time.Time{}
+	for {
+		r.relayDue(ctx)
+
+		if time.Since(lastPurge) > time.Hour {
+			r.purge(ctx)
+			lastPurge = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.LogInfo(ctx, "Outbox relay stopped", nil)
+			return
+		case <-ticker.C:
+		case <-r.wake:
+		}
+	}
+}
+
+// relayDue delivers every record that is currently due
+func (r *OutboxRelay) relayDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		record, err := r.store.ClaimDue(ctx, r.config.Lease)
+		if err == mongo.ErrNoDocuments {
+			return
+		}
+		if err != nil {
+			logger.LogError(ctx, "RelayOutbox", err, logrus.Fields{
+				"operation": "claim_record",
+			})
+			return
+		}
+
+		r.relay(ctx, record)
+	}
+}
+
+func (r *OutboxRelay) relay(ctx context.Context, record *models.OutboxRecord) {
+	ctx, span := tracing.Start(ctx, "OutboxRelay.Relay",
+		attribute.String("event.name", record.EventName),
+		attribute.String("event.id", record.EventID),
+		attribute.Int("outbox.attempt", record.Attempts+1))
+	var err error
+	defer func() { tracing.End(span, err) }()
+
+	event, err := events.Decode(record.EventName, record.Payload)
+	if err != nil {
+		// Retrying won't make the payload decodable
+		r.fail(ctx, record, err, models.OutboxDead, time.Now())
+		return
+	}
+
+	// Subscribers log under the request that caused the event
+	ctx = logger.WithRequestID(ctx, event.Metadata().RequestID)
+	if err = r.bus.Deliver(ctx, event); err != nil {
+		attempts := record.Attempts + 1
+		if attempts >= r.config.MaxAttempts {
+			r.fail(ctx, record, err, models.OutboxDead, time.Now())
+			return
+		}
+		r.fail(ctx, record, err, models.OutboxPending, time.Now().Add(backoff(attempts, r.config.BaseBackoff, r.config.MaxBackoff)))
+		return
+	}
+
+	if err := r.store.MarkDelivered(ctx, record.ID); err != nil {
+		// The lease expires and the record is delivered again
+		logger.LogError(ctx, "RelayOutbox", err, logrus.Fields{
+			"operation": "mark_delivered",
+			"event_id":  record.EventID,
+		})
+	}
+}
+
+func (r *OutboxRelay) fail(ctx context.Context, record *models.OutboxRecord, cause error, status string, next time.Time) {
+	fields := logrus.Fields{
+		"event":    record.EventName,
+		"event_id": record.EventID,
+		"attempts": record.Attempts + 1,
+		"error":    cause.Error(),
+		"type":     "outbox",
+	}
+	if status == models.OutboxDead {
+		logger.Logger.WithContext(ctx).WithFields(fields).Error("Outbox event dead-lettered")
+	} else {
+		logger.Logger.WithContext(ctx).WithFields(fields).Warn("Outbox event delivery failed, will retry")
+	}
+
+	if err := r.store.MarkFailed(ctx, record.ID, status, cause.Error(), next); err != nil {
+		logger.LogError(ctx, "RelayOutbox", err, logrus.Fields{
+			"operation": "mark_failed",
+			"event_id":  record.EventID,
+		})
+	}
+}
+
+func (r *OutboxRelay) purge(ctx context.Context) {
+	removed, err := r.store.PurgeDelivered(ctx, time.Now().Add(-r.config.Retention))
+	if err != nil {
+		logger.LogError(ctx, "RelayOutbox", err, logrus.Fields{
+			"operation": "purge",
+		})
+		return
+	}
+	if removed > 0 {
+		logger.LogDebug(ctx, "Purged delivered outbox records", logrus.Fields{
+			"count": removed,
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/config"
+	"github.com/4Noyis/my-library/internal/events"
+	"github.com/4Noyis/my-library/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+// memoryOutbox is an in-memory OutboxStore with injectable faults
+type memoryOutbox struct {
+	mu      sync.Mutex
+	records []*models.OutboxRecord
+
+	failMarkDelivered int // number of MarkDelivered calls that fail
+}
+
+func (m *memoryOutbox) Insert(ctx context.Context, record *models.OutboxRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record.ID = primitive.NewObjectID()
+	record.Status = models.OutboxPending
+	record.CreatedAt = time.Now()
+	record.NextAttemptAt = record.CreatedAt
+	m.records = append(m.records, record)
+	return nil
+}
+
+func (m *memoryOutbox) ClaimDue(ctx context.Context, lease time.Duration) (*models.OutboxRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for _, record := range m.records {
+		if record.Status == models.OutboxPending && !record.NextAttemptAt.After(now) && !record.LockedUntil.After(now) {
+			record.LockedUntil = now.Add(lease)
+			claimed := *record
+			return &claimed, nil
+		}
+	}
+	return nil, mongo.ErrNoDocuments
+}
+
+func (m *memoryOutbox) MarkDelivered(ctx context.Context, id primitive.ObjectID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.failMarkDelivered > 0 {
+		m.failMarkDelivered--
+		return errors.New("injected: connection reset")
+	}
+	record := m.find(id)
+	record.Status = models.OutboxDelivered
+	record.LockedUntil = time.Time{}
+	return nil
+}
+
+func (m *memoryOutbox) MarkFailed(ctx context.Context, id primitive.ObjectID, status, lastError string, nextAttemptAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record := m.find(id)
+	record.Status = status
+	record.LastError = lastError
+	record.NextAttemptAt = nextAttemptAt
+	record.LockedUntil = time.Time{}
+	record.Attempts++
+	return nil
+}
+
+func (m *memoryOutbox) PurgeDelivered(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (m *memoryOutbox) find(id primitive.ObjectID) *models.OutboxRecord {
+	for _, record := range m.records {
+		if record.ID == id {
+			return record
+		}
+	}
+	return nil
+}
+
+func (m *memoryOutbox) status(i int) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.records[i].Status
+}
+
+// idempotentSink counts calls and distinct event IDs, like a subscriber
+// that deduplicates on the event ID
+type idempotentSink struct {
+	calls int
+	seen  map[string]bool
+}
+
+func (s *idempotentSink) handle(ctx context.Context, e events.BookCreated) error {
+	s.calls++
+	if s.seen == nil {
+		s.seen = map[string]bool{}
+	}
+	s.seen[e.ID] = true
+	return nil
+}
+
+func newTestRelay(store OutboxStore, bus *events.Bus) *OutboxRelay {
+	return &OutboxRelay{
+		store: store,
+		bus:   bus,
+		config: config.Outbox{
+			MaxAttempts: 3,
+			MaxBackoff:  time.Hour,
+		},
+	}
+}
+
+func enqueueBook(t *testing.T, store OutboxStore, title string) events.BookCreated {
+	t.Helper()
+	event := events.BookCreated{
+		Meta:  events.NewMeta(context.Background()),
+		Book:  models.Book{ID: 7, Title: title},
+		Actor: models.Actor{UserID: primitive.NewObjectID(), Username: "librarian"},
+	}
+	if err := NewOutbox(store).Enqueue(context.Background(), event); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	return event
+}
+
+func TestOutboxRelayDeliversAndRoundTripsEvent(t *testing.T) {
+	store := &memoryOutbox{}
+	bus := events.NewBus()
+
+	var got events.BookCreated
+	events.On(bus, "test", func(ctx context.Context, e events.BookCreated) error {
+		got = e
+		return nil
+	})
+
+	sent := enqueueBook(t, store, "Dune")
+	newTestRelay(store, bus).relayDue(context.Background())
+
+	if store.status(0) != models.OutboxDelivered {
+		t.Fatalf("status = %q, want delivered", store.status(0))
+	}
+	if got.ID != sent.ID || got.Book.Title != "Dune" || got.Actor.UserID != sent.Actor.UserID {
+		t.Fatalf("delivered event = %+v, want %+v", got, sent)
+	}
+}
+
+func TestOutboxRelayRedeliversAfterCrashBeforeAck(t *testing.T) {
+	// The subscriber succeeds but marking the record delivered fails, as if
+	// the process died in between. With a zero lease the record is due again
+	// right away and must be delivered with the same event ID.
+	store := &memoryOutbox{failMarkDelivered: 1}
+	bus := events.NewBus()
+	sink := &idempotentSink{}
+	events.On(bus, "sink", sink.handle)
+
+	enqueueBook(t, store, "Dune")
+	relay := newTestRelay(store, bus)
+	relay.relayDue(context.Background())
+
+	if store.status(0) != models.OutboxDelivered {
+		t.Fatalf("status = %q, want delivered", store.status(0))
+	}
+	if sink.calls != 2 {
+		t.Fatalf("subscriber called %d times, want 2", sink.calls)
+	}
+	if len(sink.seen) != 1 {
+		t.Fatalf("saw %d distinct event IDs, want 1", len(sink.seen))
+	}
+}
+
+func TestOutboxRelayRetriesFailingSubscriber(t *testing.T) {
+	store := &memoryOutbox{}
+	bus := events.NewBus()
+
+	failures := 1
+	sink := &idempotentSink{}
+	events.On(bus, "flaky", func(ctx context.Context, e events.BookCreated) error {
+		if failures > 0 {
+			failures--
+			return errors.New("injected: audit store unavailable")
+		}
+		return nil
+	})
+	events.On(bus, "sink", sink.handle)
+
+	enqueueBook(t, store, "Dune")
+	newTestRelay(store, bus).relayDue(context.Background())
+
+	if store.status(0) != models.OutboxDelivered {
+		t.Fatalf("status = %q, want delivered", store.status(0))
+	}
+	if store.records[0].Attempts != 1 {
+		t.Fatalf("attempts = %d, want 1 failed attempt", store.records[0].Attempts)
+	}
+	if sink.calls != 2 || len(sink.seen) != 1 {
+		t.Fatalf("sink calls = %d, distinct = %d; want 2 calls of 1 event", sink.calls, len(sink.seen))
+	}
+}
+
+func TestOutboxRelayIsolatesPanics(t *testing.T) {
+	store := &memoryOutbox{}
+	bus := events.NewBus()
+	sink := &idempotentSink{}
+
+	events.On(bus, "broken", func(ctx context.Context, e events.BookCreated) error {
+		panic("injected panic")
+	})
+	events.On(bus, "sink", sink.handle)
+
+	enqueueBook(t, store, "Dune")
+	newTestRelay(store, bus).relayDue(context.Background())
+
+	if store.status(0) != models.OutboxDead {
+		t.Fatalf("status = %q, want dead after max attempts", store.status(0))
+	}
+	if store.records[0].Attempts != 3 {
+		t.Fatalf("attempts = %d, want 3", store.records[0].Attempts)
+	}
+	if sink.calls != 3 {
+		t.Fatalf("healthy subscriber called %d times, want 3", sink.calls)
+	}
+}
+
+func TestOutboxRelayDeadLettersUndecodableRecord(t *testing.T) {
+	store := &memoryOutbox{}
+	store.Insert(context.Background(), &models.OutboxRecord{
+		EventID:   "1",
+		EventName: "book.unknown",
+	})
+
+	newTestRelay(store, events.NewBus()).relayDue(context.Background())
+
+	if store.status(0) != models.OutboxDead {
+		t.Fatalf("status = %q, want dead", store.status(0))
+	}
+}
+
+func TestOutboxRelayKeepsOrderAcrossRecords(t *testing.T) {
+	store := &memoryOutbox{}
+	bus := events.NewBus()
+
+	var titles []string
+	events.On(bus, "test", func(ctx context.Context, e events.BookCreated) error {
+		titles = append(titles, e.Book.Title)
+		return nil
+	})
+
+	for _, title := range []string{"first", "second", "third"} {
+		enqueueBook(t, store, title)
+	}
+	newTestRelay(store, bus).relayDue(context.Background())
+
+	if len(titles) != 3 || titles[0] != "first" || titles[2] != "third" {
+		t.Fatalf("delivered %v, want in insertion order", titles)
+	}
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/4Noyis/my-library/internal/config"
+)
+
+// bcrypt ignores everything past the first 72 bytes of a password
+const maxPasswordBytes = 72
+
+// PasswordPolicy describes the rules a new password has to satisfy
+type PasswordPolicy struct {
+	MinLength      int
+	MinCharClasses int // out of lowercase, uppercase, digits and symbols
+	Breached       *BreachedPasswordChecker
+}
+
+// PasswordPolicyError lists every rule a rejected password violated
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "password does not meet policy: " + strings.Join(e.Violations, "; ")
+}
+
+// Unwrap makes policy violations match ErrValidation
+func (e *PasswordPolicyError) Unwrap() error {
+	return ErrValidation
+}
+
+// NewPasswordPolicy builds the policy from the password settings. If the
+// breached password list cannot be loaded the returned policy is still
+// usable, just without the breach check.
+func NewPasswordPolicy(cfg config.Password) (*PasswordPolicy, error) {
+	policy := &PasswordPolicy{
+		MinLength:      cfg.MinLength,
+		MinCharClasses: cfg.MinCharClasses,
+	}
+
+	if cfg.BreachCheck {
+		checker, err := LoadBreachedPasswords(cfg.BreachedPasswordsFile)
+		if err != nil {
+			return policy, err
+		}
+		policy.Breached = checker
+	}
+
+	return policy, nil
+}
+
+// Validate checks password against the policy. username and email are the
+// account's identifiers, which must not appear inside the password.
+func (p *PasswordPolicy) Validate(password, username, email string) error {
+	var violations []string
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters", p.MinLength))
+	}
+	if len(password) > maxPasswordBytes {
+		violations = append(violations, fmt.Sprintf("must be at most %d bytes", maxPasswordBytes))
+	}
+
+	if classes := charClasses(password); classes < p.MinCharClasses {
+		violations = append(violations, fmt.Sprintf(
+			"must contain at least %d of: lowercase letters, uppercase letters, digits, symbols", p.MinCharClasses))
+	}
+
+	lower := strings.ToLower(password)
+	if len(username) >= 3 && strings.Contains(lower, strings.ToLower(username)) {
+		violations = append(violations, "must not contain the username")
+	}
+	if email != "" {
+		local := strings.ToLower(strings.SplitN(email, "@", 2)[0])
+		if strings.Contains(lower, strings.ToLower(email)) || (len(local) >= 3 && strings.Contains(lower, local)) {
+			violations = append(violations, "must not contain the email address")
+		}
+	}
+
+	if p.Breached != nil && p.Breached.IsBreached(password) {
+		violations = append(violations, "appears in a list of common or breached passwords")
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+func charClasses(password string) int {
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	count := 0
+	for _, present := range []bool{lower, upper, digit, symbol} {
+		if present {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	policy := &PasswordPolicy{MinLength: 8, MinCharClasses: 3}
+
+	const (
+		tooShort   = "must be at least 8 characters"
+		tooLong    = "must be at most 72 bytes"
+		tooSimple  = "must contain at least 3 of: lowercase letters, uppercase letters, digits, symbols"
+		inUsername = "must not contain the username"
+		inEmail    = "must not contain the email address"
+	)
+
+	tests := []struct {
+		name     string
+		password string
+		username string
+		email    string
+		want     []string
+	}{
+		{"valid", "Tr0ub4dor&3", "sam", "sam@example.com", nil},
+		{"too short", "Ab1!", "sam", "", []string{tooShort}},
+		// Length counts characters; 7 of them are 13 bytes
+		{"short in characters, long in bytes", "äöüÄÖÜ1", "sam", "", []string{tooShort}},
+		{"long enough in characters", "äöüÄÖÜ12", "sam", "", nil},
+		{"one class", "correcthorse", "sam", "", []string{tooSimple}},
+		{"two classes", "correcthorse1", "sam", "", []string{tooSimple}},
+		{"symbols count as a class", "correct horse1", "sam", "", nil},
+		{"non-ASCII letters count", "Ünïcödé-wörds", "sam", "", nil},
+		{"at the bcrypt limit", strings.Repeat("aB1", 24), "sam", "", nil},
+		// bcrypt would silently ignore the rest
+		{"over the bcrypt limit", strings.Repeat("aB1", 24) + "x", "sam", "", []string{tooLong}},
+		{"over the limit in bytes only", strings.Repeat("Ü", 36) + "a1", "sam", "", []string{tooLong}},
+		{"contains the username", "Sam-Rivers-2024", "rivers", "", []string{inUsername}},
+		{"username too short to match", "xAb1-xxxx", "ab", "", nil},
+		{"contains the email", "x-Jo.Smith@Example.com-1", "other", "jo.smith@example.com", []string{inEmail}},
+		{"contains the email's local part", "Jo.Smith-99", "other", "jo.smith@example.com", []string{inEmail}},
+		{"local part too short to match", "Jo-Smith-99", "other", "jo@example.com", nil},
+		{"every rule at once", "sam", "sam", "sam@example.com", []string{tooShort, tooSimple, inUsername, inEmail}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate(tt.password, tt.username, tt.email)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("err = %v, want none", err)
+				}
+				return
+			}
+
+			var policyErr *PasswordPolicyError
+			if !errors.As(err, &policyErr) {
+				t.Fatalf("err = %v, want a policy error", err)
+			}
+			if !errors.Is(err, ErrValidation) {
+				t.Errorf("err = %v, want it to match %v", err, ErrValidation)
+			}
+			if !reflect.DeepEqual(policyErr.Violations, tt.want) {
+				t.Errorf("violations = %q, want %q", policyErr.Violations, tt.want)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyRejectsBreachedPasswords(t *testing.T) {
+	breached, err := LoadBreachedPasswords("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := &PasswordPolicy{MinLength: 8, Breached: breached}
+
+	var policyErr *PasswordPolicyError
+	err = policy.Validate("password", "sam", "")
+	if !errors.As(err, &policyErr) || !reflect.DeepEqual(policyErr.Violations,
+		[]string{"appears in a list of common or breached passwords"}) {
+		t.Errorf("err = %v, want the breach violation alone", err)
+	}
+
+	if err := policy.Validate("correct horse battery staple", "sam", ""); err != nil {
+		t.Errorf("err = %v, want none", err)
+	}
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/config"
+	"github.com/4Noyis/my-library/internal/models"
+)
+
+// RateLimitPolicy allows Requests per Window, refilled continuously, with
+// bursts of up to Requests
+type RateLimitPolicy struct {
+	Requests int
+	Window   time.Duration
+}
+
+// RateLimitResult is the state of a caller's bucket after a request
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int
+	Reset      time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until the next request is allowed, if denied
+}
+
+// RateLimitStore keeps the token buckets. MemoryRateLimitStore serves a
+// single instance; running several replicas behind a load balancer needs a
+// shared implementation (e.g. Redis) so they draw from the same buckets.
+type RateLimitStore interface {
+	// Take removes a token from the bucket at key, created full under policy
+	// if it doesn't exist yet
+	Take(ctx context.Context, key string, policy RateLimitPolicy) (RateLimitResult, error)
+}
+
+type RateLimitConfig struct {
+	Enabled       bool
+	Anonymous     RateLimitPolicy
+	Authenticated RateLimitPolicy
+	Staff         RateLimitPolicy
+	// Routes override the caller's policy for the given "METHOD /route/template"
+	// or full gRPC method name, and are counted separately from the rest of
+	// the caller's traffic
+	Routes map[string]RateLimitPolicy
+}
+
+// NewRateLimitConfig turns the per-minute limits of cfg into policies
+func NewRateLimitConfig(cfg config.RateLimit) RateLimitConfig {
+	perMinute := func(requests int) RateLimitPolicy {
+		return RateLimitPolicy{Requests: requests, Window: time.Minute}
+	}
+
+	auth := perMinute(cfg.Auth)
+	return RateLimitConfig{
+		Enabled:       cfg.Enabled,
+		Anonymous:     perMinute(cfg.Anonymous),
+		Authenticated: perMinute(cfg.Authenticated),
+		Staff:         perMinute(cfg.Staff),
+		// Credential endpoints are what brute-force attempts target
+		Routes: map[string]RateLimitPolicy{
+			"POST /api/v1/auth/login":     auth,
+			"POST /api/v1/auth/register":  auth,
+			"GET /api/v1/auth/oidc/login": auth,
+			"POST /api/v2/auth/login":     auth,
+			"POST /api/v2/auth/register":  auth,
+
+			"/library.v1.AuthService/Login":    auth,
+			"/library.v1.AuthService/Register": auth,
+		},
+	}
+}
+
+// RateLimiter picks the policy for a request and draws from its bucket
+type RateLimiter struct {
+	store  RateLimitStore
+	config RateLimitConfig
+}
+
+func NewRateLimiter(store RateLimitStore, config RateLimitConfig) *RateLimiter {
+	return &RateLimiter{store: store, config: config}
+}
+
+func (rl *RateLimiter) Enabled() bool {
+	return rl.config.Enabled
+}
+
+// Take counts a request to route (e.g. "GET /api/v1/books") against the
+// caller's bucket. Authenticated callers are limited per user, everyone else
+// per IP address; admins get the staff policy.
+func (rl *RateLimiter) Take(ctx context.Context, route string, user *models.User, ip string) (RateLimitPolicy, RateLimitResult, error) {
+	caller := "ip:" + ip
+	policy := rl.config.Anonymous
+	key := "anonymous:" + caller
+	if user != nil {
+		caller = "user:" + user.ID.Hex()
+		policy = rl.config.Authenticated
+		key = "authenticated:" + caller
+		if user.Role == "admin" {
+			policy = rl.config.Staff
+			key = "staff:" + caller
+		}
+	}
+
+	if routePolicy, ok := rl.config.Routes[route]; ok {
+		policy = routePolicy
+		key = "route:" + route + ":" + caller
+	}
+
+	result, err := rl.store.Take(ctx, key, policy)
+	return policy, result, err
+}
+
+// How often idle buckets are dropped from the memory store
+const rateLimitSweepInterval = time.Minute
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+	fullAt  time.Time // once passed the bucket is indistinguishable from a new one
+}
+
+// MemoryRateLimitStore keeps token buckets in process memory
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, policy RateLimitPolicy) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if now.Sub(s.lastSweep) >= rateLimitSweepInterval {
+		s.sweep(now)
+	}
+
+	capacity := float64(policy.Requests)
+	rate := capacity / policy.Window.Seconds() // tokens per second
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: capacity, updated: now}
+		s.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(capacity, bucket.tokens+now.Sub(bucket.updated).Seconds()*rate)
+	bucket.updated = now
+
+	var result RateLimitResult
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsDuration((1 - bucket.tokens) / rate)
+	}
+
+	result.Remaining = int(bucket.tokens)
+	result.Reset = secondsDuration((capacity - bucket.tokens) / rate)
+	bucket.fullAt = now.Add(result.Reset)
+	return result, nil
+}
+
+// sweep drops buckets that have refilled completely
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	for key, bucket := range s.buckets {
+		if !bucket.fullAt.After(now) {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// clock is a manually advanced time source for MemoryRateLimitStore
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time { return c.now }
+
+func (c *clock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newTestRateLimitStore() (*MemoryRateLimitStore, *clock) {
+	c := &clock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	store := NewMemoryRateLimitStore()
+	store.now = c.Now
+	return store, c
+}
+
+func TestTokenBucketRefillsContinuously(t *testing.T) {
+	ctx := context.Background()
+	store, clock := newTestRateLimitStore()
+	// One token every 10 seconds, bursts of 6
+	policy := RateLimitPolicy{Requests: 6, Window: time.Minute}
+
+	take := func() RateLimitResult {
+		t.Helper()
+		result, err := store.Take(ctx, "caller", policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	for i := 1; i <= 6; i++ {
+		result := take()
+		want := RateLimitResult{Allowed: true, Remaining: 6 - i, Reset: time.Duration(i) * 10 * time.Second}
+		if !closeResults(result, want) {
+			t.Fatalf("request %d = %+v, want %+v", i, result, want)
+		}
+	}
+
+	steps := []struct {
+		name    string
+		advance time.Duration
+		want    RateLimitResult
+	}{
+		{"burst used up", 0,
+			RateLimitResult{Allowed: false, Remaining: 0, Reset: time.Minute, RetryAfter: 10 * time.Second}},
+		{"part of a token back", 4 * time.Second,
+			RateLimitResult{Allowed: false, Remaining: 0, Reset: 56 * time.Second, RetryAfter: 6 * time.Second}},
+		// Denied requests don't cost a token, so the wait isn't extended
+		{"one token back", 6 * time.Second,
+			RateLimitResult{Allowed: true, Remaining: 0, Reset: time.Minute}},
+		{"two and a half tokens back", 25 * time.Second,
+			RateLimitResult{Allowed: true, Remaining: 1, Reset: 45 * time.Second}},
+		{"never more than the burst", time.Hour,
+			RateLimitResult{Allowed: true, Remaining: 5, Reset: 10 * time.Second}},
+	}
+	for _, step := range steps {
+		clock.Advance(step.advance)
+		if got := take(); !closeResults(got, step.want) {
+			t.Errorf("%s: got %+v, want %+v", step.name, got, step.want)
+		}
+	}
+}
+
+// closeResults compares results allowing for float rounding in the durations
+func closeResults(got, want RateLimitResult) bool {
+	close := func(a, b time.Duration) bool {
+		return (a - b).Abs() < time.Millisecond
+	}
+	return got.Allowed == want.Allowed && got.Remaining == want.Remaining &&
+		close(got.Reset, want.Reset) && close(got.RetryAfter, want.RetryAfter)
+}
+
+func TestTokenBucketsAreSweptOnceFull(t *testing.T) {
+	ctx := context.Background()
+	store, clock := newTestRateLimitStore()
+	policy := RateLimitPolicy{Requests: 2, Window: time.Minute}
+
+	store.Take(ctx, "idle", policy)
+	store.Take(ctx, "busy", policy)
+	store.Take(ctx, "busy", policy)
+	clock.Advance(30 * time.Second)
+	store.Take(ctx, "busy", policy)
+
+	// Buckets are swept once a minute: by then idle has been full for 30s,
+	// while busy is still missing a token
+	clock.Advance(31 * time.Second)
+	store.Take(ctx, "other", policy)
+	if _, ok := store.buckets["idle"]; ok {
+		t.Error("full bucket was kept")
+	}
+	if _, ok := store.buckets["busy"]; !ok {
+		t.Fatal("bucket still refilling was dropped")
+	}
+
+	// A dropped bucket comes back full, exactly as it would have been
+	result, _ := store.Take(ctx, "idle", policy)
+	if !result.Allowed || result.Remaining != 1 {
+		t.Errorf("recreated bucket = %+v, want full", result)
+	}
+	result, _ = store.Take(ctx, "busy", policy)
+	if !result.Allowed || result.Remaining != 0 {
+		t.Errorf("busy bucket = %+v, want its last token taken", result)
+	}
+}
+
+func TestRateLimiterPicksBucketPerCaller(t *testing.T) {
+	ctx := context.Background()
+	store, _ := newTestRateLimitStore()
+	limiter := NewRateLimiter(store, RateLimitConfig{
+		Enabled:       true,
+		Anonymous:     RateLimitPolicy{Requests: 1, Window: time.Minute},
+		Authenticated: RateLimitPolicy{Requests: 2, Window: time.Minute},
+		Staff:         RateLimitPolicy{Requests: 3, Window: time.Minute},
+		Routes: map[string]RateLimitPolicy{
+			"POST /api/v1/auth/login": {Requests: 4, Window: time.Minute},
+		},
+	})
+
+	user := &models.User{ID: primitive.NewObjectID(), Role: "user"}
+	admin := &models.User{ID: primitive.NewObjectID(), Role: "admin"}
+
+	tests := []struct {
+		name      string
+		route     string
+		user      *models.User
+		ip        string
+		wantLimit int
+		wantLeft  int
+	}{
+		{"anonymous", "GET /api/v1/books", nil, "192.0.2.1", 1, 0},
+		{"anonymous from another address", "GET /api/v1/books", nil, "192.0.2.2", 1, 0},
+		{"user", "GET /api/v1/books", user, "192.0.2.1", 2, 1},
+		{"same user elsewhere", "GET /api/v1/books", user, "192.0.2.9", 2, 0},
+		{"admin", "GET /api/v1/books", admin, "192.0.2.1", 3, 2},
+		{"route override", "POST /api/v1/auth/login", nil, "192.0.2.1", 4, 3},
+		{"route override, counted separately", "POST /api/v1/auth/login", user, "192.0.2.1", 4, 3},
+	}
+	for _, tt := range tests {
+		policy, result, err := limiter.Take(ctx, tt.route, tt.user, tt.ip)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if policy.Requests != tt.wantLimit || !result.Allowed || result.Remaining != tt.wantLeft {
+			t.Errorf("%s: limit %d, %+v, want limit %d with %d left", tt.name, policy.Requests, result, tt.wantLimit, tt.wantLeft)
+		}
+	}
+}
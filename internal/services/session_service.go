@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/4Noyis/my-library/internal/tracing"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Tokens expire 24 hours after login
+const tokenLifetime = 24 * time.Hour
+
+// Last-seen timestamps are only written once per interval so that not every
+// authenticated request costs a database write
+const sessionTouchInterval = time.Minute
+
+// issueToken records a new session for the login and returns a JWT bound to it
+func (us *UserService) issueToken(ctx context.Context, user *models.User, client models.ClientInfo) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "SessionService.IssueToken", attribute.String("user.id", user.ID.Hex()))
+	defer func() { tracing.End(span, err) }()
+
+	session := &models.Session{
+		UserID:    user.ID,
+		UserAgent: client.UserAgent,
+		IPAddress: client.IPAddress,
+		ExpiresAt: time.Now().Add(tokenLifetime),
+	}
+	if err := us.sessionRepo.CreateSession(ctx, session); err != nil {
+		logger.LogError(ctx, "issueToken", err, logrus.Fields{
+			"operation": "create_session",
+			"user_id":   user.ID.Hex(),
+		})
+		return "", errors.New("failed to create session")
+	}
+
+	token, err := us.generateJWT(user, session)
+	if err != nil {
+		return "", errors.New("failed to generate token")
+	}
+
+	return token, nil
+}
+
+// validateSession makes sure the session named in the token's sid claim
+// belongs to the user and has not been terminated
+func (us *UserService) validateSession(ctx context.Context, claims jwt.MapClaims, user *models.User) (_ *models.Session, err error) {
+	ctx, span := tracing.Start(ctx, "SessionService.ValidateSession", attribute.String("user.id", user.ID.Hex()))
+	defer func() { tracing.End(span, err) }()
+
+	sid, ok := claims["sid"].(string)
+	if !ok {
+		return nil, unauthorized("invalid session in token")
+	}
+	sessionID, err := primitive.ObjectIDFromHex(sid)
+	if err != nil {
+		return nil, unauthorized("invalid session in token")
+	}
+
+	session, err := us.sessionRepo.GetSession(ctx, sessionID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, unauthorized("session has been terminated")
+		}
+		return nil, errors.New("database error during token validation")
+	}
+
+	if session.UserID != user.ID || session.RevokedAt != nil {
+		return nil, unauthorized("session has been terminated")
+	}
+
+	if now := time.Now(); now.Sub(session.LastSeenAt) > sessionTouchInterval {
+		if err := us.sessionRepo.TouchSession(ctx, session.ID, now); err != nil {
+			logger.LogError(ctx, "validateSession", err, logrus.Fields{
+				"operation":  "touch_session",
+				"session_id": session.ID.Hex(),
+			})
+		}
+		session.LastSeenAt = now
+	}
+
+	return session, nil
+}
+
+// ListSessions returns the user's active sessions, flagging the one with
+// currentSessionID as current
+func (us *UserService) ListSessions(ctx context.Context, userID, currentSessionID primitive.ObjectID) (_ []models.Session, err error) {
+	ctx, span := tracing.Start(ctx, "SessionService.ListSessions", attribute.String("user.id", userID.Hex()))
+	defer func() { tracing.End(span, err) }()
+
+	sessions, err := us.sessionRepo.ListActiveSessions(ctx, userID)
+	if err != nil {
+		return nil, errors.New("database error while listing sessions")
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession terminates one of the user's sessions
+func (us *UserService) RevokeSession(ctx context.Context, userID, sessionID primitive.ObjectID) (err error) {
+	ctx, span := tracing.Start(ctx, "SessionService.RevokeSession", attribute.String("user.id", userID.Hex()), attribute.String("session.id", sessionID.Hex()))
+	defer func() { tracing.End(span, err) }()
+
+	err = us.sessionRepo.RevokeSession(ctx, sessionID, userID)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return notFound("session not found")
+		}
+		return errors.New("failed to revoke session")
+	}
+
+	return nil
+}
+
+// revokeAllSessions terminates every session of the user. Failures are
+// logged rather than returned since the triggering change already happened.
+func (us *UserService) revokeAllSessions(ctx context.Context, userID primitive.ObjectID) {
+	if err := us.sessionRepo.RevokeUserSessions(ctx, userID); err != nil {
+		logger.LogError(ctx, "revokeAllSessions", err, logrus.Fields{
+			"user_id": userID.Hex(),
+		})
+	}
+}
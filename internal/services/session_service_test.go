@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/4Noyis/my-library/internal/config"
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/4Noyis/my-library/internal/repositories/memory"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newSessionTestService(t *testing.T) *UserService {
+	t.Helper()
+	return NewUserService(config.Default(config.ProfileTest), memory.NewDB(), memory.NewUserRepository(),
+		memory.NewSessionRepository(), NewOutbox(&memoryOutbox{}))
+}
+
+// signIn registers username and logs in from each of clients, returning one
+// token per client
+func signIn(t *testing.T, us *UserService, username string, clients ...string) (*models.User, []string) {
+	t.Helper()
+	ctx := context.Background()
+
+	user, err := us.RegisterUser(ctx, &models.RegisterRequest{
+		Username: username,
+		Email:    username + "@example.com",
+		Password: "correct-Horse-1",
+	})
+	if err != nil {
+		t.Fatalf("register %s: %v", username, err)
+	}
+
+	var tokens []string
+	for _, client := range clients {
+		resp, err := us.LoginUser(ctx, &models.LoginRequest{Username: username, Password: "correct-Horse-1"},
+			models.ClientInfo{UserAgent: client})
+		if err != nil {
+			t.Fatalf("login %s from %s: %v", username, client, err)
+		}
+		tokens = append(tokens, resp.Token)
+	}
+	return user, tokens
+}
+
+func sessionOf(t *testing.T, us *UserService, token string) *models.Session {
+	t.Helper()
+	_, session, err := us.ValidateJWT(context.Background(), token)
+	if err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	return session
+}
+
+func TestRevokeSessionEndsOnlyThatSession(t *testing.T) {
+	ctx := context.Background()
+	us := newSessionTestService(t)
+	user, tokens := signIn(t, us, "sam", "laptop", "phone")
+	laptop, phone := sessionOf(t, us, tokens[0]), sessionOf(t, us, tokens[1])
+
+	sessions, err := us.ListSessions(ctx, user.ID, laptop.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("got %d sessions, want 2", len(sessions))
+	}
+	for _, session := range sessions {
+		if session.Current != (session.ID == laptop.ID) {
+			t.Errorf("session %s (%s) current = %v", session.ID.Hex(), session.UserAgent, session.Current)
+		}
+	}
+
+	if err := us.RevokeSession(ctx, user.ID, phone.ID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+
+	if _, _, err := us.ValidateJWT(ctx, tokens[1]); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("revoked token: err = %v, want %v", err, ErrUnauthorized)
+	}
+	if got := sessionOf(t, us, tokens[0]); got.ID != laptop.ID {
+		t.Errorf("remaining token resolved to session %s, want %s", got.ID.Hex(), laptop.ID.Hex())
+	}
+
+	sessions, err = us.ListSessions(ctx, user.ID, laptop.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != laptop.ID || sessions[0].UserAgent != "laptop" {
+		t.Errorf("sessions after revoking the phone = %+v, want the laptop", sessions)
+	}
+}
+
+func TestRevokeSessionOnlyRevokesOwnActiveSessions(t *testing.T) {
+	ctx := context.Background()
+	us := newSessionTestService(t)
+	owner, ownerTokens := signIn(t, us, "olga", "laptop")
+	other, _ := signIn(t, us, "omar")
+	session := sessionOf(t, us, ownerTokens[0])
+
+	tests := []struct {
+		name    string
+		userID  primitive.ObjectID
+		session primitive.ObjectID
+	}{
+		{"another user's session", other.ID, session.ID},
+		{"unknown session", owner.ID, primitive.NewObjectID()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := us.RevokeSession(ctx, tt.userID, tt.session); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("err = %v, want %v", err, ErrNotFound)
+			}
+			sessionOf(t, us, ownerTokens[0])
+		})
+	}
+
+	if err := us.RevokeSession(ctx, owner.ID, session.ID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if err := us.RevokeSession(ctx, owner.ID, session.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("revoking twice: err = %v, want %v", err, ErrNotFound)
+	}
+}
+
+func TestAdminActionsRevokeEverySession(t *testing.T) {
+	ctx := context.Background()
+	admin := models.Actor{UserID: primitive.NewObjectID(), Username: "admin"}
+
+	tests := []struct {
+		name   string
+		action func(us *UserService, id primitive.ObjectID) error
+	}{
+		{"password reset", func(us *UserService, id primitive.ObjectID) error {
+			_, err := us.ForcePasswordReset(ctx, admin, id)
+			return err
+		}},
+		{"deletion", func(us *UserService, id primitive.ObjectID) error {
+			return us.DeleteUser(ctx, admin, id)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			us := newSessionTestService(t)
+			user, tokens := signIn(t, us, "pia", "laptop", "phone")
+
+			if err := tt.action(us, user.ID); err != nil {
+				t.Fatal(err)
+			}
+
+			for i, token := range tokens {
+				if _, _, err := us.ValidateJWT(ctx, token); !errors.Is(err, ErrUnauthorized) {
+					t.Errorf("token %d: err = %v, want %v", i+1, err, ErrUnauthorized)
+				}
+			}
+			sessions, err := us.ListSessions(ctx, user.ID, primitive.NilObjectID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(sessions) != 0 {
+				t.Errorf("%d sessions still active", len(sessions))
+			}
+		})
+	}
+}
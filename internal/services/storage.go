@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+// The stores below are the persistence the services are built on. They are
+// implemented for MongoDB by the repositories package and in memory by
+// repositories/memory. Lookups that find nothing return mongo.ErrNoDocuments.
+
+// Database runs transactions and reports whether storage is reachable,
+// implemented by database.DB
+type Database interface {
+	WithTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+	Ping(ctx context.Context) error
+}
+
+type BookStore interface {
+	GetAllBooks(ctx context.Context) ([]models.Book, error)
+	ListBooks(ctx context.Context, query models.BookListQuery) ([]models.Book, int64, error)
+	GetOneBook(ctx context.Context, id int) (models.Book, error)
+	AddNewBook(ctx context.Context, book models.Book) (models.Book, error)
+	UpdateBook(ctx context.Context, id int, updates models.Book) (models.Book, error)
+	ReplaceBookFields(ctx context.Context, id int, book models.Book) (models.Book, error)
+	DeleteBook(ctx context.Context, id int) (models.Book, error)
+}
+
+type BookRevisionStore interface {
+	AddRevision(ctx context.Context, revision *models.BookRevision) error
+	GetRevisions(ctx context.Context, bookID int) ([]models.BookRevision, error)
+	// GetRevisionsOfBooks returns the revisions of several books at once,
+	// ordered by book ID and then oldest first
+	GetRevisionsOfBooks(ctx context.Context, bookIDs []int) ([]models.BookRevision, error)
+	GetRevision(ctx context.Context, bookID, rev int) (*models.BookRevision, error)
+	CountRevisions(ctx context.Context, bookID int) (int64, error)
+}
+
+type UserStore interface {
+	CreateUser(ctx context.Context, user *models.User) error
+	GetUserByUsername(ctx context.Context, username string) (*models.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	GetUserByID(ctx context.Context, id primitive.ObjectID) (*models.User, error)
+	// GetUsersByIDs returns the users found among ids, in no particular order
+	GetUsersByIDs(ctx context.Context, ids []primitive.ObjectID) ([]models.User, error)
+	GetUserByExternalID(ctx context.Context, provider, externalID string) (*models.User, error)
+	// UpdateUser sets the fields in updates, keyed by their bson names
+	UpdateUser(ctx context.Context, id primitive.ObjectID, updates bson.D) error
+	ListUsers(ctx context.Context, query models.UserListQuery) ([]models.User, int64, error)
+	DeleteUser(ctx context.Context, id primitive.ObjectID) error
+}
+
+type SessionStore interface {
+	CreateSession(ctx context.Context, session *models.Session) error
+	GetSession(ctx context.Context, id primitive.ObjectID) (*models.Session, error)
+	ListActiveSessions(ctx context.Context, userID primitive.ObjectID) ([]models.Session, error)
+	TouchSession(ctx context.Context, id primitive.ObjectID, lastSeen time.Time) error
+	RevokeSession(ctx context.Context, id, userID primitive.ObjectID) error
+	RevokeUserSessions(ctx context.Context, userID primitive.ObjectID) error
+}
+
+type AuditStore interface {
+	InsertEvent(ctx context.Context, event *models.AuditEvent) error
+	FindEvents(ctx context.Context, query models.AuditQuery) ([]models.AuditEvent, int64, error)
+}
+
+type WebhookStore interface {
+	CreateSubscription(ctx context.Context, sub *models.WebhookSubscription) error
+	GetSubscription(ctx context.Context, id primitive.ObjectID) (*models.WebhookSubscription, error)
+	ListSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error)
+	FindSubscribers(ctx context.Context, eventType string) ([]models.WebhookSubscription, error)
+	// UpdateSubscription sets the fields in updates, keyed by their bson names
+	UpdateSubscription(ctx context.Context, id primitive.ObjectID, updates bson.D) error
+	DeleteSubscription(ctx context.Context, id primitive.ObjectID) error
+	// InsertDelivery returns models.ErrDuplicateDelivery when the
+	// subscription already has a delivery for the event
+	InsertDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	ClaimDueDelivery(ctx context.Context, lease time.Duration) (*models.WebhookDelivery, error)
+	RecordAttempt(ctx context.Context, id primitive.ObjectID, attempt models.DeliveryAttempt, status string, nextAttemptAt time.Time) error
+	GetDelivery(ctx context.Context, subscriptionID, id primitive.ObjectID) (*models.WebhookDelivery, error)
+	RequeueDelivery(ctx context.Context, id primitive.ObjectID) error
+	ListDeliveries(ctx context.Context, subscriptionID primitive.ObjectID, status string, page, limit int) ([]models.WebhookDelivery, int64, error)
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/4Noyis/my-library/internal/events"
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/sirupsen/logrus"
+)
+
+// RegisterEventSubscribers wires the built-in side effects of domain events.
+// Events reach the bus through the outbox relay, so these run in the
+// background and may see an event more than once; both deduplicate on the
+// event ID. They are synchronous so that a failure makes the relay retry.
+func RegisterEventSubscribers(bus *events.Bus, audit *AuditService, webhooks *WebhookService) {
+	bus.Subscribe(events.AllEvents, "log", func(ctx context.Context, event events.Event) error {
+		logger.LogDebug(ctx, "Domain event published", logrus.Fields{
+			"event":    event.EventName(),
+			"event_id": event.Metadata().ID,
+			"type":     "domain_event",
+		})
+		return nil
+	})
+
+	events.On(bus, "audit", func(ctx context.Context, e events.BookCreated) error {
+		return audit.Record(ctx, e.ID, e.OccurredAt, e.Actor, AuditBookCreate, "book", strconv.Itoa(e.Book.ID), nil, &e.Book)
+	})
+	events.On(bus, "audit", func(ctx context.Context, e events.BookUpdated) error {
+		action := AuditBookUpdate
+		if e.RevertedFrom > 0 {
+			action = AuditBookRevert
+		}
+		return audit.Record(ctx, e.ID, e.OccurredAt, e.Actor, action, "book", strconv.Itoa(e.After.ID), &e.Before, &e.After)
+	})
+	events.On(bus, "audit", func(ctx context.Context, e events.BookDeleted) error {
+		return audit.Record(ctx, e.ID, e.OccurredAt, e.Actor, AuditBookDelete, "book", strconv.Itoa(e.Book.ID), &e.Book, nil)
+	})
+	events.On(bus, "audit", func(ctx context.Context, e events.UserUpdated) error {
+		return audit.Record(ctx, e.ID, e.OccurredAt, e.Actor, e.Action, "user", e.UserID.Hex(), e.Before, e.After)
+	})
+	events.On(bus, "audit", func(ctx context.Context, e events.UserDeleted) error {
+		return audit.Record(ctx, e.ID, e.OccurredAt, e.Actor, AuditUserDelete, "user", e.User.ID.Hex(), &e.User, nil)
+	})
+
+	events.On(bus, "webhooks", func(ctx context.Context, e events.BookCreated) error {
+		return webhooks.Publish(ctx, e.ID, EventBookCreated, e.Book)
+	})
+	events.On(bus, "webhooks", func(ctx context.Context, e events.BookUpdated) error {
+		return webhooks.Publish(ctx, e.ID, EventBookUpdated, e.After)
+	})
+	events.On(bus, "webhooks", func(ctx context.Context, e events.BookDeleted) error {
+		return webhooks.Publish(ctx, e.ID, EventBookDeleted, e.Book)
+	})
+}
@@ -1,79 +1,105 @@
 package services
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"errors"
-	"os"
-	"time"
 
+	"github.com/4Noyis/my-library/internal/config"
+	"github.com/4Noyis/my-library/internal/events"
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/metrics"
 	"github.com/4Noyis/my-library/internal/models"
-	"github.com/4Noyis/my-library/internal/repositories"
+	"github.com/4Noyis/my-library/internal/tracing"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type UserService struct {
-	userRepo  *repositories.UserRepository
-	jwtSecret []byte
+	db             Database
+	userRepo       UserStore
+	sessionRepo    SessionStore
+	jwtSecret      []byte
+	passwordPolicy *PasswordPolicy
+	bcryptCost     int
+	authenticators []Authenticator
+	outbox         *Outbox
 }
 
-func NewUserService() *UserService {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		secret = "your-super-secret-jwt-key-change-in-production" // Default for development
+func NewUserService(cfg *config.Config, db Database, users UserStore, sessions SessionStore, outbox *Outbox) *UserService {
+	policy, err := NewPasswordPolicy(cfg.Password)
+	if err != nil {
+		logger.LogError(context.Background(), "NewUserService", err, logrus.Fields{
+			"operation": "load_password_policy",
+		})
+		// Fall back to the bundled breached password list
+		policy.Breached, _ = LoadBreachedPasswords("")
 	}
 
-	return &UserService{
-		userRepo:  repositories.NewUserRepository(),
-		jwtSecret: []byte(secret),
+	us := &UserService{
+		db:             db,
+		userRepo:       users,
+		sessionRepo:    sessions,
+		jwtSecret:      []byte(cfg.Auth.JWTSecret),
+		passwordPolicy: policy,
+		bcryptCost:     cfg.Auth.BcryptCost,
+		outbox:         outbox,
 	}
+	us.authenticators = us.newAuthenticators(cfg.Auth, cfg.LDAP)
+
+	return us
 }
 
-func (us *UserService) RegisterUser(req *models.RegisterRequest) (*models.User, error) {
+func (us *UserService) RegisterUser(ctx context.Context, req *models.RegisterRequest) (_ *models.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.RegisterUser")
+	defer func() { tracing.End(span, err) }()
+
 	// Check if username already exists
-	existingUser, err := us.userRepo.GetUserByUsername(req.Username)
+	existingUser, err := us.userRepo.GetUserByUsername(ctx, req.Username)
 	if err != nil && err != mongo.ErrNoDocuments {
 		// Database error occurred
 		return nil, errors.New("database error while checking username")
 	}
 	if existingUser != nil {
-		return nil, errors.New("username already exists")
+		return nil, conflict("username already exists")
 	}
 
 	// Check if email already exists
-	existingUser, err = us.userRepo.GetUserByEmail(req.Email)
+	existingUser, err = us.userRepo.GetUserByEmail(ctx, req.Email)
 	if err != nil && err != mongo.ErrNoDocuments {
 		// Database error occurred
 		return nil, errors.New("database error while checking email")
 	}
 	if existingUser != nil {
-		return nil, errors.New("email already exists")
+		return nil, conflict("email already exists")
+	}
+
+	if err := us.passwordPolicy.Validate(req.Password, req.Username, req.Email); err != nil {
+		return nil, err
 	}
 
 	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), us.bcryptCost)
 	if err != nil {
 		return nil, errors.New("failed to hash password")
 	}
 
-	// Set default role if not provided
-	role := req.Role
-	if role == "" {
-		role = "user"
-	}
-
 	// Create user
 	user := &models.User{
 		Username: req.Username,
 		Email:    req.Email,
 		Password: string(hashedPassword),
-		Role:     role,
+		Role:     "user", // admins are made with UpdateRole, never at registration
 	}
 
-	err = us.userRepo.CreateUser(user)
-	if err != nil {
+	if err := us.createUser(ctx, user); err != nil {
 		return nil, errors.New("failed to create user")
 	}
 
@@ -82,56 +108,87 @@ func (us *UserService) RegisterUser(req *models.RegisterRequest) (*models.User,
 	return user, nil
 }
 
-func (us *UserService) LoginUser(req *models.LoginRequest) (*models.LoginResponse, error) {
-	// Get user by username
-	user, err := us.userRepo.GetUserByUsername(req.Username)
+func (us *UserService) LoginUser(ctx context.Context, req *models.LoginRequest, client models.ClientInfo) (_ *models.LoginResponse, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.LoginUser")
+	defer func() { tracing.End(span, err) }()
+
+	user, err := us.authenticate(ctx, req.Username, req.Password)
 	if err != nil {
-		if err == mongo.ErrNoDocuments {
-			return nil, errors.New("invalid credentials")
-		}
-		return nil, errors.New("database error during login")
+		metrics.RecordLogin("password", false)
+		return nil, err
 	}
 
 	// Check if user is active
 	if !user.IsActive {
-		return nil, errors.New("account is deactivated")
-	}
-
-	// Compare password
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password))
-	if err != nil {
-		return nil, errors.New("invalid credentials (password)")
+		metrics.RecordLogin("password", false)
+		return nil, unauthorized("account is deactivated")
 	}
 
 	// Generate JWT token
-	token, err := us.generateJWT(user)
+	token, err := us.issueToken(ctx, user, client)
 	if err != nil {
-		return nil, errors.New("failed to generate token")
+		metrics.RecordLogin("password", false)
+		return nil, err
 	}
+	metrics.RecordLogin("password", true)
 
 	// Don't return password
 	user.Password = ""
 
+	us.publishLogin(ctx, user, client)
 	return &models.LoginResponse{
 		Token: token,
 		User:  *user,
 	}, nil
 }
 
-func (us *UserService) generateJWT(user *models.User) (string, error) {
+// upgradePasswordHash rehashes a correctly entered password when it was stored
+// with a lower bcrypt cost than the one currently configured. Failures are
+// logged and never block the login.
+func (us *UserService) upgradePasswordHash(ctx context.Context, user *models.User, password string) {
+	cost, err := bcrypt.Cost([]byte(user.Password))
+	if err != nil || cost >= us.bcryptCost {
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), us.bcryptCost)
+	if err == nil {
+		err = us.userRepo.UpdateUser(ctx, user.ID, bson.D{{Key: "password", Value: string(hashedPassword)}})
+	}
+	if err != nil {
+		logger.LogError(ctx, "upgradePasswordHash", err, logrus.Fields{
+			"user_id": user.ID.Hex(),
+		})
+		return
+	}
+
+	logger.LogInfo(ctx, "Upgraded password hash cost", logrus.Fields{
+		"user_id":  user.ID.Hex(),
+		"old_cost": cost,
+		"new_cost": us.bcryptCost,
+	})
+}
+
+func (us *UserService) generateJWT(user *models.User, session *models.Session) (string, error) {
 	claims := jwt.MapClaims{
 		"user_id":  user.ID.Hex(),
+		"sid":      session.ID.Hex(),
 		"username": user.Username,
 		"email":    user.Email,
 		"role":     user.Role,
-		"exp":      time.Now().Add(time.Hour * 24).Unix(), // Token expires in 24 hours
+		"exp":      session.ExpiresAt.Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(us.jwtSecret)
 }
 
-func (us *UserService) ValidateJWT(tokenString string) (*models.User, error) {
+// ValidateJWT checks the token signature and expiry and returns the user and
+// the session the token was issued for
+func (us *UserService) ValidateJWT(ctx context.Context, tokenString string) (_ *models.User, _ *models.Session, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.ValidateJWT")
+	defer func() { tracing.End(span, err) }()
+
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, errors.New("unexpected signing method")
@@ -140,34 +197,381 @@ func (us *UserService) ValidateJWT(tokenString string) (*models.User, error) {
 	})
 
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
 		userIDStr, ok := claims["user_id"].(string)
 		if !ok {
-			return nil, errors.New("invalid user_id in token")
+			return nil, nil, unauthorized("invalid user_id in token")
 		}
 
 		userID, err := primitive.ObjectIDFromHex(userIDStr)
 		if err != nil {
-			return nil, errors.New("invalid user_id format")
+			return nil, nil, unauthorized("invalid user_id format")
 		}
 
-		user, err := us.userRepo.GetUserByID(userID)
+		user, err := us.userRepo.GetUserByID(ctx, userID)
 		if err != nil {
 			if err == mongo.ErrNoDocuments {
-				return nil, errors.New("user not found")
+				return nil, nil, unauthorized("user not found")
 			}
-			return nil, errors.New("database error during token validation")
+			return nil, nil, errors.New("database error during token validation")
 		}
 
 		if !user.IsActive {
-			return nil, errors.New("user account is deactivated")
+			return nil, nil, unauthorized("user account is deactivated")
 		}
 
-		return user, nil
+		session, err := us.validateSession(ctx, claims, user)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return user, session, nil
+	}
+
+	return nil, nil, unauthorized("invalid token")
+}
+
+func (us *UserService) ListUsers(ctx context.Context, query models.UserListQuery) (_ *models.UserListResponse, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.ListUsers")
+	defer func() { tracing.End(span, err) }()
+
+	if query.Page < 1 {
+		query.Page = 1
+	}
+	if query.Limit < 1 {
+		query.Limit = 20
+	}
+	if query.Limit > 100 {
+		query.Limit = 100
+	}
+	if query.Role != "" && query.Role != "admin" && query.Role != "user" {
+		return nil, invalid("invalid role")
+	}
+
+	users, total, err := us.userRepo.ListUsers(ctx, query)
+	if err != nil {
+		return nil, errors.New("database error while listing users")
+	}
+
+	return &models.UserListResponse{
+		Users: users,
+		Total: total,
+		Page:  query.Page,
+		Limit: query.Limit,
+	}, nil
+}
+
+func (us *UserService) GetUser(ctx context.Context, id primitive.ObjectID) (_ *models.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetUser", attribute.String("user.id", id.Hex()))
+	defer func() { tracing.End(span, err) }()
+
+	user, err := us.userRepo.GetUserByID(ctx, id)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, notFound("user not found")
+		}
+		return nil, errors.New("database error while fetching user")
+	}
+
+	user.Password = ""
+	return user, nil
+}
+
+// GetUsers returns the users found among ids, keyed by ID, with a single
+// repository call
+func (us *UserService) GetUsers(ctx context.Context, ids []primitive.ObjectID) (_ map[primitive.ObjectID]*models.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetUsers", attribute.Int("user.count", len(ids)))
+	defer func() { tracing.End(span, err) }()
+
+	users, err := us.userRepo.GetUsersByIDs(ctx, ids)
+	if err != nil {
+		return nil, errors.New("database error while fetching users")
+	}
+
+	found := make(map[primitive.ObjectID]*models.User, len(users))
+	for i := range users {
+		users[i].Password = ""
+		found[users[i].ID] = &users[i]
+	}
+	return found, nil
+}
+
+// UpdateProfile lets the acting user change their own username and email
+func (us *UserService) UpdateProfile(ctx context.Context, actor models.Actor, req *models.UpdateProfileRequest) (_ *models.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.UpdateProfile", attribute.String("user.id", actor.UserID.Hex()))
+	defer func() { tracing.End(span, err) }()
+
+	id := actor.UserID
+	before, err := us.GetUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := bson.D{}
+	after := *before
+
+	if req.Username != "" {
+		if len(req.Username) < 3 || len(req.Username) > 50 {
+			return nil, invalid("username must be between 3 and 50 characters")
+		}
+		existingUser, err := us.userRepo.GetUserByUsername(ctx, req.Username)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return nil, errors.New("database error while checking username")
+		}
+		if existingUser != nil && existingUser.ID != id {
+			return nil, conflict("username already exists")
+		}
+		updates = append(updates, bson.E{Key: "username", Value: req.Username})
+		after.Username = req.Username
+	}
+
+	if req.Email != "" {
+		existingUser, err := us.userRepo.GetUserByEmail(ctx, req.Email)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return nil, errors.New("database error while checking email")
+		}
+		if existingUser != nil && existingUser.ID != id {
+			return nil, conflict("email already exists")
+		}
+		updates = append(updates, bson.E{Key: "email", Value: req.Email})
+		after.Email = req.Email
+	}
+
+	if len(updates) == 0 {
+		return nil, invalid("no fields to update")
+	}
+
+	if err := us.updateUser(ctx, actor, id, AuditUserProfileUpdate, updates, before, &after); err != nil {
+		return nil, errors.New("failed to update user")
+	}
+
+	return us.GetUser(ctx, id)
+}
+
+// ChangePassword verifies the acting user's current password before storing
+// the new one and clears any pending admin-forced reset
+func (us *UserService) ChangePassword(ctx context.Context, actor models.Actor, req *models.ChangePasswordRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.ChangePassword", attribute.String("user.id", actor.UserID.Hex()))
+	defer func() { tracing.End(span, err) }()
+
+	id := actor.UserID
+	user, err := us.userRepo.GetUserByID(ctx, id)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return notFound("user not found")
+		}
+		return errors.New("database error while fetching user")
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword))
+	if err != nil {
+		return unauthorized("current password is incorrect")
+	}
+
+	if req.NewPassword == "" {
+		return invalid("new password is required")
+	}
+	if err := us.passwordPolicy.Validate(req.NewPassword, user.Username, user.Email); err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), us.bcryptCost)
+	if err != nil {
+		return errors.New("failed to hash password")
+	}
+
+	// Password hashes are never part of the event
+	err = us.updateUser(ctx, actor, id, AuditUserPasswordChange, bson.D{
+		{Key: "password", Value: string(hashedPassword)},
+		{Key: "must_change_password", Value: false},
+	}, nil, nil)
+	if err != nil {
+		return errors.New("failed to update password")
+	}
+
+	return nil
+}
+
+func (us *UserService) UpdateRole(ctx context.Context, actor models.Actor, id primitive.ObjectID, role string) (_ *models.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.UpdateRole", attribute.String("user.id", id.Hex()))
+	defer func() { tracing.End(span, err) }()
+
+	if role != "admin" && role != "user" {
+		return nil, invalid("invalid role")
+	}
+	if actor.UserID == id {
+		return nil, forbidden("cannot change your own role")
+	}
+
+	before, err := us.GetUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	after := *before
+	after.Role = role
+	if err := us.updateUser(ctx, actor, id, AuditUserRoleChange, bson.D{{Key: "role", Value: role}}, before, &after); err != nil {
+		return nil, errors.New("failed to update user")
+	}
+
+	return us.GetUser(ctx, id)
+}
+
+func (us *UserService) SetActive(ctx context.Context, actor models.Actor, id primitive.ObjectID, active bool) (_ *models.User, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.SetActive", attribute.String("user.id", id.Hex()))
+	defer func() { tracing.End(span, err) }()
+
+	if actor.UserID == id && !active {
+		return nil, forbidden("cannot deactivate your own account")
+	}
+
+	before, err := us.GetUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	after := *before
+	after.IsActive = active
+	if err := us.updateUser(ctx, actor, id, AuditUserStatusChange, bson.D{{Key: "is_active", Value: active}}, before, &after); err != nil {
+		return nil, errors.New("failed to update user")
+	}
+
+	return us.GetUser(ctx, id)
+}
+
+// ForcePasswordReset replaces the user's password with a random temporary one
+// and requires them to choose a new password before using the API again
+func (us *UserService) ForcePasswordReset(ctx context.Context, actor models.Actor, id primitive.ObjectID) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "UserService.ForcePasswordReset", attribute.String("user.id", id.Hex()))
+	defer func() { tracing.End(span, err) }()
+
+	before, err := us.GetUser(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", errors.New("failed to generate temporary password")
+	}
+	tempPassword := base64.RawURLEncoding.EncodeToString(buf)
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(tempPassword), us.bcryptCost)
+	if err != nil {
+		return "", errors.New("failed to hash password")
+	}
+
+	after := *before
+	after.MustChangePassword = true
+	err = us.updateUser(ctx, actor, id, AuditUserPasswordReset, bson.D{
+		{Key: "password", Value: string(hashedPassword)},
+		{Key: "must_change_password", Value: true},
+	}, before, &after)
+	if err != nil {
+		return "", errors.New("failed to update user")
+	}
+
+	us.revokeAllSessions(ctx, id)
+
+	return tempPassword, nil
+}
+
+func (us *UserService) DeleteUser(ctx context.Context, actor models.Actor, id primitive.ObjectID) (err error) {
+	ctx, span := tracing.Start(ctx, "UserService.DeleteUser", attribute.String("user.id", id.Hex()))
+	defer func() { tracing.End(span, err) }()
+
+	if actor.UserID == id {
+		return forbidden("cannot delete your own account")
+	}
+
+	before, err := us.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = us.db.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := us.userRepo.DeleteUser(ctx, id); err != nil {
+			return err
+		}
+		return us.outbox.Enqueue(ctx, events.UserDeleted{Meta: events.NewMeta(ctx), User: *before, Actor: actor})
+	})
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return notFound("user not found")
+		}
+		return errors.New("failed to delete user")
+	}
+	us.outbox.Notify()
+
+	us.revokeAllSessions(ctx, id)
+	return nil
+}
+
+// createUser inserts user and its UserRegistered event in one transaction
+func (us *UserService) createUser(ctx context.Context, user *models.User) error {
+	err := us.db.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := us.userRepo.CreateUser(ctx, user); err != nil {
+			return err
+		}
+		registered := *user
+		registered.Password = ""
+		return us.outbox.Enqueue(ctx, events.UserRegistered{Meta: events.NewMeta(ctx), User: registered})
+	})
+	if err != nil {
+		return err
+	}
+
+	us.outbox.Notify()
+	return nil
+}
+
+// updateUser applies updates and writes the matching UserUpdated event in one
+// transaction. action is one of the Audit* user actions.
+func (us *UserService) updateUser(ctx context.Context, actor models.Actor, id primitive.ObjectID, action string, updates bson.D, before, after *models.User) error {
+	err := us.db.WithTransaction(ctx, func(ctx context.Context) error {
+		if err := us.userRepo.UpdateUser(ctx, id, updates); err != nil {
+			return err
+		}
+		return us.outbox.Enqueue(ctx, events.UserUpdated{
+			Meta:   events.NewMeta(ctx),
+			UserID: id,
+			Action: action,
+			Before: before,
+			After:  after,
+			Actor:  actor,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	us.outbox.Notify()
+	return nil
+}
+
+// publishLogin records a login. Nothing else is written with it, so the
+// outbox record is inserted on its own.
+func (us *UserService) publishLogin(ctx context.Context, user *models.User, client models.ClientInfo) {
+	method := user.AuthProvider
+	if method == "" {
+		method = "local"
+	}
+
+	err := us.outbox.Enqueue(ctx, events.UserLoggedIn{
+		Meta:   events.NewMeta(ctx),
+		User:   *user,
+		Method: method,
+		Client: client,
+	})
+	if err != nil {
+		logger.LogError(ctx, "PublishLogin", err, logrus.Fields{
+			"user_id": user.ID.Hex(),
+		})
+		return
 	}
 
-	return nil, errors.New("invalid token")
+	us.outbox.Notify()
 }
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/4Noyis/my-library/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestLoginUpgradesPasswordHashCost(t *testing.T) {
+	ctx := context.Background()
+	us := newSessionTestService(t)
+	user, _ := signIn(t, us, "uma")
+
+	storedCost := func() int {
+		t.Helper()
+		stored, err := us.userRepo.GetUserByID(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cost, err := bcrypt.Cost([]byte(stored.Password))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cost
+	}
+	login := func(password string) error {
+		t.Helper()
+		_, err := us.LoginUser(ctx, &models.LoginRequest{Username: "uma", Password: password}, models.ClientInfo{})
+		return err
+	}
+
+	registered := storedCost()
+	us.bcryptCost = registered + 1
+
+	// Only a correct password can be rehashed
+	if err := login("wrong-Horse-1"); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("wrong password: err = %v, want %v", err, ErrUnauthorized)
+	}
+	if got := storedCost(); got != registered {
+		t.Fatalf("cost after a failed login = %d, want %d", got, registered)
+	}
+
+	if err := login("correct-Horse-1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := storedCost(); got != registered+1 {
+		t.Fatalf("cost after login = %d, want %d", got, registered+1)
+	}
+
+	// Lowering the configured cost never weakens existing hashes, and the
+	// rehashed password still works
+	us.bcryptCost = registered
+	if err := login("correct-Horse-1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := storedCost(); got != registered+1 {
+		t.Errorf("cost after lowering the setting = %d, want %d", got, registered+1)
+	}
+}
@@ -0,0 +1,485 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/config"
+	"github.com/4Noyis/my-library/internal/logger"
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/4Noyis/my-library/internal/tracing"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// Webhook event types
+const (
+	EventBookCreated = "book.created"
+	EventBookUpdated = "book.updated"
+	EventBookDeleted = "book.deleted"
+	EventPing        = "ping"
+)
+
+// Event types a subscription may ask for; "*" subscribes to everything
+var webhookEventTypes = map[string]bool{
+	EventBookCreated: true,
+	EventBookUpdated: true,
+	EventBookDeleted: true,
+	"*":              true,
+}
+
+type WebhookService struct {
+	webhookRepo WebhookStore
+	config      config.Webhooks
+	client      *http.Client
+}
+
+func NewWebhookService(store WebhookStore, cfg config.Webhooks) *WebhookService {
+	return &WebhookService{
+		webhookRepo: store,
+		config:      cfg,
+		client:      &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+func (ws *WebhookService) CreateSubscription(ctx context.Context, req *models.WebhookSubscriptionRequest, actor models.Actor) (_ *models.WebhookSubscription, err error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.CreateSubscription")
+	defer func() { tracing.End(span, err) }()
+
+	if err := validateSubscription(req.URL, req.EventTypes); err != nil {
+		return nil, err
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		generated, err := randomToken()
+		if err != nil {
+			return nil, errors.New("failed to generate webhook secret")
+		}
+		secret = generated
+	}
+
+	sub := &models.WebhookSubscription{
+		URL:        req.URL,
+		EventTypes: req.EventTypes,
+		Secret:     secret,
+		Active:     req.Active == nil || *req.Active,
+		CreatedBy:  actor.UserID,
+	}
+	if err := ws.webhookRepo.CreateSubscription(ctx, sub); err != nil {
+		return nil, errors.New("failed to create webhook subscription")
+	}
+
+	return sub, nil
+}
+
+func (ws *WebhookService) ListSubscriptions(ctx context.Context) (_ []models.WebhookSubscription, err error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.ListSubscriptions")
+	defer func() { tracing.End(span, err) }()
+
+	subs, err := ws.webhookRepo.ListSubscriptions(ctx)
+	if err != nil {
+		return nil, errors.New("database error while listing webhook subscriptions")
+	}
+
+	for i := range subs {
+		subs[i].Secret = ""
+	}
+	return subs, nil
+}
+
+func (ws *WebhookService) GetSubscription(ctx context.Context, id primitive.ObjectID) (_ *models.WebhookSubscription, err error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.GetSubscription", attribute.String("webhook.subscription_id", id.Hex()))
+	defer func() { tracing.End(span, err) }()
+
+	sub, err := ws.webhookRepo.GetSubscription(ctx, id)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, notFound("webhook subscription not found")
+		}
+		return nil, errors.New("database error while fetching webhook subscription")
+	}
+
+	sub.Secret = ""
+	return sub, nil
+}
+
+// UpdateSubscription changes the fields present in req
+func (ws *WebhookService) UpdateSubscription(ctx context.Context, id primitive.ObjectID, req *models.WebhookSubscriptionRequest) (_ *models.WebhookSubscription, err error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.UpdateSubscription", attribute.String("webhook.subscription_id", id.Hex()))
+	defer func() { tracing.End(span, err) }()
+
+	current, err := ws.GetSubscription(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := bson.D{}
+	if req.URL != "" {
+		updates = append(updates, bson.E{Key: "url", Value: req.URL})
+		current.URL = req.URL
+	}
+	if req.EventTypes != nil {
+		updates = append(updates, bson.E{Key: "event_types", Value: req.EventTypes})
+		current.EventTypes = req.EventTypes
+	}
+	if req.Secret != "" {
+		updates = append(updates, bson.E{Key: "secret", Value: req.Secret})
+	}
+	if req.Active != nil {
+		updates = append(updates, bson.E{Key: "active", Value: *req.Active})
+	}
+	if len(updates) == 0 {
+		return nil, invalid("no fields to update")
+	}
+	if err := validateSubscription(current.URL, current.EventTypes); err != nil {
+		return nil, err
+	}
+
+	if err := ws.webhookRepo.UpdateSubscription(ctx, id, updates); err != nil {
+		return nil, errors.New("failed to update webhook subscription")
+	}
+
+	return ws.GetSubscription(ctx, id)
+}
+
+func (ws *WebhookService) DeleteSubscription(ctx context.Context, id primitive.ObjectID) (err error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.DeleteSubscription", attribute.String("webhook.subscription_id", id.Hex()))
+	defer func() { tracing.End(span, err) }()
+
+	err = ws.webhookRepo.DeleteSubscription(ctx, id)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return notFound("webhook subscription not found")
+		}
+		return errors.New("failed to delete webhook subscription")
+	}
+	return nil
+}
+
+func (ws *WebhookService) ListDeliveries(ctx context.Context, id primitive.ObjectID, status string, page, limit int) (_ *models.WebhookDeliveryListResponse, err error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.ListDeliveries", attribute.String("webhook.subscription_id", id.Hex()))
+	defer func() { tracing.End(span, err) }()
+
+	if _, err := ws.GetSubscription(ctx, id); err != nil {
+		return nil, err
+	}
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	deliveries, total, err := ws.webhookRepo.ListDeliveries(ctx, id, status, page, limit)
+	if err != nil {
+		return nil, errors.New("database error while listing webhook deliveries")
+	}
+
+	return &models.WebhookDeliveryListResponse{
+		Deliveries: deliveries,
+		Total:      total,
+		Page:       page,
+		Limit:      limit,
+	}, nil
+}
+
+// RetryDelivery moves a dead-lettered delivery back into the queue
+func (ws *WebhookService) RetryDelivery(ctx context.Context, subscriptionID, deliveryID primitive.ObjectID) (err error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.RetryDelivery", attribute.String("webhook.delivery_id", deliveryID.Hex()))
+	defer func() { tracing.End(span, err) }()
+
+	_, err = ws.webhookRepo.GetDelivery(ctx, subscriptionID, deliveryID)
+	if err == nil {
+		err = ws.webhookRepo.RequeueDelivery(ctx, deliveryID)
+	}
+	if err == mongo.ErrNoDocuments {
+		return notFound("dead-lettered delivery not found")
+	}
+	if err != nil {
+		return errors.New("failed to requeue delivery")
+	}
+	return nil
+}
+
+// Ping sends a test event to the subscription right away and returns the
+// resulting delivery log entry
+func (ws *WebhookService) Ping(ctx context.Context, id primitive.ObjectID) (_ *models.WebhookDelivery, err error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.Ping", attribute.String("webhook.subscription_id", id.Hex()))
+	defer func() { tracing.End(span, err) }()
+
+	sub, err := ws.webhookRepo.GetSubscription(ctx, id)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, notFound("webhook subscription not found")
+		}
+		return nil, errors.New("database error while fetching webhook subscription")
+	}
+
+	delivery, err := ws.newDelivery(sub.ID, primitive.NewObjectID().Hex(), EventPing, map[string]string{"message": "ping"})
+	if err != nil {
+		return nil, err
+	}
+
+	// Pings are attempted once and never retried, so they are only stored,
+	// as finished, once the attempt was made
+	delivery.ID = primitive.NewObjectID()
+	attempt := ws.send(ctx, sub, delivery)
+	delivery.Status = models.DeliveryDead
+	if attempt.Error == "" {
+		delivery.Status = models.DeliverySucceeded
+	}
+	delivery.Attempts = []models.DeliveryAttempt{attempt}
+	delivery.AttemptCount = 1
+	delivery.NextAttemptAt = attempt.At
+	delivery.CompletedAt = &attempt.At
+	if err := ws.webhookRepo.InsertDelivery(ctx, delivery); err != nil {
+		return nil, errors.New("failed to record delivery")
+	}
+
+	return ws.webhookRepo.GetDelivery(ctx, sub.ID, delivery.ID)
+}
+
+// Publish queues an event for every active subscription interested in it.
+// eventID identifies the event to receivers; an event that is published again
+// with the same ID is not queued twice.
+func (ws *WebhookService) Publish(ctx context.Context, eventID, eventType string, data interface{}) (err error) {
+	ctx, span := tracing.Start(ctx, "WebhookService.Publish", attribute.String("event.id", eventID), attribute.String("webhook.event_type", eventType))
+	defer func() { tracing.End(span, err) }()
+
+	subs, err := ws.webhookRepo.FindSubscribers(ctx, eventType)
+	if err != nil {
+		logger.LogError(ctx, "PublishWebhookEvent", err, logrus.Fields{
+			"event_type": eventType,
+		})
+		return err
+	}
+
+	var errs []error
+	for _, sub := range subs {
+		delivery, err := ws.newDelivery(sub.ID, eventID, eventType, data)
+		if err == nil {
+			err = ws.webhookRepo.InsertDelivery(ctx, delivery)
+		}
+		if err == models.ErrDuplicateDelivery {
+			continue
+		}
+		if err != nil {
+			logger.LogError(ctx, "PublishWebhookEvent", err, logrus.Fields{
+				"event_type":      eventType,
+				"subscription_id": sub.ID.Hex(),
+			})
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// RunDispatcher delivers queued events until ctx is cancelled
+func (ws *WebhookService) RunDispatcher(ctx context.Context) {
+	logger.LogInfo(ctx, "Webhook dispatcher started", logrus.Fields{
+		"poll_interval": ws.config.PollInterval.String(),
+		"max_attempts":  ws.config.MaxAttempts,
+	})
+
+	ticker := time.NewTicker(ws.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		ws.dispatchDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			logger.LogInfo(ctx, "Webhook dispatcher stopped", nil)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatchDue sends every delivery that is currently due
+func (ws *WebhookService) dispatchDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		delivery, err := ws.webhookRepo.ClaimDueDelivery(ctx, 2*ws.config.Timeout)
+		if err == mongo.ErrNoDocuments {
+			return
+		}
+		if err != nil {
+			logger.LogError(ctx, "DispatchWebhooks", err, logrus.Fields{
+				"operation": "claim_delivery",
+			})
+			return
+		}
+
+		ws.attempt(ctx, delivery)
+	}
+}
+
+// attempt makes one delivery attempt and schedules a retry or dead-letters
+// the delivery on failure
+func (ws *WebhookService) attempt(ctx context.Context, delivery *models.WebhookDelivery) {
+	ctx, span := tracing.Start(ctx, "WebhookService.Deliver",
+		attribute.String("webhook.delivery_id", delivery.ID.Hex()),
+		attribute.String("webhook.subscription_id", delivery.SubscriptionID.Hex()),
+		attribute.String("webhook.event_type", delivery.EventType),
+		attribute.Int("webhook.attempt", delivery.AttemptCount+1))
+	var attempt models.DeliveryAttempt
+	defer func() { tracing.End(span, attemptError(attempt)) }()
+
+	sub, err := ws.webhookRepo.GetSubscription(ctx, delivery.SubscriptionID)
+	switch {
+	case err == mongo.ErrNoDocuments || (err == nil && !sub.Active):
+		attempt = models.DeliveryAttempt{At: time.Now(), Error: "subscription deleted or inactive"}
+		ws.record(ctx, delivery, attempt, models.DeliveryDead, attempt.At)
+		return
+	case err != nil:
+		// Leave the delivery locked; it is retried once the lease expires
+		logger.LogError(ctx, "DispatchWebhooks", err, logrus.Fields{
+			"operation":   "get_subscription",
+			"delivery_id": delivery.ID.Hex(),
+		})
+		return
+	}
+
+	attempt = ws.send(ctx, sub, delivery)
+	attempts := delivery.AttemptCount + 1
+
+	switch {
+	case attempt.Error == "":
+		ws.record(ctx, delivery, attempt, models.DeliverySucceeded, attempt.At)
+	case attempts >= ws.config.MaxAttempts:
+		logger.Logger.WithContext(ctx).WithFields(logrus.Fields{
+			"delivery_id":     delivery.ID.Hex(),
+			"subscription_id": sub.ID.Hex(),
+			"event_type":      delivery.EventType,
+			"attempts":        attempts,
+			"error":           attempt.Error,
+			"type":            "webhook",
+		}).Warn("Webhook delivery dead-lettered")
+		ws.record(ctx, delivery, attempt, models.DeliveryDead, attempt.At)
+	default:
+		ws.record(ctx, delivery, attempt, models.DeliveryPending, attempt.At.Add(backoff(attempts, ws.config.BaseBackoff, ws.config.MaxBackoff)))
+	}
+}
+
+func (ws *WebhookService) record(ctx context.Context, delivery *models.WebhookDelivery, attempt models.DeliveryAttempt, status string, next time.Time) {
+	if err := ws.webhookRepo.RecordAttempt(ctx, delivery.ID, attempt, status, next); err != nil {
+		logger.LogError(ctx, "DispatchWebhooks", err, logrus.Fields{
+			"operation":   "record_attempt",
+			"delivery_id": delivery.ID.Hex(),
+		})
+	}
+}
+
+// send posts the delivery payload, signed with the subscription secret
+func (ws *WebhookService) send(ctx context.Context, sub *models.WebhookSubscription, delivery *models.WebhookDelivery) (attempt models.DeliveryAttempt) {
+	ctx, span := tracing.Start(ctx, "WebhookService.Send", attribute.String("webhook.delivery_id", delivery.ID.Hex()))
+	defer func() {
+		if attempt.StatusCode != 0 {
+			span.SetAttributes(attribute.Int("http.response.status_code", attempt.StatusCode))
+		}
+		tracing.End(span, attemptError(attempt))
+	}()
+
+	start := time.Now()
+	attempt = models.DeliveryAttempt{At: start}
+
+	timestamp := strconv.FormatInt(start.Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewBufferString(delivery.Payload))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "my-library-webhooks/1.0")
+	req.Header.Set("X-Webhook-Id", delivery.ID.Hex())
+	req.Header.Set("X-Webhook-Event", delivery.EventType)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+SignWebhookPayload(sub.Secret, timestamp, []byte(delivery.Payload)))
+
+	resp, err := ws.client.Do(req)
+	attempt.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	attempt.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		attempt.Error = fmt.Sprintf("unexpected status %d", resp.StatusCode)
+	}
+
+	return attempt
+}
+
+// attemptError is the error of a failed attempt, for its span
+func attemptError(attempt models.DeliveryAttempt) error {
+	if attempt.Error == "" {
+		return nil
+	}
+	return errors.New(attempt.Error)
+}
+
+// SignWebhookPayload returns the hex HMAC-SHA256 of "timestamp.body".
+// Receivers recompute it to verify X-Webhook-Signature.
+func SignWebhookPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (ws *WebhookService) newDelivery(subscriptionID primitive.ObjectID, eventID, eventType string, data interface{}) (*models.WebhookDelivery, error) {
+	payload, err := json.Marshal(models.WebhookEvent{
+		ID:        eventID,
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return nil, errors.New("failed to encode webhook payload")
+	}
+
+	return &models.WebhookDelivery{
+		SubscriptionID: subscriptionID,
+		EventID:        eventID,
+		EventType:      eventType,
+		Payload:        string(payload),
+		Status:         models.DeliveryPending,
+		NextAttemptAt:  time.Now(),
+	}, nil
+}
+
+func validateSubscription(rawURL string, eventTypes []string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return invalid("invalid webhook url")
+	}
+	if len(eventTypes) == 0 {
+		return invalid("at least one event type is required")
+	}
+	for _, eventType := range eventTypes {
+		if !webhookEventTypes[eventType] {
+			return invalid("unknown event type: " + eventType)
+		}
+	}
+	return nil
+}
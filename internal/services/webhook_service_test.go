@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/4Noyis/my-library/internal/config"
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/4Noyis/my-library/internal/repositories/memory"
+)
+
+const testWebhookSecret = "s3cret"
+
+// webhookReceiver records the deliveries it gets and answers them with
+// status, or 401 when the signature doesn't verify
+type webhookReceiver struct {
+	server *httptest.Server
+
+	mu         sync.Mutex
+	status     int
+	deliveries []string // X-Webhook-Id of every request
+	badSigs    int
+}
+
+func newWebhookReceiver(t *testing.T, status int) *webhookReceiver {
+	t.Helper()
+
+	rcv := &webhookReceiver{status: status}
+	rcv.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		want := "sha256=" + SignWebhookPayload(testWebhookSecret, r.Header.Get("X-Webhook-Timestamp"), body)
+
+		rcv.mu.Lock()
+		defer rcv.mu.Unlock()
+		rcv.deliveries = append(rcv.deliveries, r.Header.Get("X-Webhook-Id"))
+		if r.Header.Get("X-Webhook-Signature") != want {
+			rcv.badSigs++
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(rcv.status)
+	}))
+	t.Cleanup(rcv.server.Close)
+
+	return rcv
+}
+
+func (rcv *webhookReceiver) setStatus(status int) {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	rcv.status = status
+}
+
+func (rcv *webhookReceiver) received() []string {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	return append([]string{}, rcv.deliveries...)
+}
+
+func newWebhookTestService(t *testing.T, rcv *webhookReceiver) (*WebhookService, *memory.WebhookRepository, *models.WebhookSubscription) {
+	t.Helper()
+
+	store := memory.NewWebhookRepository()
+	ws := NewWebhookService(store, config.Webhooks{
+		MaxAttempts: 3,
+		BaseBackoff: time.Millisecond,
+		MaxBackoff:  4 * time.Millisecond,
+		Timeout:     5 * time.Second,
+	})
+
+	sub, err := ws.CreateSubscription(context.Background(), &models.WebhookSubscriptionRequest{
+		URL:        rcv.server.URL + "/hook",
+		EventTypes: []string{EventBookCreated},
+		Secret:     testWebhookSecret,
+	}, models.Actor{Username: "admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ws, store, sub
+}
+
+// dispatchUntil runs the dispatcher until done reports true, waiting out the
+// retry backoff in between
+func dispatchUntil(t *testing.T, ws *WebhookService, done func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out dispatching webhooks")
+		}
+		ws.dispatchDue(context.Background())
+		time.Sleep(2 * time.Millisecond)
+	}
+}
+
+func onlyDelivery(t *testing.T, store *memory.WebhookRepository, sub *models.WebhookSubscription) models.WebhookDelivery {
+	t.Helper()
+
+	deliveries, total, err := store.ListDeliveries(context.Background(), sub.ID, "", 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 {
+		t.Fatalf("got %d deliveries, want 1", total)
+	}
+	return deliveries[0]
+}
+
+func TestWebhookDeliveryRetriesThenDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	rcv := newWebhookReceiver(t, http.StatusInternalServerError)
+	ws, store, sub := newWebhookTestService(t, rcv)
+
+	if err := ws.Publish(ctx, "event-1", EventBookCreated, models.Book{ID: 1, Title: "Dune"}); err != nil {
+		t.Fatal(err)
+	}
+	dispatchUntil(t, ws, func() bool { return onlyDelivery(t, store, sub).Status == models.DeliveryDead })
+
+	delivery := onlyDelivery(t, store, sub)
+	if delivery.AttemptCount != 3 || len(delivery.Attempts) != 3 {
+		t.Fatalf("dead-lettered after %d attempts (%d logged), want 3", delivery.AttemptCount, len(delivery.Attempts))
+	}
+	for i, attempt := range delivery.Attempts {
+		if attempt.StatusCode != http.StatusInternalServerError || attempt.Error != "unexpected status 500" {
+			t.Errorf("attempt %d = %d %q, want a 500", i+1, attempt.StatusCode, attempt.Error)
+		}
+		if i > 0 && !attempt.At.After(delivery.Attempts[i-1].At) {
+			t.Errorf("attempt %d at %s, not after the one before", i+1, attempt.At)
+		}
+	}
+	if delivery.CompletedAt == nil {
+		t.Error("dead delivery has no completed_at")
+	}
+
+	received := rcv.received()
+	if len(received) != 3 {
+		t.Fatalf("receiver got %d requests, want 3", len(received))
+	}
+	for _, id := range received {
+		if id != delivery.ID.Hex() {
+			t.Errorf("request for delivery %s, want %s on every retry", id, delivery.ID.Hex())
+		}
+	}
+	rcv.mu.Lock()
+	if rcv.badSigs != 0 {
+		t.Errorf("%d requests had an invalid signature", rcv.badSigs)
+	}
+	rcv.mu.Unlock()
+
+	// Dead deliveries stay dead until an admin retries them
+	ws.dispatchDue(ctx)
+	if n := len(rcv.received()); n != 3 {
+		t.Fatalf("receiver got %d requests after dead-lettering, want 3", n)
+	}
+
+	rcv.setStatus(http.StatusNoContent)
+	if err := ws.RetryDelivery(ctx, sub.ID, delivery.ID); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	dispatchUntil(t, ws, func() bool { return onlyDelivery(t, store, sub).Status == models.DeliverySucceeded })
+	if n := len(onlyDelivery(t, store, sub).Attempts); n != 4 {
+		t.Errorf("%d attempts logged after the retry, want 4", n)
+	}
+}
+
+func TestWebhookPublishQueuesEachEventOnce(t *testing.T) {
+	ctx := context.Background()
+	rcv := newWebhookReceiver(t, http.StatusNoContent)
+	ws, store, sub := newWebhookTestService(t, rcv)
+
+	// The outbox redelivers events after a crash; the second publish is a no-op
+	for i := 0; i < 2; i++ {
+		if err := ws.Publish(ctx, "event-1", EventBookCreated, models.Book{ID: 1, Title: "Dune"}); err != nil {
+			t.Fatalf("publish %d: %v", i+1, err)
+		}
+	}
+	onlyDelivery(t, store, sub)
+}
+
+func TestWebhookPingIsNeverRetried(t *testing.T) {
+	ctx := context.Background()
+	rcv := newWebhookReceiver(t, http.StatusInternalServerError)
+	ws, store, sub := newWebhookTestService(t, rcv)
+
+	delivery, err := ws.Ping(ctx, sub.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delivery.Status != models.DeliveryDead || delivery.AttemptCount != 1 || len(delivery.Attempts) != 1 {
+		t.Fatalf("ping delivery = %s after %d attempts, want dead after 1", delivery.Status, delivery.AttemptCount)
+	}
+	if received := rcv.received(); len(received) != 1 || received[0] != delivery.ID.Hex() {
+		t.Fatalf("receiver got %v, want the ping %s", received, delivery.ID.Hex())
+	}
+
+	ws.dispatchDue(ctx)
+	if n := len(rcv.received()); n != 1 {
+		t.Errorf("receiver got %d requests, want the ping alone", n)
+	}
+	if got := onlyDelivery(t, store, sub); got.Status != models.DeliveryDead {
+		t.Errorf("ping delivery became %s", got.Status)
+	}
+}
@@ -0,0 +1,156 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/4Noyis/my-library/internal/config"
+	"github.com/4Noyis/my-library/internal/models"
+	"github.com/4Noyis/my-library/internal/repositories/memory"
+	"github.com/4Noyis/my-library/internal/services"
+	"github.com/4Noyis/my-library/internal/tracing"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// keepSpans stops the in-memory exporter from discarding spans on shutdown
+type keepSpans struct {
+	*tracetest.InMemoryExporter
+}
+
+func (keepSpans) Shutdown(context.Context) error { return nil }
+
+func TestSpansJoinIncomingTraceparent(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	shutdown := tracing.Install(keepSpans{exporter}, "my-library")
+
+	r := mux.NewRouter()
+	r.Use(otelmux.Middleware("test"))
+	r.HandleFunc("/books/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, span := tracing.Start(r.Context(), "BookService.GetOneBook")
+		tracing.End(span, errors.New("book not found"))
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/books/42", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+
+	service, server := spans[0], spans[1]
+	if server.Name != "/books/{id}" {
+		t.Errorf("server span name = %q, want the route template", server.Name)
+	}
+	for _, span := range spans {
+		if got := span.SpanContext.TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("%s: trace id = %s, want the one from traceparent", span.Name, got)
+		}
+	}
+	if server.Parent.SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("server span parent = %s, want the caller's span", server.Parent.SpanID())
+	}
+	if service.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Errorf("service span is not a child of the server span")
+	}
+	if service.Status.Code != codes.Error {
+		t.Errorf("service span status = %v, want error", service.Status.Code)
+	}
+}
+
+func TestLoginSpanTree(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	shutdown := tracing.Install(keepSpans{exporter}, "my-library")
+
+	us := services.NewUserService(config.Default(config.ProfileTest), memory.NewDB(),
+		memory.NewUserRepository(), memory.NewSessionRepository(), services.NewOutbox(memory.NewOutboxRepository()))
+	_, err := us.RegisterUser(context.Background(), &models.RegisterRequest{
+		Username: "tess", Email: "tess@example.com", Password: "correct-Horse-1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Stands in for the server span of the login request
+	ctx, request := tracing.Start(context.Background(), "POST /api/v1/auth/login")
+	_, err = us.LoginUser(ctx, &models.LoginRequest{Username: "tess", Password: "correct-Horse-1"}, models.ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = us.LoginUser(ctx, &models.LoginRequest{Username: "tess", Password: "wrong"}, models.ClientInfo{})
+	if err == nil {
+		t.Fatal("login with the wrong password succeeded")
+	}
+	request.End()
+
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	var logins tracetest.SpanStubs
+	for _, span := range spans {
+		if span.Name == "UserService.LoginUser" {
+			logins = append(logins, span)
+		}
+	}
+	if len(logins) != 2 {
+		t.Fatalf("got %d login spans, want 2", len(logins))
+	}
+
+	// childrenOf returns the spans directly under parent, in the order they ended
+	childrenOf := func(parent tracetest.SpanStub) (names []string, children tracetest.SpanStubs) {
+		for _, span := range spans {
+			if span.Parent.SpanID() == parent.SpanContext.SpanID() {
+				names = append(names, span.Name)
+				children = append(children, span)
+			}
+		}
+		return names, children
+	}
+
+	want := [][]string{
+		{"UserService.Authenticate", "SessionService.IssueToken", "Outbox.Enqueue"},
+		{"UserService.Authenticate"},
+	}
+	for i, login := range logins {
+		if login.Parent.SpanID() != request.SpanContext().SpanID() {
+			t.Errorf("login %d is not a child of the request span", i+1)
+		}
+		if login.SpanContext.TraceID() != request.SpanContext().TraceID() {
+			t.Errorf("login %d is in trace %s, want the request's", i+1, login.SpanContext.TraceID())
+		}
+		if names, _ := childrenOf(login); strings.Join(names, ",") != strings.Join(want[i], ",") {
+			t.Fatalf("login %d children = %v, want %v", i+1, names, want[i])
+		}
+	}
+
+	if logins[0].Status.Code == codes.Error {
+		t.Errorf("successful login span has status error")
+	}
+	if logins[1].Status.Code != codes.Error {
+		t.Errorf("failed login span status = %v, want error", logins[1].Status.Code)
+	}
+
+	_, children := childrenOf(logins[0])
+	authenticated := false
+	for _, kv := range children[0].Attributes {
+		authenticated = authenticated || (kv.Key == "auth.authenticator" && kv.Value.AsString() == "local")
+	}
+	if !authenticated {
+		t.Errorf("authenticate span attributes = %v, want auth.authenticator=local", children[0].Attributes)
+	}
+}